@@ -43,7 +43,8 @@ export enum Origin {
     System = 0,
     Bot = 1,
     Web = 2,
-    InGame = 3
+    InGame = 3,
+    Imported = 4
 }
 
 export enum BanReason {
@@ -58,7 +59,8 @@ export enum BanReason {
     Language = 9,
     Profile = 10,
     ItemDescriptions = 11,
-    BotHost = 12
+    BotHost = 12,
+    Evading = 13
 }
 
 export const ip2int = (ip: string): number =>
@@ -110,7 +112,8 @@ export const BanReasons: Record<BanReason, string> = {
     [BanReason.Language]: 'Language',
     [BanReason.Profile]: 'Inappropriate Steam Profile',
     [BanReason.ItemDescriptions]: 'Item Name/Descriptions',
-    [BanReason.BotHost]: 'Bot Host'
+    [BanReason.BotHost]: 'Bot Host',
+    [BanReason.Evading]: 'Ban Evasion'
 };
 
 export const banReasonsList = [
@@ -125,7 +128,8 @@ export const banReasonsList = [
     BanReason.ItemDescriptions,
     BanReason.External,
     BanReason.Custom,
-    BanReason.BotHost
+    BanReason.BotHost,
+    BanReason.Evading
 ];
 
 export enum BanType {
@@ -149,6 +153,7 @@ export const banTypeString = (bt: BanType) => {
 export interface BannedPerson {
     ban: IAPIBanRecord;
     person: Person;
+    join_attempts?: number;
 }
 
 export interface BanBase extends TimeStamped {
@@ -225,6 +230,17 @@ export interface BanBasePayload {
 
 export interface BanPayloadSteam extends BanBasePayload {
     report_id?: number;
+    // Replace the duration with the one from the ban escalation policy of the reason
+    escalate?: boolean;
+}
+
+export interface BanEscalation {
+    escalated: boolean;
+    target_id: string;
+    reason: BanReason;
+    prior_offences: number;
+    duration: string;
+    valid_until: Date;
 }
 
 export interface BanPayloadCIDR extends BanBasePayload {
@@ -322,6 +338,15 @@ export const apiCreateBanSteam = async (p: BanPayloadSteam) =>
         p
     );
 
+export const apiGetBanEscalation = async (
+    target_id: string,
+    reason: BanReason
+) =>
+    await apiCall<BanEscalation>(`/api/bans/steam/escalation`, 'POST', {
+        target_id,
+        reason
+    });
+
 export const apiCreateBanCIDR = async (p: BanPayloadCIDR) =>
     await apiCall<IAPIBanCIDRRecord, BanPayloadCIDR>(
         `/api/bans/cidr/create`,
@@ -459,8 +484,12 @@ export const apiDeleteGroupBan = async (
 
 export const apiSetBanAppealState = async (
     ban_id: number,
-    appeal_state: AppealState
+    appeal_state: AppealState,
+    duration?: Duration,
+    note?: string
 ) =>
     await apiCall(`/api/bans/steam/${ban_id}/status`, 'POST', {
-        appeal_state
+        appeal_state,
+        duration,
+        note
     });
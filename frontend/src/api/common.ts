@@ -142,4 +142,6 @@ export interface AuthorQueryFilter<T> extends QueryFilter<T> {
 
 export interface ReportQueryFilter<T> extends AuthorQueryFilter<T> {
     report_status?: ReportStatus;
+    assignee_id?: string;
+    unassigned?: boolean;
 }
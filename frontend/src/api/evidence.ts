@@ -0,0 +1,79 @@
+import { apiCall, TimeStamped } from './common';
+import { Person, PersonMessage } from './profile';
+import { BaseUploadedMedia, UserUploadedFile } from './media';
+import { MatchSummary } from './stats';
+import SteamID from 'steamid';
+
+export type EvidenceKind = 'media' | 'message' | 'demo' | 'match';
+
+export type EvidenceParent = 'report' | 'ban';
+
+export interface Evidence extends TimeStamped {
+    evidence_id: number;
+    parent_kind: EvidenceParent;
+    parent_id: number;
+    message_id: number;
+    author_id: SteamID;
+    kind: EvidenceKind;
+    media_id: number;
+    person_message_id: number;
+    demo_id: number;
+    tick_start: number;
+    tick_end: number;
+    match_id: number;
+    note: string;
+    deleted: boolean;
+}
+
+export interface EvidenceDemo {
+    demo_id: number;
+    server_id: number;
+    title: string;
+    size: number;
+    downloads: number;
+    created_on: Date;
+}
+
+export interface EvidenceDetail extends Evidence {
+    author: Person;
+    media?: BaseUploadedMedia;
+    message?: PersonMessage;
+    demo?: EvidenceDemo;
+    match?: MatchSummary;
+}
+
+export interface CreateEvidenceRequest {
+    kind: EvidenceKind;
+    message_id?: number;
+    media?: UserUploadedFile;
+    media_id?: number;
+    person_message_id?: number;
+    demo_id?: number;
+    tick_start?: number;
+    tick_end?: number;
+    match_id?: number;
+    note?: string;
+}
+
+export const apiGetEvidence = async (
+    parent_kind: EvidenceParent,
+    parent_id: number
+) =>
+    await apiCall<EvidenceDetail[]>(
+        `/api/evidence/${parent_kind}/${parent_id}`,
+        'GET'
+    );
+
+export const apiCreateEvidence = async (
+    parent_kind: EvidenceParent,
+    parent_id: number,
+    opts: CreateEvidenceRequest
+) =>
+    await apiCall<Evidence, CreateEvidenceRequest>(
+        `/api/evidence/${parent_kind}/${parent_id}`,
+        'POST',
+        opts
+    );
+
+export const apiDeleteEvidence = async (evidence_id: number) =>
+    await apiCall(`/api/evidence/${evidence_id}`, 'DELETE', {});
@@ -1,4 +1,7 @@
 import { apiCall } from './common';
+import { BanReason } from './bans';
+
+export type FilterAction = 'warn' | 'gag' | 'kick' | 'ban';
 
 export interface Filter {
     word_id: number;
@@ -8,6 +11,12 @@ export interface Filter {
     discord_id?: string;
     discord_created_on?: Date;
     filter_name: string;
+    action?: FilterAction;
+    weight?: number;
+    ban_reason?: BanReason;
+    duration?: string;
+    ping_discord?: boolean;
+    source?: string;
 }
 
 export const apiGetFilters = async () =>
@@ -21,8 +30,30 @@ export interface FilterQuery {
     corpus: string;
 }
 
+export type NormaliseStep =
+    | 'none'
+    | 'confusables'
+    | 'substitutions'
+    | 'separators';
+
+export interface NormalisedText {
+    step: NormaliseStep;
+    text: string;
+}
+
+export interface FilterMatch {
+    filter: Filter;
+    word: string;
+    step: NormaliseStep;
+}
+
+export interface FilterMatchResult {
+    normalised: NormalisedText[];
+    matches: FilterMatch[];
+}
+
 export const apiMatchFilter = async (opts: FilterQuery) =>
-    await apiCall<Filter[]>(`/api/filter_match`, 'POST', opts);
+    await apiCall<FilterMatchResult>(`/api/filter_match`, 'POST', opts);
 
 export const apiDeleteFilter = async (word_id: number) =>
     await apiCall(`/api/filters/${word_id}`, 'DELETE');
@@ -52,9 +52,33 @@ export interface Person extends UserProfile {
     ip_addr: string;
 }
 
+export interface LinkedAccount {
+    steam_id: string;
+    persona_name: string;
+    avatar: string;
+    ban_type: number;
+    // Number of links between this account and the profile
+    depth: number;
+}
+
+export interface PersonLink {
+    steam_id: string;
+    linked_id: string;
+    ip_addr: string;
+    created_on: Date;
+    updated_on: Date;
+}
+
+export interface LinkedAccounts {
+    nodes: LinkedAccount[];
+    links: PersonLink[];
+}
+
 export interface PlayerProfile {
     player: Person;
     friends?: Person[];
+    // Only included for moderators
+    linked_accounts?: LinkedAccounts;
 }
 
 const validSteamIdKeys = ['target_id', 'source_id', 'steam_id', 'author_id'];
@@ -174,3 +198,46 @@ export const apiGetMessages = async (opts: MessageQuery) => {
     });
     return resp;
 };
+
+export type ChatSearchMode = 'contains' | 'text' | 'phrase' | 'regex';
+
+export interface ChatSearchQuery extends QueryFilter<PersonMessage> {
+    query?: string;
+    mode?: ChatSearchMode;
+    steam_ids?: string[];
+    server_id?: number;
+    team_only?: boolean;
+    sent_after?: Date;
+    sent_before?: Date;
+    context?: number;
+}
+
+export interface ChatSearchHit {
+    message: PersonMessage;
+    before: PersonMessage[];
+    after: PersonMessage[];
+}
+
+const parseMessageDates = (messages: PersonMessage[]) =>
+    messages.map((msg) => {
+        return {
+            ...msg,
+            created_on: parseDateTime(msg.created_on as unknown as string)
+        };
+    });
+
+export const apiSearchMessages = async (opts: ChatSearchQuery) => {
+    const resp = await apiCall<ChatSearchHit[]>(
+        `/api/messages/search`,
+        'POST',
+        opts
+    );
+    resp.result = resp.result?.map((hit) => {
+        return {
+            message: parseMessageDates([hit.message])[0],
+            before: parseMessageDates(hit.before),
+            after: parseMessageDates(hit.after)
+        };
+    });
+    return resp;
+};
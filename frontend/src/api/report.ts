@@ -1,4 +1,4 @@
-import { apiCall, AuthorQueryFilter, TimeStamped } from './common';
+import { apiCall, ReportQueryFilter, TimeStamped } from './common';
 import { Person, UserProfile } from './profile';
 import { Theme } from '@mui/material';
 import { BanReason } from './bans';
@@ -48,6 +48,10 @@ export interface Report extends TimeStamped {
     deleted: boolean;
     reason: BanReason;
     reason_text: string;
+    demo_name: string;
+    assignee_id: SteamID;
+    status_changed_on: Date;
+    escalated: boolean;
 }
 
 export interface ReportMessagesResponse {
@@ -74,6 +78,8 @@ export interface ReportWithAuthor {
     author: Person;
     report: Report;
     subject: Person;
+    assignee: Person;
+    time_in_state: number;
 }
 
 export const apiCreateReport = async (opts: CreateReportRequest) =>
@@ -82,8 +88,8 @@ export const apiCreateReport = async (opts: CreateReportRequest) =>
 export const apiGetReport = async (report_id: number) =>
     await apiCall<ReportWithAuthor>(`/api/report/${report_id}`, 'GET');
 
-export const apiGetReports = async (opts?: AuthorQueryFilter<Report>) =>
-    await apiCall<ReportWithAuthor[], AuthorQueryFilter<Report>>(
+export const apiGetReports = async (opts?: ReportQueryFilter<Report>) =>
+    await apiCall<ReportWithAuthor[], ReportQueryFilter<Report>>(
         `/api/reports`,
         'POST',
         opts
@@ -111,10 +117,22 @@ export const apiCreateReportMessage = async (
 
 export const apiReportSetState = async (
     report_id: number,
-    stateAction: ReportStatus
+    stateAction: ReportStatus,
+    ban_id?: number
 ) =>
     await apiCall(`/api/report_status/${report_id}`, 'POST', {
-        status: stateAction
+        status: stateAction,
+        ban_id
+    });
+
+export const apiReportAssign = async (
+    report_id: number,
+    assignee_id?: string,
+    unassign?: boolean
+) =>
+    await apiCall<Report>(`/api/report/${report_id}/assign`, 'POST', {
+        assignee_id,
+        unassign
     });
 
 export const apiUpdateReportMessage = async (
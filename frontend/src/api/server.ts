@@ -16,6 +16,10 @@ export interface ServerState {
     longitude: number;
     reserved: number;
     last_update: string;
+    watched: boolean;
+    watched_server_id: number;
+    flagged: boolean;
+    flagged_reason: string;
     name_a2s: string;
     protocol: number;
     map: string;
@@ -35,6 +39,7 @@ export interface ServerState {
     game_id: number;
     stv_port: number;
     stv_name: string;
+    rules: Record<string, string>;
     players: ServerStatePlayer[];
     distance?: number;
 }
@@ -43,6 +48,7 @@ export interface ServerStatePlayer {
     user_id: number;
     name: string;
     steam_id: string;
+    score: number;
     connected_time: number;
     state: string;
     ping: number;
@@ -163,6 +169,67 @@ export const apiSaveServer = async (server_id: number, opts: SaveServerOpts) =>
 export const apiGetServers = async () =>
     await apiCall<Server[]>(`/api/servers`, 'GET');
 
+export interface RCONStats {
+    server_id: number;
+    server_name: string;
+    connected: boolean;
+    commands: number;
+    errors: number;
+    reconnects: number;
+    failures: number;
+    last_latency_ms: number;
+    last_error: string;
+    last_error_on: Date;
+    retry_at: Date;
+}
+
+export const apiGetRCONStats = async () =>
+    await apiCall<RCONStats[]>(`/api/servers/rcon`, 'GET');
+
+export type HealthCheck = 'a2s' | 'rcon' | 'logs';
+
+export interface HealthCheckState {
+    known: boolean;
+    up: boolean;
+    since: Date;
+    last_success: Date;
+    failing_since: Date;
+    last_error: string;
+}
+
+export interface ServerHealth {
+    server_id: number;
+    server_name: string;
+    checks: Record<HealthCheck, HealthCheckState>;
+    player_count: number;
+    last_log: Date;
+}
+
+export interface ServerUptimeInterval {
+    server_uptime_id: number;
+    server_id: number;
+    check: HealthCheck;
+    up: boolean;
+    started_on: Date;
+    ended_on: Date;
+}
+
+export interface ServerUptime {
+    server_id: number;
+    since: Date;
+    uptime: Partial<Record<HealthCheck, number>>;
+    intervals: ServerUptimeInterval[];
+}
+
+export const apiGetServerHealth = async () =>
+    await apiCall<ServerHealth[]>(`/api/servers/health`, 'GET');
+
+export const apiGetServerUptime = async (server_id: number, window = '7d') =>
+    await apiCall<ServerUptime>(
+        `/api/servers/${server_id}/uptime?window=${encodeURIComponent(window)}`,
+        'GET'
+    );
+
 export const apiDeleteServer = async (server_id: number) =>
     await apiCall(`/api/servers/${server_id}`, 'DELETE');
 
@@ -190,6 +257,7 @@ export interface SlimServer {
     latitude: number;
     longitude: number;
     distance: number;
+    watched: boolean;
 }
 
 export const apiServerQuery = async (opts: ServerQueryOpts) =>
@@ -198,3 +266,133 @@ export const apiServerQuery = async (opts: ServerQueryOpts) =>
         'POST',
         opts
     );
+
+export interface WatchedServer extends TimeStamped {
+    watched_server_id: number;
+    name: string;
+    address: string;
+    port: number;
+    region: string;
+    cc: string;
+    is_enabled: boolean;
+}
+
+export interface SaveWatchedServerOpts {
+    name: string;
+    host: string;
+    port: number;
+    region: string;
+    cc: string;
+    is_enabled: boolean;
+}
+
+export const apiGetWatchedServers = async () =>
+    await apiCall<WatchedServer[]>(`/api/watched_servers`, 'GET');
+
+export const apiCreateWatchedServer = async (opts: SaveWatchedServerOpts) =>
+    await apiCall<WatchedServer, SaveWatchedServerOpts>(
+        `/api/watched_servers`,
+        'POST',
+        opts
+    );
+
+export const apiSaveWatchedServer = async (
+    watched_server_id: number,
+    opts: SaveWatchedServerOpts
+) =>
+    await apiCall<WatchedServer, SaveWatchedServerOpts>(
+        `/api/watched_servers/${watched_server_id}`,
+        'POST',
+        opts
+    );
+
+export const apiDeleteWatchedServer = async (watched_server_id: number) =>
+    await apiCall<WatchedServer>(
+        `/api/watched_servers/${watched_server_id}`,
+        'DELETE'
+    );
+
+export type ScheduledTaskKind = 'command' | 'announce';
+
+export type ScheduledTaskRunStatus = 'success' | 'failed' | 'skipped';
+
+export interface ScheduledTask extends TimeStamped {
+    scheduled_task_id: number;
+    name: string;
+    schedule: string;
+    kind: ScheduledTaskKind;
+    command: string;
+    server_id: number;
+    region: string;
+    only_when_empty: boolean;
+    only_when_outdated: boolean;
+    is_enabled: boolean;
+}
+
+export interface SaveScheduledTaskOpts {
+    name: string;
+    schedule: string;
+    kind: ScheduledTaskKind;
+    command: string;
+    server_id: number;
+    region: string;
+    only_when_empty: boolean;
+    only_when_outdated: boolean;
+    is_enabled: boolean;
+}
+
+export interface ScheduledTaskRun {
+    scheduled_task_run_id: number;
+    scheduled_task_id: number;
+    server_id: number;
+    status: ScheduledTaskRunStatus;
+    output: string;
+    created_on: Date;
+}
+
+export interface ScheduledTaskRunQueryOpts {
+    scheduled_task_id?: number;
+    status?: ScheduledTaskRunStatus;
+    limit?: number;
+}
+
+export const apiGetScheduledTasks = async () =>
+    await apiCall<ScheduledTask[]>(`/api/scheduled_tasks`, 'GET');
+
+export const apiCreateScheduledTask = async (opts: SaveScheduledTaskOpts) =>
+    await apiCall<ScheduledTask, SaveScheduledTaskOpts>(
+        `/api/scheduled_tasks`,
+        'POST',
+        opts
+    );
+
+export const apiSaveScheduledTask = async (
+    scheduled_task_id: number,
+    opts: SaveScheduledTaskOpts
+) =>
+    await apiCall<ScheduledTask, SaveScheduledTaskOpts>(
+        `/api/scheduled_tasks/${scheduled_task_id}`,
+        'POST',
+        opts
+    );
+
+export const apiDeleteScheduledTask = async (scheduled_task_id: number) =>
+    await apiCall<ScheduledTask>(
+        `/api/scheduled_tasks/${scheduled_task_id}`,
+        'DELETE'
+    );
+
+export const apiRunScheduledTask = async (scheduled_task_id: number) =>
+    await apiCall<ScheduledTaskRun[]>(
+        `/api/scheduled_tasks/${scheduled_task_id}/run`,
+        'POST'
+    );
+
+export const apiGetScheduledTaskRuns = async (
+    opts: ScheduledTaskRunQueryOpts
+) =>
+    await apiCall<ScheduledTaskRun[], ScheduledTaskRunQueryOpts>(
+        `/api/scheduled_task_runs`,
+        'POST',
+        opts
+    );
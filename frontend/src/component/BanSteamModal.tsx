@@ -1,6 +1,15 @@
-import React, { useMemo } from 'react';
+import React, { useEffect, useMemo, useState } from 'react';
 import Stack from '@mui/material/Stack';
-import { apiCreateBanSteam, BanReason, BanType, Duration } from '../api';
+import FormControlLabel from '@mui/material/FormControlLabel';
+import Switch from '@mui/material/Switch';
+import {
+    apiCreateBanSteam,
+    apiGetBanEscalation,
+    BanEscalation,
+    BanReason,
+    BanType,
+    Duration
+} from '../api';
 import { Heading } from './Heading';
 import SteamID from 'steamid';
 import * as yup from 'yup';
@@ -47,6 +56,7 @@ interface BanSteamFormValues extends SteamIDInputValue {
     duration: Duration;
     durationCustom: string;
     note: string;
+    escalate: boolean;
 }
 
 const validationSchema = yup.object({
@@ -67,6 +77,7 @@ export const BanSteamModal = ({
     reportId
 }: BanModalProps) => {
     const { sendFlash } = useUserFlashCtx();
+    const [escalation, setEscalation] = useState<BanEscalation>();
 
     const isReadOnlySid = useMemo(() => {
         return !!steamId?.getSteamID64();
@@ -81,7 +92,8 @@ export const BanSteamModal = ({
             reason: BanReason.Cheating,
             steam_id: steamId?.getSteamID64() ?? '',
             reasonText: '',
-            reportId: reportId
+            reportId: reportId,
+            escalate: false
         },
         validateOnBlur: true,
         validateOnChange: false,
@@ -90,11 +102,13 @@ export const BanSteamModal = ({
         },
         validationSchema: validationSchema,
         onSubmit: async (values) => {
+            const useEscalation = values.escalate && !!escalation?.escalated;
             try {
                 const resp = await apiCreateBanSteam({
                     note: values.note,
                     ban_type: values.banType,
-                    duration: values.duration,
+                    duration: useEscalation ? '0' : values.duration,
+                    escalate: useEscalation,
                     reason: values.reason,
                     reason_text: values.reasonText,
                     report_id: values.reportId,
@@ -113,6 +127,23 @@ export const BanSteamModal = ({
             }
         }
     });
+
+    // Preview the escalation policy duration so it can be reviewed before confirming
+    useEffect(() => {
+        setEscalation(undefined);
+        if (!formik.values.steam_id) {
+            return;
+        }
+        apiGetBanEscalation(formik.values.steam_id, formik.values.reason)
+            .then((resp) => {
+                if (!resp.status || !resp.result) {
+                    return;
+                }
+                setEscalation(resp.result);
+            })
+            .catch(logErr);
+    }, [formik.values.steam_id, formik.values.reason]);
+
     const formId = 'banSteamForm';
     return (
         <form onSubmit={formik.handleSubmit} id={formId}>
@@ -138,8 +169,27 @@ export const BanSteamModal = ({
                         <BanTypeField formik={formik} />
                         <BanReasonField formik={formik} />
                         <BanReasonTextField formik={formik} />
-                        <DurationField formik={formik} />
-                        <DurationCustomField formik={formik} />
+                        {escalation?.escalated && (
+                            <FormControlLabel
+                                control={
+                                    <Switch
+                                        id={'escalate'}
+                                        name={'escalate'}
+                                        checked={formik.values.escalate}
+                                        onChange={formik.handleChange}
+                                    />
+                                }
+                                label={`Use escalation policy: offence #${
+                                    escalation.prior_offences + 1
+                                } (${escalation.duration})`}
+                            />
+                        )}
+                        {!(formik.values.escalate && escalation?.escalated) && (
+                            <>
+                                <DurationField formik={formik} />
+                                <DurationCustomField formik={formik} />
+                            </>
+                        )}
                         <NoteField formik={formik} />
                     </Stack>
                 </DialogContent>
@@ -0,0 +1,55 @@
+import React from 'react';
+import List from '@mui/material/List';
+import ListItemButton from '@mui/material/ListItemButton';
+import ListItemAvatar from '@mui/material/ListItemAvatar';
+import ListItemText from '@mui/material/ListItemText';
+import Avatar from '@mui/material/Avatar';
+import Typography from '@mui/material/Typography';
+import { useNavigate } from 'react-router-dom';
+import { BanType, LinkedAccounts } from '../api';
+
+export interface LinkedAccountListProps {
+    linked: LinkedAccounts;
+}
+
+export const LinkedAccountList = ({ linked }: LinkedAccountListProps) => {
+    const navigate = useNavigate();
+    // The profile itself is the node at depth 0
+    const accounts = linked.nodes.filter((n) => n.depth > 0);
+    if (accounts.length == 0) {
+        return (
+            <Typography padding={2} variant={'body2'}>
+                No linked accounts
+            </Typography>
+        );
+    }
+    return (
+        <List dense>
+            {accounts.map((account) => (
+                <ListItemButton
+                    key={account.steam_id}
+                    onClick={() => {
+                        navigate(`/profile/${account.steam_id}`);
+                    }}
+                >
+                    <ListItemAvatar>
+                        <Avatar
+                            alt={account.persona_name}
+                            src={account.avatar}
+                        />
+                    </ListItemAvatar>
+                    <ListItemText
+                        primary={account.persona_name || account.steam_id}
+                        secondary={`Depth: ${account.depth}${
+                            account.ban_type == BanType.Banned
+                                ? ' (Banned)'
+                                : account.ban_type == BanType.NoComm
+                                ? ' (Muted)'
+                                : ''
+                        }`}
+                    />
+                </ListItemButton>
+            ))}
+        </List>
+    );
+};
@@ -74,12 +74,19 @@ export const ServerList = () => {
                         align: 'left',
                         width: '100%',
                         queryValue: (obj) => obj.name + obj.name_short,
-                        renderer: (_, value) => (
+                        renderer: (obj, value) => (
                             <Typography
                                 variant={'button'}
                                 fontFamily={tf2Fonts}
+                                color={obj.flagged ? 'error' : undefined}
                             >
-                                {value as string}
+                                {obj.flagged ? (
+                                    <Tooltip title={obj.flagged_reason}>
+                                        <span>{`${value} (flagged)`}</span>
+                                    </Tooltip>
+                                ) : (
+                                    (value as string)
+                                )}
                             </Typography>
                         )
                     },
@@ -18,7 +18,9 @@ export const ServerSelect = ({ setServerIDs }: ServerSelectProps) => {
     useEffect(() => {
         apiGetServerStates()
             .then((servers) => {
-                setServers(servers.result || []);
+                setServers(
+                    (servers.result || []).filter((s) => !s.watched)
+                );
             })
             .catch(logErr);
     }, []);
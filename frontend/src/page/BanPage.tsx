@@ -281,6 +281,18 @@ export const BanPage = (): JSX.Element => {
                                             />
                                         </ListItem>
                                     )}
+                                {ban &&
+                                    currentUser.permission_level >=
+                                        PermissionLevel.Moderator && (
+                                        <ListItem>
+                                            <ListItemText
+                                                primary={'Join Attempts'}
+                                                secondary={(
+                                                    ban.join_attempts ?? 0
+                                                ).toString()}
+                                            />
+                                        </ListItem>
+                                    )}
                             </List>
                         </ContainerWithHeader>
                     )}
@@ -23,6 +23,8 @@ import { useUserFlashCtx } from '../contexts/UserFlashCtx';
 import { ContainerWithHeader } from '../component/ContainerWithHeader';
 import LocalLibraryIcon from '@mui/icons-material/LocalLibrary';
 import LinkIcon from '@mui/icons-material/Link';
+import AccountTreeIcon from '@mui/icons-material/AccountTree';
+import { LinkedAccountList } from '../component/LinkedAccountList';
 
 export const Profile = (): JSX.Element => {
     const [profile, setProfile] = React.useState<Nullable<PlayerProfile>>(null);
@@ -164,6 +166,16 @@ export const Profile = (): JSX.Element => {
                         <Paper elevation={1}>
                             <FriendList friends={profile?.friends || []} />
                         </Paper>
+                        {profile.linked_accounts && (
+                            <ContainerWithHeader
+                                title={'Linked Accounts'}
+                                iconLeft={<AccountTreeIcon />}
+                            >
+                                <LinkedAccountList
+                                    linked={profile.linked_accounts}
+                                />
+                            </ContainerWithHeader>
+                        )}
                     </Stack>
                 </Grid>
             </>
@@ -37,7 +37,9 @@ function LinearProgressWithLabel(
 }
 
 export const ServerStats = () => {
-    const { servers } = useMapStateCtx();
+    const { servers: allServers } = useMapStateCtx();
+    // Only our own servers count towards the network stats
+    const servers = allServers.filter((s) => !s.watched);
     const cap = servers.length * 24;
     const use = sum(servers.map((value) => value?.players?.length || 0));
     const regions = servers.reduce((acc, cv) => {
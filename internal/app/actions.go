@@ -3,6 +3,7 @@ package app
 import (
 	"context"
 	"fmt"
+	"github.com/bwmarrin/discordgo"
 	"github.com/leighmacdonald/gbans/internal/config"
 	"github.com/leighmacdonald/gbans/internal/consts"
 	"github.com/leighmacdonald/gbans/internal/model"
@@ -18,6 +19,18 @@ import (
 	"time"
 )
 
+// sendDiscordDirectMessage queues the embed to be sent as a direct message to the discord user
+func sendDiscordDirectMessage(outChannel chan discordPayload, userId string, embed *discordgo.MessageEmbed) {
+	if !config.Discord.Enabled || userId == "" {
+		return
+	}
+	select {
+	case outChannel <- discordPayload{userId: userId, embed: embed}:
+	default:
+		log.Warnf("Cannot send discord direct message, channel full")
+	}
+}
+
 func sendDiscordPayload(outChannel chan discordPayload, payload discordPayload) {
 	if config.Discord.PublicLogChannelEnable {
 		select {
@@ -28,7 +41,15 @@ func sendDiscordPayload(outChannel chan discordPayload, payload discordPayload)
 	}
 }
 
-// Kick will kick the steam id from whatever server it is connected to.
+// sendModChannels sends the embed to each of the configured moderator channels
+func sendModChannels(outChannel chan discordPayload, embed *discordgo.MessageEmbed) {
+	for _, channelId := range config.Discord.ModChannels {
+		sendDiscordPayload(outChannel, discordPayload{channelId: channelId, embed: embed})
+	}
+}
+
+// Kick will kick the steam id from whatever server it is connected to. The kick is pushed to the
+// server plugin, which confirms it once applied.
 func (app *App) Kick(ctx context.Context, database store.Store, origin model.Origin, target model.StringSID, author model.StringSID,
 	reason model.Reason, playerInfo *model.PlayerInfo) error {
 	authorSid64, errAid := author.SID64()
@@ -41,12 +62,11 @@ func (app *App) Kick(ctx context.Context, database store.Store, origin model.Ori
 		return errFind
 	}
 	if foundPI.Valid && foundPI.InGame {
-		rconResponse, errExecRCON := query.ExecRCON(ctx, *foundPI.Server, fmt.Sprintf("sm_kick #%d %s", foundPI.Player.UserID, reason))
-		if errExecRCON != nil {
-			log.Errorf("Faied to kick user afeter ban: %v", errExecRCON)
-			return errExecRCON
+		if errPush := app.pushServerAction(ctx, database, *foundPI.Server, foundPI.Player.SID,
+			model.ServerActionKick, reason.String()); errPush != nil {
+			log.Errorf("Failed to kick user: %v", errPush)
+			return errPush
 		}
-		log.Debugf("RCON response: %s", rconResponse)
 		log.WithFields(log.Fields{"origin": origin, "target": target, "author": util.SanitizeLog(authorSid64.String())}).
 			Infof("User kicked")
 	}
@@ -57,29 +77,25 @@ func (app *App) Kick(ctx context.Context, database store.Store, origin model.Ori
 	return nil
 }
 
-// Silence will gag & mute a player
+// Silence will gag & mute a player. Like Kick, the gag is pushed to the server plugin so that it
+// applies immediately rather than on the next connection.
 func (app *App) Silence(ctx context.Context, database store.Store, origin model.Origin, target model.StringSID, author model.StringSID,
 	reason model.Reason, playerInfo *model.PlayerInfo) error {
 	authorSid64, errAid := author.SID64()
 	if errAid != nil {
 		return errAid
 	}
-	// kick the user if they currently are playing on a server
+	// silence the user if they currently are playing on a server
 	var foundPI model.PlayerInfo
 	if errFind := app.Find(ctx, database, target, "", &foundPI); errFind != nil {
 		return errFind
 	}
 	if foundPI.Valid && foundPI.InGame {
-		rconResponse, errExecRCON := query.ExecRCON(
-			ctx,
-			*foundPI.Server,
-			fmt.Sprintf(`sm_silence "#%s" %s`, steamid.SID64ToSID(foundPI.Player.SID), reason),
-		)
-		if errExecRCON != nil {
-			log.Errorf("Faied to kick user afeter ban: %v", errExecRCON)
-			return errExecRCON
+		if errPush := app.pushServerAction(ctx, database, *foundPI.Server, foundPI.Player.SID,
+			model.ServerActionGag, reason.String()); errPush != nil {
+			log.Errorf("Failed to silence user: %v", errPush)
+			return errPush
 		}
-		log.Debugf("RCON response: %s", rconResponse)
 		log.WithFields(log.Fields{
 			"origin": origin,
 			"target": target,
@@ -180,20 +196,85 @@ func (app *App) PSay(ctx context.Context, database store.Store, author steamid.S
 	if errExecRCON != nil {
 		return errors.Errorf("Failed to exec psay command: %v", errExecRCON)
 	}
-	log.WithFields(log.Fields{"author": author, "server": server.ServerNameShort, "msg": message, "target": sid}).
+	log.WithFields(log.Fields{"author": author, "server": actualServer.ServerNameShort, "msg": message, "target": sid}).
 		Infof("Private message sent")
 	return nil
 }
 
+// WarnAdd manually issues a warning to the target. The warning is handled by the warnWorker in the same
+// manner as automatic warnings, so it counts towards the warning limit and may trigger the configured action.
+func (app *App) WarnAdd(ctx context.Context, database store.Store, source steamid.SID64, target steamid.SID64,
+	reason model.Reason, message string, serverId int) (model.UserWarning, error) {
+	if !target.Valid() {
+		return model.UserWarning{}, consts.ErrInvalidSID
+	}
+	serverEvent := model.ServerEvent{Source: model.NewPerson(target)}
+	if errPerson := app.PersonBySID(ctx, database, target, &serverEvent.Source); errPerson != nil {
+		return model.UserWarning{}, errors.Wrap(errPerson, "Failed to load target")
+	}
+	if serverId > 0 {
+		if errServer := database.GetServer(ctx, serverId, &serverEvent.Server); errServer != nil {
+			return model.UserWarning{}, errors.Wrap(errServer, "Failed to load server")
+		}
+	}
+	warning := newUserWarning{
+		ServerEvent: serverEvent,
+		UserWarning: model.UserWarning{
+			SteamId:    target,
+			SourceId:   source,
+			ServerId:   serverEvent.Server.ServerID,
+			ServerName: serverEvent.Server.ServerNameShort,
+			WarnReason: reason,
+			Message:    message,
+			CreatedOn:  config.Now(),
+			ValidUntil: config.Now().Add(config.General.WarningTimeout),
+			Weight:     1,
+		},
+	}
+	select {
+	case app.warningChan <- warning:
+	case <-ctx.Done():
+		return model.UserWarning{}, ctx.Err()
+	}
+	log.WithFields(log.Fields{"author": source, "target": target, "reason": reason.String()}).
+		Infof("Warning issued")
+	return warning.UserWarning, nil
+}
+
+// WarnClear removes all warnings issued to the target, resetting their warning count
+func (app *App) WarnClear(ctx context.Context, database store.WarningStore, target steamid.SID64) (int64, error) {
+	count, errDrop := database.DropWarnings(ctx, target)
+	if errDrop != nil {
+		return 0, errors.Wrap(errDrop, "Failed to drop warnings")
+	}
+	select {
+	case app.warningClearChan <- target:
+	case <-ctx.Done():
+		return count, ctx.Err()
+	}
+	log.WithFields(log.Fields{"target": target, "count": count}).Infof("Warnings cleared")
+	return count, nil
+}
+
+// FilterActionOpts defines the action settings applied to a filter by FilterAdd. Zero values, and a nil
+// PingDiscord, leave the existing, or default, settings unchanged.
+type FilterActionOpts struct {
+	Action      model.FilterAction
+	Weight      int
+	BanReason   model.Reason
+	Duration    string
+	PingDiscord *bool
+}
+
 // FilterAdd creates a new chat filter using a regex pattern
-func (app *App) FilterAdd(ctx context.Context, database store.Store, newPattern *regexp.Regexp, name string) (model.Filter, error) {
+func (app *App) FilterAdd(ctx context.Context, database store.Store, newPattern *regexp.Regexp, name string,
+	opts FilterActionOpts) (model.Filter, error) {
 	var filter model.Filter
 	if errGetFilter := database.GetFilterByName(ctx, name, &filter); errGetFilter != nil {
 		if !errors.Is(errGetFilter, store.ErrNoResult) {
 			return filter, errors.Wrapf(errGetFilter, "Failed to get parent filter")
 		}
-		filter.CreatedOn = config.Now()
-		filter.FilterName = name
+		filter = model.NewFilter(name, nil)
 	}
 	existing := filter.Patterns
 	for _, pat := range existing {
@@ -202,6 +283,24 @@ func (app *App) FilterAdd(ctx context.Context, database store.Store, newPattern
 		}
 	}
 	filter.Patterns = append(filter.Patterns, newPattern)
+	if opts.Action != "" {
+		filter.Action = opts.Action
+	}
+	if opts.Weight > 0 {
+		filter.Weight = opts.Weight
+	}
+	if opts.BanReason > 0 {
+		filter.BanReason = opts.BanReason
+	}
+	if opts.Duration != "" {
+		filter.Duration = opts.Duration
+	}
+	if opts.PingDiscord != nil {
+		filter.PingDiscord = *opts.PingDiscord
+	}
+	if errValidate := filter.Validate(); errValidate != nil {
+		return filter, errValidate
+	}
 	if errSave := database.SaveFilter(ctx, &filter); errSave != nil {
 		if errSave == store.ErrDuplicate {
 			return filter, store.ErrDuplicate
@@ -225,19 +324,18 @@ func (app *App) FilterDel(ctx context.Context, database store.Store, filterId in
 }
 
 // FilterCheck can be used to check if a phrase will match any filters
-func (app *App) FilterCheck(message string) []model.Filter {
+func (app *App) FilterCheck(message string) []model.FilterMatch {
 	if message == "" {
 		return nil
 	}
-	words := strings.Split(strings.ToLower(message), " ")
+	normalised := model.Normalise(message)
 	wordFiltersMu.RLock()
 	defer wordFiltersMu.RUnlock()
-	var found []model.Filter
-	for _, filter := range wordFilters {
-		for _, word := range words {
-			if filter.Match(word) {
-				found = append(found, filter)
-			}
+	var found []model.FilterMatch
+	for idx := range wordFilters {
+		filter := wordFilters[idx]
+		if word, step, matched := filter.MatchNormalised(normalised, config.Filter.Normalise); matched {
+			found = append(found, model.FilterMatch{Filter: &filter, Word: word, Step: step})
 		}
 	}
 	return found
@@ -245,21 +343,20 @@ func (app *App) FilterCheck(message string) []model.Filter {
 
 // findFilteredWordMatch checks to see if the body of text contains a known filtered word
 // It will only return the first matched filter found.
-func findFilteredWordMatch(body string) (string, *model.Filter) {
+func findFilteredWordMatch(body string) *model.FilterMatch {
 	if body == "" {
-		return "", nil
+		return nil
 	}
-	words := strings.Split(strings.ToLower(body), " ")
+	normalised := model.Normalise(body)
 	wordFiltersMu.RLock()
 	defer wordFiltersMu.RUnlock()
-	for _, filter := range wordFilters {
-		for _, word := range words {
-			if filter.Match(word) {
-				return word, &filter
-			}
+	for idx := range wordFilters {
+		filter := wordFilters[idx]
+		if word, step, matched := filter.MatchNormalised(normalised, config.Filter.Normalise); matched {
+			return &model.FilterMatch{Filter: &filter, Word: word, Step: step}
 		}
 	}
-	return "", nil
+	return nil
 }
 
 // PersonBySID fetches the person from the database, updating the PlayerSummary if it out of date
@@ -14,7 +14,10 @@ import (
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"net"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 )
 
 func newBaseBanOpts(source model.SteamIDProvider, target model.StringSID, duration model.Duration,
@@ -73,6 +76,61 @@ func NewBanSteam(source model.SteamIDProvider, target model.StringSID, duration
 	return nil
 }
 
+// BanEscalation calculates the ban duration for the targets next offence of the reason from the configured
+// ban_escalation policies and the targets prior ban history. Returns false if no policy applies to the reason.
+func BanEscalation(ctx context.Context, database store.BanStore, target steamid.SID64, reason model.Reason,
+	escalation *model.BanEscalation) (bool, error) {
+	policy, found := config.BanEscalation.Policy(int(reason))
+	if !found {
+		return false, nil
+	}
+	priorCounts, errCount := database.GetBanCountsByReason(ctx, target, reason)
+	if errCount != nil {
+		return false, errors.Wrap(errCount, "Failed to get prior ban count")
+	}
+	// Escalation only applies to steam bans, so network bans of the player are not prior offences
+	priorOffences := priorCounts[model.BanKindSteam]
+	duration := model.Duration(policy.Duration(priorOffences))
+	durationValue, errDuration := duration.Value()
+	if errDuration != nil {
+		return false, errors.Wrapf(errDuration, "Invalid escalation duration for reason: %s", reason)
+	}
+	escalation.TargetId = target
+	escalation.Reason = reason
+	escalation.PriorOffences = priorOffences
+	escalation.Duration = duration
+	escalation.ValidUntil = config.Now().Add(durationValue)
+	return true, nil
+}
+
+// ApplyBanEscalation replaces the duration of the ban with the duration of the ban escalation policy of its
+// reason. Escalation is opt-in so that durations explicitly chosen by moderators or configured for automatic
+// bans are never replaced. Returns false, leaving the ban unchanged, if no policy applies to the reason.
+func ApplyBanEscalation(ctx context.Context, database store.BanStore, banSteam *model.BanSteam,
+	escalation *model.BanEscalation) (bool, error) {
+	escalated, errEscalation := BanEscalation(ctx, database, banSteam.TargetId, banSteam.Reason, escalation)
+	if errEscalation != nil || !escalated {
+		return false, errEscalation
+	}
+	banSteam.ValidUntil = escalation.ValidUntil
+	log.WithFields(log.Fields{
+		"target_id": banSteam.TargetId.String(),
+		"reason":    banSteam.Reason.String(),
+		"offences":  escalation.PriorOffences + 1,
+		"duration":  escalation.Duration,
+	}).Infof("Ban duration escalated")
+	return true, nil
+}
+
+// saveBanAudit records a ban lifecycle event. Failures are only logged so they never block the ban action itself.
+func saveBanAudit(ctx context.Context, database store.BanStore, kind model.BanKind, banId int64, target string,
+	action model.BanAuditAction, note string) {
+	entry := model.NewBanAuditEntry(kind, banId, target, action, note)
+	if errSave := database.SaveBanAuditEntry(ctx, &entry); errSave != nil {
+		log.Errorf("Failed to save ban audit entry: %v", errSave)
+	}
+}
+
 // BanSteam will ban the steam id from all servers. Players are immediately kicked from servers
 // once executed. If duration is 0, the value of config.DefaultExpiration() will be used.
 func (app *App) BanSteam(ctx context.Context, database store.Store, banSteam *model.BanSteam, botSendMessageChan chan discordPayload) error {
@@ -87,10 +145,11 @@ func (app *App) BanSteam(ctx context.Context, database store.Store, banSteam *mo
 	if errGetExistingBan != nil && errGetExistingBan != store.ErrNoResult {
 		return errors.Wrapf(errGetExistingBan, "Failed to get ban")
 	}
-
 	if errSave := database.SaveBan(ctx, banSteam); errSave != nil {
 		return errors.Wrap(errSave, "Failed to save ban")
 	}
+	saveBanAudit(ctx, database, model.BanKindSteam, banSteam.BanID, banSteam.TargetId.String(),
+		model.BanAuditCreated, banSteam.Reason.String())
 	var updateAppealState = func(reportId int64) error {
 		var report model.Report
 		if errReport := database.GetReport(ctx, reportId, &report); errReport != nil {
@@ -99,7 +158,7 @@ func (app *App) BanSteam(ctx context.Context, database store.Store, banSteam *mo
 			}).Errorf("Failed to get associated report for ban")
 			return errors.New("Failed to get report")
 		}
-		report.ReportStatus = model.ClosedWithAction
+		report.SetStatus(model.ClosedWithAction)
 		if errSaveReport := database.SaveReport(ctx, &report); errSaveReport != nil {
 			log.WithFields(log.Fields{
 				"report_id": reportId,
@@ -145,7 +204,6 @@ func (app *App) BanSteam(ctx context.Context, database store.Store, banSteam *mo
 		addField(banNotice, "Expires At", expAt)
 		sendDiscordPayload(payloadChan, discordPayload{channelId: config.Discord.PublicLogChannelId, embed: banNotice})
 	}(botSendMessageChan)
-	// TODO mute player currently in-game w/o kicking
 	if banSteam.BanType == model.Banned {
 		if errKick := app.Kick(ctx, database, model.System,
 			model.StringSID(banSteam.TargetId.String()),
@@ -207,6 +265,8 @@ func (app *App) BanASN(ctx context.Context, database store.Store, banASN *model.
 	if errSave := database.SaveBanASN(ctx, banASN); errSave != nil {
 		return errSave
 	}
+	saveBanAudit(ctx, database, model.BanKindASN, banASN.BanASNId, strconv.FormatInt(banASN.ASNum, 10),
+		model.BanAuditCreated, banASN.Reason.String())
 	// TODO Kick all current players matching
 	return nil
 }
@@ -246,6 +306,8 @@ func (app *App) BanCIDR(ctx context.Context, database store.Store, banNet *model
 	if errSaveBanNet := database.SaveBanNet(ctx, banNet); errSaveBanNet != nil {
 		return errSaveBanNet
 	}
+	saveBanAudit(ctx, database, model.BanKindCIDR, banNet.NetID, banNet.CIDR.String(),
+		model.BanAuditCreated, banNet.Reason.String())
 	go func() {
 		var playerInfo model.PlayerInfo
 		if errFindPI := app.FindPlayerByCIDR(ctx, database, banNet.CIDR, &playerInfo); errFindPI != nil {
@@ -286,6 +348,14 @@ func (app *App) BanSteamGroup(ctx context.Context, database store.Store, banGrou
 	if errSaveBanGroup := database.SaveBanGroup(ctx, banGroup); errSaveBanGroup != nil {
 		return errSaveBanGroup
 	}
+	saveBanAudit(ctx, database, model.BanKindGroup, banGroup.BanGroupId, banGroup.GroupId.String(),
+		model.BanAuditCreated, banGroup.Reason.String())
+	if !isConfigBannedGroup(banGroup.GroupId) {
+		app.setBannedGroup(*banGroup, members)
+	}
+	if errMembers := database.SaveBanGroupMembers(ctx, banGroup.GroupId, members); errMembers != nil {
+		log.Errorf("Failed to save banned group members: %v", errMembers)
+	}
 	log.WithFields(log.Fields{
 		"gid":     banGroup.GroupId.String(),
 		"members": len(members),
@@ -305,12 +375,34 @@ func (app *App) Unban(ctx context.Context, database store.Store, target steamid.
 		}
 		return false, errGetBan
 	}
+	if errUnban := app.unbanPerson(ctx, database, &bannedPerson, reason, outChannel, nil); errUnban != nil {
+		return false, errUnban
+	}
+	return true, nil
+}
+
+// unbanPerson removes the ban of the banned person. When change is set, the appeal state change which
+// caused the unban is recorded in the same transaction as the ban update.
+func (app *App) unbanPerson(ctx context.Context, database store.Store, bannedPerson *model.BannedPerson, reason string,
+	outChannel chan discordPayload, change *model.AppealStateChange) error {
+	target := bannedPerson.Ban.TargetId
 	bannedPerson.Ban.Deleted = true
 	bannedPerson.Ban.UnbanReasonText = reason
-	if errSaveBan := database.SaveBan(ctx, &bannedPerson.Ban); errSaveBan != nil {
-		return false, errors.Wrapf(errSaveBan, "Failed to save unban")
+	if change != nil {
+		if errSaveBan := database.SaveBanAppealState(ctx, &bannedPerson.Ban, change); errSaveBan != nil {
+			return errors.Wrapf(errSaveBan, "Failed to save unban")
+		}
+	} else if errSaveBan := database.SaveBan(ctx, &bannedPerson.Ban); errSaveBan != nil {
+		return errors.Wrapf(errSaveBan, "Failed to save unban")
 	}
+	saveBanAudit(ctx, database, model.BanKindSteam, bannedPerson.Ban.BanID, target.String(),
+		model.BanAuditUnbanned, reason)
 	log.Infof("Player unbanned: %v", target)
+	if bannedPerson.Ban.BanType == model.NoComm {
+		if errUngag := app.Ungag(ctx, database, target, reason); errUngag != nil {
+			log.Errorf("Failed to ungag unbanned player: %v", errUngag)
+		}
+	}
 	if outChannel != nil {
 		go func() {
 			unbanNotice := &discordgo.MessageEmbed{
@@ -328,8 +420,7 @@ func (app *App) Unban(ctx context.Context, database store.Store, target steamid.
 			})
 		}()
 	}
-
-	return true, nil
+	return nil
 }
 
 // UnbanASN will remove an existing ASN ban
@@ -342,10 +433,255 @@ func (app *App) UnbanASN(ctx context.Context, database store.Store, asnNum strin
 	if errGetBanASN := database.GetBanASN(ctx, asNum, &banASN); errGetBanASN != nil {
 		return false, errGetBanASN
 	}
-	if errDrop := database.DropBanASN(ctx, &banASN); errDrop != nil {
-		log.Errorf("Failed to drop ASN ban: %v", errDrop)
-		return false, errDrop
+	if errUnban := app.unbanASN(ctx, database, &banASN, ""); errUnban != nil {
+		log.Errorf("Failed to drop ASN ban: %v", errUnban)
+		return false, errUnban
 	}
-	log.Infof("ASN unbanned: %d", asNum)
 	return true, nil
 }
+
+// unbanASN removes the ASN ban, recording the unban in the ban audit log
+func (app *App) unbanASN(ctx context.Context, database store.Store, banASN *model.BanASN, reason string) error {
+	banASN.UnbanReasonText = reason
+	if errDrop := database.DropBanASN(ctx, banASN); errDrop != nil {
+		return errDrop
+	}
+	saveBanAudit(ctx, database, model.BanKindASN, banASN.BanASNId, strconv.FormatInt(banASN.ASNum, 10),
+		model.BanAuditUnbanned, reason)
+	log.Infof("ASN unbanned: %d", banASN.ASNum)
+	return nil
+}
+
+// unbanCIDR removes the network ban, recording the unban in the ban audit log
+func (app *App) unbanCIDR(ctx context.Context, database store.Store, banCIDR *model.BanCIDR, reason string) error {
+	banCIDR.UnbanReasonText = reason
+	banCIDR.Deleted = true
+	if errSave := database.SaveBanNet(ctx, banCIDR); errSave != nil {
+		return errSave
+	}
+	saveBanAudit(ctx, database, model.BanKindCIDR, banCIDR.NetID, banCIDR.CIDR.String(), model.BanAuditUnbanned, reason)
+	log.Infof("CIDR unbanned: %s", banCIDR.CIDR.String())
+	return nil
+}
+
+// unbanSteamGroup removes the steam group ban, recording the unban in the ban audit log
+func (app *App) unbanSteamGroup(ctx context.Context, database store.Store, banGroup *model.BanGroup, reason string) error {
+	banGroup.UnbanReasonText = reason
+	banGroup.Deleted = true
+	if errSave := database.SaveBanGroup(ctx, banGroup); errSave != nil {
+		return errSave
+	}
+	if !isConfigBannedGroup(banGroup.GroupId) {
+		app.removeBannedGroup(banGroup.GroupId)
+	}
+	saveBanAudit(ctx, database, model.BanKindGroup, banGroup.BanGroupId, banGroup.GroupId.String(),
+		model.BanAuditUnbanned, reason)
+	log.Infof("Steam group unbanned: %s", banGroup.GroupId.String())
+	return nil
+}
+
+// SetAppealState moves the appeal of a ban to a new state, recording the change in the appeal audit trail.
+// Accepted appeals remove the ban and Reduced appeals shorten the ban to expire after duration from now.
+// The banned player is notified by discord direct message if they have linked their discord account.
+func (app *App) SetAppealState(ctx context.Context, database store.Store, bannedPerson *model.BannedPerson,
+	author steamid.SID64, newState model.AppealState, duration model.Duration, note string) (model.AppealStateChange, error) {
+	ban := &bannedPerson.Ban
+	if !ban.AppealState.CanTransition(newState) {
+		return model.AppealStateChange{}, errors.Wrapf(consts.ErrInvalidState, "Cannot change appeal from %s to %s",
+			ban.AppealState, newState)
+	}
+	change := model.AppealStateChange{
+		BanId:            ban.BanID,
+		SourceId:         author,
+		FromState:        ban.AppealState,
+		ToState:          newState,
+		Note:             note,
+		ValidUntilBefore: ban.ValidUntil,
+		CreatedOn:        config.Now(),
+	}
+	switch newState {
+	case model.Reduced:
+		durationValue, errDuration := duration.Value()
+		if errDuration != nil {
+			return model.AppealStateChange{}, errDuration
+		}
+		validUntil := config.Now().Add(durationValue)
+		if !validUntil.Before(ban.ValidUntil) {
+			return model.AppealStateChange{}, errors.New("Reduced duration must be shorter than the current ban")
+		}
+		ban.ValidUntil = validUntil
+	}
+	change.ValidUntilAfter = ban.ValidUntil
+	ban.AppealState = newState
+	if newState == model.Accepted {
+		// Accepted appeals go through the regular unban so that comm bans are lifted in game and mods notified
+		reason := "Appeal accepted"
+		if note != "" {
+			reason = fmt.Sprintf("Appeal accepted: %s", note)
+		}
+		if errUnban := app.unbanPerson(ctx, database, bannedPerson, reason, app.discordSendMsg, &change); errUnban != nil {
+			return model.AppealStateChange{}, errors.Wrap(errUnban, "Failed to save appeal state")
+		}
+	} else if errSave := database.SaveBanAppealState(ctx, ban, &change); errSave != nil {
+		return model.AppealStateChange{}, errors.Wrap(errSave, "Failed to save appeal state")
+	}
+	log.WithFields(log.Fields{
+		"ban_id": ban.BanID,
+		"author": author.String(),
+		"from":   change.FromState.String(),
+		"to":     change.ToState.String(),
+	}).Infof("Updated ban appeal state")
+
+	embed := &discordgo.MessageEmbed{
+		URL:   config.ExtURL("/ban/%d", ban.BanID),
+		Type:  discordgo.EmbedTypeRich,
+		Title: fmt.Sprintf("Appeal %s (#%d)", newState.String(), ban.BanID),
+		Color: int(orange),
+	}
+	switch newState {
+	case model.Accepted, model.Reduced:
+		embed.Color = int(green)
+	case model.Denied, model.NoAppeal:
+		embed.Color = int(red)
+	}
+	if note != "" {
+		embed.Description = note
+	}
+	if newState == model.Reduced {
+		addField(embed, "Expires At", config.FmtTimeShort(ban.ValidUntil))
+	}
+	sendDiscordDirectMessage(app.discordSendMsg, bannedPerson.Person.DiscordID, embed)
+
+	modEmbed := *embed
+	modEmbed.Fields = append([]*discordgo.MessageEmbedField{}, embed.Fields...)
+	addFieldsSteamID(&modEmbed, ban.TargetId)
+	addField(&modEmbed, "Previous State", change.FromState.String())
+	addField(&modEmbed, "Changed By", author.String())
+	sendDiscordPayload(app.discordSendMsg, discordPayload{
+		channelId: config.Discord.ModLogChannelId,
+		embed:     &modEmbed,
+	})
+	return change, nil
+}
+
+// AppealCooldownRemaining returns how long the banned player must wait before they can appeal a denied
+// ban again. Returns 0 when the ban is not denied or the cooldown has passed.
+func AppealCooldownRemaining(ctx context.Context, database store.BanStore, ban model.BanSteam) (time.Duration, error) {
+	if ban.AppealState != model.Denied {
+		return 0, nil
+	}
+	changes, errChanges := database.GetAppealStateChanges(ctx, ban.BanID)
+	if errChanges != nil {
+		return 0, errors.Wrap(errChanges, "Failed to get appeal state changes")
+	}
+	// Bans denied before the audit trail existed fall back to when the ban was last updated
+	deniedOn := ban.UpdatedOn
+	for _, change := range changes {
+		if change.ToState == model.Denied {
+			deniedOn = change.CreatedOn
+		}
+	}
+	remaining := deniedOn.Add(config.Appeals.ReasonCooldown(int(ban.Reason))).Sub(config.Now())
+	if remaining < 0 {
+		return 0, nil
+	}
+	return remaining, nil
+}
+
+// banSearchMaxPeople limits how many players a name search is expanded into
+const banSearchMaxPeople = 25
+
+var reSteamIDQuery = regexp.MustCompile(`(?i)^(STEAM_[0-5]:[01]:\d+|\[U:1:\d+]|U:1:\d+|7656119\d{10})$`)
+
+// isSteamIDQuery checks if the search query is a steam id or profile url. Anything else is searched as a
+// player name, otherwise names which happen to be a valid vanity url would resolve to an unrelated player.
+func isSteamIDQuery(query string) bool {
+	return reSteamIDQuery.MatchString(query) || strings.Contains(strings.ToLower(query), "steamcommunity.com/")
+}
+
+// SearchBans finds every ban affecting the subject of the query, which may be a steam id, ip address or
+// player name. The query is first expanded into the steam ids and addresses it refers to, using the known
+// connection history, and then matched against all ban kinds. Steam group bans are matched using the
+// recorded members of banned groups.
+func (app *App) SearchBans(ctx context.Context, database store.Store, query string, includeDeleted bool) (model.BanSearchResult, error) {
+	query = strings.TrimSpace(query)
+	result := model.BanSearchResult{Query: query, SteamIds: steamid.Collection{}, Addresses: []net.IP{}, Bans: []model.BanMatch{}}
+	if query == "" {
+		return result, consts.ErrInvalidSID
+	}
+	if addr := net.ParseIP(query); addr != nil {
+		result.Addresses = append(result.Addresses, addr)
+		bits := 128
+		if addr.To4() != nil {
+			addr = addr.To4()
+			bits = 32
+		}
+		steamIds, errSteamIds := database.GetSteamIDsAtNetwork(ctx,
+			&net.IPNet{IP: addr, Mask: net.CIDRMask(bits, bits)}, time.Time{})
+		if errSteamIds != nil && !errors.Is(errSteamIds, store.ErrNoResult) {
+			return result, errors.Wrap(errSteamIds, "Failed to get steam ids at address")
+		}
+		result.SteamIds = append(result.SteamIds, steamIds...)
+	} else if isSteamIDQuery(query) {
+		sid64, errResolve := ResolveSID(ctx, query)
+		if errResolve != nil {
+			return result, errors.Wrap(consts.ErrInvalidSID, errResolve.Error())
+		}
+		result.SteamIds = append(result.SteamIds, sid64)
+	} else {
+		people, errPeople := database.GetPeople(ctx, store.QueryFilter{Query: "%" + query + "%", Limit: banSearchMaxPeople})
+		if errPeople != nil && !errors.Is(errPeople, store.ErrNoResult) {
+			return result, errors.Wrap(errPeople, "Failed to search people")
+		}
+		for _, person := range people {
+			result.SteamIds = append(result.SteamIds, person.SteamID)
+		}
+	}
+	knownAddrs := map[string]bool{}
+	for _, addr := range result.Addresses {
+		knownAddrs[addr.String()] = true
+	}
+	for _, sid64 := range result.SteamIds {
+		connections, errConnections := database.GetPersonIPHistory(ctx, sid64, 1000)
+		if errConnections != nil && !errors.Is(errConnections, store.ErrNoResult) {
+			return result, errors.Wrap(errConnections, "Failed to get ip history")
+		}
+		for _, conn := range connections {
+			if conn.IPAddr == nil || knownAddrs[conn.IPAddr.String()] {
+				continue
+			}
+			knownAddrs[conn.IPAddr.String()] = true
+			result.Addresses = append(result.Addresses, conn.IPAddr)
+		}
+	}
+	// Memberships are recorded for every banned group, including groups with expired or deleted bans
+	groupIds, errGroupIds := database.GetBanGroupMemberships(ctx, result.SteamIds)
+	if errGroupIds != nil && !errors.Is(errGroupIds, store.ErrNoResult) {
+		return result, errors.Wrap(errGroupIds, "Failed to get group memberships")
+	}
+	filter := store.BanSubjectFilter{
+		SteamIds:  result.SteamIds,
+		Addresses: result.Addresses,
+		GroupIds:  groupIds,
+		Deleted:   includeDeleted,
+	}
+	matches, errMatches := database.GetBansBySubject(ctx, filter)
+	if errMatches != nil {
+		return result, errors.Wrap(errMatches, "Failed to get bans")
+	}
+	result.Bans = append(result.Bans, matches...)
+	// Groups banned via the config have no ban record
+	for _, groupId := range groupIds {
+		if !isConfigBannedGroup(groupId) {
+			continue
+		}
+		result.Bans = append(result.Bans, model.BanMatch{
+			BanBase:   model.BanBase{BanType: model.Banned, Origin: model.System, IsEnabled: true},
+			Kind:      model.BanKindGroup,
+			Target:    groupId.String(),
+			MatchedOn: "Configured banned group",
+			Active:    true,
+		})
+	}
+	return result, nil
+}
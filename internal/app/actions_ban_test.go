@@ -0,0 +1,22 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsSteamIDQuery(t *testing.T) {
+	for _, query := range []string{
+		"76561198083950960",
+		"STEAM_0:0:61842616",
+		"[U:1:123685232]",
+		"https://steamcommunity.com/id/example",
+		"https://steamcommunity.com/profiles/76561198083950960",
+	} {
+		require.True(t, isSteamIDQuery(query), query)
+	}
+	for _, query := range []string{"example", "bob 123", "1234"} {
+		require.False(t, isSteamIDQuery(query), query)
+	}
+}
@@ -0,0 +1,180 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/gbans/internal/store"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"strings"
+)
+
+// linkedAccountsMaxDepth limits how many links away from the requested account the graph is followed
+const linkedAccountsMaxDepth = 3
+
+// altNetwork returns the network used to search for other accounts of the address
+func altNetwork(addr net.IP) (*net.IPNet, error) {
+	ipv4 := addr.To4()
+	if ipv4 == nil {
+		return nil, errors.New("Only ipv4 addresses are supported")
+	}
+	ones := 32
+	if config.AltDetection.MatchSubnet {
+		ones = 24
+	}
+	mask := net.CIDRMask(ones, 32)
+	return &net.IPNet{IP: ipv4.Mask(mask), Mask: mask}, nil
+}
+
+// checkLinkedAccounts links the connecting player to all other accounts seen on the same address, or network,
+// within the configured window. If any linked account is currently banned, the mods are alerted and
+// depending on the configured action, the player is banned for ban evasion.
+func (app *App) checkLinkedAccounts(ctx context.Context, database store.Store, steamId steamid.SID64, addr net.IP) error {
+	ipNet, errNetwork := altNetwork(addr)
+	if errNetwork != nil {
+		return errNetwork
+	}
+	steamIds, errSteamIds := database.GetSteamIDsAtNetwork(ctx, ipNet, config.Now().Add(-config.AltDetection.Window))
+	if errSteamIds != nil {
+		return errors.Wrap(errSteamIds, "Failed to get steam ids at network")
+	}
+	var bannedAlts []model.BannedPerson
+	for _, linkedId := range steamIds {
+		if linkedId == steamId {
+			continue
+		}
+		link := model.NewPersonLink(steamId, linkedId, addr)
+		if errLink := database.SavePersonLink(ctx, &link); errLink != nil {
+			return errors.Wrap(errLink, "Failed to save person link")
+		}
+		bannedPerson := model.NewBannedPerson()
+		if errBan := database.GetBanBySteamID(ctx, linkedId, &bannedPerson, false); errBan != nil {
+			if errors.Is(errBan, store.ErrNoResult) {
+				continue
+			}
+			return errors.Wrap(errBan, "Failed to get linked account ban")
+		}
+		if bannedPerson.Ban.BanType == model.Banned {
+			bannedAlts = append(bannedAlts, bannedPerson)
+		}
+	}
+	if len(bannedAlts) == 0 {
+		return nil
+	}
+	existingBan := model.NewBannedPerson()
+	errExisting := database.GetBanBySteamID(ctx, steamId, &existingBan, false)
+	if errExisting != nil && !errors.Is(errExisting, store.ErrNoResult) {
+		return errors.Wrap(errExisting, "Failed to get existing ban")
+	}
+	if errExisting == nil && existingBan.Ban.BanType == model.Banned {
+		// Already dealt with
+		return nil
+	}
+	var bannedIds []string
+	for _, bannedAlt := range bannedAlts {
+		bannedIds = append(bannedIds, bannedAlt.Ban.TargetId.String())
+	}
+	embed := &discordgo.MessageEmbed{
+		URL:   config.ExtURL("/profile/%d", steamId),
+		Type:  discordgo.EmbedTypeRich,
+		Title: "Possible ban evasion",
+		Color: int(orange),
+	}
+	addFieldsSteamID(embed, steamId)
+	addField(embed, "Address", addr.String())
+	addField(embed, "Banned Accounts", strings.Join(bannedIds, ", "))
+	if config.AltDetection.Action == config.AltBan {
+		var banSteam model.BanSteam
+		if errOpts := NewBanSteam(
+			model.StringSID(config.General.Owner.String()),
+			model.StringSID(steamId.String()),
+			"0",
+			model.Evading,
+			"",
+			fmt.Sprintf("Linked to banned account(s): %s", strings.Join(bannedIds, ", ")),
+			model.System,
+			0,
+			model.Banned,
+			&banSteam); errOpts != nil {
+			return errors.Wrap(errOpts, "Failed to create evasion ban")
+		}
+		// Evading accounts share the ban of the account they are evading
+		banSteam.ValidUntil = bannedAlts[0].Ban.ValidUntil
+		if errBan := app.BanSteam(ctx, database, &banSteam, app.discordSendMsg); errBan != nil &&
+			!errors.Is(errBan, store.ErrDuplicate) {
+			return errors.Wrap(errBan, "Failed to ban evading player")
+		}
+		embed.Color = int(red)
+		embed.Title = "Ban evasion, player banned"
+		addField(embed, "Expires At", config.FmtTimeShort(banSteam.ValidUntil))
+	}
+	log.WithFields(log.Fields{"sid64": steamId.String(), "banned_alts": len(bannedAlts),
+		"action": config.AltDetection.Action}).Infof("Player linked to banned account")
+	sendDiscordPayload(app.discordSendMsg, discordPayload{
+		channelId: config.Discord.ModLogChannelId,
+		embed:     embed,
+	})
+	return nil
+}
+
+// LinkedAccounts builds the graph of accounts linked to the steam id, following links up to
+// linkedAccountsMaxDepth away from it.
+func (app *App) LinkedAccounts(ctx context.Context, database store.Store, steamId steamid.SID64, graph *model.LinkedAccounts) error {
+	depths := map[steamid.SID64]int{steamId: 0}
+	seenLinks := map[[2]steamid.SID64]bool{}
+	queue := steamid.Collection{steamId}
+	graph.Links = []model.PersonLink{}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if depths[current] >= linkedAccountsMaxDepth {
+			continue
+		}
+		links, errLinks := database.GetPersonLinks(ctx, current)
+		if errLinks != nil {
+			return errors.Wrap(errLinks, "Failed to get person links")
+		}
+		for _, link := range links {
+			key := [2]steamid.SID64{link.SteamId, link.LinkedId}
+			if !seenLinks[key] {
+				seenLinks[key] = true
+				graph.Links = append(graph.Links, link)
+			}
+			other := link.Other(current)
+			if _, found := depths[other]; !found {
+				depths[other] = depths[current] + 1
+				queue = append(queue, other)
+			}
+		}
+	}
+	var ids steamid.Collection
+	for sid64 := range depths {
+		ids = append(ids, sid64)
+	}
+	people, errPeople := database.GetPeopleBySteamID(ctx, ids)
+	if errPeople != nil {
+		return errors.Wrap(errPeople, "Failed to get linked people")
+	}
+	peopleMap := people.AsMap()
+	graph.Nodes = []model.LinkedAccount{}
+	for _, sid64 := range ids {
+		node := model.LinkedAccount{SteamId: sid64, Depth: depths[sid64], BanType: model.OK}
+		if person, found := peopleMap[sid64]; found {
+			node.PersonaName = person.PersonaName
+			node.Avatar = person.Avatar
+		}
+		bannedPerson := model.NewBannedPerson()
+		if errBan := database.GetBanBySteamID(ctx, sid64, &bannedPerson, false); errBan == nil {
+			node.BanType = bannedPerson.Ban.BanType
+		} else if !errors.Is(errBan, store.ErrNoResult) {
+			return errors.Wrap(errBan, "Failed to get linked account ban")
+		}
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	return nil
+}
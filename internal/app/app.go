@@ -4,7 +4,6 @@ package app
 import (
 	"context"
 	"fmt"
-	"github.com/leighmacdonald/gbans/internal/thirdparty"
 	"math/rand"
 	"net"
 	"os"
@@ -15,12 +14,12 @@ import (
 	"github.com/leighmacdonald/gbans/internal/config"
 	"github.com/leighmacdonald/gbans/internal/event"
 	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/gbans/internal/query"
 	"github.com/leighmacdonald/gbans/internal/store"
 	"github.com/leighmacdonald/gbans/pkg/logparse"
 	"github.com/leighmacdonald/steamid/v2/extra"
 	"github.com/leighmacdonald/steamid/v2/steamid"
 	"github.com/pkg/errors"
-	"github.com/rumblefrog/go-a2s"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -30,17 +29,24 @@ type App struct {
 	serverStateStatus   map[string]extra.Status
 	serverStateStatusMu *sync.RWMutex
 	// Current known state of the servers a2s server info query
-	serverStateA2S     map[string]a2s.ServerInfo
+	serverStateA2S     map[string]query.A2SResult
 	serverStateA2SMu   *sync.RWMutex
 	masterServerList   []model.ServerLocation
 	masterServerListMu *sync.RWMutex
 	discordSendMsg     chan discordPayload
 	warningChan        chan newUserWarning
+	warningClearChan   chan steamid.SID64
 	serverStateMu      *sync.RWMutex
 	serverState        model.ServerStateCollection
+	// Current known state of the third party servers we watch, kept apart from our own servers
+	watchedServerState   model.ServerStateCollection
+	watchedServerStateMu *sync.RWMutex
 
-	bannedGroupMembers   map[steamid.GID]steamid.Collection
+	bannedGroupMembers   map[steamid.GID]bannedGroup
 	bannedGroupMembersMu *sync.RWMutex
+	serverHealth         *serverHealthTracker
+	// scheduledTaskReload signals the scheduledTaskRunner to reload the tasks after changes
+	scheduledTaskReload chan struct{}
 }
 
 var (
@@ -52,14 +58,13 @@ func init() {
 	rand.Seed(time.Now().Unix())
 }
 
-type userWarning struct {
-	WarnReason model.Reason
-	CreatedOn  time.Time
-}
-
 type discordPayload struct {
 	channelId string
-	embed     *discordgo.MessageEmbed
+	// userId is set instead of channelId when the embed should be sent as a direct message
+	userId string
+	embed  *discordgo.MessageEmbed
+	// message is sent as a plain text channel message when there is no embed
+	message string
 }
 
 func New() *App {
@@ -67,16 +72,21 @@ func New() *App {
 		logFileChan:          make(chan *LogFilePayload, 10),
 		serverStateStatus:    map[string]extra.Status{},
 		serverStateStatusMu:  &sync.RWMutex{},
-		serverStateA2S:       map[string]a2s.ServerInfo{},
+		serverStateA2S:       map[string]query.A2SResult{},
 		serverStateA2SMu:     &sync.RWMutex{},
 		masterServerList:     []model.ServerLocation{},
 		masterServerListMu:   &sync.RWMutex{},
 		discordSendMsg:       make(chan discordPayload, 5),
 		warningChan:          make(chan newUserWarning),
+		warningClearChan:     make(chan steamid.SID64),
 		serverStateMu:        &sync.RWMutex{},
 		serverState:          model.ServerStateCollection{},
-		bannedGroupMembers:   map[steamid.GID]steamid.Collection{},
+		watchedServerState:   model.ServerStateCollection{},
+		watchedServerStateMu: &sync.RWMutex{},
+		scheduledTaskReload:  make(chan struct{}, 1),
+		bannedGroupMembers:   map[steamid.GID]bannedGroup{},
 		bannedGroupMembersMu: &sync.RWMutex{},
+		serverHealth:         newServerHealthTracker(config.Now()),
 	}
 	return &app
 }
@@ -98,9 +108,9 @@ func (app *App) Start(ctx context.Context) error {
 		return errors.Wrapf(errWeb, "Failed to setup web")
 	}
 
-	// Load in the external network block / ip ban lists to memory if enabled
+	// Sync the external network block / ip ban lists into the database if enabled
 	if config.Net.Enabled {
-		initNetBans()
+		go app.externalBanSync(ctx, dbStore)
 	} else {
 		log.Warnf("External Network ban lists not enabled")
 	}
@@ -129,14 +139,29 @@ func (app *App) Start(ctx context.Context) error {
 
 type newUserWarning struct {
 	ServerEvent model.ServerEvent
-	Message     string
-	userWarning
+	// Filter is the word filter which triggered the warning, nil for manually issued warnings
+	Filter *model.Filter
+	// SpamKind is set when the warning was issued by the spam detection
+	SpamKind spamKind
+	model.UserWarning
 }
 
-// warnWorker handles tracking and applying warnings based on incoming events
+// warnWorker handles tracking and applying warnings based on incoming events. Warnings are persisted
+// so that the active warning state can be rebuilt from the database on start.
 func (app *App) warnWorker(ctx context.Context, newWarnings chan newUserWarning,
 	botSendMessageChan chan discordPayload, database store.Store) {
-	warnings := map[steamid.SID64][]userWarning{}
+	warnings := map[steamid.SID64][]model.UserWarning{}
+	loadCtx, cancelLoad := context.WithTimeout(ctx, time.Second*10)
+	activeWarnings, errActive := database.GetActiveWarnings(loadCtx)
+	cancelLoad()
+	if errActive != nil && !errors.Is(errActive, store.ErrNoResult) {
+		log.WithError(errActive).Errorf("Failed to load active warnings")
+	}
+	for _, warning := range activeWarnings {
+		warnings[warning.SteamId] = append(warnings[warning.SteamId], warning)
+	}
+	log.WithFields(log.Fields{"players": len(warnings), "warnings": len(activeWarnings)}).
+		Debugf("Loaded active warnings")
 	eventChan := make(chan model.ServerEvent)
 	if errRegister := event.Consume(eventChan, []logparse.EventType{logparse.Say, logparse.SayTeam}); errRegister != nil {
 		log.Fatalf("Failed to register event reader: %v", errRegister)
@@ -147,59 +172,101 @@ func (app *App) warnWorker(ctx context.Context, newWarnings chan newUserWarning,
 		for {
 			select {
 			case now := <-ticker.C:
-				for steamId := range warnings {
-					for warnIdx, warning := range warnings[steamId] {
-						if now.Sub(warning.CreatedOn) > config.General.WarningTimeout {
-							if len(warnings[steamId]) > 1 {
-								warnings[steamId] = append(warnings[steamId][:warnIdx], warnings[steamId][warnIdx+1])
-							} else {
-								warnings[steamId] = nil
-							}
-						}
-						if len(warnings[steamId]) == 0 {
-							delete(warnings, steamId)
+				for steamId, userWarnings := range warnings {
+					var active []model.UserWarning
+					for _, warning := range userWarnings {
+						if !warning.Expired(now) {
+							active = append(active, warning)
 						}
 					}
+					if len(active) == 0 {
+						delete(warnings, steamId)
+					} else {
+						warnings[steamId] = active
+					}
 				}
+			case steamId := <-app.warningClearChan:
+				delete(warnings, steamId)
 			case newWarn := <-newWarnings:
 				steamId := newWarn.ServerEvent.Source.SteamID
 				if !steamId.Valid() {
 					continue
 				}
-				_, found := warnings[steamId]
-				if !found {
-					warnings[steamId] = []userWarning{}
+				newWarn.SteamId = steamId
+				newWarn.ServerId = newWarn.ServerEvent.Server.ServerID
+				newWarn.ServerName = newWarn.ServerEvent.Server.ServerNameShort
+				if newWarn.Weight <= 0 {
+					newWarn.Weight = 1
+				}
+				if newWarn.ValidUntil.IsZero() {
+					newWarn.ValidUntil = newWarn.CreatedOn.Add(config.General.WarningTimeout)
+				}
+				if errSave := database.SaveWarning(ctx, &newWarn.UserWarning); errSave != nil {
+					log.WithError(errSave).Errorf("Failed to save warning")
 				}
-				warnings[steamId] = append(warnings[steamId], newWarn.userWarning)
+				warnings[steamId] = append(warnings[steamId], newWarn.UserWarning)
+				totalWeight := model.UserWarnings(warnings[steamId]).TotalWeight()
 
 				warnNotice := &discordgo.MessageEmbed{
 					URL:   fmt.Sprintf(config.ExtURL("/profiles/%d", steamId)),
 					Type:  discordgo.EmbedTypeRich,
-					Title: fmt.Sprintf("Language Warning (#%d/%d)", len(warnings[steamId]), config.General.WarningLimit),
+					Title: fmt.Sprintf("%s Warning (#%d/%d)", newWarn.WarnReason.String(), totalWeight, config.General.WarningLimit),
 					Color: int(orange),
 					Image: &discordgo.MessageEmbedImage{URL: newWarn.ServerEvent.Source.AvatarFull},
 				}
 				addField(warnNotice, "Server", newWarn.ServerEvent.Server.ServerNameShort)
 				addField(warnNotice, "Message", newWarn.Message)
-				if len(warnings[steamId]) > config.General.WarningLimit {
-					log.Infof("Warn limit exceeded (%d): %d", steamId, len(warnings[steamId]))
+				if newWarn.Filter != nil {
+					addField(warnNotice, "Filter", newWarn.Filter.FilterName)
+					addFieldInline(warnNotice, "Matched", fmt.Sprintf("||%s||", newWarn.MatchedWord))
+					addFieldInline(warnNotice, "Normalisation", string(newWarn.MatchedStep))
+				}
+				if newWarn.SpamKind != "" {
+					addField(warnNotice, "Spam", string(newWarn.SpamKind))
+				}
+				// Filters with an action other than warn apply it immediately, regardless of the warning count
+				action := config.General.WarningExceededAction
+				duration := config.General.WarningExceededDurationValue
+				reason := newWarn.WarnReason
+				note := "Automatic warning ban"
+				// The configured warning duration is only a fallback for reasons without an escalation policy
+				escalate := true
+				applyAction := totalWeight > config.General.WarningLimit
+				if newWarn.Filter != nil && newWarn.Filter.Action.Valid() && newWarn.Filter.Action != model.FilterActionWarn {
+					applyAction = true
+					action = config.Action(newWarn.Filter.Action)
+					reason = newWarn.Filter.BanReason
+					note = fmt.Sprintf("Automatic word filter action: %s", newWarn.Filter.FilterName)
+					if newWarn.Filter.Duration != "" {
+						duration = newWarn.Filter.Duration
+						escalate = false
+					}
+				}
+				if applyAction {
+					log.Infof("Applying warning action %s (%d): %d", action, steamId, totalWeight)
 					var errBan error
 					var banSteam model.BanSteam
 					if errOpts := NewBanSteam(model.StringSID(config.General.Owner.String()),
 						model.StringSID(steamId.String()),
-						model.Duration(config.General.WarningExceededDurationValue),
-						newWarn.WarnReason,
+						model.Duration(duration),
+						reason,
 						"",
-						"Automatic warning ban",
+						note,
 						model.System,
 						0,
 						model.NoComm,
 						&banSteam); errOpts != nil {
 						log.Errorf("Failed to create warning ban: %v", errOpts)
-						return
+						continue
+					}
+					if escalate && action != config.Kick {
+						var escalation model.BanEscalation
+						if _, errEscalation := ApplyBanEscalation(ctx, database, &banSteam, &escalation); errEscalation != nil {
+							log.Errorf("Failed to calculate ban escalation: %v", errEscalation)
+						}
 					}
 
-					switch config.General.WarningExceededAction {
+					switch action {
 					case config.Gag:
 						banSteam.BanType = model.NoComm
 						errBan = app.BanSteam(ctx, database, &banSteam, botSendMessageChan)
@@ -209,25 +276,37 @@ func (app *App) warnWorker(ctx context.Context, newWarnings chan newUserWarning,
 					case config.Kick:
 						var playerInfo model.PlayerInfo
 						errBan = app.Kick(ctx, database, model.System, model.StringSID(steamId.String()),
-							model.StringSID(config.General.Owner.String()), newWarn.WarnReason, &playerInfo)
+							model.StringSID(config.General.Owner.String()), reason, &playerInfo)
 					}
 					if errBan != nil {
-						log.WithFields(log.Fields{"action": config.General.WarningExceededAction}).
+						log.WithFields(log.Fields{"action": action}).
 							Errorf("Failed to apply warning action: %v", errBan)
 					}
 					addField(warnNotice, "Name", newWarn.ServerEvent.Source.PersonaName)
-					expIn := "Permanent"
-					expAt := "Permanent"
-					if banSteam.ValidUntil.Year()-config.Now().Year() < 5 {
-						expIn = config.FmtDuration(banSteam.ValidUntil)
-						expAt = config.FmtTimeShort(banSteam.ValidUntil)
+					addField(warnNotice, "Action", string(action))
+					if action != config.Kick {
+						expIn := "Permanent"
+						expAt := "Permanent"
+						if banSteam.ValidUntil.Year()-config.Now().Year() < 5 {
+							expIn = config.FmtDuration(banSteam.ValidUntil)
+							expAt = config.FmtTimeShort(banSteam.ValidUntil)
+						}
+						addField(warnNotice, "Expires In", expIn)
+						addField(warnNotice, "Expires At", expAt)
 					}
-					addField(warnNotice, "Expires In", expIn)
-					addField(warnNotice, "Expires At", expAt)
 				} else {
 					msg := fmt.Sprintf("[WARN #%d] Please refrain from using slurs/toxicity (see: rules & MOTD). "+
-						"Further offenses will result in mutes/bans", len(warnings[steamId]))
-					if errPSay := app.PSay(ctx, database, 0, model.StringSID(steamId.String()), msg, &newWarn.ServerEvent.Server); errPSay != nil {
+						"Further offenses will result in mutes/bans", totalWeight)
+					if newWarn.WarnReason == model.Spam {
+						msg = fmt.Sprintf("[WARN #%d] Please refrain from spamming chat (see: rules & MOTD). "+
+							"Further offenses will result in mutes/bans", totalWeight)
+					}
+					// Manually issued warnings have no server, so we look up where the player is currently playing
+					var server *model.Server
+					if newWarn.ServerId > 0 {
+						server = &newWarn.ServerEvent.Server
+					}
+					if errPSay := app.PSay(ctx, database, 0, model.StringSID(steamId.String()), msg, server); errPSay != nil {
 						log.WithError(errPSay).Errorf("Failed to send user warning psay message")
 					}
 					addFieldsSteamID(warnNotice, steamId)
@@ -237,6 +316,11 @@ func (app *App) warnWorker(ctx context.Context, newWarnings chan newUserWarning,
 					channelId: config.Discord.ModLogChannelId,
 					embed:     warnNotice,
 				})
+				if newWarn.Filter != nil && newWarn.Filter.PingDiscord {
+					pingNotice := *warnNotice
+					pingNotice.Description = modRoleMentions()
+					sendModChannels(app.discordSendMsg, &pingNotice)
+				}
 			case <-ctx.Done():
 				return
 			}
@@ -252,20 +336,27 @@ func (app *App) warnWorker(ctx context.Context, newWarnings chan newUserWarning,
 			if !found {
 				continue
 			}
-			matchedWord, matchedFilter := findFilteredWordMatch(msg)
-			if matchedFilter != nil {
+			match := findFilteredWordMatch(msg)
+			if match != nil {
 				app.warningChan <- newUserWarning{
 					ServerEvent: serverEvent,
-					userWarning: userWarning{
-						WarnReason: model.Language,
-						CreatedOn:  config.Now(),
+					Filter:      match.Filter,
+					UserWarning: model.UserWarning{
+						WarnReason:  model.Language,
+						Message:     msg,
+						FilterId:    match.Filter.WordID,
+						MatchedWord: match.Word,
+						MatchedStep: match.Step,
+						CreatedOn:   config.Now(),
+						Weight:      match.Filter.Weight,
 					},
 				}
 				log.WithFields(log.Fields{
-					"word":        fmt.Sprintf("||%s||", matchedWord),
+					"word":        fmt.Sprintf("||%s||", match.Word),
+					"step":        match.Step,
 					"msg":         msg,
-					"filter_id":   matchedFilter.WordID,
-					"filter_name": matchedFilter.FilterName,
+					"filter_id":   match.Filter.WordID,
+					"filter_name": match.Filter.FilterName,
 				}).Debugf("User triggered word filter")
 
 			}
@@ -275,84 +366,168 @@ func (app *App) warnWorker(ctx context.Context, newWarnings chan newUserWarning,
 	}
 }
 
-//
-//func matchSummarizer(ctx context.Context, db store.Store) {
-//	eventChan := make(chan model.ServerEvent)
-//	if errReg := event.Consume(eventChan, []logparse.EventType{logparse.Any}); errReg != nil {
-//		log.Warnf("logWriter Tried to register duplicate reader channel")
-//	}
-//	match := model.NewMatch()
-//
-//	var reset = func() {
-//		match = model.NewMatch()
-//		log.Debugf("New match summary created")
-//	}
-//
-//	var curServer model.Server
-//	for {
-//		// TODO reset on match start incase of stale data
-//		select {
-//		case evt := <-eventChan:
-//			if match.ServerId == 0 && evt.Server.ServerID > 0 {
-//				curServer = evt.Server
-//				match.ServerId = curServer.ServerID
-//			}
-//			switch evt.EventType {
-//			case logparse.MapLoad:
-//				reset()
-//			}
-//			// Apply the update before any secondary side effects trigger
-//			if errApply := match.Apply(evt); errApply != nil {
-//				log.Tracef("Error applying event: %v", errApply)
-//			}
-//			switch evt.EventType {
-//			case logparse.WGameOver:
-//				if errSave := db.MatchSave(ctx, &match); errSave != nil {
-//					log.Errorf("Failed to save match: %v", errSave)
-//				} else {
-//					sendDiscordNotif(curServer, &match)
-//				}
-//				reset()
-//			}
-//		case <-ctx.Done():
-//			return
-//		}
-//	}
-//}
-//
-//func sendDiscordNotif(server model.Server, match *model.Match) {
-//	embed := &discordgo.MessageEmbed{
-//		Type:        discordgo.EmbedTypeRich,
-//		Title:       fmt.Sprintf("Match #%d - %s - %s", match.MatchID, server.ServerNameShort, match.MapName),
-//		Description: "Match results",
-//		Color:       int(green),
-//		URL:         config.ExtURL("/log/%d", match.MatchID),
-//	}
-//	redScore := 0
-//	bluScore := 0
-//	for _, round := range match.Rounds {
-//		redScore += round.Score.Red
-//		bluScore += round.Score.Blu
-//	}
-//
-//	found := 0
-//	for _, teamStats := range match.TeamSums {
-//		addFieldInline(embed, fmt.Sprintf("%s Kills", teamStats.Team.String()), fmt.Sprintf("%d", teamStats.Kills))
-//		addFieldInline(embed, fmt.Sprintf("%s Damage", teamStats.Team.String()), fmt.Sprintf("%d", teamStats.Damage))
-//		addFieldInline(embed, fmt.Sprintf("%s Ubers/Drops", teamStats.Team.String()), fmt.Sprintf("%d/%d", teamStats.Charges, teamStats.Drops))
-//		found++
-//	}
-//	addFieldInline(embed, "Red Score", fmt.Sprintf("%d", redScore))
-//	addFieldInline(embed, "Blu Score", fmt.Sprintf("%d", bluScore))
-//	if found == 2 {
-//		log.Debugf("Sending discord summary")
-//		select {
-//		case discordSendMsg <- discordPayload{channelId: config.Discord.LogChannelID, embed: embed}:
-//		default:
-//			log.Warnf("Cannot send discord payload, channel full")
-//		}
-//	}
-//}
+const (
+	// matchEventBufferSize is the number of log events buffered for the matchSummarizer, so that log
+	// readers are not blocked while match state is being written
+	matchEventBufferSize = 1000
+	// matchStateFlushInterval is how often the state of in-progress matches with changes is persisted
+	matchStateFlushInterval = time.Second * 15
+)
+
+// matchSummarizer builds matches from the live log events of each server. A new match is started
+// on LogStart/WRoundStart and is closed and saved once WGameOver/LogStop is seen. The state of each
+// in-progress match is periodically persisted, in a single batch, so that a restart does not lose the match.
+func (app *App) matchSummarizer(ctx context.Context, database store.StatStore) {
+	eventChan := make(chan model.ServerEvent, matchEventBufferSize)
+	if errReg := event.Consume(eventChan, []logparse.EventType{logparse.Any}); errReg != nil {
+		log.Warnf("matchSummarizer Tried to register duplicate reader channel")
+		return
+	}
+	matches := map[int]*model.Match{}
+	loadCtx, cancelLoad := context.WithTimeout(ctx, time.Second*10)
+	savedMatches, errSaved := database.GetMatchStates(loadCtx)
+	cancelLoad()
+	if errSaved != nil {
+		log.WithError(errSaved).Errorf("Failed to load in-progress matches")
+	} else {
+		matches = savedMatches
+		log.WithFields(log.Fields{"count": len(matches)}).Debugf("Resumed in-progress matches")
+	}
+	// Servers with match state changes that have not been persisted yet
+	dirty := map[int]bool{}
+	// Last known map for each server, used when a match starts without a new map load
+	mapNames := map[int]string{}
+
+	var saveStates = func(localCtx context.Context) {
+		var pending []*model.Match
+		for serverId := range dirty {
+			if match, found := matches[serverId]; found {
+				pending = append(pending, match)
+			}
+		}
+		if len(pending) == 0 {
+			return
+		}
+		saveCtx, cancel := context.WithTimeout(localCtx, time.Second*10)
+		defer cancel()
+		if errSave := database.SaveMatchStates(saveCtx, pending); errSave != nil {
+			log.WithError(errSave).WithField("count", len(pending)).Errorf("Failed to save match states")
+			return
+		}
+		dirty = map[int]bool{}
+	}
+
+	var closeMatch = func(serverId int, server model.Server) {
+		match, found := matches[serverId]
+		if !found {
+			return
+		}
+		delete(matches, serverId)
+		delete(dirty, serverId)
+		localCtx, cancel := context.WithTimeout(ctx, time.Second*20)
+		defer cancel()
+		var dropState = func() {
+			if errDrop := database.DropMatchState(localCtx, serverId); errDrop != nil {
+				log.WithError(errDrop).WithField("server_id", serverId).Errorf("Failed to drop match state")
+			}
+		}
+		// Nothing worth recording, eg: map changed on an empty server
+		if len(match.Rounds) == 0 || len(match.PlayerSums) == 0 {
+			log.WithField("server_id", serverId).Debugf("Discarding empty match")
+			dropState()
+			return
+		}
+		// The persisted state is only dropped once the match is saved, so a failed save is restored and
+		// retried if gbans restarts before the server starts its next match
+		if errSave := database.MatchSave(localCtx, match); errSave != nil {
+			log.WithError(errSave).WithField("server_id", serverId).Errorf("Failed to save match")
+			return
+		}
+		dropState()
+		log.WithFields(log.Fields{"match_id": match.MatchID, "server_id": serverId}).Infof("Match saved")
+		app.sendMatchSummary(server, match)
+	}
+
+	var startMatch = func(server model.Server) *model.Match {
+		match := model.NewMatch()
+		match.ServerId = server.ServerID
+		if mapName, found := mapNames[server.ServerID]; found {
+			match.MapName = mapName
+		}
+		matches[server.ServerID] = &match
+		log.WithFields(log.Fields{"server_id": server.ServerID}).Debugf("New match started")
+		return &match
+	}
+
+	ticker := time.NewTicker(matchStateFlushInterval)
+	for {
+		select {
+		case evt := <-eventChan:
+			serverId := evt.Server.ServerID
+			if serverId <= 0 {
+				continue
+			}
+			match, found := matches[serverId]
+			switch evt.EventType {
+			case logparse.MapLoad:
+				if mapName, ok := evt.MetaData["map"].(string); ok {
+					mapNames[serverId] = mapName
+				}
+			case logparse.LogStart:
+				if found {
+					// The previous log was never closed, so finish off what we have
+					closeMatch(serverId, evt.Server)
+				}
+				match = startMatch(evt.Server)
+			case logparse.WRoundStart:
+				if !found {
+					match = startMatch(evt.Server)
+				}
+			}
+			if match == nil {
+				continue
+			}
+			// Apply the update before any secondary side effects trigger
+			if errApply := match.Apply(evt); errApply != nil {
+				log.Tracef("Error applying event: %v", errApply)
+			}
+			dirty[serverId] = true
+			if evt.EventType == logparse.WGameOver || evt.EventType == logparse.LogStop {
+				closeMatch(serverId, evt.Server)
+			}
+		case <-ticker.C:
+			saveStates(ctx)
+		case <-ctx.Done():
+			// The parent context is already cancelled, so use a fresh one for the final flush
+			saveStates(context.Background())
+			return
+		}
+	}
+}
+
+func (app *App) sendMatchSummary(server model.Server, match *model.Match) {
+	embed := &discordgo.MessageEmbed{
+		Type:        discordgo.EmbedTypeRich,
+		Title:       fmt.Sprintf("Match #%d - %s - %s", match.MatchID, server.ServerNameShort, match.MapName),
+		Description: "Match results",
+		Color:       int(green),
+		URL:         config.ExtURL("/log/%d", match.MatchID),
+	}
+	redScore := 0
+	bluScore := 0
+	for _, round := range match.Rounds {
+		redScore += round.Score.Red
+		bluScore += round.Score.Blu
+	}
+	for _, teamStats := range match.TeamSums {
+		addFieldInline(embed, fmt.Sprintf("%s Kills", teamStats.Team.String()), fmt.Sprintf("%d", teamStats.Kills))
+		addFieldInline(embed, fmt.Sprintf("%s Damage", teamStats.Team.String()), fmt.Sprintf("%d", teamStats.Damage))
+		addFieldInline(embed, fmt.Sprintf("%s Ubers/Drops", teamStats.Team.String()), fmt.Sprintf("%d/%d", teamStats.Charges, teamStats.Drops))
+	}
+	addFieldInline(embed, "Red Score", fmt.Sprintf("%d", redScore))
+	addFieldInline(embed, "Blu Score", fmt.Sprintf("%d", bluScore))
+	sendDiscordPayload(app.discordSendMsg, discordPayload{channelId: config.Discord.LogChannelID, embed: embed})
+}
 
 func playerMessageWriter(ctx context.Context, database store.Store) {
 	serverEventChan := make(chan model.ServerEvent)
@@ -575,7 +750,9 @@ func logReader(ctx context.Context, logFileChan chan *LogFilePayload, db store.S
 }
 
 func initFilters(ctx context.Context, database store.FilterStore) {
-	// TODO load external lists via http
+	if config.Filter.ExternalEnabled {
+		go externalFilterSync(ctx)
+	}
 	localCtx, cancel := context.WithTimeout(ctx, time.Second*15)
 	defer cancel()
 	words, errGetFilters := database.GetFilters(localCtx)
@@ -599,17 +776,30 @@ func (app *App) initWorkers(ctx context.Context, database store.Store, botSendMe
 		log.Fatalf("Failed to parse master_server_status_update_freq: %v", errParseMasterUpdateFreq)
 	}
 
-	go banSweeper(ctx, database)
+	go query.StartRCONPool(ctx)
+	go app.banSweeper(ctx, database)
+	if config.Spam.Enabled {
+		go app.spamWorker(ctx)
+	}
+	if config.Discord.Enabled && config.Discord.ChatRelayEnabled {
+		go app.chatRelay(ctx)
+	}
+	go app.reportTriage(ctx, database)
 	go app.mapChanger(ctx, database, time.Second*300)
 	go app.serverA2SStatusUpdater(ctx, database, freq)
 	go app.serverRCONStatusUpdater(ctx, database, freq)
 	go app.serverStateRefresher(ctx, database, freq)
+	go app.watchedServerUpdater(ctx, database, freq)
+	go app.scheduledTaskRunner(ctx, database)
+	if config.Health.Enabled {
+		go app.serverHealthMonitor(ctx, database, freq)
+	}
 	go profileUpdater(ctx, database)
 	go app.warnWorker(ctx, warningChan, botSendMessageChan, database)
 	go logReader(ctx, logFileC, database)
 	go initLogSrc(ctx, database)
 	go logMetricsConsumer(ctx)
-	//go matchSummarizer(ctx, database)
+	go app.matchSummarizer(ctx, database)
 	go playerMessageWriter(ctx, database)
 	go playerConnectionWriter(ctx, database)
 	go app.steamGroupMembershipUpdater(ctx, database)
@@ -641,7 +831,15 @@ func (app *App) initDiscord(ctx context.Context, database store.Store, botSendMe
 					if !session.Ready {
 						continue
 					}
-					if errSend := session.SendEmbed(payload.channelId, payload.embed); errSend != nil {
+					var errSend error
+					if payload.userId != "" {
+						errSend = session.SendDirectEmbed(payload.userId, payload.embed)
+					} else if payload.embed == nil {
+						errSend = session.Send(payload.channelId, payload.message, false)
+					} else {
+						errSend = session.SendEmbed(payload.channelId, payload.embed)
+					}
+					if errSend != nil {
 						log.Errorf("Failed to send discord payload: %v", errSend)
 					}
 				}
@@ -657,14 +855,6 @@ func (app *App) initDiscord(ctx context.Context, database store.Store, botSendMe
 	}
 }
 
-func initNetBans() {
-	for _, banList := range config.Net.Sources {
-		if errImport := thirdparty.Import(banList); errImport != nil {
-			log.Errorf("Failed to import banList: %v", errImport)
-		}
-	}
-}
-
 // validateLink is used in the case of discord origin actions that require mapping the
 // discord member ID to a SteamID so that we can track its use and apply permissions, etc.
 //
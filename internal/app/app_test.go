@@ -48,9 +48,9 @@ func TestMain(testMain *testing.M) {
 		return
 	}
 
-	// Load in the external network block / ip ban lists to memory if enabled
+	// Sync the external network block / ip ban lists into the database if enabled
 	if config.Net.Enabled {
-		initNetBans()
+		go app.externalBanSync(testCtx, dbStore)
 	} else {
 		log.Warnf("External Network ban lists not enabled")
 	}
@@ -3,6 +3,7 @@ package app
 import (
 	"context"
 	"fmt"
+	"github.com/bwmarrin/discordgo"
 	"github.com/krayzpipes/cronticker/cronticker"
 	"github.com/leighmacdonald/gbans/internal/config"
 	"github.com/leighmacdonald/gbans/internal/model"
@@ -12,6 +13,7 @@ import (
 	"github.com/leighmacdonald/steamid/v2/steamid"
 	"github.com/leighmacdonald/steamweb"
 	"github.com/pkg/errors"
+	"github.com/rumblefrog/go-a2s"
 	log "github.com/sirupsen/logrus"
 	"math/rand"
 	"net"
@@ -20,38 +22,96 @@ import (
 	"time"
 )
 
+// bannedGroup holds the current members of a banned steam group. A zero validUntil denotes a
+// permanent ban, as is the case for groups defined in the config. Groups defined in the config have
+// no banGroupId.
+type bannedGroup struct {
+	banGroupId int64
+	validUntil time.Time
+	members    steamid.Collection
+}
+
 func (app *App) IsSteamGroupBanned(steamId steamid.SID64) bool {
+	_, banned := app.bannedSteamGroup(steamId)
+	return banned
+}
+
+// bannedSteamGroup returns the BanGroupId of the banned steam group the steam id is a member of, if any.
+// Groups whose ban has expired, but which have not yet been swept, are ignored.
+func (app *App) bannedSteamGroup(steamId steamid.SID64) (int64, bool) {
+	now := config.Now()
 	app.bannedGroupMembersMu.RLock()
 	defer app.bannedGroupMembersMu.RUnlock()
-	for _, groupMembers := range app.bannedGroupMembers {
-		for _, member := range groupMembers {
+	for _, group := range app.bannedGroupMembers {
+		if !group.validUntil.IsZero() && group.validUntil.Before(now) {
+			continue
+		}
+		for _, member := range group.members {
 			if steamId == member {
-				return true
+				return group.banGroupId, true
 			}
 		}
 	}
-	return false
+	return 0, false
 }
 
-func (app *App) steamGroupMembershipUpdater(ctx context.Context, _ store.PersonStore) {
+// setBannedGroup adds or replaces the tracked members of a banned steam group
+func (app *App) setBannedGroup(banGroup model.BanGroup, members steamid.Collection) {
+	app.bannedGroupMembersMu.Lock()
+	app.bannedGroupMembers[banGroup.GroupId] = bannedGroup{
+		banGroupId: banGroup.BanGroupId,
+		validUntil: banGroup.ValidUntil,
+		members:    members,
+	}
+	app.bannedGroupMembersMu.Unlock()
+}
+
+// removeBannedGroup stops enforcing a steam group ban
+func (app *App) removeBannedGroup(groupId steamid.GID) {
+	app.bannedGroupMembersMu.Lock()
+	delete(app.bannedGroupMembers, groupId)
+	app.bannedGroupMembersMu.Unlock()
+}
+
+func (app *App) steamGroupMembershipUpdater(ctx context.Context, database store.BanStore) {
 	var update = func() {
 		localCtx, cancel := context.WithTimeout(ctx, time.Second*120)
-		newMap := map[steamid.GID]steamid.Collection{}
-		total := 0
+		defer cancel()
+		groups := map[steamid.GID]bannedGroup{}
 		for _, gid := range config.General.BannedSteamGroupIds {
+			groups[gid] = bannedGroup{}
+		}
+		banGroups, errBanGroups := database.GetBanGroups(localCtx)
+		if errBanGroups != nil && !errors.Is(errBanGroups, store.ErrNoResult) {
+			log.Warnf("Failed to fetch banned groups: %v", errBanGroups)
+		}
+		now := config.Now()
+		for _, banGroup := range banGroups {
+			if !banGroup.IsEnabled || banGroup.ValidUntil.Before(now) {
+				continue
+			}
+			if _, found := groups[banGroup.GroupId]; !found {
+				groups[banGroup.GroupId] = bannedGroup{banGroupId: banGroup.BanGroupId, validUntil: banGroup.ValidUntil}
+			}
+		}
+		newMap := map[steamid.GID]bannedGroup{}
+		total := 0
+		for gid, group := range groups {
 			members, errMembers := steamweb.GetGroupMembers(localCtx, gid)
 			if errMembers != nil {
 				log.Warnf("Failed to fetch group members")
-				cancel()
 				continue
 			}
-			newMap[gid] = members
+			group.members = members
+			newMap[gid] = group
 			total += len(members)
+			if errSave := database.SaveBanGroupMembers(localCtx, gid, members); errSave != nil {
+				log.Warnf("Failed to save group members: %v", errSave)
+			}
 		}
 		app.bannedGroupMembersMu.Lock()
 		app.bannedGroupMembers = newMap
 		app.bannedGroupMembersMu.Unlock()
-		cancel()
 		log.WithFields(log.Fields{"count": total}).Debugf("Updated group member ban list")
 	}
 	update()
@@ -149,6 +209,7 @@ func (app *App) serverA2SStatusUpdater(ctx context.Context, database store.Serve
 			go func(server model.Server) {
 				defer waitGroup.Done()
 				newStatus, errA := query.A2SQueryServer(server)
+				app.onHealthCheck(ctx, localDb, server, model.HealthCheckA2S, errA)
 				if errA != nil {
 					log.Tracef("Failed to update a2s status: %v", errA)
 					return
@@ -190,6 +251,7 @@ func (app *App) serverRCONStatusUpdater(ctx context.Context, database store.Serv
 			go func(c context.Context, server model.Server) {
 				defer waitGroup.Done()
 				newStatus, queryErr := query.GetServerStatus(c, server)
+				app.onHealthCheck(c, localDb, server, model.HealthCheckRCON, queryErr)
 				if queryErr != nil {
 					log.Tracef("Failed to query server status: %v", queryErr)
 					return
@@ -217,6 +279,76 @@ func (app *App) serverRCONStatusUpdater(ctx context.Context, database store.Serv
 	}
 }
 
+// a2sPlayers converts the a2s player list, skipping players who are still connecting and have no name yet
+func a2sPlayers(info *a2s.PlayerInfo) []model.ServerStatePlayer {
+	if info == nil {
+		return nil
+	}
+	var players []model.ServerStatePlayer
+	for _, player := range info.Players {
+		if player.Name == "" {
+			continue
+		}
+		players = append(players, model.ServerStatePlayer{
+			Name:          player.Name,
+			Score:         int(player.Score),
+			ConnectedTime: time.Duration(float64(player.Duration) * float64(time.Second)),
+		})
+	}
+	return players
+}
+
+// mergePlayerScores fills in the scores of the rcon status players, which only a2s reports, matching
+// players by name
+func mergePlayerScores(players []model.ServerStatePlayer, a2sPlayers []model.ServerStatePlayer) []model.ServerStatePlayer {
+	used := make([]bool, len(a2sPlayers))
+	for i := range players {
+		for j, a2sPlayer := range a2sPlayers {
+			if !used[j] && a2sPlayer.Name == players[i].Name {
+				players[i].Score = a2sPlayer.Score
+				used[j] = true
+				break
+			}
+		}
+	}
+	return players
+}
+
+// applyA2SState fills in the state from the a2s query responses
+func applyA2SState(state *model.ServerState, a2sResult query.A2SResult) {
+	a2sInfo := a2sResult.Info
+	if a2sInfo.Name != "" {
+		state.Name = a2sInfo.Name
+	}
+	state.NameA2S = a2sInfo.Name
+	state.Protocol = a2sInfo.Protocol
+	state.Map = a2sInfo.Map
+	state.Folder = a2sInfo.Folder
+	state.Game = a2sInfo.Game
+	state.AppId = a2sInfo.ID
+	state.PlayerCount = int(a2sInfo.Players)
+	state.MaxPlayers = int(a2sInfo.MaxPlayers)
+	state.Bots = int(a2sInfo.Bots)
+	state.ServerType = a2sInfo.ServerType.String()
+	state.ServerOS = a2sInfo.ServerOS.String()
+	state.Password = !a2sInfo.Visibility
+	state.VAC = a2sInfo.VAC
+	state.Version = a2sInfo.Version
+	if a2sInfo.SourceTV != nil {
+		state.STVPort = a2sInfo.SourceTV.Port
+		state.STVName = a2sInfo.SourceTV.Name
+	}
+	if a2sInfo.ExtendedServerInfo != nil {
+		state.SteamID = steamid.SID64(a2sInfo.ExtendedServerInfo.SteamID)
+		state.GameID = a2sInfo.ExtendedServerInfo.GameID
+		state.Keywords = strings.Split(a2sInfo.ExtendedServerInfo.Keywords, ",")
+	}
+	if a2sResult.Rules != nil {
+		state.Rules = model.FilterServerRules(a2sResult.Rules.Rules)
+	}
+	state.Players = a2sPlayers(a2sResult.Players)
+}
+
 // serverStateRefresher periodically compiles and caches the current known db, rcon & a2s server state
 // into a ServerState instance
 func (app *App) serverStateRefresher(ctx context.Context, database store.ServerStore, updateFreq time.Duration) {
@@ -243,34 +375,8 @@ func (app *App) serverStateRefresher(ctx context.Context, database store.ServerS
 			state.CountryCode = server.CC
 			state.Latitude = server.Latitude
 			state.Longitude = server.Longitude
-			a2sInfo, a2sFound := app.serverStateA2S[server.ServerNameShort]
-			if a2sFound {
-				if a2sInfo.Name != "" {
-					state.Name = a2sInfo.Name
-				}
-				state.NameA2S = a2sInfo.Name
-				state.Protocol = a2sInfo.Protocol
-				state.Map = a2sInfo.Map
-				state.Folder = a2sInfo.Folder
-				state.Game = a2sInfo.Game
-				state.AppId = a2sInfo.ID
-				state.PlayerCount = int(a2sInfo.Players)
-				state.MaxPlayers = int(a2sInfo.MaxPlayers)
-				state.Bots = int(a2sInfo.Bots)
-				state.ServerType = a2sInfo.ServerType.String()
-				state.ServerOS = a2sInfo.ServerOS.String()
-				state.Password = !a2sInfo.Visibility
-				state.VAC = a2sInfo.VAC
-				state.Version = a2sInfo.Version
-				if a2sInfo.SourceTV != nil {
-					state.STVPort = a2sInfo.SourceTV.Port
-					state.STVName = a2sInfo.SourceTV.Name
-				}
-				if a2sInfo.ExtendedServerInfo != nil {
-					state.SteamID = steamid.SID64(a2sInfo.ExtendedServerInfo.SteamID)
-					state.GameID = a2sInfo.ExtendedServerInfo.GameID
-					state.Keywords = strings.Split(a2sInfo.ExtendedServerInfo.Keywords, ",")
-				}
+			if a2sResult, a2sFound := app.serverStateA2S[server.ServerNameShort]; a2sFound {
+				applyA2SState(&state, a2sResult)
 			}
 			statusInfo, statusFound := app.serverStateStatus[server.ServerNameShort]
 			if statusFound {
@@ -301,7 +407,7 @@ func (app *App) serverStateRefresher(ctx context.Context, database store.ServerS
 					newPlayer.Port = player.Port
 					knownPlayers = append(knownPlayers, newPlayer)
 				}
-				state.Players = knownPlayers
+				state.Players = mergePlayerScores(knownPlayers, state.Players)
 			}
 			newState = append(newState, state)
 		}
@@ -398,81 +504,34 @@ func (app *App) mapChanger(ctx context.Context, database store.ServerStore, time
 	}
 }
 
-// banSweeper periodically will query the database for expired bans and remove them.
-func banSweeper(ctx context.Context, database store.Store) {
+// banSweeper periodically will query the database for expired bans of every kind and remove them.
+func (app *App) banSweeper(ctx context.Context, database store.Store) {
 	log.WithFields(log.Fields{"service": "ban_sweeper", "status": "ready"}).Debugf("Service status changed")
 	ticker := time.NewTicker(time.Minute)
 	for {
 		select {
 		case <-ticker.C:
 			waitGroup := &sync.WaitGroup{}
-			waitGroup.Add(3)
+			waitGroup.Add(5)
 			go func() {
 				defer waitGroup.Done()
-				expiredBans, errExpiredBans := database.GetExpiredBans(ctx)
-				if errExpiredBans != nil && !errors.Is(errExpiredBans, store.ErrNoResult) {
-					log.Warnf("Failed to get expired expiredBans: %v", errExpiredBans)
-				} else {
-					for _, expiredBan := range expiredBans {
-						if errDrop := database.DropBan(ctx, &expiredBan, false); errDrop != nil {
-							log.Errorf("Failed to drop expired expiredBan: %v", errDrop)
-						} else {
-							banType := "Ban"
-							if expiredBan.BanType == model.NoComm {
-								banType = "Mute"
-							}
-							var person model.Person
-							if errPerson := database.GetOrCreatePersonBySteamID(ctx, expiredBan.TargetId, &person); errPerson != nil {
-								log.Errorf("Failed to get expired person: %v", errPerson)
-								continue
-							}
-							name := person.PersonaName
-							if name == "" {
-								name = person.SteamID.String()
-							}
-							fields := log.Fields{
-								"sid":    expiredBan.TargetId,
-								"name":   name,
-								"origin": expiredBan.Origin.String(),
-								"reason": expiredBan.Reason.String(),
-							}
-							if expiredBan.ReasonText != "" {
-								fields["custom"] = expiredBan.ReasonText
-							}
-							log.WithFields(fields).Infof("%s expired", banType)
-						}
-					}
-				}
+				app.expireSteamBans(ctx, database)
 			}()
 			go func() {
 				defer waitGroup.Done()
-				expiredNetBans, errExpiredNetBans := database.GetExpiredNetBans(ctx)
-				if errExpiredNetBans != nil && !errors.Is(errExpiredNetBans, store.ErrNoResult) {
-					log.Warnf("Failed to get expired netbans: %v", errExpiredNetBans)
-				} else {
-					for _, expiredNetBan := range expiredNetBans {
-						if errDropBanNet := database.DropBanNet(ctx, &expiredNetBan); errDropBanNet != nil {
-							log.Errorf("Failed to drop expired network expiredNetBan: %v", errDropBanNet)
-						} else {
-							log.Infof("CIDR ban expired: %v", expiredNetBan)
-						}
-					}
-				}
+				app.expireNetBans(ctx, database)
 			}()
 			go func() {
 				defer waitGroup.Done()
-				expiredASNBans, errExpiredASNBans := database.GetExpiredASNBans(ctx)
-				if errExpiredASNBans != nil && !errors.Is(errExpiredASNBans, store.ErrNoResult) {
-					log.Warnf("Failed to get expired asnbans: %v", errExpiredASNBans)
-				} else {
-					for _, expiredASNBan := range expiredASNBans {
-						if errDropASN := database.DropBanASN(ctx, &expiredASNBan); errDropASN != nil {
-							log.Errorf("Failed to drop expired asn ban: %v", errDropASN)
-						} else {
-							log.Infof("ASN ban expired: %v", expiredASNBan)
-						}
-					}
-				}
+				app.expireASNBans(ctx, database)
+			}()
+			go func() {
+				defer waitGroup.Done()
+				app.expireGroupBans(ctx, database)
+			}()
+			go func() {
+				defer waitGroup.Done()
+				app.expireServerActions(ctx, database)
 			}()
 			waitGroup.Wait()
 		case <-ctx.Done():
@@ -482,6 +541,141 @@ func banSweeper(ctx context.Context, database store.Store) {
 	}
 }
 
+// onBanExpired records the expiry in the ban audit log and announces it to the mod log channel
+func (app *App) onBanExpired(ctx context.Context, database store.BanStore, kind model.BanKind, banId int64,
+	target string, banBase model.BanBase, embed *discordgo.MessageEmbed) {
+	saveBanAudit(ctx, database, kind, banId, target, model.BanAuditExpired, banBase.Reason.String())
+	fields := log.Fields{
+		"kind":   kind,
+		"ban_id": banId,
+		"target": target,
+		"origin": banBase.Origin.String(),
+		"reason": banBase.Reason.String(),
+	}
+	if banBase.ReasonText != "" {
+		fields["custom"] = banBase.ReasonText
+	}
+	log.WithFields(fields).Infof("%s expired", embed.Title)
+	embed.Type = discordgo.EmbedTypeRich
+	embed.Color = int(green)
+	addField(embed, "Reason", banBase.Reason.String())
+	if banBase.ReasonText != "" {
+		addField(embed, "Custom Reason", banBase.ReasonText)
+	}
+	addField(embed, "Created", config.FmtTimeShort(banBase.CreatedOn))
+	embed.Title += " expired"
+	sendDiscordPayload(app.discordSendMsg, discordPayload{
+		channelId: config.Discord.ModLogChannelId,
+		embed:     embed,
+	})
+}
+
+func (app *App) expireSteamBans(ctx context.Context, database store.Store) {
+	expiredBans, errExpiredBans := database.GetExpiredBans(ctx)
+	if errExpiredBans != nil && !errors.Is(errExpiredBans, store.ErrNoResult) {
+		log.Warnf("Failed to get expired expiredBans: %v", errExpiredBans)
+		return
+	}
+	for _, expiredBan := range expiredBans {
+		if errDrop := database.DropBan(ctx, &expiredBan, false); errDrop != nil {
+			log.Errorf("Failed to drop expired expiredBan: %v", errDrop)
+			continue
+		}
+		banType := "Ban"
+		if expiredBan.BanType == model.NoComm {
+			banType = "Mute"
+			if errUngag := app.Ungag(ctx, database, expiredBan.TargetId, "Mute expired"); errUngag != nil {
+				log.Errorf("Failed to ungag expired mute: %v", errUngag)
+			}
+		}
+		var person model.Person
+		if errPerson := database.GetOrCreatePersonBySteamID(ctx, expiredBan.TargetId, &person); errPerson != nil {
+			log.Errorf("Failed to get expired person: %v", errPerson)
+			continue
+		}
+		name := person.PersonaName
+		if name == "" {
+			name = person.SteamID.String()
+		}
+		embed := &discordgo.MessageEmbed{
+			URL:   config.ExtURL("/ban/%d", expiredBan.BanID),
+			Title: fmt.Sprintf("%s of %s", banType, name),
+		}
+		addFieldsSteamID(embed, expiredBan.TargetId)
+		app.onBanExpired(ctx, database, model.BanKindSteam, expiredBan.BanID, expiredBan.TargetId.String(),
+			expiredBan.BanBase, embed)
+	}
+}
+
+func (app *App) expireNetBans(ctx context.Context, database store.Store) {
+	expiredNetBans, errExpiredNetBans := database.GetExpiredNetBans(ctx)
+	if errExpiredNetBans != nil && !errors.Is(errExpiredNetBans, store.ErrNoResult) {
+		log.Warnf("Failed to get expired netbans: %v", errExpiredNetBans)
+		return
+	}
+	for _, expiredNetBan := range expiredNetBans {
+		netId := expiredNetBan.NetID
+		if errDropBanNet := database.DropBanNet(ctx, &expiredNetBan); errDropBanNet != nil {
+			log.Errorf("Failed to drop expired network expiredNetBan: %v", errDropBanNet)
+			continue
+		}
+		embed := &discordgo.MessageEmbed{Title: "CIDR ban"}
+		addField(embed, "CIDR", expiredNetBan.CIDR.String())
+		app.onBanExpired(ctx, database, model.BanKindCIDR, netId, expiredNetBan.CIDR.String(),
+			expiredNetBan.BanBase, embed)
+	}
+}
+
+func (app *App) expireASNBans(ctx context.Context, database store.Store) {
+	expiredASNBans, errExpiredASNBans := database.GetExpiredASNBans(ctx)
+	if errExpiredASNBans != nil && !errors.Is(errExpiredASNBans, store.ErrNoResult) {
+		log.Warnf("Failed to get expired asnbans: %v", errExpiredASNBans)
+		return
+	}
+	for _, expiredASNBan := range expiredASNBans {
+		if errDropASN := database.DropBanASN(ctx, &expiredASNBan); errDropASN != nil {
+			log.Errorf("Failed to drop expired asn ban: %v", errDropASN)
+			continue
+		}
+		asNum := fmt.Sprintf("%d", expiredASNBan.ASNum)
+		embed := &discordgo.MessageEmbed{Title: "ASN ban"}
+		addField(embed, "ASN", asNum)
+		app.onBanExpired(ctx, database, model.BanKindASN, expiredASNBan.BanASNId, asNum, expiredASNBan.BanBase, embed)
+	}
+}
+
+func (app *App) expireGroupBans(ctx context.Context, database store.Store) {
+	expiredGroupBans, errExpiredGroupBans := database.GetExpiredBanGroups(ctx)
+	if errExpiredGroupBans != nil && !errors.Is(errExpiredGroupBans, store.ErrNoResult) {
+		log.Warnf("Failed to get expired group bans: %v", errExpiredGroupBans)
+		return
+	}
+	for _, expiredGroupBan := range expiredGroupBans {
+		if errDropGroup := database.DropBanGroup(ctx, &expiredGroupBan); errDropGroup != nil {
+			log.Errorf("Failed to drop expired group ban: %v", errDropGroup)
+			continue
+		}
+		if !isConfigBannedGroup(expiredGroupBan.GroupId) {
+			app.removeBannedGroup(expiredGroupBan.GroupId)
+		}
+		embed := &discordgo.MessageEmbed{Title: "Steam group ban"}
+		addField(embed, "Group", expiredGroupBan.GroupName)
+		addField(embed, "Group ID", expiredGroupBan.GroupId.String())
+		app.onBanExpired(ctx, database, model.BanKindGroup, expiredGroupBan.BanGroupId, expiredGroupBan.GroupId.String(),
+			expiredGroupBan.BanBase, embed)
+	}
+}
+
+// isConfigBannedGroup checks if the group is permanently banned via the config
+func isConfigBannedGroup(groupId steamid.GID) bool {
+	for _, gid := range config.General.BannedSteamGroupIds {
+		if gid == groupId {
+			return true
+		}
+	}
+	return false
+}
+
 func guessMapType(mapName string) string {
 	mapName = strings.TrimPrefix(mapName, "workshop/")
 	pieces := strings.SplitN(mapName, "_", 2)
@@ -0,0 +1,48 @@
+package app
+
+import (
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/rumblefrog/go-a2s"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestA2SPlayers(t *testing.T) {
+	require.Nil(t, a2sPlayers(nil))
+	players := a2sPlayers(&a2s.PlayerInfo{Count: 3, Players: []*a2s.Player{
+		{Name: "player_a", Score: 10, Duration: 90.5},
+		{Name: "", Score: 0, Duration: 1},
+		{Name: "player_b", Score: 3, Duration: 30},
+	}})
+	require.Equal(t, []model.ServerStatePlayer{
+		{Name: "player_a", Score: 10, ConnectedTime: time.Millisecond * 90500},
+		{Name: "player_b", Score: 3, ConnectedTime: time.Second * 30},
+	}, players)
+
+	merged := mergePlayerScores([]model.ServerStatePlayer{
+		{UserID: 1, Name: "dupe"}, {UserID: 2, Name: "dupe"}, {UserID: 3, Name: "missing"},
+	}, []model.ServerStatePlayer{{Name: "dupe", Score: 5}, {Name: "dupe", Score: 7}})
+	require.Equal(t, 5, merged[0].Score)
+	require.Equal(t, 7, merged[1].Score)
+	require.Equal(t, 0, merged[2].Score)
+}
+
+func TestBannedSteamGroup(t *testing.T) {
+	app := New()
+	member := steamid.SID64(76561198084134025)
+	var banGroup model.BanGroup
+	banGroup.BanGroupId = 42
+	banGroup.GroupId = steamid.GID(103582791429521412)
+	banGroup.ValidUntil = config.Now().Add(time.Hour)
+	app.setBannedGroup(banGroup, steamid.Collection{member})
+	banGroupId, banned := app.bannedSteamGroup(member)
+	require.True(t, banned)
+	require.Equal(t, banGroup.BanGroupId, banGroupId)
+	_, otherBanned := app.bannedSteamGroup(steamid.SID64(76561198084134026))
+	require.False(t, otherBanned)
+	app.removeBannedGroup(banGroup.GroupId)
+	require.False(t, app.IsSteamGroupBanned(member))
+}
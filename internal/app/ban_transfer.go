@@ -0,0 +1,357 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/gbans/internal/store"
+	"github.com/leighmacdonald/gbans/internal/thirdparty"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"time"
+)
+
+// BanFormat is a file format which bans can be imported from, and exported to
+type BanFormat string
+
+const (
+	// BanFormatJSON is the native gbans model.BanArchive format
+	BanFormatJSON BanFormat = "json"
+	// BanFormatSourceBans is a SourceBans++ mysql dump of the sb_bans and sb_comms tables
+	BanFormatSourceBans BanFormat = "sourcebans"
+	// BanFormatValveSID is the srcds banned_user.cfg format
+	BanFormatValveSID BanFormat = "banned_user"
+	// BanFormatValveIP is the srcds banned_ip.cfg format
+	BanFormatValveIP BanFormat = "banned_ip"
+)
+
+// banArchiveVersion is the current version of the model.BanArchive format
+const banArchiveVersion = 1
+
+// permanentDuration is used to detect bans which should be treated as permanent when exporting
+const permanentDuration = time.Hour * 24 * 365 * 5
+
+func ParseBanFormat(format string) (BanFormat, error) {
+	switch BanFormat(format) {
+	case BanFormatJSON, BanFormatSourceBans, BanFormatValveSID, BanFormatValveIP:
+		return BanFormat(format), nil
+	default:
+		return "", errors.Errorf("Unknown ban format: %s", format)
+	}
+}
+
+// ImportBans reads bans in the given format and saves them as new steam and cidr bans. Bans which have
+// already expired or were removed, and players or networks which are already banned, are skipped.
+// Bans read from external formats are recorded with the model.Imported origin, while the native json
+// format preserves the original origin.
+func ImportBans(ctx context.Context, database store.Store, format BanFormat, src []byte) (model.BanImportResult, error) {
+	result := model.BanImportResult{Errors: []string{}}
+	var (
+		steamBans []model.BanSteam
+		cidrBans  []model.BanCIDR
+		now       = config.Now()
+	)
+	switch format {
+	case BanFormatJSON:
+		var archive model.BanArchive
+		if errUnmarshal := json.Unmarshal(src, &archive); errUnmarshal != nil {
+			return result, errors.Wrap(errUnmarshal, "Failed to decode ban archive")
+		}
+		for _, banSteam := range archive.Steam {
+			banSteam.BanID = 0
+			banSteam.ReportId = 0
+			steamBans = append(steamBans, banSteam)
+		}
+		for _, banCIDR := range archive.CIDR {
+			banCIDR.NetID = 0
+			cidrBans = append(cidrBans, banCIDR)
+		}
+	case BanFormatSourceBans:
+		bans, errParse := thirdparty.ParseSourceBansSQL(src)
+		if errParse != nil {
+			return result, errors.Wrap(errParse, "Failed to parse sourcebans dump")
+		}
+		for _, sbBan := range bans {
+			validUntil := sbBan.Ends
+			if sbBan.Permanent() {
+				validUntil = now.Add(time.Hour * 24 * 365 * 10)
+			}
+			banType := model.Banned
+			if sbBan.Comm {
+				banType = model.NoComm
+			}
+			base := importedBanBase(banType, sbBan.Reason, sbBan.Created, validUntil)
+			base.Deleted = sbBan.Removed
+			base.UnbanReasonText = sbBan.UnbanReason
+			// Type 1 bans are ip bans, except for comm blocks
+			if !sbBan.Comm && sbBan.Type == 1 {
+				if sbBan.IP == nil {
+					result.Errors = append(result.Errors, fmt.Sprintf("sourcebans ban %d: missing ip", sbBan.BanId))
+					continue
+				}
+				cidrBans = append(cidrBans, model.BanCIDR{BanBase: base, CIDR: singleHostNet(sbBan.IP)})
+				continue
+			}
+			base.TargetId = sbBan.SteamId
+			steamBans = append(steamBans, model.BanSteam{BanBase: base})
+		}
+	case BanFormatValveSID, BanFormatValveIP:
+		bans, errParse := thirdparty.ParseValveBans(src)
+		if errParse != nil {
+			return result, errors.Wrap(errParse, "Failed to parse valve ban list")
+		}
+		for _, valveBan := range bans {
+			validUntil := now.Add(time.Hour * 24 * 365 * 10)
+			if valveBan.Minutes > 0 {
+				validUntil = now.Add(time.Minute * time.Duration(valveBan.Minutes))
+			}
+			base := importedBanBase(model.Banned, "", now, validUntil)
+			if valveBan.IP != nil {
+				cidrBans = append(cidrBans, model.BanCIDR{BanBase: base, CIDR: singleHostNet(valveBan.IP)})
+				continue
+			}
+			base.TargetId = valveBan.SteamId
+			steamBans = append(steamBans, model.BanSteam{BanBase: base})
+		}
+	default:
+		return result, errors.Errorf("Unknown ban format: %s", format)
+	}
+	for _, banSteam := range steamBans {
+		if banSteam.Deleted || !banSteam.ValidUntil.After(now) || !banSteam.TargetId.Valid() {
+			result.Skipped++
+			continue
+		}
+		if !banSteam.SourceId.Valid() {
+			banSteam.SourceId = config.General.Owner
+		}
+		existing := model.NewBannedPerson()
+		if errExisting := database.GetBanBySteamID(ctx, banSteam.TargetId, &existing, false); errExisting == nil {
+			result.Skipped++
+			continue
+		} else if !errors.Is(errExisting, store.ErrNoResult) {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", banSteam.TargetId.String(), errExisting))
+			continue
+		}
+		// Imported players are usually unknown to us, the ban target must exist in the person table
+		target := model.NewPerson(banSteam.TargetId)
+		if errPerson := database.GetOrCreatePersonBySteamID(ctx, banSteam.TargetId, &target); errPerson != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", banSteam.TargetId.String(), errPerson))
+			continue
+		}
+		source := model.NewPerson(banSteam.SourceId)
+		if errPerson := database.GetOrCreatePersonBySteamID(ctx, banSteam.SourceId, &source); errPerson != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", banSteam.SourceId.String(), errPerson))
+			continue
+		}
+		if errSave := database.SaveBan(ctx, &banSteam); errSave != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", banSteam.TargetId.String(), errSave))
+			continue
+		}
+		result.Steam++
+	}
+	for _, banCIDR := range cidrBans {
+		if banCIDR.Deleted || !banCIDR.ValidUntil.After(now) || banCIDR.CIDR == nil {
+			result.Skipped++
+			continue
+		}
+		existing, errExisting := database.GetBanNetByAddress(ctx, banCIDR.CIDR.IP)
+		if errExisting != nil && !errors.Is(errExisting, store.ErrNoResult) {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", banCIDR.CIDR.String(), errExisting))
+			continue
+		}
+		if containsNetwork(existing, banCIDR.CIDR) {
+			result.Skipped++
+			continue
+		}
+		if !banCIDR.SourceId.Valid() {
+			banCIDR.SourceId = config.General.Owner
+		}
+		if errSave := database.SaveBanNet(ctx, &banCIDR); errSave != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", banCIDR.CIDR.String(), errSave))
+			continue
+		}
+		result.CIDR++
+	}
+	log.WithFields(log.Fields{"format": format, "steam": result.Steam, "cidr": result.CIDR,
+		"skipped": result.Skipped, "errors": len(result.Errors)}).Infof("Imported bans")
+	return result, nil
+}
+
+// ExportBans writes all active bans in the given format. Formats which cannot represent network
+// bans only include cidr bans covering a single address.
+func ExportBans(ctx context.Context, database store.Store, format BanFormat, writer io.Writer) error {
+	bannedPeople, errSteam := database.GetBansSteam(ctx, store.BansQueryFilter{})
+	if errSteam != nil && !errors.Is(errSteam, store.ErrNoResult) {
+		return errors.Wrap(errSteam, "Failed to get steam bans")
+	}
+	netBans, errNet := database.GetBansNet(ctx)
+	if errNet != nil && !errors.Is(errNet, store.ErrNoResult) {
+		return errors.Wrap(errNet, "Failed to get cidr bans")
+	}
+	now := config.Now()
+	var steamBans []model.BanSteam
+	for _, bannedPerson := range bannedPeople {
+		if bannedPerson.Ban.IsEnabled && bannedPerson.Ban.ValidUntil.After(now) {
+			steamBans = append(steamBans, bannedPerson.Ban)
+		}
+	}
+	var cidrBans []model.BanCIDR
+	for _, banCIDR := range netBans {
+		if !banCIDR.Deleted && banCIDR.IsEnabled && banCIDR.ValidUntil.After(now) && banCIDR.CIDR != nil {
+			cidrBans = append(cidrBans, banCIDR)
+		}
+	}
+	switch format {
+	case BanFormatJSON:
+		archive := model.BanArchive{
+			Version:    banArchiveVersion,
+			ExportedOn: now,
+			Steam:      steamBans,
+			CIDR:       cidrBans,
+		}
+		if archive.Steam == nil {
+			archive.Steam = []model.BanSteam{}
+		}
+		if archive.CIDR == nil {
+			archive.CIDR = []model.BanCIDR{}
+		}
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(archive)
+	case BanFormatSourceBans:
+		var bans []thirdparty.SourceBansBan
+		for _, banSteam := range steamBans {
+			bans = append(bans, thirdparty.SourceBansBan{
+				Comm:    banSteam.BanType == model.NoComm,
+				Type:    sourceBansType(banSteam.BanType == model.NoComm),
+				SteamId: banSteam.TargetId,
+				Reason:  exportReason(banSteam.BanBase),
+				Created: banSteam.CreatedOn,
+				Ends:    banSteam.ValidUntil,
+				Length:  exportLength(banSteam.BanBase),
+			})
+		}
+		for _, banCIDR := range cidrBans {
+			addr, isHost := singleHost(banCIDR.CIDR)
+			if !isHost {
+				continue
+			}
+			bans = append(bans, thirdparty.SourceBansBan{
+				Type:    1,
+				IP:      addr,
+				Reason:  exportReason(banCIDR.BanBase),
+				Created: banCIDR.CreatedOn,
+				Ends:    banCIDR.ValidUntil,
+				Length:  exportLength(banCIDR.BanBase),
+			})
+		}
+		return thirdparty.WriteSourceBansSQL(writer, bans)
+	case BanFormatValveSID:
+		var bans []thirdparty.ValveBan
+		for _, banSteam := range steamBans {
+			if banSteam.BanType != model.Banned {
+				continue
+			}
+			bans = append(bans, thirdparty.ValveBan{
+				SteamId: banSteam.TargetId,
+				Minutes: valveMinutes(banSteam.BanBase),
+			})
+		}
+		return thirdparty.WriteValveBans(writer, bans)
+	case BanFormatValveIP:
+		var bans []thirdparty.ValveBan
+		for _, banCIDR := range cidrBans {
+			addr, isHost := singleHost(banCIDR.CIDR)
+			if !isHost {
+				continue
+			}
+			bans = append(bans, thirdparty.ValveBan{
+				IP:      addr,
+				Minutes: valveMinutes(banCIDR.BanBase),
+			})
+		}
+		return thirdparty.WriteValveBans(writer, bans)
+	default:
+		return errors.Errorf("Unknown ban format: %s", format)
+	}
+}
+
+func importedBanBase(banType model.BanType, reasonText string, createdOn time.Time, validUntil time.Time) model.BanBase {
+	reason := model.Custom
+	if reasonText == "" {
+		reasonText = "Imported"
+	}
+	return model.BanBase{
+		SourceId:   config.General.Owner,
+		BanType:    banType,
+		Reason:     reason,
+		ReasonText: reasonText,
+		Origin:     model.Imported,
+		IsEnabled:  true,
+		ValidUntil: validUntil,
+		CreatedOn:  createdOn,
+		UpdatedOn:  createdOn,
+	}
+}
+
+// exportLength returns the total length of the ban, with 0 denoting a permanent ban
+func exportLength(ban model.BanBase) time.Duration {
+	if ban.ValidUntil.Sub(config.Now()) > permanentDuration {
+		return 0
+	}
+	return ban.ValidUntil.Sub(ban.CreatedOn).Round(time.Minute)
+}
+
+// valveMinutes returns the remaining minutes of the ban, since srcds applies the duration from when
+// the ban list is loaded
+func valveMinutes(ban model.BanBase) int {
+	if exportLength(ban) == 0 {
+		return 0
+	}
+	minutes := int(ban.ValidUntil.Sub(config.Now()) / time.Minute)
+	if minutes < 1 {
+		return 1
+	}
+	return minutes
+}
+
+func exportReason(ban model.BanBase) string {
+	if ban.Reason == model.Custom && ban.ReasonText != "" {
+		return ban.ReasonText
+	}
+	return ban.Reason.String()
+}
+
+func sourceBansType(isComm bool) int {
+	if isComm {
+		// Silence, blocking both voice and chat
+		return 3
+	}
+	return 0
+}
+
+func singleHostNet(addr net.IP) *net.IPNet {
+	if ipv4 := addr.To4(); ipv4 != nil {
+		return &net.IPNet{IP: ipv4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: addr, Mask: net.CIDRMask(128, 128)}
+}
+
+// singleHost returns the address of the network if it only covers a single host
+func singleHost(network *net.IPNet) (net.IP, bool) {
+	ones, bits := network.Mask.Size()
+	return network.IP, ones == bits
+}
+
+func containsNetwork(bans []model.BanCIDR, network *net.IPNet) bool {
+	for _, ban := range bans {
+		if ban.CIDR != nil && ban.CIDR.String() == network.String() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/leighmacdonald/gbans/internal/event"
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/gbans/internal/store"
+	"github.com/leighmacdonald/gbans/pkg/fp"
+	"github.com/leighmacdonald/gbans/pkg/logparse"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// maxRelayMessageLen limits the length of messages relayed into the game, which has its own chat limits
+const maxRelayMessageLen = 200
+
+var discordEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "~", `\~`, "`", "\\`", "|", `\|`, ">", `\>`,
+	// Stop players from pinging roles, users and everyone
+	"@", "@\u200b",
+)
+
+// rconEscaper removes characters which could be used to break out of the sm_say command
+var rconEscaper = strings.NewReplacer(";", "", "\"", "'", "\n", " ", "\r", "")
+
+// maskFilteredWords replaces the words of the message matching any of the word filters with asterisks
+func maskFilteredWords(message string) string {
+	words := strings.Split(message, " ")
+	wordFiltersMu.RLock()
+	defer wordFiltersMu.RUnlock()
+	for wordIdx, word := range words {
+		if word == "" {
+			continue
+		}
+		normalised := model.Normalise(word)
+		for idx := range wordFilters {
+			if _, _, matched := wordFilters[idx].MatchNormalised(normalised, config.Filter.Normalise); matched {
+				words[wordIdx] = strings.Repeat("*", len([]rune(word)))
+				break
+			}
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// formatRelayMessage formats an in-game chat message for discord
+func formatRelayMessage(serverEvent model.ServerEvent, message string) string {
+	name := ""
+	if serverEvent.Source.PlayerSummary != nil {
+		name = serverEvent.Source.PersonaName
+	}
+	if name == "" {
+		name = serverEvent.Source.SteamID.String()
+	}
+	prefix := ""
+	if serverEvent.EventType == logparse.SayTeam {
+		prefix = "(TEAM) "
+	}
+	return fmt.Sprintf("%s**%s**: %s", prefix, discordEscaper.Replace(name),
+		discordEscaper.Replace(maskFilteredWords(message)))
+}
+
+// chatRelayServer returns the server short name that is relayed to the discord channel
+func chatRelayServer(channelId string) (string, bool) {
+	for serverName, relayChannelId := range config.Discord.ChatRelayChannels {
+		if relayChannelId == channelId {
+			return serverName, true
+		}
+	}
+	return "", false
+}
+
+// relayServerName resolves the server short name used as a chat_relay_channels key, which is lowercased when the
+// config is loaded, to the short name of the server
+func relayServerName(ctx context.Context, database store.ServerStore, configName string) (string, error) {
+	servers, errServers := database.GetServers(ctx, false)
+	if errServers != nil {
+		return "", errServers
+	}
+	for _, server := range servers {
+		if strings.EqualFold(server.ServerNameShort, configName) {
+			return server.ServerNameShort, nil
+		}
+	}
+	return "", store.ErrNoResult
+}
+
+// chatRelay mirrors in-game chat into the discord channels mapped to each server
+func (app *App) chatRelay(ctx context.Context) {
+	eventChan := make(chan model.ServerEvent)
+	if errRegister := event.Consume(eventChan, []logparse.EventType{logparse.Say, logparse.SayTeam}); errRegister != nil {
+		log.Errorf("Failed to register chat relay event reader: %v", errRegister)
+		return
+	}
+	log.WithFields(log.Fields{"service": "chat_relay", "status": "ready"}).Debugf("Service status changed")
+	for {
+		select {
+		case serverEvent := <-eventChan:
+			// Messages from the console, including the ones we relay into the server, have no steam id
+			if !serverEvent.Source.SteamID.Valid() {
+				continue
+			}
+			channelId, found := config.Discord.ChatRelayChannels[strings.ToLower(serverEvent.Server.ServerNameShort)]
+			if !found {
+				continue
+			}
+			msg, msgFound := serverEvent.MetaData["msg"].(string)
+			if !msgFound || msg == "" {
+				continue
+			}
+			select {
+			case app.discordSendMsg <- discordPayload{channelId: channelId, message: formatRelayMessage(serverEvent, msg)}:
+			default:
+				log.Warnf("Cannot send discord payload, channel full")
+			}
+		case <-ctx.Done():
+			log.Debugf("chatRelay shutting down")
+			return
+		}
+	}
+}
+
+// onMessageCreate relays messages sent by moderators in a chat relay channel into the mapped server
+func (bot *Discord) onMessageCreate(_ *discordgo.Session, message *discordgo.MessageCreate) {
+	if !config.Discord.ChatRelayEnabled || message.Author == nil || message.Author.Bot || message.Member == nil {
+		return
+	}
+	serverName, found := chatRelayServer(message.ChannelID)
+	if !found {
+		return
+	}
+	isMod := false
+	for _, roleId := range message.Member.Roles {
+		if fp.Contains(config.Discord.ModRoleIDs, roleId) {
+			isMod = true
+			break
+		}
+	}
+	if !isMod {
+		return
+	}
+	ctx, cancel := context.WithTimeout(bot.ctx, time.Second*10)
+	defer cancel()
+	if errRelay := bot.relayToServer(ctx, message.Author.ID, serverName, message.Content); errRelay != nil {
+		log.WithFields(log.Fields{"server": serverName, "discord_id": message.Author.ID}).
+			Errorf("Failed to relay discord message: %v", errRelay)
+	}
+}
+
+func (bot *Discord) relayToServer(ctx context.Context, discordId string, serverName string, content string) error {
+	content = strings.TrimSpace(rconEscaper.Replace(content))
+	if content == "" {
+		return nil
+	}
+	var author model.Person
+	if errPerson := bot.database.GetPersonByDiscordID(ctx, discordId, &author); errPerson != nil {
+		if errors.Is(errPerson, store.ErrNoResult) {
+			return errors.New("Discord user has no linked steam id")
+		}
+		return errPerson
+	}
+	name := ""
+	if author.PlayerSummary != nil {
+		name = rconEscaper.Replace(author.PersonaName)
+	}
+	if name == "" {
+		name = author.SteamID.String()
+	}
+	msg := fmt.Sprintf("[Discord] %s: %s", name, content)
+	if runes := []rune(msg); len(runes) > maxRelayMessageLen {
+		msg = string(runes[:maxRelayMessageLen])
+	}
+	nameShort, errServer := relayServerName(ctx, bot.database, serverName)
+	if errServer != nil {
+		return errServer
+	}
+	return bot.app.Say(ctx, bot.database, author.SteamID, nameShort, msg)
+}
@@ -0,0 +1,22 @@
+package app
+
+import (
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/gbans/pkg/logparse"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFormatRelayMessage(t *testing.T) {
+	importFilteredWords([]model.Filter{model.NewFilter("test", model.WordFiltersFromString("^badword$"))})
+	defer importFilteredWords(nil)
+	serverEvent := model.ServerEvent{
+		EventType: logparse.SayTeam,
+		Source:    model.NewPerson(steamid.SID64(76561198084134025)),
+	}
+	serverEvent.Source.PersonaName = "*bold*"
+	require.Equal(t, "(TEAM) **\\*bold\\***: you \\*\\*\\*\\*\\*\\*\\* @\u200beveryone",
+		formatRelayMessage(serverEvent, "you badword @everyone"))
+	require.Equal(t, "say 'hi' quit", rconEscaper.Replace("say \"hi\"; quit"))
+}
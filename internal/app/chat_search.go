@@ -0,0 +1,89 @@
+package app
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// ChatExportFormat defines the file formats chat search results can be exported as
+type ChatExportFormat string
+
+const (
+	ChatExportJSON ChatExportFormat = "json"
+	// ChatExportCSV flattens the hits and their context into rows, grouped by the hit column
+	ChatExportCSV ChatExportFormat = "csv"
+)
+
+func ParseChatExportFormat(format string) (ChatExportFormat, error) {
+	switch ChatExportFormat(format) {
+	case ChatExportJSON, ChatExportCSV:
+		return ChatExportFormat(format), nil
+	default:
+		return "", errors.Errorf("Unknown chat export format: %s", format)
+	}
+}
+
+// ExportChatSearch writes the search hits, including their context messages, in the requested format
+func ExportChatSearch(hits []model.ChatSearchHit, format ChatExportFormat, writer io.Writer) error {
+	switch format {
+	case ChatExportJSON:
+		encoder := json.NewEncoder(writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(hits)
+	case ChatExportCSV:
+		csvWriter := csv.NewWriter(writer)
+		if errHeader := csvWriter.Write([]string{"hit", "match", "person_message_id", "created_on", "server",
+			"steam_id", "persona_name", "team", "body"}); errHeader != nil {
+			return errHeader
+		}
+		for hitIdx, hit := range hits {
+			var rows [][]string
+			for _, message := range hit.Before {
+				rows = append(rows, chatExportRow(hitIdx+1, false, message))
+			}
+			rows = append(rows, chatExportRow(hitIdx+1, true, hit.Message))
+			for _, message := range hit.After {
+				rows = append(rows, chatExportRow(hitIdx+1, false, message))
+			}
+			if errWrite := csvWriter.WriteAll(rows); errWrite != nil {
+				return errWrite
+			}
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	default:
+		return errors.Errorf("Unknown chat export format: %s", format)
+	}
+}
+
+// csvFormulaPrefixes are the leading characters that cause spreadsheet applications to evaluate a cell
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// csvSafe prefixes user supplied values that would otherwise be evaluated as a formula when the export is
+// opened in a spreadsheet application
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune(csvFormulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func chatExportRow(hit int, match bool, message model.PersonMessage) []string {
+	return []string{
+		fmt.Sprintf("%d", hit),
+		fmt.Sprintf("%v", match),
+		fmt.Sprintf("%d", message.PersonMessageId),
+		message.CreatedOn.UTC().Format(time.RFC3339),
+		csvSafe(message.ServerName),
+		message.SteamId.String(),
+		csvSafe(message.PersonaName),
+		fmt.Sprintf("%v", message.Team),
+		csvSafe(message.Body),
+	}
+}
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"bytes"
+	"encoding/csv"
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestExportChatSearch(t *testing.T) {
+	t0 := time.Date(2022, 11, 1, 12, 0, 0, 0, time.UTC)
+	newMessage := func(id int64, body string) model.PersonMessage {
+		return model.PersonMessage{PersonMessageId: id, SteamId: steamid.SID64(76561198084134025),
+			PersonaName: "test", ServerName: "test-1", Body: body, CreatedOn: t0.Add(time.Duration(id) * time.Second)}
+	}
+	hits := []model.ChatSearchHit{{
+		Message: newMessage(2, "hit, with \"quotes\""),
+		Before:  model.PersonMessages{newMessage(1, "before")},
+		After:   model.PersonMessages{newMessage(3, "after")},
+	}}
+	var buf bytes.Buffer
+	require.NoError(t, ExportChatSearch(hits, ChatExportCSV, &buf))
+	rows, errRead := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, errRead)
+	require.Len(t, rows, 4)
+	require.Equal(t, []string{"1", "false", "1", "2022-11-01T12:00:01Z", "test-1", "76561198084134025", "test",
+		"false", "before"}, rows[1])
+	require.Equal(t, "true", rows[2][1])
+	require.Equal(t, "hit, with \"quotes\"", rows[2][8])
+
+	buf.Reset()
+	formula := []model.ChatSearchHit{{Message: newMessage(4, "=HYPERLINK(\"http://example.com\")")}}
+	formula[0].Message.PersonaName = "@SUM(A1)"
+	require.NoError(t, ExportChatSearch(formula, ChatExportCSV, &buf))
+	formulaRows, errFormula := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, errFormula)
+	require.Equal(t, "'@SUM(A1)", formulaRows[1][6])
+	require.Equal(t, "'=HYPERLINK(\"http://example.com\")", formulaRows[1][8])
+	require.Equal(t, "'-1", csvSafe("-1"))
+	require.Equal(t, "'\tcmd", csvSafe("\tcmd"))
+	require.Equal(t, "safe", csvSafe("safe"))
+
+	buf.Reset()
+	require.NoError(t, ExportChatSearch(hits, ChatExportJSON, &buf))
+	require.Contains(t, buf.String(), `"before"`)
+
+	_, errFormat := ParseChatExportFormat("xml")
+	require.Error(t, errFormat)
+}
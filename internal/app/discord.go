@@ -39,6 +39,18 @@ func (bot *Discord) SendEmbed(channelId string, message *discordgo.MessageEmbed)
 	return nil
 }
 
+// SendDirectEmbed sends the embed as a direct message to the discord user
+func (bot *Discord) SendDirectEmbed(userId string, message *discordgo.MessageEmbed) error {
+	if bot.session == nil {
+		return nil
+	}
+	channel, errChannel := bot.session.UserChannelCreate(userId)
+	if errChannel != nil {
+		return errors.Wrap(errChannel, "Failed to open direct message channel")
+	}
+	return bot.SendEmbed(channel.ID, message)
+}
+
 // Discord implements the ChatBot interface for the discord chat platform.
 type Discord struct {
 	session            *discordgo.Session
@@ -82,6 +94,7 @@ func NewDiscord(ctx context.Context, app *App, database store.Store) (*Discord,
 		cmdHistory:  bot.onHistory,
 		cmdFilter:   bot.onFilter,
 		cmdLog:      bot.onLog,
+		cmdWarnings: bot.onWarnings,
 		//cmdStats:    bot.onStats,
 	}
 	return &bot, nil
@@ -105,6 +118,11 @@ func (bot *Discord) Start(ctx context.Context, token string) error {
 	session.AddHandler(bot.onDisconnect)
 	session.AddHandler(bot.onInteractionCreate)
 	session.Identify.Intents = discordgo.MakeIntent(discordgo.IntentsGuildMessages)
+	if config.Discord.ChatRelayEnabled {
+		session.AddHandler(bot.onMessageCreate)
+		// Reading the content of messages is a privileged intent which must also be enabled for the bot
+		session.Identify.Intents |= discordgo.IntentMessageContent
+	}
 
 	bot.session = session
 
@@ -323,6 +341,17 @@ func addFieldsSteamID(embed *discordgo.MessageEmbed, steamId steamid.SID64) {
 func addFieldFilter(embed *discordgo.MessageEmbed, filter model.Filter) {
 	addFieldInline(embed, "Patterns", filter.Patterns.String())
 	addFieldInline(embed, "ID", fmt.Sprintf("%d", filter.WordID))
+	addFieldInline(embed, "Action", string(filter.Action))
+	addFieldInline(embed, "Weight", fmt.Sprintf("%d", filter.Weight))
+	if filter.Action == model.FilterActionGag || filter.Action == model.FilterActionBan {
+		addFieldInline(embed, "Reason", filter.BanReason.String())
+		duration := filter.Duration
+		if duration == "" {
+			duration = config.General.WarningExceededDurationValue
+		}
+		addFieldInline(embed, "Duration", duration)
+	}
+	addFieldInline(embed, "Ping", fmt.Sprintf("%v", filter.PingDiscord))
 }
 
 // ChatBot defines a interface for communication with 3rd party service bots
@@ -40,6 +40,7 @@ const (
 	cmdHistoryChat botCmd = "chat"
 	cmdFilter      botCmd = "filter"
 	cmdLog         botCmd = "log"
+	cmdWarnings    botCmd = "warnings"
 )
 
 //type subCommandKey string
@@ -105,6 +106,13 @@ func (bot *Discord) botRegisterSlashCommands() error {
 		Description: "Duration [s,m,h,d,w,M,y]N|0",
 		Required:    true,
 	}
+	// Player bans and mutes fall back to the ban escalation policy of the reason when no duration is given
+	optEscalatedDuration := &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        OptDuration,
+		Description: "Duration [s,m,h,d,w,M,y]N|0, defaults to the escalation policy of the reason",
+		Required:    false,
+	}
 	optAsn := &discordgo.ApplicationCommandOption{
 		Type:        discordgo.ApplicationCommandOptionString,
 		Name:        OptASN,
@@ -132,6 +140,14 @@ func (bot *Discord) botRegisterSlashCommands() error {
 			Value: r,
 		})
 	}
+	var filterActions []*discordgo.ApplicationCommandOptionChoice
+	for _, action := range []model.FilterAction{model.FilterActionWarn, model.FilterActionGag,
+		model.FilterActionKick, model.FilterActionBan} {
+		filterActions = append(filterActions, &discordgo.ApplicationCommandOptionChoice{
+			Name:  string(action),
+			Value: action,
+		})
+	}
 	enabledDefault := true
 	optBanReason := &discordgo.ApplicationCommandOption{
 		Type:        discordgo.ApplicationCommandOptionInteger,
@@ -160,7 +176,6 @@ func (bot *Discord) botRegisterSlashCommands() error {
 			Description: "Mute a player",
 			Options: []*discordgo.ApplicationCommandOption{
 				optUserID,
-				optDuration,
 				optBanReason,
 				{
 					Type:        discordgo.ApplicationCommandOptionString,
@@ -168,14 +183,20 @@ func (bot *Discord) botRegisterSlashCommands() error {
 					Description: "Mod only notes for the mute reason",
 					Required:    true,
 				},
+				optEscalatedDuration,
 			},
 		},
 		{
 			ApplicationID: config.Discord.AppID,
 			Name:          string(cmdCheck),
-			Description:   "Get ban status for a steam id",
+			Description:   "Get ban status for a steam id, ip address or player name",
 			Options: []*discordgo.ApplicationCommandOption{
-				optUserID,
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        OptUserIdentifier,
+					Description: "SteamID in any format, profile url, ip address or player name",
+					Required:    true,
+				},
 			},
 		},
 		{
@@ -284,7 +305,6 @@ func (bot *Discord) botRegisterSlashCommands() error {
 					Type:        discordgo.ApplicationCommandOptionSubCommand,
 					Options: []*discordgo.ApplicationCommandOption{
 						optUserID,
-						optDuration,
 						optBanReason,
 						{
 							Type:        discordgo.ApplicationCommandOptionString,
@@ -292,6 +312,7 @@ func (bot *Discord) botRegisterSlashCommands() error {
 							Description: "Mod only notes for the ban reason",
 							Required:    true,
 						},
+						optEscalatedDuration,
 					},
 				},
 				{
@@ -394,10 +415,48 @@ func (bot *Discord) botRegisterSlashCommands() error {
 					Options: []*discordgo.ApplicationCommandOption{
 						{
 							Type:        discordgo.ApplicationCommandOptionString,
-							Name:        "filter",
+							Name:        "pattern",
 							Description: "Regular expression for matching word(s)",
 							Required:    true,
 						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "filter_name",
+							Description: "Name of the filter to add the pattern to",
+							Required:    true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        "action",
+							Description: "Action taken when the filter is matched",
+							Required:    false,
+							Choices:     filterActions,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        "weight",
+							Description: "Number of warnings a match counts as",
+							Required:    false,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        OptBanReason,
+							Description: "Reason for the gag/ban",
+							Required:    false,
+							Choices:     reasons,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        OptDuration,
+							Description: "Duration of the gag/ban [s,m,h,d,w,M,y]N|0",
+							Required:    false,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Name:        "ping_discord",
+							Description: "Alert the moderators when the filter is matched",
+							Required:    false,
+						},
 					},
 				},
 				{
@@ -428,6 +487,50 @@ func (bot *Discord) botRegisterSlashCommands() error {
 				},
 			},
 		},
+		{
+			ApplicationID: config.Discord.AppID,
+			Name:          string(cmdWarnings),
+			Description:   "Manage player warnings",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Name:        "list",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Description: "List the warnings issued to a player",
+					Options: []*discordgo.ApplicationCommandOption{
+						optUserID,
+					},
+				},
+				{
+					Name:        "clear",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Description: "Remove all warnings issued to a player",
+					Options: []*discordgo.ApplicationCommandOption{
+						optUserID,
+					},
+				},
+				{
+					Name:        "add",
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Description: "Manually issue a warning to a player",
+					Options: []*discordgo.ApplicationCommandOption{
+						optUserID,
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        OptBanReason,
+							Description: "Reason for the warning",
+							Required:    true,
+							Choices:     reasons,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        OptMessage,
+							Description: "Message or note describing the warning",
+							Required:    false,
+						},
+					},
+				},
+			},
+		},
 	}
 	var modPerms []*discordgo.ApplicationCommandPermissions
 	for _, roleId := range config.Discord.ModRoleIDs {
@@ -146,7 +146,7 @@ func (bot *Discord) onMute(ctx context.Context, _ *discordgo.Session, interactio
 		return errors.New("Invalid mute reason")
 	}
 	reason = model.Reason(reasonValueOpt.IntValue())
-	duration := model.Duration(opts[OptDuration].StringValue())
+	duration, escalate := discordBanDuration(opts)
 	modNote := opts[OptNote].StringValue()
 	author := model.NewPerson(0)
 	if errGetAuthor := bot.database.GetPersonByDiscordID(ctx, interaction.Interaction.Member.User.ID, &author); errGetAuthor != nil {
@@ -170,11 +170,20 @@ func (bot *Discord) onMute(ctx context.Context, _ *discordgo.Session, interactio
 	); errOpts != nil {
 		return errors.Wrapf(errOpts, "Failed to parse options")
 	}
+	var escalation model.BanEscalation
+	if escalate {
+		if errEscalation := discordApplyEscalation(ctx, bot.database, &banSteam, &escalation); errEscalation != nil {
+			return errEscalation
+		}
+	}
 	if errBan := bot.app.BanSteam(ctx, bot.database, &banSteam, bot.botSendMessageChan); errBan != nil {
 		return errBan
 	}
 	response := respOk(r, "Player muted successfully")
 	addFieldsSteamID(response, banSteam.TargetId)
+	if escalate {
+		addFieldEscalation(response, escalation)
+	}
 	return nil
 }
 
@@ -293,7 +302,7 @@ func (bot *Discord) onBanSteam(ctx context.Context, _ *discordgo.Session,
 	target := opts[OptUserIdentifier].StringValue()
 	reason := model.Reason(opts[OptBanReason].IntValue())
 	modNote := opts[OptNote].StringValue()
-	duration := model.Duration(opts[OptDuration].StringValue())
+	duration, escalate := discordBanDuration(opts)
 	author := model.NewPerson(0)
 	if errGetAuthor := bot.database.GetPersonByDiscordID(ctx, interaction.Interaction.Member.User.ID, &author); errGetAuthor != nil {
 		if errGetAuthor == store.ErrNoResult {
@@ -316,6 +325,12 @@ func (bot *Discord) onBanSteam(ctx context.Context, _ *discordgo.Session,
 	); errOpts != nil {
 		return errors.Wrapf(errOpts, "Failed to parse options")
 	}
+	var escalation model.BanEscalation
+	if escalate {
+		if errEscalation := discordApplyEscalation(ctx, bot.database, &banSteam, &escalation); errEscalation != nil {
+			return errEscalation
+		}
+	}
 	if errBan := bot.app.BanSteam(ctx, bot.database, &banSteam, bot.botSendMessageChan); errBan != nil {
 		if errors.Is(errBan, store.ErrDuplicate) {
 			return errors.New("Duplicate ban")
@@ -323,10 +338,43 @@ func (bot *Discord) onBanSteam(ctx context.Context, _ *discordgo.Session,
 		log.Errorf("Failed to execute ban: %v", errBan)
 		return errCommandFailed
 	}
-	createDiscordBanEmbed(banSteam, response)
+	embed := createDiscordBanEmbed(banSteam, response)
+	if escalate {
+		addFieldEscalation(embed, escalation)
+	}
+	return nil
+}
+
+// discordBanDuration returns the requested ban duration, or the permanent placeholder and true when no
+// duration was given and the ban escalation policy should decide instead
+func discordBanDuration(opts CommandOptions) (model.Duration, bool) {
+	duration := opts.String(OptDuration)
+	if duration == "" {
+		return "0", true
+	}
+	return model.Duration(duration), false
+}
+
+// discordApplyEscalation applies the ban escalation policy to bans created without a duration. A duration
+// is required when no policy exists for the reason.
+func discordApplyEscalation(ctx context.Context, database store.BanStore, banSteam *model.BanSteam,
+	escalation *model.BanEscalation) error {
+	escalated, errEscalation := ApplyBanEscalation(ctx, database, banSteam, escalation)
+	if errEscalation != nil {
+		log.Errorf("Failed to calculate ban escalation: %v", errEscalation)
+		return errCommandFailed
+	}
+	if !escalated {
+		return errors.New("Duration is required, no escalation policy exists for the reason")
+	}
 	return nil
 }
 
+func addFieldEscalation(embed *discordgo.MessageEmbed, escalation model.BanEscalation) {
+	addField(embed, "Escalation", fmt.Sprintf("Offence #%d for %s (%s)",
+		escalation.PriorOffences+1, escalation.Reason.String(), escalation.Duration))
+}
+
 func createDiscordBanEmbed(ban model.BanSteam, response *botResponse) *discordgo.MessageEmbed {
 	embed := respOk(response, "User Banned")
 	embed.Title = fmt.Sprintf("Ban created successfully (#%d)", ban.BanID)
@@ -348,10 +396,20 @@ func createDiscordBanEmbed(ban model.BanSteam, response *botResponse) *discordgo
 func (bot *Discord) onCheck(ctx context.Context, _ *discordgo.Session, interaction *discordgo.InteractionCreate,
 	response *botResponse) error {
 	opts := optionMap(interaction.ApplicationCommandData().Options)
-	sid, errResolveSID := ResolveSID(ctx, opts[OptUserIdentifier].StringValue())
-	if errResolveSID != nil {
-		return consts.ErrInvalidSID
+	subject := opts[OptUserIdentifier].StringValue()
+	searchResult, errSearch := bot.app.SearchBans(ctx, bot.database, subject, true)
+	if errSearch != nil {
+		if errors.Is(errSearch, consts.ErrInvalidSID) {
+			return consts.ErrInvalidSID
+		}
+		log.Errorf("Failed to search bans: %v", errSearch)
+		return errCommandFailed
+	}
+	// Addresses, or names matching more than a single player, are shown as a summary of the matching bans
+	if net.ParseIP(subject) != nil || len(searchResult.SteamIds) != 1 {
+		return onCheckSubject(response, searchResult)
 	}
+	sid := searchResult.SteamIds[0]
 	player := model.NewPerson(sid)
 	if errGetPlayer := getOrCreateProfileBySteamID(ctx, bot.database, sid, "", &player); errGetPlayer != nil {
 		return errCommandFailed
@@ -428,6 +486,7 @@ func (bot *Discord) onCheck(ctx context.Context, _ *discordgo.Session, interacti
 		banStateStr = "muted"
 	}
 	addFieldInline(embed, "BanSteam/Muted", banStateStr)
+	addFieldBanMatches(embed, searchResult.Bans)
 	// TODO move elsewhere
 	logData, errLogs := thirdparty.LogsTFOverview(sid)
 	if errLogs != nil {
@@ -554,6 +613,57 @@ func (bot *Discord) onCheck(ctx context.Context, _ *discordgo.Session, interacti
 	return nil
 }
 
+// banMatchesMaxShown limits how many matched bans are listed in a single embed
+const banMatchesMaxShown = 10
+
+// addFieldBanMatches lists the active bans, of any kind, which matched a ban search
+func addFieldBanMatches(embed *discordgo.MessageEmbed, matches []model.BanMatch) {
+	var lines []string
+	for _, match := range matches {
+		if !match.Active {
+			continue
+		}
+		if len(lines) == banMatchesMaxShown {
+			lines = append(lines, "...")
+			break
+		}
+		line := fmt.Sprintf("%s #%d: %s", match.Kind, match.BanId, match.Target)
+		if match.MatchedOn != match.Target {
+			line += fmt.Sprintf(" (matched %s)", match.MatchedOn)
+		}
+		line += fmt.Sprintf(" - %s", match.Reason.String())
+		if !match.ValidUntil.IsZero() {
+			line += fmt.Sprintf(", expires %s", config.FmtTimeShort(match.ValidUntil))
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return
+	}
+	addField(embed, "Matched Bans", strings.Join(lines, "\n"))
+}
+
+// onCheckSubject responds with a summary of the bans affecting a subject which does not resolve to a single player
+func onCheckSubject(response *botResponse, result model.BanSearchResult) error {
+	embed := respOk(response, fmt.Sprintf("Ban check: %s", result.Query))
+	active := 0
+	for _, match := range result.Bans {
+		if match.Active {
+			active++
+		}
+	}
+	embed.Color = int(green)
+	if active > 0 {
+		embed.Color = int(red)
+	}
+	addFieldInline(embed, "Players", fmt.Sprintf("%d", len(result.SteamIds)))
+	addFieldInline(embed, "Addresses", fmt.Sprintf("%d", len(result.Addresses)))
+	addFieldInline(embed, "Active Bans", fmt.Sprintf("%d", active))
+	addFieldInline(embed, "Total Bans", fmt.Sprintf("%d", len(result.Bans)))
+	addFieldBanMatches(embed, result.Bans)
+	return nil
+}
+
 func (bot *Discord) onHistory(ctx context.Context, session *discordgo.Session,
 	interaction *discordgo.InteractionCreate, response *botResponse) error {
 	switch interaction.ApplicationCommandData().Name {
@@ -946,8 +1056,29 @@ func (bot *Discord) onFilterAdd(ctx context.Context, _ *discordgo.Session, inter
 	if errExpr != nil {
 		return errors.Wrap(errExpr, "Error fetching author info")
 	}
-	newFilter, errFilterAdd := bot.app.FilterAdd(ctx, bot.database, expr, filterName)
+	var actionOpts FilterActionOpts
+	if action, found := opts["action"]; found {
+		actionOpts.Action = model.FilterAction(action.StringValue())
+	}
+	if weight, found := opts["weight"]; found {
+		actionOpts.Weight = int(weight.IntValue())
+	}
+	if reason, found := opts[OptBanReason]; found {
+		actionOpts.BanReason = model.Reason(reason.IntValue())
+	}
+	if duration, found := opts[OptDuration]; found {
+		actionOpts.Duration = duration.StringValue()
+	}
+	if ping, found := opts["ping_discord"]; found {
+		pingDiscord := ping.BoolValue()
+		actionOpts.PingDiscord = &pingDiscord
+	}
+	newFilter, errFilterAdd := bot.app.FilterAdd(ctx, bot.database, expr, filterName, actionOpts)
 	if errFilterAdd != nil {
+		if errors.Is(errFilterAdd, model.ErrFilterAction) || errors.Is(errFilterAdd, model.ErrFilterWeight) ||
+			errors.Is(errFilterAdd, model.ErrFilterDuration) {
+			return errFilterAdd
+		}
 		return errCommandFailed
 	}
 	embed := respOk(response, "Filter Created Successfully")
@@ -986,8 +1117,10 @@ func (bot *Discord) onFilterCheck(_ context.Context, _ *discordgo.Session, inter
 		title = "Matched Found"
 	}
 	embed := respOk(response, title)
-	for _, filter := range matches {
-		addFieldFilter(embed, filter)
+	for _, match := range matches {
+		addFieldFilter(embed, *match.Filter)
+		addFieldInline(embed, "Matched", fmt.Sprintf("||%s||", match.Word))
+		addFieldInline(embed, "Normalisation", string(match.Step))
 	}
 	return nil
 }
@@ -1142,3 +1275,95 @@ func (bot *Discord) onLog(ctx context.Context, _ *discordgo.Session, interaction
 	embed.Description = desc
 	return nil
 }
+
+func (bot *Discord) onWarnings(ctx context.Context, session *discordgo.Session, interaction *discordgo.InteractionCreate,
+	response *botResponse) error {
+	switch interaction.ApplicationCommandData().Options[0].Name {
+	case "list":
+		return bot.onWarningsList(ctx, session, interaction, response)
+	case "clear":
+		return bot.onWarningsClear(ctx, session, interaction, response)
+	case "add":
+		return bot.onWarningsAdd(ctx, session, interaction, response)
+	default:
+		return errCommandFailed
+	}
+}
+
+func (bot *Discord) onWarningsList(ctx context.Context, _ *discordgo.Session, interaction *discordgo.InteractionCreate,
+	response *botResponse) error {
+	opts := optionMap(interaction.ApplicationCommandData().Options[0].Options)
+	steamId, errResolveSID := ResolveSID(ctx, opts.String(OptUserIdentifier))
+	if errResolveSID != nil {
+		return consts.ErrInvalidSID
+	}
+	warnings, errWarnings := bot.database.GetWarnings(ctx, steamId, true)
+	if errWarnings != nil && !errors.Is(errWarnings, store.ErrNoResult) {
+		return errCommandFailed
+	}
+	embed := respOk(response, fmt.Sprintf("Warnings (%d)", len(warnings)))
+	addFieldsSteamID(embed, steamId)
+	now := config.Now()
+	for i, warning := range warnings {
+		// Discord embeds are limited to 25 fields
+		if i >= 20 {
+			break
+		}
+		status := "Active"
+		if warning.Expired(now) {
+			status = "Expired"
+		}
+		addField(embed, fmt.Sprintf("#%d %s (%s)", warning.WarningId, warning.WarnReason.String(), status),
+			fmt.Sprintf("%s %s: %s", config.FmtTimeShort(warning.CreatedOn), warning.ServerName, warning.Message))
+	}
+	return nil
+}
+
+func (bot *Discord) onWarningsClear(ctx context.Context, _ *discordgo.Session, interaction *discordgo.InteractionCreate,
+	response *botResponse) error {
+	opts := optionMap(interaction.ApplicationCommandData().Options[0].Options)
+	steamId, errResolveSID := ResolveSID(ctx, opts.String(OptUserIdentifier))
+	if errResolveSID != nil {
+		return consts.ErrInvalidSID
+	}
+	count, errClear := bot.app.WarnClear(ctx, bot.database, steamId)
+	if errClear != nil {
+		log.WithError(errClear).Errorf("Failed to clear warnings")
+		return errCommandFailed
+	}
+	embed := respOk(response, "Warnings Cleared")
+	addFieldsSteamID(embed, steamId)
+	addField(embed, "Removed", fmt.Sprintf("%d", count))
+	return nil
+}
+
+func (bot *Discord) onWarningsAdd(ctx context.Context, _ *discordgo.Session, interaction *discordgo.InteractionCreate,
+	response *botResponse) error {
+	opts := optionMap(interaction.ApplicationCommandData().Options[0].Options)
+	steamId, errResolveSID := ResolveSID(ctx, opts.String(OptUserIdentifier))
+	if errResolveSID != nil {
+		return consts.ErrInvalidSID
+	}
+	reasonValueOpt, ok := opts[OptBanReason]
+	if !ok {
+		return errors.New("Invalid warning reason")
+	}
+	author := model.NewPerson(0)
+	if errGetAuthor := bot.database.GetPersonByDiscordID(ctx, interaction.Interaction.Member.User.ID, &author); errGetAuthor != nil {
+		if errGetAuthor == store.ErrNoResult {
+			return errors.New("Must set steam id. See /set_steam")
+		}
+		return errors.New("Error fetching author info")
+	}
+	warning, errWarn := bot.app.WarnAdd(ctx, bot.database, author.SteamID, steamId,
+		model.Reason(reasonValueOpt.IntValue()), opts.String(OptMessage), 0)
+	if errWarn != nil {
+		log.WithError(errWarn).Errorf("Failed to add warning")
+		return errCommandFailed
+	}
+	embed := respOk(response, "Warning Issued")
+	addFieldsSteamID(embed, steamId)
+	addField(embed, "Reason", warning.WarnReason.String())
+	addField(embed, "Expires At", config.FmtTimeShort(warning.ValidUntil))
+	return nil
+}
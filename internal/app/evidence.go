@@ -0,0 +1,252 @@
+package app
+
+import (
+	"context"
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/gbans/internal/store"
+	"github.com/leighmacdonald/gbans/pkg/fp"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/pkg/errors"
+)
+
+var (
+	errEvidenceMimeType = errors.New("Invalid evidence file format")
+	errEvidenceMessage  = errors.New("Message does not belong to the thread")
+	errEvidenceChat     = errors.New("Chat message must be sent by the reported player or during an attached match")
+	errEvidenceOwner    = errors.New("Media must be uploaded by you")
+	errEvidenceDemo     = errors.New("Demo must be the one recorded with the report")
+)
+
+// evidenceParticipants returns the users, other than moderators, that are allowed to view and attach
+// evidence on the parent thread
+func evidenceParticipants(ctx context.Context, database store.Store, parentKind model.EvidenceParent,
+	parentId int64) (steamid.Collection, error) {
+	switch parentKind {
+	case model.EvidenceParentReport:
+		var report model.Report
+		if errReport := database.GetReport(ctx, parentId, &report); errReport != nil {
+			return nil, errReport
+		}
+		return steamid.Collection{report.AuthorId}, nil
+	case model.EvidenceParentBan:
+		banPerson := model.NewBannedPerson()
+		if errBan := database.GetBanByBanID(ctx, parentId, &banPerson, true); errBan != nil {
+			return nil, errBan
+		}
+		return steamid.Collection{banPerson.Ban.TargetId, banPerson.Ban.SourceId}, nil
+	default:
+		return nil, model.ErrEvidenceParent
+	}
+}
+
+// evidenceSubject returns the player the report or ban thread is about
+func evidenceSubject(ctx context.Context, database store.Store, parentKind model.EvidenceParent,
+	parentId int64) (steamid.SID64, error) {
+	switch parentKind {
+	case model.EvidenceParentReport:
+		var report model.Report
+		if errReport := database.GetReport(ctx, parentId, &report); errReport != nil {
+			return 0, errReport
+		}
+		return report.ReportedId, nil
+	case model.EvidenceParentBan:
+		banPerson := model.NewBannedPerson()
+		if errBan := database.GetBanByBanID(ctx, parentId, &banPerson, true); errBan != nil {
+			return 0, errBan
+		}
+		return banPerson.Ban.TargetId, nil
+	default:
+		return 0, model.ErrEvidenceParent
+	}
+}
+
+// matchContainsMessage checks if the message was sent on the server of the match by one of its players
+// while they were playing in it
+func matchContainsMessage(match *model.Match, message model.PersonMessage) bool {
+	if match.ServerId != message.ServerId {
+		return false
+	}
+	for _, player := range match.PlayerSums {
+		if player.SteamId != message.SteamId || player.TimeEnd == nil {
+			continue
+		}
+		start := match.CreatedOn
+		if player.TimeStart != nil {
+			start = *player.TimeStart
+		}
+		if !message.CreatedOn.Before(start) && !message.CreatedOn.After(*player.TimeEnd) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateChatEvidence restricts the chat messages that non-moderators can attach to those sent by the
+// subject of the thread, or those sent during one of the matches already attached to the thread
+func validateChatEvidence(ctx context.Context, database store.Store, evidence model.Evidence,
+	message model.PersonMessage) error {
+	subject, errSubject := evidenceSubject(ctx, database, evidence.ParentKind, evidence.ParentId)
+	if errSubject != nil {
+		return errSubject
+	}
+	if message.SteamId == subject {
+		return nil
+	}
+	attached, errAttached := database.GetEvidenceByParent(ctx, evidence.ParentKind, evidence.ParentId)
+	if errAttached != nil {
+		return errAttached
+	}
+	for _, entry := range attached {
+		if entry.Kind != model.EvidenceMatch {
+			continue
+		}
+		match, errMatch := database.MatchGetById(ctx, entry.MatchId)
+		if errMatch != nil {
+			continue
+		}
+		if matchContainsMessage(match, message) {
+			return nil
+		}
+	}
+	return errEvidenceChat
+}
+
+// validateDemoEvidence restricts the demos that non-moderators can attach to the demo recorded when the
+// report was created in-game
+func validateDemoEvidence(ctx context.Context, database store.Store, evidence model.Evidence,
+	demo model.DemoFile) error {
+	if evidence.ParentKind != model.EvidenceParentReport {
+		return errEvidenceDemo
+	}
+	var report model.Report
+	if errReport := database.GetReport(ctx, evidence.ParentId, &report); errReport != nil {
+		return errReport
+	}
+	if report.DemoName == "" || report.DemoName != demo.Title {
+		return errEvidenceDemo
+	}
+	return nil
+}
+
+// validateEvidence ensures the subject of the evidence exists and is of an allowed type. For new uploads,
+// upload is the media which has not been saved yet, otherwise the existing media of MediaId is checked. Users
+// other than moderators can only attach their own media, and the chat messages and demos they can attach are
+// further restricted by validateChatEvidence and validateDemoEvidence.
+func validateEvidence(ctx context.Context, database store.Store, evidence model.Evidence, upload *model.Media,
+	moderator bool) error {
+	if errValidate := evidence.Validate(); errValidate != nil {
+		// Uploads are only assigned a MediaId once saved
+		if upload == nil || evidence.Kind != model.EvidenceMedia || !errors.Is(errValidate, model.ErrEvidenceRequired) {
+			return errValidate
+		}
+	}
+	if evidence.MessageId > 0 {
+		var message model.UserMessage
+		switch evidence.ParentKind {
+		case model.EvidenceParentReport:
+			if errMessage := database.GetReportMessageById(ctx, evidence.MessageId, &message); errMessage != nil {
+				return errMessage
+			}
+		case model.EvidenceParentBan:
+			if errMessage := database.GetBanMessageById(ctx, int(evidence.MessageId), &message); errMessage != nil {
+				return errMessage
+			}
+		}
+		if message.ParentId != evidence.ParentId {
+			return errEvidenceMessage
+		}
+	}
+	switch evidence.Kind {
+	case model.EvidenceMedia:
+		if upload != nil {
+			if !fp.Contains(model.MediaSafeMimeTypesEvidence, upload.MimeType) {
+				return errEvidenceMimeType
+			}
+			break
+		}
+		var media model.Media
+		if errMedia := database.GetMediaById(ctx, evidence.MediaId, &media); errMedia != nil {
+			return errMedia
+		}
+		if !fp.Contains(model.MediaSafeMimeTypesEvidence, media.MimeType) {
+			return errEvidenceMimeType
+		}
+		if !moderator && media.AuthorId != evidence.AuthorId {
+			return errEvidenceOwner
+		}
+	case model.EvidenceMessage:
+		var message model.PersonMessage
+		if errMessage := database.GetPersonMessageById(ctx, evidence.PersonMessageId, &message); errMessage != nil {
+			return errMessage
+		}
+		if !moderator {
+			return validateChatEvidence(ctx, database, evidence, message)
+		}
+	case model.EvidenceDemo:
+		var demo model.DemoFile
+		if errDemo := database.GetDemo(ctx, evidence.DemoId, &demo); errDemo != nil {
+			return errDemo
+		}
+		if !moderator {
+			return validateDemoEvidence(ctx, database, evidence, demo)
+		}
+	case model.EvidenceMatch:
+		if _, errMatch := database.MatchGetById(ctx, evidence.MatchId); errMatch != nil {
+			return errMatch
+		}
+	}
+	return nil
+}
+
+// evidenceDetails resolves the subjects of the evidence entries for display in the evidence panel. Subjects
+// which have since been removed are left empty.
+func evidenceDetails(ctx context.Context, database store.Store, evidence []model.Evidence) ([]model.EvidenceDetail, error) {
+	var authorIds steamid.Collection
+	for _, entry := range evidence {
+		authorIds = append(authorIds, entry.AuthorId)
+	}
+	authors, errAuthors := database.GetPeopleBySteamID(ctx, fp.Uniq(authorIds))
+	if errAuthors != nil {
+		return nil, errAuthors
+	}
+	authorsMap := authors.AsMap()
+	details := []model.EvidenceDetail{}
+	for _, entry := range evidence {
+		detail := model.EvidenceDetail{Evidence: entry, Author: authorsMap[entry.AuthorId]}
+		switch entry.Kind {
+		case model.EvidenceMedia:
+			var media model.Media
+			if errMedia := database.GetMediaById(ctx, entry.MediaId, &media); errMedia == nil {
+				detail.Media = &media
+			} else if !errors.Is(errMedia, store.ErrNoResult) {
+				return nil, errMedia
+			}
+		case model.EvidenceMessage:
+			var message model.PersonMessage
+			if errMessage := database.GetPersonMessageById(ctx, entry.PersonMessageId, &message); errMessage == nil {
+				detail.Message = &message
+			} else if !errors.Is(errMessage, store.ErrNoResult) {
+				return nil, errMessage
+			}
+		case model.EvidenceDemo:
+			var demo model.DemoFile
+			if errDemo := database.GetDemo(ctx, entry.DemoId, &demo); errDemo == nil {
+				detail.Demo = &demo
+			} else if !errors.Is(errDemo, store.ErrNoResult) {
+				return nil, errDemo
+			}
+		case model.EvidenceMatch:
+			if match, errMatch := database.MatchGetById(ctx, entry.MatchId); errMatch == nil {
+				detail.Match = &model.MatchSummary{
+					MatchID:     match.MatchID,
+					ServerId:    match.ServerId,
+					MapName:     match.MapName,
+					CreatedOn:   match.CreatedOn,
+					PlayerCount: len(match.PlayerSums),
+				}
+			}
+		}
+		details = append(details, detail)
+	}
+	return details, nil
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchContainsMessage(t *testing.T) {
+	t0 := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	start, end := t0.Add(time.Minute), t0.Add(time.Minute*30)
+	player := steamid.SID64(76561198083950960)
+	match := &model.Match{
+		ServerId:   1,
+		CreatedOn:  t0,
+		PlayerSums: model.MatchPlayerSums{{SteamId: player, TimeStart: &start, TimeEnd: &end}},
+	}
+	message := model.PersonMessage{SteamId: player, ServerId: 1, CreatedOn: t0.Add(time.Minute * 10)}
+	require.True(t, matchContainsMessage(match, message))
+	otherServer := message
+	otherServer.ServerId = 2
+	require.False(t, matchContainsMessage(match, otherServer))
+	otherPlayer := message
+	otherPlayer.SteamId = player + 1
+	require.False(t, matchContainsMessage(match, otherPlayer))
+	afterMatch := message
+	afterMatch.CreatedOn = end.Add(time.Second)
+	require.False(t, matchContainsMessage(match, afterMatch))
+}
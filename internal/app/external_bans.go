@@ -0,0 +1,237 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/gbans/internal/store"
+	"github.com/leighmacdonald/gbans/internal/thirdparty"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"time"
+)
+
+// externalBanSync periodically syncs the configured external ban lists into the database. The sync runs
+// several times per network_bans.max_age, as the lists are only downloaded again once their cached copy is
+// older than max_age. This keeps the lists from going stale for up to twice max_age.
+func (app *App) externalBanSync(ctx context.Context, database store.Store) {
+	maxAge, errMaxAge := config.ParseDuration(config.Net.MaxAge)
+	if errMaxAge != nil {
+		log.Errorf("Invalid network_bans.max_age, external ban lists disabled: %v", errMaxAge)
+		return
+	}
+	var update = func() {
+		for _, list := range config.Net.Sources {
+			if errSync := syncExternalBanList(ctx, database, list); errSync != nil {
+				log.WithFields(log.Fields{"list": list.Name}).Errorf("Failed to sync external ban list: %v", errSync)
+			}
+		}
+	}
+	update()
+	ticker := time.NewTicker(maxAge / externalBanSyncsPerMaxAge)
+	for {
+		select {
+		case <-ticker.C:
+			update()
+		case <-ctx.Done():
+			log.Debugf("externalBanSync shutting down")
+			return
+		}
+	}
+}
+
+// externalBanSyncsPerMaxAge is how many times the lists are synced within network_bans.max_age
+const externalBanSyncsPerMaxAge = 4
+
+// syncExternalBanList diffs the current contents of an external ban list against the entries synced
+// previously. New entries are banned with the model.External reason and entries which have been removed
+// from the list are unbanned. Entries recorded without a ban, because the subject was already banned, are
+// checked again so that they are banned once the other ban no longer covers them.
+func syncExternalBanList(ctx context.Context, database store.Store, list config.BanList) error {
+	listEntries, errFetch := thirdparty.FetchBanList(list)
+	if errFetch != nil {
+		return errFetch
+	}
+	existing, errExisting := database.GetExternalBanEntries(ctx, list.Name)
+	if errExisting != nil && !errors.Is(errExisting, store.ErrNoResult) {
+		return errors.Wrap(errExisting, "Failed to get existing entries")
+	}
+	type entryKey struct {
+		kind  model.BanKind
+		entry string
+	}
+	known := map[entryKey]model.ExternalBanEntry{}
+	for _, entry := range existing {
+		known[entryKey{entry.Kind, entry.Entry}] = entry
+	}
+	current := map[entryKey]bool{}
+	note := fmt.Sprintf("External ban list: %s", list.Name)
+	added, removed := 0, 0
+	for _, sid64 := range listEntries.SteamIds {
+		key := entryKey{model.BanKindSteam, sid64.String()}
+		current[key] = true
+		knownEntry, found := known[key]
+		if found && knownEntry.BanId > 0 {
+			continue
+		}
+		entry, errEntry := addExternalSteamBan(ctx, database, list, sid64, note)
+		if errEntry != nil {
+			log.WithFields(log.Fields{"list": list.Name, "sid": sid64.String()}).
+				Errorf("Failed to add external ban entry: %v", errEntry)
+			continue
+		}
+		if found && entry.BanId == 0 {
+			// Still covered by the other ban
+			continue
+		}
+		if errSave := database.SaveExternalBanEntry(ctx, &entry); errSave != nil {
+			log.WithFields(log.Fields{"list": list.Name, "sid": sid64.String()}).
+				Errorf("Failed to save external ban entry: %v", errSave)
+			continue
+		}
+		added++
+	}
+	for _, network := range listEntries.Networks {
+		key := entryKey{model.BanKindCIDR, network.String()}
+		current[key] = true
+		knownEntry, found := known[key]
+		if found && knownEntry.BanId > 0 {
+			continue
+		}
+		entry, errEntry := addExternalCIDRBan(ctx, database, list, network, note)
+		if errEntry != nil {
+			log.WithFields(log.Fields{"list": list.Name, "cidr": network.String()}).
+				Errorf("Failed to add external ban entry: %v", errEntry)
+			continue
+		}
+		if found && entry.BanId == 0 {
+			continue
+		}
+		if errSave := database.SaveExternalBanEntry(ctx, &entry); errSave != nil {
+			log.WithFields(log.Fields{"list": list.Name, "cidr": network.String()}).
+				Errorf("Failed to save external ban entry: %v", errSave)
+			continue
+		}
+		added++
+	}
+	// An empty list almost always means the source is broken rather than every entry being removed, so
+	// the existing bans are kept until the source returns entries again
+	if len(current) == 0 {
+		log.WithFields(log.Fields{"list": list.Name, "existing": len(known)}).
+			Warnf("External ban list returned no entries, skipping removals")
+		return nil
+	}
+	for key, entry := range known {
+		if current[key] {
+			continue
+		}
+		if errDrop := dropExternalBan(ctx, database, entry); errDrop != nil {
+			log.WithFields(log.Fields{"list": list.Name, "entry": entry.Entry}).
+				Errorf("Failed to drop external ban: %v", errDrop)
+			continue
+		}
+		if errEntry := database.DropExternalBanEntry(ctx, &entry); errEntry != nil {
+			log.WithFields(log.Fields{"list": list.Name, "entry": entry.Entry}).
+				Errorf("Failed to drop external ban entry: %v", errEntry)
+			continue
+		}
+		removed++
+	}
+	log.WithFields(log.Fields{"list": list.Name, "added": added, "removed": removed,
+		"total": len(current)}).Infof("Synced external ban list")
+	return nil
+}
+
+// addExternalSteamBan bans the steam id on behalf of the list, returning the entry to record. Players who
+// already have an active ban are not banned again, and the entry is recorded without a ban so that the
+// existing ban is left alone when the entry is later removed from the list.
+func addExternalSteamBan(ctx context.Context, database store.Store, list config.BanList, sid64 steamid.SID64,
+	note string) (model.ExternalBanEntry, error) {
+	entry := model.ExternalBanEntry{Source: list.Name, Kind: model.BanKindSteam, Entry: sid64.String(),
+		CreatedOn: config.Now()}
+	existing := model.NewBannedPerson()
+	if errExisting := database.GetBanBySteamID(ctx, sid64, &existing, false); errExisting == nil {
+		return entry, nil
+	} else if !errors.Is(errExisting, store.ErrNoResult) {
+		return entry, errors.Wrap(errExisting, "Failed to check existing ban")
+	}
+	// The ban target must exist in the person table
+	target := model.NewPerson(sid64)
+	if errPerson := database.GetOrCreatePersonBySteamID(ctx, sid64, &target); errPerson != nil {
+		return entry, errors.Wrap(errPerson, "Failed to create person")
+	}
+	var banSteam model.BanSteam
+	if errOpts := NewBanSteam(model.StringSID(config.General.Owner.String()), model.StringSID(sid64.String()),
+		"0", model.External, list.Name, note, model.System, 0, model.Banned, &banSteam); errOpts != nil {
+		return entry, errors.Wrap(errOpts, "Failed to create ban")
+	}
+	if errSave := database.SaveBan(ctx, &banSteam); errSave != nil {
+		return entry, errors.Wrap(errSave, "Failed to save ban")
+	}
+	entry.BanId = banSteam.BanID
+	return entry, nil
+}
+
+// addExternalCIDRBan bans the network on behalf of the list, returning the entry to record. Networks which
+// are already covered by an existing ban are recorded without a ban, the same as addExternalSteamBan.
+func addExternalCIDRBan(ctx context.Context, database store.Store, list config.BanList, network *net.IPNet,
+	note string) (model.ExternalBanEntry, error) {
+	entry := model.ExternalBanEntry{Source: list.Name, Kind: model.BanKindCIDR, Entry: network.String(),
+		CreatedOn: config.Now()}
+	existingNets, errExistingNets := database.GetBanNetByAddress(ctx, network.IP)
+	if errExistingNets != nil && !errors.Is(errExistingNets, store.ErrNoResult) {
+		return entry, errors.Wrap(errExistingNets, "Failed to get existing cidr bans")
+	}
+	if containsNetwork(existingNets, network) {
+		return entry, nil
+	}
+	var banCIDR model.BanCIDR
+	if errOpts := NewBanCIDR(model.StringSID(config.General.Owner.String()), "0", "0", model.External,
+		list.Name, note, model.System, network.String(), model.Banned, &banCIDR); errOpts != nil {
+		return entry, errors.Wrap(errOpts, "Failed to create cidr ban")
+	}
+	if errSave := database.SaveBanNet(ctx, &banCIDR); errSave != nil {
+		return entry, errors.Wrap(errSave, "Failed to save cidr ban")
+	}
+	entry.BanId = banCIDR.NetID
+	return entry, nil
+}
+
+// dropExternalBan removes the ban created for an external ban list entry. Bans which have since been
+// changed to another reason by a moderator are left in place.
+func dropExternalBan(ctx context.Context, database store.Store, entry model.ExternalBanEntry) error {
+	if entry.BanId == 0 {
+		return nil
+	}
+	switch entry.Kind {
+	case model.BanKindSteam:
+		bannedPerson := model.NewBannedPerson()
+		if errBan := database.GetBanByBanID(ctx, entry.BanId, &bannedPerson, false); errBan != nil {
+			if errors.Is(errBan, store.ErrNoResult) {
+				return nil
+			}
+			return errors.Wrap(errBan, "Failed to get external ban")
+		}
+		if bannedPerson.Ban.Reason != model.External {
+			return nil
+		}
+		return database.DropBan(ctx, &bannedPerson.Ban, false)
+	case model.BanKindCIDR:
+		var banCIDR model.BanCIDR
+		if errBan := database.GetBanNetById(ctx, entry.BanId, &banCIDR); errBan != nil {
+			if errors.Is(errBan, store.ErrNoResult) {
+				return nil
+			}
+			return errors.Wrap(errBan, "Failed to get external cidr ban")
+		}
+		if banCIDR.Reason != model.External {
+			return nil
+		}
+		return database.DropBanNet(ctx, &banCIDR)
+	default:
+		return errors.Errorf("Unsupported external ban kind: %s", entry.Kind)
+	}
+}
@@ -1,22 +1,114 @@
 package app
 
 import (
+	"context"
+	"github.com/leighmacdonald/gbans/internal/config"
 	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/gbans/internal/thirdparty"
+	log "github.com/sirupsen/logrus"
 	"sync"
+	"time"
 )
 
 var (
-	wordFilters   []model.Filter
-	wordFiltersMu *sync.RWMutex
+	wordFilters     []model.Filter
+	localFilters    []model.Filter
+	externalFilters map[string]model.Filter
+	wordFiltersMu   *sync.RWMutex
 )
 
 func init() {
 	wordFiltersMu = &sync.RWMutex{}
+	externalFilters = map[string]model.Filter{}
 }
 
-// importFilteredWords loads the supplied word list into memory
+// importFilteredWords loads the supplied word list into memory, merged with the filters loaded
+// from the external word lists
 func importFilteredWords(filters []model.Filter) {
 	wordFiltersMu.Lock()
 	defer wordFiltersMu.Unlock()
-	wordFilters = filters
+	localFilters = filters
+	mergeFilters()
+}
+
+// importExternalFilter replaces the filter for an external word list
+func importExternalFilter(filter model.Filter) {
+	wordFiltersMu.Lock()
+	defer wordFiltersMu.Unlock()
+	externalFilters[filter.Source] = filter
+	mergeFilters()
+}
+
+// mergeFilters rebuilds the active filter set. The caller must hold the write lock.
+func mergeFilters() {
+	merged := append([]model.Filter{}, localFilters...)
+	for _, list := range config.Filter.ExternalSources {
+		if filter, found := externalFilters[list.Name]; found {
+			merged = append(merged, filter)
+		}
+	}
+	wordFilters = merged
+}
+
+// getExternalFilters returns the currently loaded read-only external filters
+func getExternalFilters() []model.Filter {
+	wordFiltersMu.RLock()
+	defer wordFiltersMu.RUnlock()
+	var filters []model.Filter
+	for _, list := range config.Filter.ExternalSources {
+		if filter, found := externalFilters[list.Name]; found {
+			filters = append(filters, filter)
+		}
+	}
+	return filters
+}
+
+// externalFilterSync periodically fetches the configured external word lists. The cached copies are loaded first
+// so that the filters are active while the sources are being fetched, or are unreachable.
+func externalFilterSync(ctx context.Context) {
+	freq, errFreq := config.ParseDuration(config.Filter.ExternalUpdateFreq)
+	if errFreq != nil {
+		log.Errorf("Invalid word_filter.external_update_freq, external word lists disabled: %v", errFreq)
+		return
+	}
+	for _, list := range config.Filter.ExternalSources {
+		words, errCached := thirdparty.LoadCachedWordList(list)
+		if errCached != nil {
+			continue
+		}
+		filter, errFilter := model.NewExternalFilter(list.Name, words)
+		if errFilter != nil {
+			log.WithFields(log.Fields{"list": list.Name}).Errorf("Invalid cached word list: %v", errFilter)
+			continue
+		}
+		importExternalFilter(filter)
+	}
+	var update = func() {
+		for _, list := range config.Filter.ExternalSources {
+			words, errFetch := thirdparty.FetchWordList(list)
+			if errFetch != nil {
+				log.WithFields(log.Fields{"list": list.Name}).Errorf("Failed to load external word list: %v", errFetch)
+				continue
+			}
+			filter, errFilter := model.NewExternalFilter(list.Name, words)
+			if errFilter != nil {
+				log.WithFields(log.Fields{"list": list.Name}).Errorf("Invalid external word list: %v", errFilter)
+				continue
+			}
+			importExternalFilter(filter)
+			log.WithFields(log.Fields{"count": len(words), "list": list.Name, "type": "words"}).
+				Debugf("Loaded blocklist")
+		}
+	}
+	update()
+	ticker := time.NewTicker(freq)
+	for {
+		select {
+		case <-ticker.C:
+			update()
+		case <-ctx.Done():
+			log.Debugf("externalFilterSync shutting down")
+			return
+		}
+	}
 }
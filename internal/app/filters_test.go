@@ -12,7 +12,37 @@ func TestIsFilteredWord(t *testing.T) {
 	l1 := len(wordFilters)
 	importFilteredWords([]model.Filter{{WordID: 1, Patterns: []*regexp.Regexp{regexp.MustCompile(".*word")}, CreatedOn: config.Now()}})
 	require.Equal(t, l1+1, len(wordFilters))
-	matched, matchedFilter := findFilteredWordMatch("This is a badword")
-	require.Equal(t, "badword", matched)
-	require.Equal(t, 1, matchedFilter.WordID)
+	match := findFilteredWordMatch("This is a badword")
+	require.NotNil(t, match)
+	require.Equal(t, "badword", match.Word)
+	require.Equal(t, int64(1), match.Filter.WordID)
+	require.Equal(t, model.NormaliseNone, match.Step)
+}
+
+func TestExternalFilters(t *testing.T) {
+	config.Filter.ExternalSources = []config.WordList{{Name: "test-list", URL: "http://localhost/list.txt"}}
+	defer func() {
+		config.Filter.ExternalSources = nil
+		importFilteredWords(nil)
+	}()
+	importFilteredWords([]model.Filter{model.NewFilter("local", model.WordFiltersFromString("localword"))})
+	external, errExternal := model.NewExternalFilter("test-list", []string{"Extword", "a.b", "two  words"})
+	require.NoError(t, errExternal)
+	importExternalFilter(external)
+	require.Len(t, wordFilters, 2)
+	require.Len(t, getExternalFilters(), 1)
+	match := findFilteredWordMatch("some extword here")
+	require.NotNil(t, match)
+	require.Equal(t, "test-list", match.Filter.Source)
+	require.Equal(t, "extword", match.Word)
+	require.True(t, match.Filter.ReadOnly())
+	require.Nil(t, findFilteredWordMatch("aab"))
+	require.Nil(t, findFilteredWordMatch("textwords"))
+	phrase := findFilteredWordMatch("these are two words")
+	require.NotNil(t, phrase)
+	require.Equal(t, "two words", phrase.Word)
+	require.Nil(t, findFilteredWordMatch("two of the words"))
+	// Reloading the local filters must keep the external ones
+	importFilteredWords(nil)
+	require.Len(t, wordFilters, 1)
 }
@@ -0,0 +1,128 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/gbans/internal/store"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// modRoleMentions returns the discord mentions for all the configured moderator roles
+func modRoleMentions() string {
+	var roleStrings []string
+	for _, roleID := range config.Discord.ModRoleIDs {
+		roleStrings = append(roleStrings, fmt.Sprintf("<@&%s>", roleID))
+	}
+	return strings.Join(roleStrings, " ")
+}
+
+// reportTriage periodically escalates reports which have been opened for longer than the configured SLA
+// and closes reports which have been waiting on the reporter for more information for too long.
+func (app *App) reportTriage(ctx context.Context, database store.Store) {
+	log.WithFields(log.Fields{"service": "report_triage", "status": "ready"}).Debugf("Service status changed")
+	ticker := time.NewTicker(time.Minute)
+	for {
+		select {
+		case <-ticker.C:
+			if config.Reports.SLA > 0 {
+				app.escalateReports(ctx, database)
+			}
+			if config.Reports.NeedMoreInfoTimeout > 0 {
+				app.closeStaleReports(ctx, database)
+			}
+		case <-ctx.Done():
+			log.Debugf("reportTriage shutting down")
+			return
+		}
+	}
+}
+
+func (app *App) escalateReports(ctx context.Context, database store.Store) {
+	changedBefore := config.Now().Add(-config.Reports.SLA)
+	filter := store.NewReportQueryFilter()
+	filter.ReportStatus = model.Opened
+	filter.StatusChangedBefore = &changedBefore
+	reports, errReports := database.GetReports(ctx, filter)
+	if errReports != nil {
+		log.Errorf("Failed to load reports exceeding sla: %v", errReports)
+		return
+	}
+	for _, report := range reports {
+		if report.Escalated {
+			continue
+		}
+		report.Escalated = true
+		if errSave := database.SaveReport(ctx, &report); errSave != nil {
+			log.WithFields(log.Fields{"report_id": report.ReportId}).
+				Errorf("Failed to save escalated report: %v", errSave)
+			continue
+		}
+		embed := respOk(nil, "Report exceeded SLA")
+		embed.Color = int(orange)
+		embed.Description = fmt.Sprintf("Report has been opened for %s | %s",
+			config.FmtDuration(report.StatusChangedOn), modRoleMentions())
+		embed.URL = report.ToURL()
+		if report.AssigneeId.Valid() {
+			addField(embed, "Assignee", report.AssigneeId.String())
+		} else {
+			addField(embed, "Assignee", "Unassigned")
+		}
+		addField(embed, "Reason", report.Reason.String())
+		addFieldsSteamID(embed, report.ReportedId)
+		addLink(embed, report)
+		sendModChannels(app.discordSendMsg, embed)
+		log.WithFields(log.Fields{"report_id": report.ReportId}).Infof("Report escalated")
+	}
+}
+
+// closeStaleReports closes reports which have been in the NeedMoreInfo state past the timeout without
+// the reporter posting a response
+func (app *App) closeStaleReports(ctx context.Context, database store.Store) {
+	changedBefore := config.Now().Add(-config.Reports.NeedMoreInfoTimeout)
+	filter := store.NewReportQueryFilter()
+	filter.ReportStatus = model.NeedMoreInfo
+	filter.StatusChangedBefore = &changedBefore
+	reports, errReports := database.GetReports(ctx, filter)
+	if errReports != nil {
+		log.Errorf("Failed to load stale reports: %v", errReports)
+		return
+	}
+	for _, report := range reports {
+		messages, errMessages := database.GetReportMessages(ctx, report.ReportId)
+		if errMessages != nil {
+			log.WithFields(log.Fields{"report_id": report.ReportId}).
+				Errorf("Failed to load report messages: %v", errMessages)
+			continue
+		}
+		if reporterResponded(report, messages) {
+			continue
+		}
+		report.SetStatus(model.ClosedWithoutAction)
+		if errSave := database.SaveReport(ctx, &report); errSave != nil {
+			log.WithFields(log.Fields{"report_id": report.ReportId}).
+				Errorf("Failed to close stale report: %v", errSave)
+			continue
+		}
+		embed := respOk(nil, "Report closed without action")
+		embed.Description = fmt.Sprintf("Reporter did not provide more information within %s",
+			config.Reports.NeedMoreInfoTimeout.String())
+		embed.URL = report.ToURL()
+		addLink(embed, report)
+		sendDiscordPayload(app.discordSendMsg, discordPayload{channelId: config.Discord.ReportLogChannelId, embed: embed})
+		log.WithFields(log.Fields{"report_id": report.ReportId}).Infof("Stale report closed")
+	}
+}
+
+// reporterResponded checks if the report author has posted a message since the report entered its current state
+func reporterResponded(report model.Report, messages []model.UserMessage) bool {
+	for _, message := range messages {
+		if message.AuthorId == report.AuthorId && !message.Deleted && message.CreatedOn.After(report.StatusChangedOn) {
+			return true
+		}
+	}
+	return false
+}
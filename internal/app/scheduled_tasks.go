@@ -0,0 +1,270 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/krayzpipes/cronticker/cronticker"
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/gbans/internal/query"
+	"github.com/leighmacdonald/gbans/internal/store"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/leighmacdonald/steamweb"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	scheduledTaskRunRetention = time.Hour * 24 * 30
+	// scheduledTaskOutputMax limits the stored rcon response, as commands like status can be long
+	scheduledTaskOutputMax = 4000
+	tf2AppId               = steamid.AppID(440)
+)
+
+func newCronTicker(schedule string) (cronticker.CronTicker, error) {
+	// cronticker expects a space after the timezone and does not check for it
+	if strings.HasPrefix(schedule, "TZ=") && !strings.Contains(schedule, " ") {
+		return cronticker.CronTicker{}, errors.New("Invalid schedule: missing cron expression")
+	}
+	ticker, errTicker := cronticker.NewTicker(schedule)
+	if errTicker != nil {
+		return ticker, errors.Wrap(errTicker, "Invalid schedule")
+	}
+	return ticker, nil
+}
+
+// validateSchedule checks that cronticker is able to parse the schedule
+func validateSchedule(schedule string) error {
+	ticker, errTicker := newCronTicker(schedule)
+	if errTicker != nil {
+		return errTicker
+	}
+	stopCronTicker(&ticker)
+	return nil
+}
+
+// stopCronTicker stops the ticker, draining any pending tick so that the ticker goroutine is not left
+// blocked and unable to see the stop signal
+func stopCronTicker(ticker *cronticker.CronTicker) {
+	ticker.Stop()
+	select {
+	case <-ticker.C:
+	default:
+	}
+}
+
+// scheduledTaskServers returns the servers targeted by the task
+func scheduledTaskServers(task model.ScheduledTask, servers []model.Server) []model.Server {
+	var targets []model.Server
+	for _, server := range servers {
+		if task.ServerId > 0 && server.ServerID == task.ServerId ||
+			task.ServerId <= 0 && task.Region != "" && strings.EqualFold(server.Region, task.Region) {
+			targets = append(targets, server)
+		}
+	}
+	return targets
+}
+
+// scheduledTaskSkipReason checks the conditions of the task against the current state of the server, which
+// is nil when unknown. An empty string is returned when the task should run.
+func scheduledTaskSkipReason(task model.ScheduledTask, state *model.ServerState,
+	upToDate func(version string) (bool, error)) string {
+	if !task.OnlyWhenEmpty && !task.OnlyWhenOutdated {
+		return ""
+	}
+	if state == nil {
+		return "Server state unknown"
+	}
+	if task.OnlyWhenEmpty {
+		if humans := state.PlayerCount - state.Bots; humans > 0 {
+			return fmt.Sprintf("Server not empty: %d players", humans)
+		}
+	}
+	if task.OnlyWhenOutdated {
+		if state.Version == "" {
+			return "Server version unknown"
+		}
+		current, errCheck := upToDate(state.Version)
+		if errCheck != nil {
+			return fmt.Sprintf("Failed to check server version: %v", errCheck)
+		}
+		if current {
+			return "Server is up to date"
+		}
+	}
+	return ""
+}
+
+// gameVersionUpToDate asks steam if the version reported by a2s is the latest
+func gameVersionUpToDate(version string) (bool, error) {
+	versionNum, errParse := strconv.ParseUint(strings.ReplaceAll(version, ".", ""), 10, 32)
+	if errParse != nil {
+		return false, errors.Wrapf(errParse, "Invalid version: %s", version)
+	}
+	info, errCheck := steamweb.UpToDateCheck(tf2AppId, uint32(versionNum))
+	if errCheck != nil {
+		return false, errors.Wrap(errCheck, "Failed to query steam")
+	}
+	return info.UpToDate, nil
+}
+
+// runScheduledTask runs the task on each targeted server, recording the outcome of each
+func (app *App) runScheduledTask(ctx context.Context, database store.Store, task model.ScheduledTask) []model.ScheduledTaskRun {
+	servers, errServers := database.GetServers(ctx, false)
+	if errServers != nil {
+		log.Errorf("Failed to load servers for scheduled task: %v", errServers)
+		return nil
+	}
+	targets := scheduledTaskServers(task, servers)
+	if len(targets) == 0 {
+		log.WithFields(log.Fields{"task": task.Name}).Warnf("Scheduled task matched no servers")
+		return nil
+	}
+	runs := make([]model.ScheduledTaskRun, len(targets))
+	waitGroup := &sync.WaitGroup{}
+	for i, srv := range targets {
+		waitGroup.Add(1)
+		go func(idx int, server model.Server) {
+			defer waitGroup.Done()
+			runs[idx] = app.runScheduledTaskOnServer(ctx, database, task, server)
+		}(i, srv)
+	}
+	waitGroup.Wait()
+	return runs
+}
+
+func (app *App) runScheduledTaskOnServer(ctx context.Context, database store.ScheduledTaskStore,
+	task model.ScheduledTask, server model.Server) model.ScheduledTaskRun {
+	run := model.ScheduledTaskRun{
+		ScheduledTaskId: task.ScheduledTaskId,
+		ServerId:        server.ServerID,
+		CreatedOn:       config.Now(),
+	}
+	var statePtr *model.ServerState
+	var state model.ServerState
+	if app.ServerState().ByName(server.ServerNameShort, &state) {
+		statePtr = &state
+	}
+	fields := log.Fields{"task": task.Name, "server": server.ServerNameShort}
+	if reason := scheduledTaskSkipReason(task, statePtr, gameVersionUpToDate); reason != "" {
+		run.Status = model.ScheduledTaskRunSkipped
+		run.Output = reason
+		log.WithFields(fields).Debugf("Scheduled task skipped: %s", reason)
+	} else if response, errExec := query.ExecRCON(ctx, server, task.RCONCommand()); errExec != nil {
+		run.Status = model.ScheduledTaskRunFailed
+		run.Output = errExec.Error()
+		log.WithFields(fields).Warnf("Scheduled task failed: %v", errExec)
+	} else {
+		run.Status = model.ScheduledTaskRunSuccess
+		run.Output = response
+		if outputRunes := []rune(response); len(outputRunes) > scheduledTaskOutputMax {
+			run.Output = string(outputRunes[:scheduledTaskOutputMax])
+		}
+		log.WithFields(fields).Debugf("Scheduled task completed")
+	}
+	if errSave := database.AddScheduledTaskRun(ctx, &run); errSave != nil {
+		log.WithFields(fields).Errorf("Failed to save scheduled task run: %v", errSave)
+	}
+	return run
+}
+
+// reloadScheduledTasks notifies the scheduledTaskRunner that the tasks have changed
+func (app *App) reloadScheduledTasks() {
+	select {
+	case app.scheduledTaskReload <- struct{}{}:
+	default:
+		// A reload is already pending
+	}
+}
+
+type scheduledTaskWorker struct {
+	task model.ScheduledTask
+	stop chan struct{}
+}
+
+func (app *App) startScheduledTask(ctx context.Context, database store.Store, task model.ScheduledTask) (*scheduledTaskWorker, error) {
+	ticker, errTicker := newCronTicker(task.Schedule)
+	if errTicker != nil {
+		return nil, errTicker
+	}
+	worker := &scheduledTaskWorker{task: task, stop: make(chan struct{})}
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				app.runScheduledTask(ctx, database, task)
+			case <-worker.stop:
+				stopCronTicker(&ticker)
+				return
+			case <-ctx.Done():
+				stopCronTicker(&ticker)
+				return
+			}
+		}
+	}()
+	return worker, nil
+}
+
+// scheduledTaskRunner keeps a cron ticker running for each enabled task, restarting them when the tasks are
+// changed, and prunes old task runs
+func (app *App) scheduledTaskRunner(ctx context.Context, database store.Store) {
+	workers := map[int]*scheduledTaskWorker{}
+	var update = func() {
+		tasks, errTasks := database.GetScheduledTasks(ctx, false)
+		if errTasks != nil {
+			log.Errorf("Failed to load scheduled tasks: %v", errTasks)
+			return
+		}
+		current := map[int]model.ScheduledTask{}
+		for _, task := range tasks {
+			current[task.ScheduledTaskId] = task
+		}
+		for taskId, worker := range workers {
+			task, found := current[taskId]
+			if !found || !task.UpdatedOn.Equal(worker.task.UpdatedOn) {
+				close(worker.stop)
+				delete(workers, taskId)
+			}
+		}
+		for _, task := range tasks {
+			if _, running := workers[task.ScheduledTaskId]; running {
+				continue
+			}
+			worker, errStart := app.startScheduledTask(ctx, database, task)
+			if errStart != nil {
+				log.WithFields(log.Fields{"task": task.Name}).Errorf("Failed to start scheduled task: %v", errStart)
+				continue
+			}
+			workers[task.ScheduledTaskId] = worker
+		}
+		log.WithFields(log.Fields{"tasks": len(workers)}).Debugf("Scheduled tasks loaded")
+	}
+	var prune = func() {
+		count, errPrune := database.PruneScheduledTaskRuns(ctx, config.Now().Add(-scheduledTaskRunRetention))
+		if errPrune != nil {
+			log.Errorf("Failed to prune scheduled task runs: %v", errPrune)
+			return
+		}
+		if count > 0 {
+			log.WithFields(log.Fields{"count": count}).Debugf("Pruned scheduled task runs")
+		}
+	}
+	update()
+	prune()
+	pruneTicker := time.NewTicker(time.Hour)
+	for {
+		select {
+		case <-app.scheduledTaskReload:
+			update()
+		case <-pruneTicker.C:
+			prune()
+		case <-ctx.Done():
+			log.Debugf("scheduledTaskRunner shutting down")
+			return
+		}
+	}
+}
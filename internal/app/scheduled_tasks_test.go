@@ -0,0 +1,54 @@
+package app
+
+import (
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestValidateSchedule(t *testing.T) {
+	require.NoError(t, validateSchedule("0 0 4 * * *"))
+	require.NoError(t, validateSchedule("*/10 * * * *"))
+	require.NoError(t, validateSchedule("TZ=America/Edmonton 0 30 3 * * *"))
+	require.NoError(t, validateSchedule("@hourly"))
+	require.Error(t, validateSchedule("not a schedule"))
+	require.Error(t, validateSchedule("TZ=Invalid/Zone 0 * * * *"))
+	require.Error(t, validateSchedule("TZ=UTC"))
+}
+
+func TestScheduledTaskServers(t *testing.T) {
+	servers := []model.Server{
+		{ServerID: 1, Region: "na"}, {ServerID: 2, Region: "NA"}, {ServerID: 3, Region: "eu"},
+	}
+	byServer := model.ScheduledTask{ServerId: 3}
+	require.Equal(t, []model.Server{servers[2]}, scheduledTaskServers(byServer, servers))
+	byRegion := model.ScheduledTask{Region: "na"}
+	require.Equal(t, []model.Server{servers[0], servers[1]}, scheduledTaskServers(byRegion, servers))
+	require.Empty(t, scheduledTaskServers(model.ScheduledTask{}, servers))
+}
+
+func TestScheduledTaskSkipReason(t *testing.T) {
+	upToDate := func(current bool, err error) func(string) (bool, error) {
+		return func(string) (bool, error) {
+			return current, err
+		}
+	}
+	plain := model.ScheduledTask{}
+	require.Equal(t, "", scheduledTaskSkipReason(plain, nil, upToDate(true, nil)))
+
+	empty := model.ScheduledTask{OnlyWhenEmpty: true}
+	require.Equal(t, "Server state unknown", scheduledTaskSkipReason(empty, nil, upToDate(true, nil)))
+	require.Equal(t, "Server not empty: 2 players",
+		scheduledTaskSkipReason(empty, &model.ServerState{PlayerCount: 5, Bots: 3}, upToDate(true, nil)))
+	require.Equal(t, "", scheduledTaskSkipReason(empty, &model.ServerState{PlayerCount: 3, Bots: 3}, upToDate(true, nil)))
+
+	outdated := model.ScheduledTask{OnlyWhenEmpty: true, OnlyWhenOutdated: true}
+	state := &model.ServerState{Version: "7370160"}
+	require.Equal(t, "Server is up to date", scheduledTaskSkipReason(outdated, state, upToDate(true, nil)))
+	require.Equal(t, "", scheduledTaskSkipReason(outdated, state, upToDate(false, nil)))
+	require.Equal(t, "Failed to check server version: steam down",
+		scheduledTaskSkipReason(outdated, state, upToDate(false, errors.New("steam down"))))
+	require.Equal(t, "Server version unknown",
+		scheduledTaskSkipReason(outdated, &model.ServerState{}, upToDate(false, nil)))
+}
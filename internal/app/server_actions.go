@@ -0,0 +1,113 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/gbans/internal/query"
+	"github.com/leighmacdonald/gbans/internal/store"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// serverActionTimeout is how long the plugin has to confirm an action. Actions only make sense while the
+// player is still connected, so older ones are expired instead of being delivered late.
+const serverActionTimeout = time.Minute * 5
+
+// pushServerAction queues an action for the plugin and asks the server to fetch it immediately with
+// sm_gbans_sync. The plugin also polls for actions, so an RCON failure only delays the action. Servers
+// still running an older plugin without sm_gbans_sync would never apply the action, so for those the
+// equivalent sourcemod command is sent directly instead and the action is recorded as applied.
+func (app *App) pushServerAction(ctx context.Context, database store.ServerActionStore, server model.Server,
+	steamId steamid.SID64, kind model.ServerActionKind, reason string) error {
+	action := model.NewServerAction(server.ServerID, steamId, kind, reason)
+	if errSave := database.SaveServerAction(ctx, &action); errSave != nil {
+		return errors.Wrap(errSave, "Failed to save server action")
+	}
+	fields := log.Fields{
+		"server_action_id": action.ServerActionId,
+		"server":           server.ServerNameShort,
+		"sid":              steamId,
+		"kind":             kind,
+	}
+	response, errExec := query.ExecRCON(ctx, server, "sm_gbans_sync")
+	if errExec != nil {
+		log.WithFields(fields).Warnf("Failed to request server action sync: %v", errExec)
+	} else if isUnknownCommand(response) {
+		log.WithFields(fields).Warnf("Server plugin does not support sm_gbans_sync, applying action directly")
+		fallbackResponse, errFallback := query.ExecRCON(ctx, server, serverActionCommand(action))
+		if errFallback != nil {
+			return errors.Wrap(errFallback, "Failed to apply server action")
+		}
+		return onServerActionConfirmed(ctx, database, &action, !isUnknownCommand(fallbackResponse),
+			strings.TrimSpace(fallbackResponse))
+	}
+	log.WithFields(fields).Debugf("Server action queued")
+	return nil
+}
+
+// isUnknownCommand checks if the rcon response is the srcds reply to a command which does not exist
+func isUnknownCommand(response string) bool {
+	return strings.HasPrefix(strings.TrimSpace(response), "Unknown command")
+}
+
+// serverActionCommand returns the stock sourcemod command which applies the action
+func serverActionCommand(action model.ServerAction) string {
+	target := fmt.Sprintf(`"#%s"`, steamid.SID64ToSID(action.SteamId))
+	switch action.Kind {
+	case model.ServerActionKick:
+		return fmt.Sprintf("sm_kick %s %s", target, action.Reason)
+	case model.ServerActionUngag:
+		return fmt.Sprintf("sm_unsilence %s", target)
+	default:
+		return fmt.Sprintf("sm_silence %s %s", target, action.Reason)
+	}
+}
+
+// Ungag lifts the gag & mute of a player who is currently connected to a server
+func (app *App) Ungag(ctx context.Context, database store.Store, target steamid.SID64, reason string) error {
+	var foundPI model.PlayerInfo
+	if errFind := app.Find(ctx, database, model.StringSID(target.String()), "", &foundPI); errFind != nil {
+		return errFind
+	}
+	if !foundPI.Valid || !foundPI.InGame {
+		return nil
+	}
+	return app.pushServerAction(ctx, database, *foundPI.Server, foundPI.Player.SID, model.ServerActionUngag, reason)
+}
+
+// onServerActionConfirmed records the result of an action reported back by the plugin
+func onServerActionConfirmed(ctx context.Context, database store.ServerActionStore, action *model.ServerAction,
+	success bool, message string) error {
+	action.Status = model.ServerActionApplied
+	if !success {
+		action.Status = model.ServerActionFailed
+	}
+	action.Message = message
+	if errSave := database.SaveServerAction(ctx, action); errSave != nil {
+		return errSave
+	}
+	log.WithFields(log.Fields{
+		"server_action_id": action.ServerActionId,
+		"sid":              action.SteamId,
+		"kind":             action.Kind,
+		"status":           action.Status,
+		"message":          message,
+	}).Infof("Server action confirmed")
+	return nil
+}
+
+func (app *App) expireServerActions(ctx context.Context, database store.ServerActionStore) {
+	count, errExpire := database.ExpireServerActions(ctx, config.Now().Add(-serverActionTimeout))
+	if errExpire != nil {
+		log.Errorf("Failed to expire server actions: %v", errExpire)
+		return
+	}
+	if count > 0 {
+		log.Warnf("Expired %d unconfirmed server actions", count)
+	}
+}
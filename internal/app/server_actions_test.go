@@ -0,0 +1,24 @@
+package app
+
+import (
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestServerActionCommand(t *testing.T) {
+	sid := steamid.SID64(76561198084134025)
+	require.Equal(t, `sm_kick "#STEAM_0:1:61934148" Cheating`,
+		serverActionCommand(model.NewServerAction(1, sid, model.ServerActionKick, "Cheating")))
+	require.Equal(t, `sm_silence "#STEAM_0:1:61934148" Spam`,
+		serverActionCommand(model.NewServerAction(1, sid, model.ServerActionGag, "Spam")))
+	require.Equal(t, `sm_unsilence "#STEAM_0:1:61934148"`,
+		serverActionCommand(model.NewServerAction(1, sid, model.ServerActionUngag, "")))
+}
+
+func TestIsUnknownCommand(t *testing.T) {
+	require.True(t, isUnknownCommand("Unknown command \"sm_gbans_sync\"\n"))
+	require.False(t, isUnknownCommand("[gbans] Synced 1 actions\n"))
+	require.False(t, isUnknownCommand(""))
+}
@@ -0,0 +1,306 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/leighmacdonald/gbans/internal/event"
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/gbans/internal/store"
+	"github.com/leighmacdonald/gbans/pkg/logparse"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"sync"
+	"time"
+)
+
+// healthTransition is a change of the confirmed state of a health check
+type healthTransition int
+
+const (
+	healthUnchanged healthTransition = iota
+	healthDown
+	healthUp
+)
+
+// healthCheckState tracks a single health check of a server. Failures are only confirmed as the check being
+// down once they have persisted for the configured duration, so a single dropped query does not cause an alert.
+type healthCheckState struct {
+	// Known is false until the first state has been confirmed
+	Known bool `json:"known"`
+	Up    bool `json:"up"`
+	// Since is when the confirmed state began
+	Since        time.Time `json:"since"`
+	LastSuccess  time.Time `json:"last_success"`
+	FailingSince time.Time `json:"failing_since"`
+	LastError    string    `json:"last_error"`
+}
+
+// update applies the result of a check, returning the confirmed state transition, if any
+func (state *healthCheckState) update(success bool, errMsg string, now time.Time, downAfter time.Duration) healthTransition {
+	if success {
+		state.LastSuccess = now
+		state.FailingSince = time.Time{}
+		if state.Known && state.Up {
+			return healthUnchanged
+		}
+		state.Known = true
+		state.Up = true
+		state.Since = now
+		return healthUp
+	}
+	state.LastError = errMsg
+	if state.FailingSince.IsZero() {
+		state.FailingSince = now
+	}
+	if state.Known && !state.Up || now.Sub(state.FailingSince) < downAfter {
+		return healthUnchanged
+	}
+	state.Known = true
+	state.Up = false
+	state.Since = state.FailingSince
+	return healthDown
+}
+
+type serverHealth struct {
+	ServerId    int                                     `json:"server_id"`
+	ServerName  string                                  `json:"server_name"`
+	Checks      map[model.HealthCheck]*healthCheckState `json:"checks"`
+	PlayerCount int                                     `json:"player_count"`
+	LastLog     time.Time                               `json:"last_log"`
+}
+
+// serverHealthTracker holds the current health of each server
+type serverHealthTracker struct {
+	mu      *sync.RWMutex
+	servers map[int]*serverHealth
+	// started is used in place of the last log time for servers which have not sent any logs yet
+	started time.Time
+}
+
+func newServerHealthTracker(now time.Time) *serverHealthTracker {
+	return &serverHealthTracker{mu: &sync.RWMutex{}, servers: map[int]*serverHealth{}, started: now}
+}
+
+// server returns the health of the server. The caller must hold the write lock.
+func (tracker *serverHealthTracker) server(server model.Server) *serverHealth {
+	health, found := tracker.servers[server.ServerID]
+	if !found {
+		health = &serverHealth{ServerId: server.ServerID, Checks: map[model.HealthCheck]*healthCheckState{}}
+		for _, check := range model.HealthChecks {
+			health.Checks[check] = &healthCheckState{}
+		}
+		tracker.servers[server.ServerID] = health
+	}
+	health.ServerName = server.ServerNameShort
+	return health
+}
+
+// record applies a check result, returning the transition along with the states before and after it
+func (tracker *serverHealthTracker) record(server model.Server, check model.HealthCheck, errCheck error,
+	now time.Time, downAfter time.Duration) (healthTransition, healthCheckState, healthCheckState) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	state := tracker.server(server).Checks[check]
+	previous := *state
+	errMsg := ""
+	if errCheck != nil {
+		errMsg = errCheck.Error()
+	}
+	transition := state.update(errCheck == nil, errMsg, now, downAfter)
+	return transition, previous, *state
+}
+
+func (tracker *serverHealthTracker) logSeen(server model.Server, now time.Time) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	tracker.server(server).LastLog = now
+}
+
+// lastLog returns when the server last sent a log line
+func (tracker *serverHealthTracker) lastLog(server model.Server) time.Time {
+	tracker.mu.RLock()
+	defer tracker.mu.RUnlock()
+	if health, found := tracker.servers[server.ServerID]; found && !health.LastLog.IsZero() {
+		return health.LastLog
+	}
+	return tracker.started
+}
+
+// updatePlayerCount stores the new player count, returning the previous one
+func (tracker *serverHealthTracker) updatePlayerCount(server model.Server, count int) int {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	health := tracker.server(server)
+	previous := health.PlayerCount
+	health.PlayerCount = count
+	return previous
+}
+
+// prune removes servers which are no longer monitored
+func (tracker *serverHealthTracker) prune(servers []model.Server) {
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	current := map[int]bool{}
+	for _, server := range servers {
+		current[server.ServerID] = true
+	}
+	for serverId := range tracker.servers {
+		if !current[serverId] {
+			delete(tracker.servers, serverId)
+		}
+	}
+}
+
+func (tracker *serverHealthTracker) snapshot() []serverHealth {
+	tracker.mu.RLock()
+	defer tracker.mu.RUnlock()
+	healths := []serverHealth{}
+	for _, health := range tracker.servers {
+		checks := map[model.HealthCheck]*healthCheckState{}
+		for check, state := range health.Checks {
+			stateCopy := *state
+			checks[check] = &stateCopy
+		}
+		healthCopy := *health
+		healthCopy.Checks = checks
+		healths = append(healths, healthCopy)
+	}
+	sort.Slice(healths, func(i, j int) bool {
+		return healths[i].ServerName < healths[j].ServerName
+	})
+	return healths
+}
+
+// playerCountAnomaly checks for a sudden drop in the number of players, which usually means the server crashed
+// or had network issues
+func playerCountAnomaly(previous int, current int, minPlayers int, dropPercent int) bool {
+	if dropPercent <= 0 || previous < minPlayers || previous == 0 {
+		return false
+	}
+	return (previous-current)*100/previous >= dropPercent
+}
+
+// onHealthCheck records the result of a check, storing the uptime interval and alerting discord when the
+// confirmed state of the check changes
+func (app *App) onHealthCheck(ctx context.Context, database store.ServerStore, server model.Server,
+	check model.HealthCheck, errCheck error) {
+	if !config.Health.Enabled {
+		return
+	}
+	now := config.Now()
+	transition, previous, state := app.serverHealth.record(server, check, errCheck, now, config.Health.DownAfter)
+	if transition == healthUnchanged {
+		return
+	}
+	uptime := model.ServerUptime{ServerId: server.ServerID, Check: check, Up: state.Up, StartedOn: state.Since}
+	if errSave := database.AddServerUptime(ctx, &uptime); errSave != nil {
+		log.Errorf("Failed to save server uptime: %v", errSave)
+	}
+	fields := log.Fields{"server": server.ServerNameShort, "check": check}
+	if transition == healthUp {
+		log.WithFields(fields).Infof("Server check is up")
+		// The initial state is not worth alerting on
+		if previous.Known {
+			embed := respOk(nil, fmt.Sprintf("Server recovered: %s", server.ServerNameShort))
+			addField(embed, "Check", string(check))
+			addField(embed, "Downtime", now.Sub(previous.Since).Round(time.Second).String())
+			app.sendHealthAlert(embed)
+		}
+		return
+	}
+	log.WithFields(fields).Warnf("Server check is down: %s", state.LastError)
+	embed := respOk(nil, fmt.Sprintf("Server down: %s", server.ServerNameShort))
+	embed.Color = int(red)
+	addField(embed, "Check", string(check))
+	addField(embed, "Down Since", config.FmtTimeShort(state.Since))
+	if state.LastError != "" {
+		addField(embed, "Error", state.LastError)
+	}
+	app.sendHealthAlert(embed)
+}
+
+func (app *App) sendHealthAlert(embed *discordgo.MessageEmbed) {
+	if !config.Discord.Enabled || config.Health.AlertChannel() == "" {
+		return
+	}
+	select {
+	case app.discordSendMsg <- discordPayload{channelId: config.Health.AlertChannel(), embed: embed}:
+	default:
+		log.Warnf("Cannot send discord payload, channel full")
+	}
+}
+
+// checkServerHealth runs the log stream and player count checks, which rely on the state collected by the
+// log reader and status updaters rather than querying the server
+func (app *App) checkServerHealth(ctx context.Context, database store.ServerStore) {
+	servers, errServers := database.GetServers(ctx, false)
+	if errServers != nil {
+		log.Errorf("Failed to load servers for health check: %v", errServers)
+		return
+	}
+	app.serverHealth.prune(servers)
+	now := config.Now()
+	for _, server := range servers {
+		var errLogs error
+		if lastLog := app.serverHealth.lastLog(server); now.Sub(lastLog) > config.Health.LogTimeout {
+			errLogs = fmt.Errorf("No logs received since %s", config.FmtTimeShort(lastLog))
+		}
+		app.onHealthCheck(ctx, database, server, model.HealthCheckLogs, errLogs)
+
+		app.serverStateA2SMu.RLock()
+		result, found := app.serverStateA2S[server.ServerNameShort]
+		app.serverStateA2SMu.RUnlock()
+		if !found {
+			continue
+		}
+		current := int(result.Info.Players)
+		previous := app.serverHealth.updatePlayerCount(server, current)
+		if playerCountAnomaly(previous, current, config.Health.PlayerDropMin, config.Health.PlayerDropPercent) {
+			log.WithFields(log.Fields{"server": server.ServerNameShort, "previous": previous, "current": current}).
+				Warnf("Player count dropped")
+			embed := respOk(nil, fmt.Sprintf("Player count dropped: %s", server.ServerNameShort))
+			embed.Color = int(orange)
+			addFieldInline(embed, "Before", fmt.Sprintf("%d", previous))
+			addFieldInline(embed, "After", fmt.Sprintf("%d", current))
+			app.sendHealthAlert(embed)
+		}
+	}
+}
+
+// serverHealthMonitor tracks the udp log stream of each server and periodically runs the health checks
+// which are not performed by the status updaters
+func (app *App) serverHealthMonitor(ctx context.Context, database store.ServerStore, updateFreq time.Duration) {
+	eventChan := make(chan model.ServerEvent)
+	if errRegister := event.Consume(eventChan, []logparse.EventType{logparse.Any}); errRegister != nil {
+		log.Errorf("Failed to register health event reader: %v", errRegister)
+		return
+	}
+	// The checks query the database and send alerts, so they run separately to avoid stalling the
+	// event broadcaster, which blocks until every consumer has received the event
+	go func() {
+		ticker := time.NewTicker(updateFreq)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				app.checkServerHealth(ctx, database)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	log.WithFields(log.Fields{"service": "server_health", "status": "ready"}).Debugf("Service status changed")
+	for {
+		select {
+		case serverEvent := <-eventChan:
+			if serverEvent.Server.ServerID > 0 {
+				app.serverHealth.logSeen(serverEvent.Server, config.Now())
+			}
+		case <-ctx.Done():
+			log.Debugf("serverHealthMonitor shutting down")
+			return
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestHealthCheckState(t *testing.T) {
+	t0 := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	downAfter := time.Minute * 2
+	var state healthCheckState
+	require.Equal(t, healthUp, state.update(true, "", t0, downAfter))
+	require.True(t, state.Up)
+	require.Equal(t, healthUnchanged, state.update(true, "", t0.Add(time.Minute), downAfter))
+
+	// Failures are only confirmed once they persist for downAfter
+	require.Equal(t, healthUnchanged, state.update(false, "timeout", t0.Add(time.Minute*2), downAfter))
+	require.True(t, state.Up)
+	require.Equal(t, healthUnchanged, state.update(false, "timeout", t0.Add(time.Minute*3), downAfter))
+	require.Equal(t, healthDown, state.update(false, "timeout", t0.Add(time.Minute*4), downAfter))
+	require.False(t, state.Up)
+	require.Equal(t, t0.Add(time.Minute*2), state.Since)
+	require.Equal(t, "timeout", state.LastError)
+	require.Equal(t, healthUnchanged, state.update(false, "timeout", t0.Add(time.Minute*5), downAfter))
+
+	require.Equal(t, healthUp, state.update(true, "", t0.Add(time.Minute*6), downAfter))
+	require.Equal(t, t0.Add(time.Minute*6), state.Since)
+
+	// A blip shorter than downAfter resets
+	require.Equal(t, healthUnchanged, state.update(false, "timeout", t0.Add(time.Minute*7), downAfter))
+	require.Equal(t, healthUnchanged, state.update(true, "", t0.Add(time.Minute*8), downAfter))
+	require.Equal(t, healthUnchanged, state.update(false, "timeout", t0.Add(time.Minute*9), downAfter))
+	require.True(t, state.Up)
+}
+
+func TestServerHealthTracker(t *testing.T) {
+	t0 := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newServerHealthTracker(t0)
+	server := model.NewServer("test-1", "localhost", 27015)
+	server.ServerID = 1
+	require.Equal(t, t0, tracker.lastLog(server))
+	tracker.logSeen(server, t0.Add(time.Minute))
+	require.Equal(t, t0.Add(time.Minute), tracker.lastLog(server))
+
+	transition, previous, state := tracker.record(server, model.HealthCheckA2S, errors.New("timeout"), t0, 0)
+	require.Equal(t, healthDown, transition)
+	require.False(t, previous.Known)
+	require.False(t, state.Up)
+	transition, previous, _ = tracker.record(server, model.HealthCheckA2S, nil, t0.Add(time.Minute), 0)
+	require.Equal(t, healthUp, transition)
+	require.True(t, previous.Known)
+	require.Equal(t, t0, previous.Since)
+
+	snapshot := tracker.snapshot()
+	require.Len(t, snapshot, 1)
+	require.True(t, snapshot[0].Checks[model.HealthCheckA2S].Up)
+
+	tracker.prune(nil)
+	require.Empty(t, tracker.snapshot())
+}
+
+func TestPlayerCountAnomaly(t *testing.T) {
+	require.True(t, playerCountAnomaly(24, 4, 8, 50))
+	require.True(t, playerCountAnomaly(24, 12, 8, 50))
+	require.False(t, playerCountAnomaly(24, 13, 8, 50))
+	require.False(t, playerCountAnomaly(6, 0, 8, 50))
+	require.False(t, playerCountAnomaly(24, 0, 8, 0))
+}
@@ -0,0 +1,235 @@
+package app
+
+import (
+	"context"
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/leighmacdonald/gbans/internal/event"
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/gbans/pkg/logparse"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	log "github.com/sirupsen/logrus"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// spamKind describes the type of spam detected in a message
+type spamKind string
+
+const (
+	spamFlood       spamKind = "flood"
+	spamRepeat      spamKind = "repeat"
+	spamMentions    spamKind = "mentions"
+	spamAdvertising spamKind = "advertising"
+)
+
+var (
+	reSpamURL    = regexp.MustCompile(`(?i)\b(?:https?://)?(?:www\.)?((?:[a-z0-9-]+\.)+(?:com|net|org|gg|io|tf|xyz|ru|de|eu|uk|me|co|us|fr|pl|info|site|store|shop|ly|be))\b(?:/\S*)?`)
+	reSpamIPAddr = regexp.MustCompile(`\b((?:\d{1,3}\.){3}\d{1,3})(?::\d{2,5})?\b`)
+)
+
+type spamHistoryEntry struct {
+	message   string
+	createdOn time.Time
+}
+
+// spamDetector tracks the recent chat history of players to detect floods, repeated messages,
+// mass mentions and advertising
+type spamDetector struct {
+	history map[steamid.SID64][]spamHistoryEntry
+	// maxWindow is the largest window of the thresholds checked so far, older history is never used
+	maxWindow time.Duration
+	// allowedHosts are the domains and addresses which are not considered advertising
+	allowedHosts []string
+}
+
+func newSpamDetector(allowedHosts []string) *spamDetector {
+	var hosts []string
+	for _, host := range allowedHosts {
+		hosts = append(hosts, strings.ToLower(host))
+	}
+	return &spamDetector{history: map[steamid.SID64][]spamHistoryEntry{}, allowedHosts: hosts}
+}
+
+// check records the message and returns the kind of spam it was detected as, if any. The history of the player
+// is reset after spam is detected so that a single burst only results in a single warning.
+// playerNames are the names of the other players on the server, used to detect mass mentions.
+func (detector *spamDetector) check(steamId steamid.SID64, message string, now time.Time,
+	thresholds config.SpamThresholds, playerNames []string) (spamKind, bool) {
+	normalised := strings.ToLower(strings.TrimSpace(message))
+	maxWindow := thresholds.FloodWindow
+	if thresholds.RepeatWindow > maxWindow {
+		maxWindow = thresholds.RepeatWindow
+	}
+	if maxWindow > detector.maxWindow {
+		detector.maxWindow = maxWindow
+	}
+	var history []spamHistoryEntry
+	for _, entry := range detector.history[steamId] {
+		if now.Sub(entry.createdOn) <= maxWindow {
+			history = append(history, entry)
+		}
+	}
+	history = append(history, spamHistoryEntry{message: normalised, createdOn: now})
+	detector.history[steamId] = history
+
+	kind, found := detector.detect(history, now, thresholds, playerNames)
+	if found {
+		delete(detector.history, steamId)
+	}
+	return kind, found
+}
+
+// prune removes the history of players whose most recent message is older than any of the windows checked,
+// which would otherwise accumulate for every player that stopped chatting or left
+func (detector *spamDetector) prune(now time.Time) {
+	for steamId, history := range detector.history {
+		if len(history) == 0 || now.Sub(history[len(history)-1].createdOn) > detector.maxWindow {
+			delete(detector.history, steamId)
+		}
+	}
+}
+
+func (detector *spamDetector) detect(history []spamHistoryEntry, now time.Time, thresholds config.SpamThresholds,
+	playerNames []string) (spamKind, bool) {
+	latest := history[len(history)-1]
+	if thresholds.FloodMessages > 0 {
+		count := 0
+		for _, entry := range history {
+			if now.Sub(entry.createdOn) <= thresholds.FloodWindow {
+				count++
+			}
+		}
+		if count >= thresholds.FloodMessages {
+			return spamFlood, true
+		}
+	}
+	if thresholds.RepeatMessages > 0 && latest.message != "" {
+		count := 0
+		for _, entry := range history {
+			if entry.message == latest.message && now.Sub(entry.createdOn) <= thresholds.RepeatWindow {
+				count++
+			}
+		}
+		if count >= thresholds.RepeatMessages {
+			return spamRepeat, true
+		}
+	}
+	if thresholds.MaxMentions > 0 {
+		mentions := 0
+		for _, name := range playerNames {
+			// Short names match too many regular words to be useful
+			if len(name) >= 3 && strings.Contains(latest.message, strings.ToLower(name)) {
+				mentions++
+			}
+		}
+		if mentions > thresholds.MaxMentions {
+			return spamMentions, true
+		}
+	}
+	if thresholds.Advertising && detector.isAdvertising(latest.message) {
+		return spamAdvertising, true
+	}
+	return "", false
+}
+
+// isAdvertising checks for links and server addresses which are not in the allowed hosts
+func (detector *spamDetector) isAdvertising(message string) bool {
+	for _, match := range reSpamIPAddr.FindAllStringSubmatch(message, -1) {
+		if !detector.allowed(match[1]) {
+			return true
+		}
+	}
+	for _, match := range reSpamURL.FindAllStringSubmatch(message, -1) {
+		if !detector.allowed(match[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (detector *spamDetector) allowed(host string) bool {
+	host = strings.ToLower(host)
+	for _, allowed := range detector.allowedHosts {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// spamAllowedHosts returns the configured allowed domains along with our own site and server addresses
+func (app *App) spamAllowedHosts() []string {
+	hosts := append([]string{}, config.Spam.AllowedDomains...)
+	if parsedUrl, errParse := url.Parse(config.General.ExternalUrl); errParse == nil && parsedUrl.Hostname() != "" {
+		hosts = append(hosts, parsedUrl.Hostname())
+	}
+	for _, state := range app.ServerState() {
+		hosts = append(hosts, state.Host)
+	}
+	return hosts
+}
+
+// spamWorker inspects chat messages for spam, issuing warnings with the model.Spam reason through the
+// warnWorker
+func (app *App) spamWorker(ctx context.Context) {
+	eventChan := make(chan model.ServerEvent)
+	if errRegister := event.Consume(eventChan, []logparse.EventType{logparse.Say, logparse.SayTeam}); errRegister != nil {
+		log.Errorf("Failed to register spam event reader: %v", errRegister)
+		return
+	}
+	detector := newSpamDetector(app.spamAllowedHosts())
+	// The server list can change, so our own addresses are refreshed periodically
+	ticker := time.NewTicker(time.Minute * 5)
+	log.WithFields(log.Fields{"service": "spam", "status": "ready"}).Debugf("Service status changed")
+	for {
+		select {
+		case <-ticker.C:
+			detector.allowedHosts = newSpamDetector(app.spamAllowedHosts()).allowedHosts
+			detector.prune(config.Now())
+		case serverEvent := <-eventChan:
+			msg, found := serverEvent.MetaData["msg"].(string)
+			if !found || !serverEvent.Source.SteamID.Valid() {
+				continue
+			}
+			var playerNames []string
+			var state model.ServerState
+			if app.ServerState().ByName(serverEvent.Server.ServerNameShort, &state) {
+				for _, player := range state.Players {
+					if player.SID != serverEvent.Source.SteamID {
+						playerNames = append(playerNames, player.Name)
+					}
+				}
+			}
+			thresholds := config.Spam.Thresholds(serverEvent.Server.ServerNameShort)
+			kind, isSpam := detector.check(serverEvent.Source.SteamID, msg, config.Now(), thresholds, playerNames)
+			if !isSpam {
+				continue
+			}
+			log.WithFields(log.Fields{
+				"kind":   kind,
+				"msg":    msg,
+				"sid":    serverEvent.Source.SteamID,
+				"server": serverEvent.Server.ServerNameShort,
+			}).Debugf("User triggered spam detection")
+			select {
+			case app.warningChan <- newUserWarning{
+				ServerEvent: serverEvent,
+				SpamKind:    kind,
+				UserWarning: model.UserWarning{
+					WarnReason: model.Spam,
+					Message:    msg,
+					CreatedOn:  config.Now(),
+					Weight:     1,
+				},
+			}:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			log.Debugf("spamWorker shutting down")
+			return
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package app
+
+import (
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestSpamDetector(t *testing.T) {
+	thresholds := config.SpamThresholds{
+		FloodMessages:  4,
+		FloodWindow:    time.Second * 5,
+		RepeatMessages: 3,
+		RepeatWindow:   time.Minute,
+		MaxMentions:    2,
+		Advertising:    true,
+	}
+	detector := newSpamDetector([]string{"example.com", "10.0.0.1"})
+	sid := steamid.SID64(76561198084134025)
+	t0 := time.Now()
+
+	for i, msg := range []string{"a", "b", "c"} {
+		_, found := detector.check(sid, msg, t0.Add(time.Duration(i)*time.Second), thresholds, nil)
+		require.False(t, found)
+	}
+	kind, found := detector.check(sid, "d", t0.Add(time.Second*3), thresholds, nil)
+	require.True(t, found)
+	require.Equal(t, spamFlood, kind)
+
+	// Repeated messages spread out further than the flood window
+	for i := 0; i < 2; i++ {
+		_, found = detector.check(sid, "Join my server", t0.Add(time.Duration(i*10)*time.Second), thresholds, nil)
+		require.False(t, found)
+	}
+	kind, found = detector.check(sid, "join my server ", t0.Add(time.Second*20), thresholds, nil)
+	require.True(t, found)
+	require.Equal(t, spamRepeat, kind)
+
+	names := []string{"player_one", "player_two", "player_three", "xy"}
+	_, found = detector.check(sid, "player_one and player_two", t0, thresholds, names)
+	require.False(t, found)
+	kind, found = detector.check(sid, "player_one player_two player_three", t0.Add(time.Minute*2), thresholds, names)
+	require.True(t, found)
+	require.Equal(t, spamMentions, kind)
+
+	for _, msg := range []string{"see https://example.com/rules", "connect 10.0.0.1:27015", "www.forum.example.com"} {
+		_, found = detector.check(sid, msg, t0.Add(time.Hour), thresholds, nil)
+		require.False(t, found, msg)
+	}
+	for _, msg := range []string{"play on cheapservers.net", "connect 1.2.3.4:27015", "https://spam.xyz/free"} {
+		kind, found = detector.check(sid, msg, t0.Add(time.Hour*2), thresholds, nil)
+		require.True(t, found, msg)
+		require.Equal(t, spamAdvertising, kind)
+	}
+}
+
+func TestSpamDetectorPrune(t *testing.T) {
+	thresholds := config.SpamThresholds{FloodMessages: 4, FloodWindow: time.Second * 5, RepeatMessages: 3,
+		RepeatWindow: time.Minute}
+	detector := newSpamDetector(nil)
+	t0 := time.Now()
+	active, idle := steamid.SID64(76561198084134025), steamid.SID64(76561198084134026)
+	_, _ = detector.check(idle, "hello", t0, thresholds, nil)
+	_, _ = detector.check(active, "hello", t0.Add(time.Minute), thresholds, nil)
+	detector.prune(t0.Add(time.Second * 90))
+	require.NotContains(t, detector.history, idle)
+	require.Contains(t, detector.history, active)
+}
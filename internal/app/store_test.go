@@ -6,12 +6,17 @@ import (
 	"github.com/leighmacdonald/gbans/internal/config"
 	"github.com/leighmacdonald/gbans/internal/model"
 	"github.com/leighmacdonald/gbans/internal/store"
+	"github.com/leighmacdonald/gbans/pkg/fp"
 	"github.com/leighmacdonald/golib"
 	"github.com/leighmacdonald/steamid/v2/steamid"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 	"math/rand"
 	"net"
+	"os"
+	"path"
+	"regexp"
+	"strings"
 	"testing"
 	"time"
 )
@@ -45,6 +50,20 @@ func TestServer(t *testing.T) {
 	require.Equal(t, serverA.TokenCreatedOn.Second(), s1Get.TokenCreatedOn.Second())
 	require.Equal(t, serverA.CreatedOn.Second(), s1Get.CreatedOn.Second())
 	require.Equal(t, serverA.UpdatedOn.Second(), s1Get.UpdatedOn.Second())
+	// Relay config keys are lowercased, resolve them to the actual short name
+	relayName, errRelayName := relayServerName(ctx, testDatabase, strings.ToLower(serverA.ServerNameShort))
+	require.NoError(t, errRelayName)
+	require.Equal(t, serverA.ServerNameShort, relayName)
+	// In-progress match states are saved in batches
+	matchState := model.NewMatch()
+	matchState.ServerId = serverA.ServerID
+	matchState.MapName = "pl_upward"
+	require.NoError(t, testDatabase.SaveMatchStates(ctx, []*model.Match{&matchState}))
+	states, errStates := testDatabase.GetMatchStates(ctx)
+	require.NoError(t, errStates)
+	require.Contains(t, states, serverA.ServerID)
+	require.Equal(t, "pl_upward", states[serverA.ServerID].MapName)
+	require.NoError(t, testDatabase.DropMatchState(ctx, serverA.ServerID))
 	// Fetch all enabled servers
 	sLenA, errGetServers := testDatabase.GetServers(ctx, false)
 	require.NoError(t, errGetServers, "Failed to fetch enabled servers")
@@ -71,7 +90,23 @@ func TestReport(t *testing.T) {
 	report.AuthorId = author.SteamID
 	report.ReportedId = target.SteamID
 	report.Description = golib.RandomString(120)
+	report.DemoName = "auto-20221011-1200-pl_upward.dem"
 	require.NoError(t, testDatabase.SaveReport(context.TODO(), &report))
+	var fetched model.Report
+	require.NoError(t, testDatabase.GetReport(context.TODO(), report.ReportId, &fetched))
+	require.Equal(t, report.DemoName, fetched.DemoName)
+	report.AssigneeId = author.SteamID
+	require.NoError(t, testDatabase.SaveReport(context.TODO(), &report))
+	filter := store.NewReportQueryFilter()
+	filter.AssigneeId = author.SteamID
+	filter.ReportStatus = model.Opened
+	assigned, errAssigned := testDatabase.GetReports(context.TODO(), filter)
+	require.NoError(t, errAssigned)
+	require.True(t, fp.Contains(reportIds(assigned), report.ReportId))
+	filter.ReportStatus = model.NeedMoreInfo
+	needInfo, errNeedInfo := testDatabase.GetReports(context.TODO(), filter)
+	require.NoError(t, errNeedInfo)
+	require.False(t, fp.Contains(reportIds(needInfo), report.ReportId))
 
 	msg1 := model.NewUserMessage(report.ReportId, author.SteamID, golib.RandomString(100))
 	msg2 := model.NewUserMessage(report.ReportId, author.SteamID, golib.RandomString(100))
@@ -83,6 +118,14 @@ func TestReport(t *testing.T) {
 	require.NoError(t, testDatabase.DropReport(context.Background(), &report))
 }
 
+func reportIds(reports []model.Report) []int64 {
+	var ids []int64
+	for _, report := range reports {
+		ids = append(ids, report.ReportId)
+	}
+	return ids
+}
+
 func TestBanNet(t *testing.T) {
 	banNetEqual := func(b1, b2 model.BanCIDR) {
 		require.Equal(t, b1.Reason, b2.Reason)
@@ -102,6 +145,26 @@ func TestBanNet(t *testing.T) {
 	require.Equal(t, banNet[0].Reason, banCidr.Reason)
 }
 
+func TestGetBansBySubject(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	rip := randIP()
+	var banCidr model.BanCIDR
+	require.NoError(t, NewBanCIDR(model.StringSID("76561198003911389"),
+		"76561198044052046", "10m", model.Custom,
+		"", "", model.System, fmt.Sprintf("%s/32", rip), model.Banned, &banCidr))
+	require.NoError(t, testDatabase.SaveBanNet(ctx, &banCidr))
+	matches, errMatches := testDatabase.GetBansBySubject(ctx, store.BanSubjectFilter{
+		Addresses: []net.IP{net.ParseIP(rip)},
+	})
+	require.NoError(t, errMatches)
+	require.Len(t, matches, 1)
+	require.Equal(t, model.BanKindCIDR, matches[0].Kind)
+	require.Equal(t, banCidr.NetID, matches[0].BanId)
+	require.Equal(t, rip, matches[0].MatchedOn)
+	require.True(t, matches[0].Active)
+}
+
 func TestBan(t *testing.T) {
 	banEqual := func(ban1, ban2 *model.BanSteam) {
 		require.Equal(t, ban1.BanID, ban2.BanID)
@@ -212,6 +275,60 @@ func TestGetChatHistory(t *testing.T) {
 	//require.Equal(t, "test-2", hist[0].Msg)
 }
 
+func TestSearchChat(t *testing.T) {
+	ctx := context.Background()
+	server := model.NewServer(golib.RandomString(10), "localhost", rand.Intn(65535))
+	require.NoError(t, testDatabase.SaveServer(ctx, &server))
+	var author model.Person
+	require.NoError(t, testDatabase.GetOrCreatePersonBySteamID(ctx, steamid.SID64(76561198084134025), &author))
+	marker := strings.ToLower(golib.RandomString(12))
+	bodies := []string{"before one", "before two", "the " + marker + " was here", "after one", "after two"}
+	t0 := config.Now().Add(-time.Minute)
+	for idx, body := range bodies {
+		message := model.PersonMessage{
+			SteamId:     author.SteamID,
+			ServerId:    server.ServerID,
+			Body:        body,
+			Team:        idx == 2,
+			CreatedOn:   t0.Add(time.Duration(idx) * time.Second),
+			PersonaName: "test-name",
+		}
+		require.NoError(t, testDatabase.AddChatHistory(ctx, &message))
+	}
+	for _, mode := range []store.ChatSearchMode{store.ChatSearchContains, store.ChatSearchText,
+		store.ChatSearchPhrase, store.ChatSearchRegex} {
+		query := "the " + marker
+		if mode == store.ChatSearchRegex {
+			query = "^the " + marker + ".+here$"
+		}
+		hits, errSearch := testDatabase.SearchChat(ctx, store.ChatSearchQueryFilter{
+			QueryFilter: store.QueryFilter{Query: query},
+			Mode:        mode,
+			ServerId:    server.ServerID,
+			SteamIds:    steamid.Collection{author.SteamID},
+			TeamOnly:    true,
+			Context:     1,
+		})
+		require.NoError(t, errSearch, mode)
+		require.Len(t, hits, 1, mode)
+		require.Equal(t, bodies[2], hits[0].Message.Body)
+		require.Len(t, hits[0].Before, 1)
+		require.Equal(t, "before two", hits[0].Before[0].Body)
+		require.Len(t, hits[0].After, 1)
+		require.Equal(t, "after one", hits[0].After[0].Body)
+	}
+	_, errMode := testDatabase.SearchChat(ctx, store.ChatSearchQueryFilter{
+		QueryFilter: store.QueryFilter{Query: marker},
+		Mode:        "invalid",
+	})
+	require.ErrorIs(t, errMode, store.ErrChatSearchMode)
+	_, errRegex := testDatabase.SearchChat(ctx, store.ChatSearchQueryFilter{
+		QueryFilter: store.QueryFilter{Query: "(" + marker},
+		Mode:        store.ChatSearchRegex,
+	})
+	require.ErrorIs(t, errRegex, store.ErrChatSearchRegex)
+}
+
 func TestFindLogEvents(t *testing.T) {
 	//sid := steamid.SID64(76561198083950960)
 	//sid2 := steamid.SID64(76561198083950961)
@@ -278,11 +395,11 @@ func TestFilters(t *testing.T) {
 	words := []string{golib.RandomString(10), golib.RandomString(20)}
 	var savedFilters []model.Filter
 	for wordIdx, word := range words {
-		filter := model.Filter{
-			FilterName: fmt.Sprintf("%d-%s", wordIdx, word),
-			Patterns:   model.WordFiltersFromString(word),
-			CreatedOn:  config.Now(),
-		}
+		filter := model.NewFilter(fmt.Sprintf("%d-%s", wordIdx, word), model.WordFiltersFromString(word))
+		filter.Action = model.FilterActionGag
+		filter.Weight = wordIdx + 2
+		filter.Duration = "1h"
+		filter.PingDiscord = true
 		require.NoError(t, testDatabase.SaveFilter(context.Background(), &filter), "Failed to insert filter: %s", word)
 		require.True(t, filter.WordID > 0)
 		savedFilters = append(savedFilters, filter)
@@ -296,6 +413,17 @@ func TestFilters(t *testing.T) {
 		require.NoError(t, testDatabase.GetFilterByID(context.Background(), savedFilters[1].WordID, &byId))
 		require.Equal(t, savedFilters[1].WordID, byId.WordID)
 		require.Equal(t, savedFilters[1].Patterns, byId.Patterns)
+		require.Equal(t, model.FilterActionGag, byId.Action)
+		require.Equal(t, savedFilters[1].Weight, byId.Weight)
+		require.Equal(t, model.Language, byId.BanReason)
+		require.Equal(t, "1h", byId.Duration)
+		require.True(t, byId.PingDiscord)
+		pingDiscord := false
+		updated, errAdd := New().FilterAdd(context.Background(), testDatabase, regexp.MustCompile(golib.RandomString(10)),
+			byId.FilterName, FilterActionOpts{PingDiscord: &pingDiscord})
+		require.NoError(t, errAdd)
+		require.False(t, updated.PingDiscord)
+		require.Equal(t, model.FilterActionGag, updated.Action)
 	}
 	droppedFilters, errGetDroppedFilters := testDatabase.GetFilters(context.Background())
 	require.NoError(t, errGetDroppedFilters)
@@ -322,10 +450,12 @@ func TestBanASN(t *testing.T) {
 }
 
 func TestBanGroup(t *testing.T) {
+	var target model.Person
+	require.NoError(t, testDatabase.GetOrCreatePersonBySteamID(context.TODO(), randSID(), &target))
 	var banGroup model.BanGroup
 	require.NoError(t, NewBanSteamGroup(
 		model.StringSID("76561198083950960"),
-		"",
+		model.StringSID(target.SteamID.String()),
 		"10m",
 		model.Cheating,
 		"",
@@ -338,9 +468,414 @@ func TestBanGroup(t *testing.T) {
 	require.NoError(t, testDatabase.SaveBanGroup(context.TODO(), &banGroup))
 	require.True(t, banGroup.BanGroupId > 0)
 	var bgB model.BanGroup
-	require.NoError(t, testDatabase.GetBanGroup(context.TODO(), banGroup.GroupId, &bgB))
+	require.NoError(t, testDatabase.GetBanGroupByGroupId(context.TODO(), banGroup.GroupId, &bgB))
 	require.EqualValues(t, banGroup.BanGroupId, bgB.BanGroupId)
+	var bgTarget model.BanGroup
+	require.NoError(t, testDatabase.GetBanGroup(context.TODO(), target.SteamID, &bgTarget))
+	require.EqualValues(t, banGroup.BanGroupId, bgTarget.BanGroupId)
 	require.NoError(t, testDatabase.DropBanGroup(context.TODO(), &banGroup))
 	var bgDeleted model.BanGroup
-	require.EqualError(t, store.ErrNoResult, testDatabase.GetBanGroup(context.TODO(), banGroup.GroupId, &bgDeleted).Error())
+	require.EqualError(t, store.ErrNoResult, testDatabase.GetBanGroupByGroupId(context.TODO(), banGroup.GroupId, &bgDeleted).Error())
+	require.EqualError(t, store.ErrNoResult, testDatabase.GetBanGroup(context.TODO(), target.SteamID, &bgDeleted).Error())
+}
+
+func TestBanGroupMembers(t *testing.T) {
+	gid := steamid.GID(int64(103000000000000000) + int64(rand.Int()))
+	memberA, memberB := randSID(), randSID()
+	require.NoError(t, testDatabase.SaveBanGroupMembers(context.TODO(), gid, steamid.Collection{memberA, memberB}))
+	groups, errGroups := testDatabase.GetBanGroupMemberships(context.TODO(), steamid.Collection{memberA})
+	require.NoError(t, errGroups)
+	require.Equal(t, []steamid.GID{gid}, groups)
+	// Saving again replaces the previous member list
+	require.NoError(t, testDatabase.SaveBanGroupMembers(context.TODO(), gid, steamid.Collection{memberB}))
+	groupsRemoved, errRemoved := testDatabase.GetBanGroupMemberships(context.TODO(), steamid.Collection{memberA})
+	require.NoError(t, errRemoved)
+	require.Empty(t, groupsRemoved)
+}
+
+func TestEvidence(t *testing.T) {
+	var author model.Person
+	require.NoError(t, testDatabase.GetOrCreatePersonBySteamID(context.TODO(), steamid.SID64(76561198003911389), &author))
+	media := model.NewMedia(author.SteamID, golib.RandomString(10)+".dem", model.MimeTypeDemo,
+		append([]byte("HL2DEMO\x00"), make([]byte, 64)...))
+	require.NoError(t, testDatabase.SaveMedia(context.TODO(), &media))
+	parentId := int64(rand.Int31())
+	evidence := model.NewEvidence(model.EvidenceParentReport, parentId, author.SteamID, model.EvidenceMedia)
+	evidence.MediaId = media.MediaId
+	require.NoError(t, validateEvidence(context.TODO(), testDatabase, evidence, nil, false))
+	stolen := model.NewEvidence(model.EvidenceParentReport, parentId, randSID(), model.EvidenceMedia)
+	stolen.MediaId = media.MediaId
+	require.ErrorIs(t, validateEvidence(context.TODO(), testDatabase, stolen, nil, false), errEvidenceOwner)
+	require.NoError(t, validateEvidence(context.TODO(), testDatabase, stolen, nil, true))
+	upload := model.NewMedia(author.SteamID, golib.RandomString(10)+".txt", "text/plain", []byte("not evidence"))
+	pending := model.NewEvidence(model.EvidenceParentReport, parentId, author.SteamID, model.EvidenceMedia)
+	require.ErrorIs(t, validateEvidence(context.TODO(), testDatabase, pending, &upload, false), errEvidenceMimeType)
+	require.NoError(t, testDatabase.SaveEvidence(context.TODO(), &evidence))
+	require.True(t, evidence.EvidenceId > 0)
+	entries, errEntries := testDatabase.GetEvidenceByParent(context.TODO(), model.EvidenceParentReport, parentId)
+	require.NoError(t, errEntries)
+	require.Len(t, entries, 1)
+	require.Equal(t, media.MediaId, entries[0].MediaId)
+	require.Equal(t, int64(0), entries[0].PersonMessageId)
+	require.NoError(t, testDatabase.DropEvidence(context.TODO(), &evidence))
+	var deleted model.Evidence
+	require.ErrorIs(t, testDatabase.GetEvidence(context.TODO(), evidence.EvidenceId, &deleted), store.ErrNoResult)
+}
+
+func TestBanAudit(t *testing.T) {
+	banId := int64(rand.Int())
+	entry := model.NewBanAuditEntry(model.BanKindASN, banId, "1234", model.BanAuditExpired, "")
+	require.NoError(t, testDatabase.SaveBanAuditEntry(context.TODO(), &entry))
+	require.True(t, entry.BanAuditId > 0)
+	entries, errEntries := testDatabase.GetBanAuditEntries(context.TODO(), model.BanKindASN, banId)
+	require.NoError(t, errEntries)
+	require.Len(t, entries, 1)
+	require.Equal(t, model.BanAuditExpired, entries[0].Action)
+	entries, errEntries = testDatabase.GetBanAuditEntries(context.TODO(), model.BanKindCIDR, banId)
+	require.NoError(t, errEntries)
+	require.Empty(t, entries)
+}
+
+func TestBanAuditLifecycle(t *testing.T) {
+	ctx := context.Background()
+	app := New()
+	asNum := int64(131072 + rand.Intn(1000000))
+	var banASN model.BanASN
+	require.NoError(t, NewBanASN(model.StringSID(config.General.Owner.String()), "0",
+		"10m", model.Cheating, "", "", model.System, asNum, model.Banned, &banASN))
+	require.NoError(t, app.BanASN(ctx, testDatabase, &banASN))
+	ok, errUnban := app.UnbanASN(ctx, testDatabase, fmt.Sprintf("%d", asNum))
+	require.NoError(t, errUnban)
+	require.True(t, ok)
+	entries, errEntries := testDatabase.GetBanAuditEntries(ctx, model.BanKindASN, banASN.BanASNId)
+	require.NoError(t, errEntries)
+	require.Len(t, entries, 2)
+	require.Equal(t, model.BanAuditCreated, entries[0].Action)
+	require.Equal(t, model.BanAuditUnbanned, entries[1].Action)
+
+	var banCIDR model.BanCIDR
+	require.NoError(t, NewBanCIDR(model.StringSID(config.General.Owner.String()), "0", "10m", model.Cheating,
+		"", "", model.System, fmt.Sprintf("10.%d.0.0/16", rand.Intn(255)), model.Banned, &banCIDR))
+	require.NoError(t, testDatabase.SaveBanNet(ctx, &banCIDR))
+	require.NoError(t, app.unbanCIDR(ctx, testDatabase, &banCIDR, "mistake"))
+	cidrEntries, errCIDREntries := testDatabase.GetBanAuditEntries(ctx, model.BanKindCIDR, banCIDR.NetID)
+	require.NoError(t, errCIDREntries)
+	require.Len(t, cidrEntries, 1)
+	require.Equal(t, model.BanAuditUnbanned, cidrEntries[0].Action)
+	require.Equal(t, "mistake", cidrEntries[0].Note)
+}
+
+func TestWarnings(t *testing.T) {
+	var target model.Person
+	require.NoError(t, testDatabase.GetOrCreatePersonBySteamID(context.TODO(),
+		steamid.SID64(steamid.RandSID64().Int64()+int64(rand.Int())), &target))
+	active := model.UserWarning{
+		SteamId:    target.SteamID,
+		WarnReason: model.Language,
+		Message:    golib.RandomString(40),
+		CreatedOn:  config.Now(),
+		ValidUntil: config.Now().Add(time.Hour),
+		Weight:     3,
+	}
+	expired := model.UserWarning{
+		SteamId:    target.SteamID,
+		WarnReason: model.Language,
+		Message:    golib.RandomString(40),
+		CreatedOn:  config.Now().Add(-time.Hour * 2),
+		ValidUntil: config.Now().Add(-time.Hour),
+	}
+	require.NoError(t, testDatabase.SaveWarning(context.TODO(), &active))
+	require.NoError(t, testDatabase.SaveWarning(context.TODO(), &expired))
+	require.True(t, active.WarningId > 0)
+
+	current, errCurrent := testDatabase.GetWarnings(context.TODO(), target.SteamID, false)
+	require.NoError(t, errCurrent)
+	require.Len(t, current, 1)
+	require.Equal(t, active.Message, current[0].Message)
+	require.Equal(t, 3, current[0].Weight)
+
+	all, errAll := testDatabase.GetWarnings(context.TODO(), target.SteamID, true)
+	require.NoError(t, errAll)
+	require.Len(t, all, 2)
+
+	removed, errDrop := testDatabase.DropWarnings(context.TODO(), target.SteamID)
+	require.NoError(t, errDrop)
+	require.Equal(t, int64(2), removed)
+}
+
+func TestServerActions(t *testing.T) {
+	ctx := context.Background()
+	server := model.NewServer(golib.RandomString(10), "localhost", rand.Intn(65535))
+	require.NoError(t, testDatabase.SaveServer(ctx, &server))
+	target := steamid.SID64(76561198084134025)
+	gag := model.NewServerAction(server.ServerID, target, model.ServerActionGag, "Language")
+	require.NoError(t, testDatabase.SaveServerAction(ctx, &gag))
+	require.True(t, gag.ServerActionId > 0)
+	stale := model.NewServerAction(server.ServerID, target, model.ServerActionKick, "Cheating")
+	stale.CreatedOn = config.Now().Add(-serverActionTimeout * 2)
+	require.NoError(t, testDatabase.SaveServerAction(ctx, &stale))
+
+	pending, errPending := testDatabase.GetPendingServerActions(ctx, server.ServerID)
+	require.NoError(t, errPending)
+	require.Len(t, pending, 2)
+	require.Equal(t, stale.ServerActionId, pending[0].ServerActionId)
+
+	expired, errExpire := testDatabase.ExpireServerActions(ctx, config.Now().Add(-serverActionTimeout))
+	require.NoError(t, errExpire)
+	require.True(t, expired >= 1)
+
+	require.NoError(t, onServerActionConfirmed(ctx, testDatabase, &gag, true, ""))
+	var fetched model.ServerAction
+	require.NoError(t, testDatabase.GetServerAction(ctx, gag.ServerActionId, &fetched))
+	require.Equal(t, model.ServerActionApplied, fetched.Status)
+	require.Equal(t, target, fetched.SteamId)
+
+	remaining, errRemaining := testDatabase.GetPendingServerActions(ctx, server.ServerID)
+	require.NoError(t, errRemaining)
+	require.Empty(t, remaining)
+}
+
+func TestServerUptime(t *testing.T) {
+	ctx := context.Background()
+	server := model.NewServer(golib.RandomString(10), "localhost", rand.Intn(65535))
+	require.NoError(t, testDatabase.SaveServer(ctx, &server))
+	t0 := config.Now().Add(-time.Hour)
+	up := model.ServerUptime{ServerId: server.ServerID, Check: model.HealthCheckA2S, Up: true, StartedOn: t0}
+	require.NoError(t, testDatabase.AddServerUptime(ctx, &up))
+	down := model.ServerUptime{ServerId: server.ServerID, Check: model.HealthCheckA2S, Up: false,
+		StartedOn: t0.Add(time.Minute * 30)}
+	require.NoError(t, testDatabase.AddServerUptime(ctx, &down))
+
+	intervals, errIntervals := testDatabase.GetServerUptime(ctx, server.ServerID, t0.Add(-time.Hour))
+	require.NoError(t, errIntervals)
+	require.Len(t, intervals, 2)
+	require.True(t, intervals[0].Up)
+	require.WithinDuration(t, down.StartedOn, intervals[0].EndedOn, time.Second)
+	require.True(t, intervals[1].EndedOn.IsZero())
+
+	recent, errRecent := testDatabase.GetServerUptime(ctx, server.ServerID, t0.Add(time.Minute*45))
+	require.NoError(t, errRecent)
+	require.Len(t, recent, 1)
+	require.False(t, recent[0].Up)
+}
+
+func TestWatchedServers(t *testing.T) {
+	ctx := context.Background()
+	server := model.NewWatchedServer("10.0.0.1", 20000+rand.Intn(40000))
+	server.Name = golib.RandomString(10)
+	require.NoError(t, testDatabase.SaveWatchedServer(ctx, &server))
+	require.Greater(t, server.WatchedServerId, 0)
+	duplicate := model.NewWatchedServer(server.Address, server.Port)
+	require.ErrorIs(t, testDatabase.SaveWatchedServer(ctx, &duplicate), store.ErrDuplicate)
+
+	server.IsEnabled = false
+	require.NoError(t, testDatabase.SaveWatchedServer(ctx, &server))
+	var fetched model.WatchedServer
+	require.NoError(t, testDatabase.GetWatchedServer(ctx, server.WatchedServerId, &fetched))
+	require.Equal(t, server.Name, fetched.Name)
+	require.False(t, fetched.IsEnabled)
+
+	enabled, errEnabled := testDatabase.GetWatchedServers(ctx, false)
+	require.NoError(t, errEnabled)
+	for _, watched := range enabled {
+		require.NotEqual(t, server.WatchedServerId, watched.WatchedServerId)
+	}
+	require.NoError(t, testDatabase.DropWatchedServer(ctx, server.WatchedServerId))
+	require.ErrorIs(t, testDatabase.GetWatchedServer(ctx, server.WatchedServerId, &fetched), store.ErrNoResult)
+}
+
+func TestScheduledTasks(t *testing.T) {
+	ctx := context.Background()
+	server := model.NewServer(golib.RandomString(10), "localhost", rand.Intn(65535))
+	require.NoError(t, testDatabase.SaveServer(ctx, &server))
+	task := model.NewScheduledTask(golib.RandomString(10), "0 0 4 * * *", model.ScheduledTaskCommand, "_restart")
+	task.ServerId = server.ServerID
+	task.OnlyWhenEmpty = true
+	require.NoError(t, testDatabase.SaveScheduledTask(ctx, &task))
+	require.Greater(t, task.ScheduledTaskId, 0)
+
+	regional := model.NewScheduledTask(golib.RandomString(10), "@hourly", model.ScheduledTaskAnnounce, "hello")
+	regional.Region = "eu"
+	regional.IsEnabled = false
+	require.NoError(t, testDatabase.SaveScheduledTask(ctx, &regional))
+	var fetched model.ScheduledTask
+	require.NoError(t, testDatabase.GetScheduledTask(ctx, regional.ScheduledTaskId, &fetched))
+	require.Equal(t, 0, fetched.ServerId)
+	require.Equal(t, "eu", fetched.Region)
+	enabled, errEnabled := testDatabase.GetScheduledTasks(ctx, false)
+	require.NoError(t, errEnabled)
+	for _, enabledTask := range enabled {
+		require.NotEqual(t, regional.ScheduledTaskId, enabledTask.ScheduledTaskId)
+	}
+
+	for _, status := range []model.ScheduledTaskRunStatus{model.ScheduledTaskRunSuccess, model.ScheduledTaskRunFailed} {
+		run := model.ScheduledTaskRun{ScheduledTaskId: task.ScheduledTaskId, ServerId: server.ServerID,
+			Status: status, Output: string(status), CreatedOn: config.Now()}
+		require.NoError(t, testDatabase.AddScheduledTaskRun(ctx, &run))
+	}
+	failed, errFailed := testDatabase.GetScheduledTaskRuns(ctx, store.ScheduledTaskRunQueryFilter{
+		ScheduledTaskId: task.ScheduledTaskId, Status: model.ScheduledTaskRunFailed})
+	require.NoError(t, errFailed)
+	require.Len(t, failed, 1)
+	require.Equal(t, "failed", failed[0].Output)
+
+	pruned, errPrune := testDatabase.PruneScheduledTaskRuns(ctx, config.Now().Add(time.Minute))
+	require.NoError(t, errPrune)
+	require.GreaterOrEqual(t, pruned, int64(2))
+	require.NoError(t, testDatabase.DropScheduledTask(ctx, task.ScheduledTaskId))
+	require.ErrorIs(t, testDatabase.GetScheduledTask(ctx, task.ScheduledTaskId, &fetched), store.ErrNoResult)
+}
+
+func TestSyncExternalBanList(t *testing.T) {
+	ctx := context.Background()
+	oldCachePath, oldMaxAge := config.Net.CachePath, config.Net.MaxAge
+	defer func() {
+		config.Net.CachePath, config.Net.MaxAge = oldCachePath, oldMaxAge
+	}()
+	config.Net.CachePath = t.TempDir()
+	config.Net.MaxAge = "1h"
+	list := config.BanList{Name: golib.RandomString(10), Type: config.ValveSID}
+	writeList := func(sids ...steamid.SID64) {
+		var lines []string
+		for _, sid := range sids {
+			lines = append(lines, fmt.Sprintf("banid 0 %s", steamid.SID64ToSID(sid)))
+		}
+		require.NoError(t, os.WriteFile(path.Join(config.Net.CachePath, list.Name),
+			[]byte(strings.Join(lines, "\n")), 0644))
+	}
+	unknown, banned := randSID(), randSID()
+	var existingBan model.BanSteam
+	require.NoError(t, NewBanSteam(model.StringSID(config.General.Owner.String()), model.StringSID(banned.String()),
+		"", model.Cheating, "", "", model.System, 0, model.Banned, &existingBan))
+	require.NoError(t, testDatabase.SaveBan(ctx, &existingBan))
+
+	writeList(unknown, banned)
+	require.NoError(t, syncExternalBanList(ctx, testDatabase, list))
+	entries, errEntries := testDatabase.GetExternalBanEntries(ctx, list.Name)
+	require.NoError(t, errEntries)
+	require.Len(t, entries, 2)
+	for _, entry := range entries {
+		if entry.Entry == banned.String() {
+			require.Equal(t, int64(0), entry.BanId)
+		} else {
+			require.NotEqual(t, int64(0), entry.BanId)
+		}
+	}
+	unknownBan := model.NewBannedPerson()
+	require.NoError(t, testDatabase.GetBanBySteamID(ctx, unknown, &unknownBan, false))
+	require.Equal(t, model.External, unknownBan.Ban.Reason)
+
+	// Once the existing ban is lifted, the list bans the player
+	require.NoError(t, testDatabase.DropBan(ctx, &existingBan, false))
+	require.NoError(t, syncExternalBanList(ctx, testDatabase, list))
+	bannedBan := model.NewBannedPerson()
+	require.NoError(t, testDatabase.GetBanBySteamID(ctx, banned, &bannedBan, false))
+	require.Equal(t, model.External, bannedBan.Ban.Reason)
+
+	// Removing the entry lifts the ban, adding it back bans the player again
+	writeList(unknown)
+	require.NoError(t, syncExternalBanList(ctx, testDatabase, list))
+	require.ErrorIs(t, testDatabase.GetBanBySteamID(ctx, banned, &bannedBan, false), store.ErrNoResult)
+	removedEntries, errRemovedEntries := testDatabase.GetExternalBanEntries(ctx, list.Name)
+	require.NoError(t, errRemovedEntries)
+	require.Len(t, removedEntries, 1)
+	writeList(unknown, banned)
+	require.NoError(t, syncExternalBanList(ctx, testDatabase, list))
+	require.NoError(t, testDatabase.GetBanBySteamID(ctx, banned, &bannedBan, false))
+	require.Equal(t, model.External, bannedBan.Ban.Reason)
+
+	writeList()
+	require.NoError(t, syncExternalBanList(ctx, testDatabase, list))
+	require.NoError(t, testDatabase.GetBanBySteamID(ctx, unknown, &unknownBan, false))
+	remaining, errRemaining := testDatabase.GetExternalBanEntries(ctx, list.Name)
+	require.NoError(t, errRemaining)
+	require.Len(t, remaining, 2)
+}
+
+func TestImportBans(t *testing.T) {
+	ctx := context.Background()
+	unknown, banned := randSID(), randSID()
+	var existingBan model.BanSteam
+	require.NoError(t, NewBanSteam(model.StringSID(config.General.Owner.String()), model.StringSID(banned.String()),
+		"", model.Cheating, "", "", model.System, 0, model.Banned, &existingBan))
+	require.NoError(t, testDatabase.SaveBan(ctx, &existingBan))
+	src := fmt.Sprintf("banid 0 %s\nbanid 0 %s\n", steamid.SID64ToSID(unknown), steamid.SID64ToSID(banned))
+	result, errImport := ImportBans(ctx, testDatabase, BanFormatValveSID, []byte(src))
+	require.NoError(t, errImport)
+	require.Empty(t, result.Errors)
+	require.Equal(t, 1, result.Steam)
+	require.Equal(t, 1, result.Skipped)
+	imported := model.NewBannedPerson()
+	require.NoError(t, testDatabase.GetBanBySteamID(ctx, unknown, &imported, false))
+}
+
+func TestSetAppealStateAccepted(t *testing.T) {
+	ctx := context.Background()
+	app := New()
+	target := randSID()
+	var banSteam model.BanSteam
+	require.NoError(t, NewBanSteam(model.StringSID(config.General.Owner.String()), model.StringSID(target.String()),
+		"1d", model.Cheating, "", "", model.System, 0, model.Banned, &banSteam))
+	require.NoError(t, testDatabase.SaveBan(ctx, &banSteam))
+	bannedPerson := model.NewBannedPerson()
+	require.NoError(t, testDatabase.GetBanBySteamID(ctx, target, &bannedPerson, false))
+	change, errChange := app.SetAppealState(ctx, testDatabase, &bannedPerson, config.General.Owner, model.Accepted,
+		"", "sorry")
+	require.NoError(t, errChange)
+	require.Greater(t, change.AppealStateChangeId, int64(0))
+	require.ErrorIs(t, testDatabase.GetBanBySteamID(ctx, target, &bannedPerson, false), store.ErrNoResult)
+	deleted := model.NewBannedPerson()
+	require.NoError(t, testDatabase.GetBanBySteamID(ctx, target, &deleted, true))
+	require.Equal(t, model.Accepted, deleted.Ban.AppealState)
+	require.Equal(t, "Appeal accepted: sorry", deleted.Ban.UnbanReasonText)
+	changes, errChanges := testDatabase.GetAppealStateChanges(ctx, banSteam.BanID)
+	require.NoError(t, errChanges)
+	require.Len(t, changes, 1)
+	require.Equal(t, model.Accepted, changes[0].ToState)
+}
+
+func TestBanEscalationOptIn(t *testing.T) {
+	ctx := context.Background()
+	app := New()
+	oldEscalation := config.BanEscalation
+	defer func() {
+		config.BanEscalation = oldEscalation
+	}()
+	config.BanEscalation.Enabled = true
+	config.BanEscalation.Policies = []config.BanEscalationPolicy{
+		{Reason: int(model.Language), Durations: []string{"1d", "1w"}}}
+
+	explicit := randSID()
+	var banExplicit model.BanSteam
+	require.NoError(t, NewBanSteam(model.StringSID(config.General.Owner.String()), model.StringSID(explicit.String()),
+		"1h", model.Language, "", "", model.System, 0, model.NoComm, &banExplicit))
+	validUntil := banExplicit.ValidUntil
+	require.NoError(t, app.BanSteam(ctx, testDatabase, &banExplicit, nil))
+	require.Equal(t, validUntil.Unix(), banExplicit.ValidUntil.Unix())
+
+	escalated := randSID()
+	var banEscalated model.BanSteam
+	require.NoError(t, NewBanSteam(model.StringSID(config.General.Owner.String()), model.StringSID(escalated.String()),
+		"1h", model.Language, "", "", model.System, 0, model.NoComm, &banEscalated))
+	var escalation model.BanEscalation
+	found, errEscalation := ApplyBanEscalation(ctx, testDatabase, &banEscalated, &escalation)
+	require.NoError(t, errEscalation)
+	require.True(t, found)
+	require.Equal(t, model.Duration("1d"), escalation.Duration)
+	require.Equal(t, escalation.ValidUntil.Unix(), banEscalated.ValidUntil.Unix())
+
+	// Network bans are counted separately and are not prior offences of a steam ban
+	var banCIDR model.BanCIDR
+	require.NoError(t, NewBanCIDR(model.StringSID(config.General.Owner.String()), model.StringSID(explicit.String()),
+		"1h", model.Language, "", "", model.System, fmt.Sprintf("10.%d.%d.0/24", rand.Intn(255), rand.Intn(255)),
+		model.Banned, &banCIDR))
+	require.NoError(t, testDatabase.SaveBanNet(ctx, &banCIDR))
+	counts, errCounts := testDatabase.GetBanCountsByReason(ctx, explicit, model.Language)
+	require.NoError(t, errCounts)
+	require.Equal(t, map[model.BanKind]int{model.BanKindSteam: 1, model.BanKindCIDR: 1, model.BanKindASN: 0}, counts)
+	var nextEscalation model.BanEscalation
+	foundNext, errNext := BanEscalation(ctx, testDatabase, explicit, model.Language, &nextEscalation)
+	require.NoError(t, errNext)
+	require.True(t, foundNext)
+	require.Equal(t, 1, nextEscalation.PriorOffences)
 }
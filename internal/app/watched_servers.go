@@ -0,0 +1,174 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/gbans/internal/query"
+	"github.com/leighmacdonald/gbans/internal/store"
+	"github.com/leighmacdonald/gbans/pkg/ip2location"
+	"github.com/leighmacdonald/steamweb"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WatchedServerState returns the current known state of the third party servers we watch
+func (app *App) WatchedServerState() model.ServerStateCollection {
+	app.watchedServerStateMu.RLock()
+	state := app.watchedServerState
+	app.watchedServerStateMu.RUnlock()
+	return state
+}
+
+// AllServerStates returns our own servers followed by the watched servers. Anything acting on the servers,
+// such as rcon commands or player lookups, should use ServerState instead.
+func (app *App) AllServerStates() model.ServerStateCollection {
+	own := app.ServerState()
+	watched := app.WatchedServerState()
+	states := make(model.ServerStateCollection, 0, len(own)+len(watched))
+	states = append(states, own...)
+	return append(states, watched...)
+}
+
+// newWatchedServerState builds the state of a watched server. The a2s result is nil when the server
+// did not respond.
+func newWatchedServerState(server model.WatchedServer, a2sResult *query.A2SResult, location ip2location.LatLong,
+	bannedAddresses []string, bannedPatterns []string) model.ServerState {
+	state := model.ServerState{
+		Watched:         true,
+		WatchedServerId: server.WatchedServerId,
+		Name:            server.Name,
+		NameShort:       server.Addr(),
+		Host:            server.Address,
+		Port:            server.Port,
+		Enabled:         server.IsEnabled,
+		Region:          server.Region,
+		CountryCode:     server.CC,
+		Latitude:        float32(location.Latitude),
+		Longitude:       float32(location.Longitude),
+		LastUpdate:      config.Now(),
+	}
+	if a2sResult != nil {
+		applyA2SState(&state, *a2sResult)
+	}
+	if reason := model.BannedServerReason(server.Address, server.Port, state.Name, bannedAddresses,
+		bannedPatterns); reason != "" {
+		state.Flagged = true
+		state.FlaggedReason = reason
+	}
+	return state
+}
+
+// watchedServerLocation resolves the location of the server address, caching the result by address
+func watchedServerLocation(ctx context.Context, database store.NetworkStore, address string,
+	cache map[string]ip2location.LocationRecord) ip2location.LocationRecord {
+	if record, found := cache[address]; found {
+		return record
+	}
+	var record ip2location.LocationRecord
+	ip := net.ParseIP(address)
+	if ip == nil {
+		ips, errResolve := net.DefaultResolver.LookupIP(ctx, "ip4", address)
+		if errResolve != nil || len(ips) == 0 {
+			log.WithFields(log.Fields{"address": address}).Debugf("Failed to resolve watched server: %v", errResolve)
+			return record
+		}
+		ip = ips[0]
+	}
+	if errLocation := database.GetLocationRecord(ctx, ip, &record); errLocation != nil {
+		log.WithFields(log.Fields{"address": address}).Debugf("Failed to get watched server location: %v", errLocation)
+	}
+	cache[address] = record
+	return record
+}
+
+// watchedServerUpdater periodically queries the watched servers over a2s, flagging any which are banned
+func (app *App) watchedServerUpdater(ctx context.Context, database store.Store, updateFreq time.Duration) {
+	locationCache := map[string]ip2location.LocationRecord{}
+	var update = func() {
+		servers, errServers := database.GetWatchedServers(ctx, false)
+		if errServers != nil {
+			log.Errorf("Failed to load watched servers: %v", errServers)
+			return
+		}
+		results := make([]*query.A2SResult, len(servers))
+		waitGroup := &sync.WaitGroup{}
+		for i, srv := range servers {
+			waitGroup.Add(1)
+			go func(idx int, server model.WatchedServer) {
+				defer waitGroup.Done()
+				result, errQuery := query.A2SQueryAddress(server.Addr(), server.Addr())
+				if errQuery != nil {
+					log.Tracef("Failed to update watched server a2s status: %v", errQuery)
+					return
+				}
+				results[idx] = result
+			}(i, srv)
+		}
+		waitGroup.Wait()
+		states := make(model.ServerStateCollection, len(servers))
+		for i, server := range servers {
+			location := watchedServerLocation(ctx, database, server.Address, locationCache)
+			if server.CC == "" {
+				server.CC = location.CountryCode
+			}
+			states[i] = newWatchedServerState(server, results[i], location.LatLong,
+				config.General.BannedServersAddresses, config.General.BannedServerPatterns)
+		}
+		app.watchedServerStateMu.Lock()
+		app.watchedServerState = states
+		app.watchedServerStateMu.Unlock()
+	}
+	update()
+	ticker := time.NewTicker(updateFreq)
+	for {
+		select {
+		case <-ticker.C:
+			update()
+		case <-ctx.Done():
+			log.Debugf("watchedServerUpdater shutting down")
+			return
+		}
+	}
+}
+
+// quickplayServers removes the banned servers from the master list and adds the watched servers which are
+// responding, not flagged and not already listed
+func quickplayServers(masterList []model.ServerLocation, watched model.ServerStateCollection,
+	bannedAddresses []string, bannedPatterns []string) []model.ServerLocation {
+	var servers []model.ServerLocation
+	listed := map[string]bool{}
+	for _, server := range masterList {
+		host := strings.SplitN(server.Addr, ":", 2)[0]
+		if model.BannedServerReason(host, server.Gameport, server.Name, bannedAddresses, bannedPatterns) != "" {
+			continue
+		}
+		listed[fmt.Sprintf("%s:%d", host, server.Gameport)] = true
+		servers = append(servers, server)
+	}
+	for _, state := range watched {
+		if state.Flagged || !state.Enabled || state.Map == "" || listed[fmt.Sprintf("%s:%d", state.Host, state.Port)] {
+			continue
+		}
+		servers = append(servers, model.ServerLocation{
+			LatLong: ip2location.LatLong{Latitude: float64(state.Latitude), Longitude: float64(state.Longitude)},
+			Server: steamweb.Server{
+				Addr:       state.Host,
+				Gameport:   state.Port,
+				Name:       state.Name,
+				Players:    state.PlayerCount,
+				MaxPlayers: state.MaxPlayers,
+				Bots:       state.Bots,
+				Map:        state.Map,
+				Secure:     state.VAC,
+				Dedicated:  true,
+				Gametype:   strings.Join(state.Keywords, ","),
+			},
+		})
+	}
+	return servers
+}
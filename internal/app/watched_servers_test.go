@@ -0,0 +1,60 @@
+package app
+
+import (
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/gbans/internal/query"
+	"github.com/leighmacdonald/gbans/pkg/ip2location"
+	"github.com/leighmacdonald/steamweb"
+	"github.com/rumblefrog/go-a2s"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNewWatchedServerState(t *testing.T) {
+	server := model.NewWatchedServer("10.0.0.1", 27015)
+	server.WatchedServerId = 3
+	server.Name = "Watched"
+	offline := newWatchedServerState(server, nil, ip2location.LatLong{Latitude: 1, Longitude: 2}, nil, nil)
+	require.True(t, offline.Watched)
+	require.Equal(t, 3, offline.WatchedServerId)
+	require.Equal(t, "Watched", offline.Name)
+	require.Equal(t, "10.0.0.1:27015", offline.NameShort)
+	require.Equal(t, float32(1), offline.Latitude)
+	require.False(t, offline.Flagged)
+
+	result := &query.A2SResult{
+		Info:    &a2s.ServerInfo{Name: "Free Items 24/7", Map: "ctf_2fort", Players: 5, MaxPlayers: 24},
+		Players: &a2s.PlayerInfo{Players: []*a2s.Player{{Name: "player", Score: 2}}},
+	}
+	online := newWatchedServerState(server, result, ip2location.LatLong{}, nil, []string{"free items*"})
+	require.Equal(t, "Free Items 24/7", online.Name)
+	require.Equal(t, "ctf_2fort", online.Map)
+	require.Equal(t, 5, online.PlayerCount)
+	require.Len(t, online.Players, 1)
+	require.True(t, online.Flagged)
+	require.Equal(t, "Matches banned pattern: free items*", online.FlaggedReason)
+
+	banned := newWatchedServerState(server, result, ip2location.LatLong{}, []string{"10.0.0.1"}, nil)
+	require.True(t, banned.Flagged)
+}
+
+func TestQuickplayServers(t *testing.T) {
+	masterList := []model.ServerLocation{
+		{Server: steamweb.Server{Addr: "1.1.1.1:27015", Gameport: 27015, Name: "Listed", Map: "pl_upward"}},
+		{Server: steamweb.Server{Addr: "2.2.2.2:27015", Gameport: 27015, Name: "Banned", Map: "pl_upward"}},
+		{Server: steamweb.Server{Addr: "3.3.3.3:27015", Gameport: 27015, Name: "Free Items", Map: "ctf_2fort"}},
+	}
+	watched := model.ServerStateCollection{
+		{Watched: true, Enabled: true, Host: "1.1.1.1", Port: 27015, Name: "Listed", Map: "pl_upward"},
+		{Watched: true, Enabled: true, Host: "4.4.4.4", Port: 27015, Name: "Watched", Map: "cp_process_final",
+			Keywords: []string{"cp", "payload"}},
+		{Watched: true, Enabled: true, Host: "5.5.5.5", Port: 27015, Name: "Offline"},
+		{Watched: true, Enabled: true, Host: "6.6.6.6", Port: 27015, Name: "Flagged", Map: "cp_dustbowl", Flagged: true},
+	}
+	servers := quickplayServers(masterList, watched, []string{"2.2.2.2"}, []string{"*free items*"})
+	require.Len(t, servers, 2)
+	require.Equal(t, "Listed", servers[0].Name)
+	require.Equal(t, "4.4.4.4", servers[1].Addr)
+	require.Equal(t, 27015, servers[1].Gameport)
+	require.Equal(t, "cp,payload", servers[1].Gametype)
+}
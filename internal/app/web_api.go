@@ -1,6 +1,7 @@
 package app
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"github.com/leighmacdonald/gbans/internal/config"
 	"github.com/leighmacdonald/gbans/internal/consts"
 	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/gbans/internal/query"
 	"github.com/leighmacdonald/gbans/internal/store"
 	"github.com/leighmacdonald/gbans/internal/thirdparty"
 	"github.com/leighmacdonald/gbans/pkg/fp"
@@ -20,6 +22,7 @@ import (
 	"github.com/leighmacdonald/steamweb"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"io"
 	"math"
 	"net"
 	"net/http"
@@ -157,6 +160,64 @@ func (web *web) onAPIGetServerAdmins(database store.Store) gin.HandlerFunc {
 	}
 }
 
+// onAPIGetServerActions returns the gags, ungags and kicks waiting to be applied by the requesting server
+func (web *web) onAPIGetServerActions(database store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		actions, errActions := database.GetPendingServerActions(ctx, currentServerId(ctx))
+		if errActions != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			return
+		}
+		if actions == nil {
+			actions = []model.ServerAction{}
+		}
+		responseOK(ctx, http.StatusOK, actions)
+	}
+}
+
+// onAPIPostServerActionConfirm records the result of applying a server action reported by the plugin
+func (web *web) onAPIPostServerActionConfirm(database store.Store) gin.HandlerFunc {
+	type confirmRequest struct {
+		Success bool   `json:"success"`
+		Message string `json:"message"`
+	}
+	return func(ctx *gin.Context) {
+		serverActionId, errId := getInt64Param(ctx, "server_action_id")
+		if errId != nil {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			return
+		}
+		var req confirmRequest
+		if errBind := ctx.BindJSON(&req); errBind != nil {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			return
+		}
+		var action model.ServerAction
+		if errAction := database.GetServerAction(ctx, serverActionId, &action); errAction != nil {
+			if errors.Is(errAction, store.ErrNoResult) {
+				responseErr(ctx, http.StatusNotFound, nil)
+				return
+			}
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			return
+		}
+		// Servers may only confirm their own actions
+		if action.ServerId != currentServerId(ctx) {
+			responseErr(ctx, http.StatusNotFound, nil)
+			return
+		}
+		if action.Status != model.ServerActionPending {
+			responseErr(ctx, http.StatusConflict, "Action already confirmed")
+			return
+		}
+		if errConfirm := onServerActionConfirmed(ctx, database, &action, req.Success, req.Message); errConfirm != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			return
+		}
+		responseOK(ctx, http.StatusOK, action)
+	}
+}
+
 func (web *web) onAPIPostPingMod(database store.Store) gin.HandlerFunc {
 	type pingReq struct {
 		ServerName string        `json:"server_name"`
@@ -180,12 +241,8 @@ func (web *web) onAPIPostPingMod(database store.Store) gin.HandlerFunc {
 		//if playerInfo.InGame {
 		//	name = fmt.Sprintf("%s (%s)", name, playerInfo.Player.Name)
 		//}
-		var roleStrings []string
-		for _, roleID := range config.Discord.ModRoleIDs {
-			roleStrings = append(roleStrings, fmt.Sprintf("<@&%s>", roleID))
-		}
 		embed := respOk(nil, "New User Report")
-		embed.Description = fmt.Sprintf("%s | %s", req.Reason, strings.Join(roleStrings, " "))
+		embed.Description = fmt.Sprintf("%s | %s", req.Reason, modRoleMentions())
 		if playerInfo.Player != nil && playerInfo.Player.Name != "" {
 			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
 				Name:   "Reporter",
@@ -223,6 +280,163 @@ func (web *web) onAPIPostPingMod(database store.Store) gin.HandlerFunc {
 	}
 }
 
+const (
+	// reportChatSnapshotSize is the max number of chat messages attached to in-game reports
+	reportChatSnapshotSize = 30
+	// reportChatSnapshotAge is how far back in-game reports look for chat messages
+	reportChatSnapshotAge = time.Minute * 5
+)
+
+// onAPIPostServerReport handles in-game reports sent by the sourcemod plugin. The report is created in
+// the same queue as web reports with a snapshot of the recent chat on the server attached.
+func (web *web) onAPIPostServerReport(database store.Store) gin.HandlerFunc {
+	type serverReportRequest struct {
+		SourceId model.StringSID `json:"source_id"`
+		TargetId model.StringSID `json:"target_id"`
+		Reason   string          `json:"reason"`
+		DemoName string          `json:"demo_name"`
+		Client   int             `json:"client"`
+	}
+	return func(ctx *gin.Context) {
+		var req serverReportRequest
+		if errBind := ctx.BindJSON(&req); errBind != nil {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			return
+		}
+		if strings.TrimSpace(req.Reason) == "" {
+			responseErr(ctx, http.StatusBadRequest, "Must supply a reason")
+			return
+		}
+		sourceId, errSourceId := req.SourceId.SID64()
+		if errSourceId != nil {
+			responseErr(ctx, http.StatusBadRequest, "Invalid source_id")
+			return
+		}
+		targetId, errTargetId := req.TargetId.SID64()
+		if errTargetId != nil {
+			responseErr(ctx, http.StatusBadRequest, "Invalid target_id")
+			return
+		}
+		if sourceId == targetId {
+			responseErr(ctx, http.StatusBadRequest, "Cannot report yourself")
+			return
+		}
+		var server model.Server
+		if errServer := database.GetServer(ctx, currentServerId(ctx), &server); errServer != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Failed to load report server: %v", errServer)
+			return
+		}
+		var author model.Person
+		if errAuthor := getOrCreateProfileBySteamID(ctx, database, sourceId, "", &author); errAuthor != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Could not load reporter profile: %v", errAuthor)
+			return
+		}
+		var target model.Person
+		if errTarget := getOrCreateProfileBySteamID(ctx, database, targetId, "", &target); errTarget != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Could not load reported profile: %v", errTarget)
+			return
+		}
+		var existing model.Report
+		if errExisting := database.GetReportBySteamId(ctx, sourceId, targetId, &existing); errExisting != nil {
+			if !errors.Is(errExisting, store.ErrNoResult) {
+				responseErr(ctx, http.StatusInternalServerError, nil)
+				log.Errorf("Failed to query reports by steam id: %v", errExisting)
+				return
+			}
+		}
+		if existing.ReportId > 0 {
+			responseOK(ctx, http.StatusConflict, gin.H{
+				"client":    req.Client,
+				"report_id": existing.ReportId,
+				"url":       existing.ToURL(),
+				"message":   "You already have an open report against this player",
+			})
+			return
+		}
+		sentAfter := config.Now().Add(-reportChatSnapshotAge)
+		messages, errMessages := database.QueryChatHistory(ctx, store.ChatHistoryQueryFilter{
+			QueryFilter: store.QueryFilter{
+				Limit:    reportChatSnapshotSize,
+				OrderBy:  "m.created_on",
+				SortDesc: true,
+			},
+			ServerId:  server.ServerID,
+			SentAfter: &sentAfter,
+		})
+		if errMessages != nil && !errors.Is(errMessages, store.ErrNoResult) {
+			// The report is still useful without the chat log
+			log.Errorf("Failed to fetch chat snapshot for report: %v", errMessages)
+		}
+		report := model.NewReport()
+		report.AuthorId = sourceId
+		report.ReportedId = targetId
+		report.ReportStatus = model.Opened
+		report.Reason = model.Custom
+		report.ReasonText = req.Reason
+		report.DemoName = req.DemoName
+		report.Description = reportDescription(server, req.Reason, req.DemoName, messages)
+		if errReportSave := database.SaveReport(ctx, &report); errReportSave != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Failed to save report: %v", errReportSave)
+			return
+		}
+		responseOK(ctx, http.StatusCreated, gin.H{
+			"client":    req.Client,
+			"report_id": report.ReportId,
+			"url":       report.ToURL(),
+			"message":   "Report created",
+		})
+
+		embed := respOk(nil, "New in-game user report created")
+		embed.Description = req.Reason
+		embed.URL = report.ToURL()
+		addField(embed, "Reporter", author.PersonaName)
+		name := target.PersonaName
+		if name == "" {
+			name = report.ReportedId.String()
+		}
+		addField(embed, "Subject", name)
+		addFieldInline(embed, "Server", server.ServerNameShort)
+		if report.DemoName != "" {
+			addFieldInline(embed, "Demo", report.DemoName)
+		}
+		addFieldsSteamID(embed, report.ReportedId)
+		addLink(embed, report)
+		select {
+		case web.botSendMessageChan <- discordPayload{channelId: config.Discord.ReportLogChannelId, embed: embed}:
+		default:
+			log.Warnf("Cannot send discord payload, channel full")
+		}
+	}
+}
+
+// reportDescription renders the markdown body for reports created in-game
+func reportDescription(server model.Server, reason string, demoName string, messages model.PersonMessages) string {
+	var body strings.Builder
+	body.WriteString(fmt.Sprintf("In-game report on **%s**\n\n%s\n", server.ServerNameShort, reason))
+	if demoName != "" {
+		body.WriteString(fmt.Sprintf("\nDemo: `%s`\n", demoName))
+	}
+	if len(messages) > 0 {
+		body.WriteString("\n#### Recent Chat\n\n```\n")
+		// Messages are fetched newest first
+		for i := len(messages) - 1; i >= 0; i-- {
+			msg := messages[i]
+			team := ""
+			if msg.Team {
+				team = "(team) "
+			}
+			body.WriteString(fmt.Sprintf("[%s] %s%s: %s\n", msg.CreatedOn.Format("15:04:05"), team,
+				msg.PersonaName, strings.ReplaceAll(msg.Body, "```", "")))
+		}
+		body.WriteString("```\n")
+	}
+	return body.String()
+}
+
 func (web *web) onAPIPostBanState(database store.Store) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		reportId, errId := getInt64Param(ctx, "report_id")
@@ -250,6 +464,9 @@ type apiUnbanRequest struct {
 func (web *web) onAPIPostSetBanAppealStatus(database store.Store) gin.HandlerFunc {
 	type setStatusReq struct {
 		AppealState model.AppealState `json:"appeal_state"`
+		// Duration is the new ban duration, from now, used when the appeal state is Reduced
+		Duration model.Duration `json:"duration"`
+		Note     string         `json:"note"`
 	}
 	return func(ctx *gin.Context) {
 		banId, banIdErr := getInt64Param(ctx, "ban_id")
@@ -271,18 +488,35 @@ func (web *web) onAPIPostSetBanAppealStatus(database store.Store) gin.HandlerFun
 			responseErr(ctx, http.StatusConflict, "State must be different than previous")
 			return
 		}
-		original := bp.Ban.AppealState
-		bp.Ban.AppealState = req.AppealState
-		if errSave := database.SaveBan(ctx, &bp.Ban); errSave != nil {
-			responseErr(ctx, http.StatusInternalServerError, "Failed to save appeal state changes")
+		change, errState := web.app.SetAppealState(ctx, database, &bp, currentUserProfile(ctx).SteamID,
+			req.AppealState, req.Duration, req.Note)
+		if errState != nil {
+			if errors.Is(errState, consts.ErrInvalidState) {
+				responseErr(ctx, http.StatusConflict, errState.Error())
+				return
+			}
+			log.WithFields(log.Fields{"ban_id": banId}).Errorf("Failed to set appeal state: %v", errState)
+			responseErr(ctx, http.StatusBadRequest, "Failed to save appeal state changes")
 			return
 		}
-		responseOK(ctx, http.StatusAccepted, nil)
-		log.WithFields(log.Fields{
-			"ban_id": banId,
-			"from":   original,
-			"to":     req.AppealState,
-		}).Info("Updated ban appeal state")
+		responseOK(ctx, http.StatusAccepted, change)
+	}
+}
+
+func (web *web) onAPIGetBanAppealHistory(database store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		banId, banIdErr := getInt64Param(ctx, "ban_id")
+		if banIdErr != nil {
+			responseErr(ctx, http.StatusBadRequest, "Invalid ban_id format")
+			return
+		}
+		changes, errChanges := database.GetAppealStateChanges(ctx, banId)
+		if errChanges != nil {
+			log.WithFields(log.Fields{"ban_id": banId}).Errorf("Failed to get appeal history: %v", errChanges)
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			return
+		}
+		responseOK(ctx, http.StatusOK, changes)
 	}
 }
 
@@ -458,6 +692,8 @@ func (web *web) onAPIPostBanSteamCreate(database store.Store) gin.HandlerFunc {
 		ReasonText string          `json:"reason_text"`
 		Note       string          `json:"note"`
 		ReportId   int64           `json:"report_id"`
+		// Escalate replaces the duration with the one from the ban escalation policy of the reason
+		Escalate bool `json:"escalate"`
 	}
 	return func(ctx *gin.Context) {
 		var banRequest apiBanRequest
@@ -488,6 +724,19 @@ func (web *web) onAPIPostBanSteamCreate(database store.Store) gin.HandlerFunc {
 			responseErr(ctx, http.StatusBadRequest, "Failed to parse options")
 			return
 		}
+		if banRequest.Escalate {
+			var escalation model.BanEscalation
+			escalated, errEscalation := ApplyBanEscalation(ctx, database, &banSteam, &escalation)
+			if errEscalation != nil {
+				log.WithError(errEscalation).Errorf("Failed to calculate ban escalation")
+				responseErr(ctx, http.StatusInternalServerError, nil)
+				return
+			}
+			if !escalated {
+				responseErr(ctx, http.StatusBadRequest, "No escalation policy exists for the reason")
+				return
+			}
+		}
 		if errBan := web.app.BanSteam(ctx, database, &banSteam, web.botSendMessageChan); errBan != nil {
 			log.WithFields(log.Fields{"target_id": banSteam.TargetId.String()}).
 				Errorf("Failed to ban steam profile: %v", errBan)
@@ -502,6 +751,40 @@ func (web *web) onAPIPostBanSteamCreate(database store.Store) gin.HandlerFunc {
 	}
 }
 
+// onAPIPostBanEscalation previews the duration that the configured ban escalation policy will apply
+// to a new ban so that it can be shown before the ban is confirmed.
+func (web *web) onAPIPostBanEscalation(database store.Store) gin.HandlerFunc {
+	type escalationRequest struct {
+		TargetId model.StringSID `json:"target_id"`
+		Reason   model.Reason    `json:"reason"`
+	}
+	type escalationResponse struct {
+		Escalated bool `json:"escalated"`
+		model.BanEscalation
+	}
+	return func(ctx *gin.Context) {
+		var req escalationRequest
+		if errBind := ctx.BindJSON(&req); errBind != nil {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			return
+		}
+		targetId, errTargetId := req.TargetId.SID64()
+		if errTargetId != nil {
+			responseErr(ctx, http.StatusBadRequest, "Invalid target_id")
+			return
+		}
+		var resp escalationResponse
+		escalated, errEscalation := BanEscalation(ctx, database, targetId, req.Reason, &resp.BanEscalation)
+		if errEscalation != nil {
+			log.WithError(errEscalation).Errorf("Failed to calculate ban escalation")
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			return
+		}
+		resp.Escalated = escalated
+		responseOK(ctx, http.StatusOK, resp)
+	}
+}
+
 func (web *web) onSAPIPostServerAuth(database store.Store) gin.HandlerFunc {
 	type authReq struct {
 		ServerName string `json:"server_name"`
@@ -587,10 +870,18 @@ func (web *web) onAPIPostServerCheck(database store.Store) gin.HandlerFunc {
 			return
 		}
 
-		if web.app.IsSteamGroupBanned(steamID) {
+		recordJoinAttempt := func(attemptType model.BanKind, banId int64) {
+			attempt := model.NewBanJoinAttempt(attemptType, banId, steamID, currentServerId(ctx), request.IP)
+			if errAttempt := database.SaveBanJoinAttempt(responseCtx, &attempt); errAttempt != nil {
+				log.WithFields(log.Fields{"type": attemptType, "ban_id": banId}).
+					Errorf("Failed to save ban join attempt: %v", errAttempt)
+			}
+		}
+		if banGroupId, groupBanned := web.app.bannedSteamGroup(steamID); groupBanned {
 			resp.BanType = model.Banned
 			resp.Msg = "Group Banned"
 			responseErr(ctx, http.StatusOK, resp)
+			recordJoinAttempt(model.BanKindGroup, banGroupId)
 			log.WithFields(log.Fields{"type": "group", "reason": "Group Ban", "sid64": steamID.String()}).
 				Infof("Player dropped")
 			return
@@ -614,6 +905,16 @@ func (web *web) onAPIPostServerCheck(database store.Store) gin.HandlerFunc {
 		}); errAddHist != nil {
 			log.Errorf("Failed to add conn history: %v", errAddHist)
 		}
+		if config.AltDetection.Enabled {
+			// Runs in the background so the connecting player is not held up, evasion bans will kick the player
+			go func() {
+				altCtx, cancelAlt := context.WithTimeout(context.Background(), time.Second*30)
+				defer cancelAlt()
+				if errAlts := web.app.checkLinkedAccounts(altCtx, database, steamID, request.IP); errAlts != nil {
+					log.WithFields(log.Fields{"sid64": steamID.String()}).Errorf("Failed to check linked accounts: %v", errAlts)
+				}
+			}()
+		}
 		// Check IP first
 		banNet, errGetBanNet := database.GetBanNetByAddress(responseCtx, request.IP)
 		if errGetBanNet != nil {
@@ -628,6 +929,7 @@ func (web *web) onAPIPostServerCheck(database store.Store) gin.HandlerFunc {
 			resp.BanType = model.Banned
 			resp.Msg = fmt.Sprintf("Network banned (C: %d)", len(banNet))
 			responseOK(ctx, http.StatusOK, resp)
+			recordJoinAttempt(model.BanKindCIDR, banNet[0].NetID)
 			log.WithFields(log.Fields{"type": "cidr", "reason": banNet[0].Reason,
 				"sid64": steamid.SIDToSID64(request.SteamID)}).Infof("Player dropped")
 			return
@@ -644,6 +946,7 @@ func (web *web) onAPIPostServerCheck(database store.Store) gin.HandlerFunc {
 				resp.BanType = model.Banned
 				resp.Msg = asnBan.Reason.String()
 				responseOK(ctx, http.StatusOK, resp)
+				recordJoinAttempt(model.BanKindASN, asnBan.BanASNId)
 				log.WithFields(log.Fields{"type": "asn", "reason": asnBan.Reason, "sid64": steamID.String()}).
 					Infof("Player dropped")
 				return
@@ -677,6 +980,7 @@ func (web *web) onAPIPostServerCheck(database store.Store) gin.HandlerFunc {
 			log.WithFields(log.Fields{"type": "steam", "reason": reason, "profile": bannedPerson.Person.ToURL(), "banType": "mute"}).
 				Infof("Player muted")
 		} else if resp.BanType == model.Banned {
+			recordJoinAttempt(model.BanKindSteam, bannedPerson.Ban.BanID)
 			log.WithFields(log.Fields{
 				"type":    "steam",
 				"profile": bannedPerson.Person.ToURL(),
@@ -758,10 +1062,76 @@ func (web *web) onAPIGetPrometheusHosts(database store.Store) gin.HandlerFunc {
 	}
 }
 
-// onAPIGetServerStates returns the current known cached server state
+// onAPIGetRCONStats returns the rcon connection state, latency and error counts of each server
+func (web *web) onAPIGetRCONStats() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		responseOK(ctx, http.StatusOK, query.GetRCONStats())
+	}
+}
+
+// onAPIGetServerHealth returns the current state of each server health check
+func (web *web) onAPIGetServerHealth() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		responseOK(ctx, http.StatusOK, web.app.serverHealth.snapshot())
+	}
+}
+
+// onAPIGetServerUptime returns the uptime percentage of each health check of a server over the requested
+// window, defaulting to the last 7 days
+func (web *web) onAPIGetServerUptime(database store.Store) gin.HandlerFunc {
+	type uptimeResponse struct {
+		ServerId  int                           `json:"server_id"`
+		Since     time.Time                     `json:"since"`
+		Uptime    map[model.HealthCheck]float64 `json:"uptime"`
+		Intervals []model.ServerUptime          `json:"intervals"`
+	}
+	return func(ctx *gin.Context) {
+		serverId, errServerId := getIntParam(ctx, "server_id")
+		if errServerId != nil {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			return
+		}
+		window, errWindow := config.ParseDuration(ctx.DefaultQuery("window", "7d"))
+		if errWindow != nil || window <= 0 {
+			responseErr(ctx, http.StatusBadRequest, "Invalid window")
+			return
+		}
+		var server model.Server
+		if errServer := database.GetServer(ctx, serverId, &server); errServer != nil {
+			if errors.Is(errServer, store.ErrNoResult) {
+				responseErr(ctx, http.StatusNotFound, nil)
+				return
+			}
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			return
+		}
+		now := config.Now()
+		since := now.Add(-window)
+		intervals, errIntervals := database.GetServerUptime(ctx, serverId, since)
+		if errIntervals != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			return
+		}
+		resp := uptimeResponse{
+			ServerId:  serverId,
+			Since:     since,
+			Uptime:    map[model.HealthCheck]float64{},
+			Intervals: intervals,
+		}
+		for _, check := range model.HealthChecks {
+			if percent, found := model.UptimePercent(intervals, check, since, now); found {
+				resp.Uptime[check] = percent
+			}
+		}
+		responseOK(ctx, http.StatusOK, resp)
+	}
+}
+
+// onAPIGetServerStates returns the current known cached state of our own and watched servers, without the
+// rcon sourced player details
 func (web *web) onAPIGetServerStates() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		responseOK(ctx, http.StatusOK, web.app.ServerState())
+		responseOK(ctx, http.StatusOK, web.app.AllServerStates().Public())
 	}
 }
 
@@ -873,6 +1243,80 @@ func (web *web) onAPIExportBansTF2BD(database store.Store) gin.HandlerFunc {
 	}
 }
 
+// maxBanImportSize limits the size of uploaded ban import files
+const maxBanImportSize = 50 << 20
+
+func (web *web) onAPIPostBansImport(database store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		format, errFormat := ParseBanFormat(ctx.Param("format"))
+		if errFormat != nil {
+			responseErr(ctx, http.StatusBadRequest, "Invalid format")
+			return
+		}
+		body, errBody := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxBanImportSize))
+		if errBody != nil {
+			responseErr(ctx, http.StatusBadRequest, "Invalid file")
+			return
+		}
+		result, errImport := ImportBans(ctx, database, format, body)
+		if errImport != nil {
+			responseErr(ctx, http.StatusBadRequest, "Failed to read bans")
+			log.Errorf("Failed to import bans: %v", errImport)
+			return
+		}
+		responseOK(ctx, http.StatusOK, result)
+	}
+}
+
+func (web *web) onAPIGetBansExport(database store.Store) gin.HandlerFunc {
+	fileNames := map[BanFormat]string{
+		BanFormatJSON:       "gbans_bans.json",
+		BanFormatSourceBans: "sourcebans.sql",
+		BanFormatValveSID:   "banned_user.cfg",
+		BanFormatValveIP:    "banned_ip.cfg",
+	}
+	return func(ctx *gin.Context) {
+		format, errFormat := ParseBanFormat(ctx.Param("format"))
+		if errFormat != nil {
+			responseErr(ctx, http.StatusBadRequest, "Invalid format")
+			return
+		}
+		var buf bytes.Buffer
+		if errExport := ExportBans(ctx, database, format, &buf); errExport != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Failed to export bans: %v", errExport)
+			return
+		}
+		ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", fileNames[format]))
+		ctx.Data(http.StatusOK, "text/plain; charset=utf-8", buf.Bytes())
+	}
+}
+
+func (web *web) onAPIGetExternalBanSources(database store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		sources, errSources := database.GetExternalBanSources(ctx)
+		if errSources != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Failed to fetch external ban sources: %v", errSources)
+			return
+		}
+		// Include configured lists which have not synced any entries yet
+		for _, list := range config.Net.Sources {
+			found := false
+			for _, source := range sources {
+				if source.Source == list.Name {
+					found = true
+					break
+				}
+			}
+			if !found {
+				sources = append(sources, model.ExternalBanSource{Source: list.Name})
+			}
+		}
+		responseOK(ctx, http.StatusOK, sources)
+	}
+}
+
 func (web *web) onAPIProfile(database store.Store) gin.HandlerFunc {
 	type req struct {
 		Query string `form:"query"`
@@ -880,6 +1324,8 @@ func (web *web) onAPIProfile(database store.Store) gin.HandlerFunc {
 	type resp struct {
 		Player  *model.Person            `json:"player"`
 		Friends []steamweb.PlayerSummary `json:"friends"`
+		// LinkedAccounts is only included for moderators
+		LinkedAccounts *model.LinkedAccounts `json:"linked_accounts,omitempty"`
 	}
 	return func(ctx *gin.Context) {
 		requestCtx, cancelRequest := context.WithTimeout(ctx, time.Second*15)
@@ -913,6 +1359,15 @@ func (web *web) onAPIProfile(database store.Store) gin.HandlerFunc {
 		var response resp
 		response.Player = &person
 		response.Friends = friends
+		if viewerPermissionLevel(ctx, database) >= model.PModerator {
+			var graph model.LinkedAccounts
+			if errLinked := web.app.LinkedAccounts(requestCtx, database, sid, &graph); errLinked != nil {
+				log.WithFields(log.Fields{"sid64": sid.String()}).Errorf("Failed to get linked accounts: %v", errLinked)
+				responseErr(ctx, http.StatusInternalServerError, nil)
+				return
+			}
+			response.LinkedAccounts = &graph
+		}
 		responseOK(ctx, http.StatusOK, response)
 	}
 }
@@ -924,7 +1379,7 @@ func (web *web) onAPIGetWordFilters(database store.Store) gin.HandlerFunc {
 			responseErr(ctx, http.StatusInternalServerError, nil)
 			return
 		}
-		responseOK(ctx, http.StatusOK, words)
+		responseOK(ctx, http.StatusOK, append(words, getExternalFilters()...))
 	}
 }
 
@@ -932,6 +1387,17 @@ func (web *web) onAPIPostWordMatch(database store.Store) gin.HandlerFunc {
 	type matchRequest struct {
 		Query string
 	}
+	type filterMatch struct {
+		Filter model.Filter        `json:"filter"`
+		Word   string              `json:"word"`
+		Step   model.NormaliseStep `json:"step"`
+	}
+	// Normalised contains the query after each normalisation step so that mods can see what the
+	// filters were matched against
+	type matchResponse struct {
+		Normalised []model.NormalisedText `json:"normalised"`
+		Matches    []filterMatch          `json:"matches"`
+	}
 	return func(ctx *gin.Context) {
 		var req matchRequest
 		if errBind := ctx.BindJSON(&req); errBind != nil {
@@ -945,13 +1411,15 @@ func (web *web) onAPIPostWordMatch(database store.Store) gin.HandlerFunc {
 			responseErr(ctx, http.StatusInternalServerError, nil)
 			return
 		}
-		var matches []model.Filter
+		words = append(words, getExternalFilters()...)
+		normalised := model.Normalise(req.Query)
+		resp := matchResponse{Normalised: normalised, Matches: []filterMatch{}}
 		for _, filter := range words {
-			if filter.Match(req.Query) {
-				matches = append(matches, filter)
+			if word, step, matched := filter.MatchNormalised(normalised, config.Filter.Normalise); matched {
+				resp.Matches = append(resp.Matches, filterMatch{Filter: filter, Word: word, Step: step})
 			}
 		}
-		responseOK(ctx, http.StatusOK, matches)
+		responseOK(ctx, http.StatusOK, resp)
 	}
 }
 
@@ -992,6 +1460,24 @@ func (web *web) onAPIPostWordFilter(database store.Store) gin.HandlerFunc {
 			responseErr(ctx, http.StatusBadRequest, nil)
 			return
 		}
+		if filter.ReadOnly() {
+			responseErr(ctx, http.StatusBadRequest, "External filters are read-only")
+			return
+		}
+		// Older clients do not send the action settings, so they get the default warn only behaviour
+		if filter.Action == "" {
+			filter.Action = model.FilterActionWarn
+		}
+		if filter.Weight == 0 {
+			filter.Weight = 1
+		}
+		if filter.BanReason == 0 {
+			filter.BanReason = model.Language
+		}
+		if errValidate := filter.Validate(); errValidate != nil {
+			responseErr(ctx, http.StatusBadRequest, errValidate.Error())
+			return
+		}
 		now := config.Now()
 		if filter.WordID > 0 {
 			var existingFilter model.Filter
@@ -1006,21 +1492,23 @@ func (web *web) onAPIPostWordFilter(database store.Store) gin.HandlerFunc {
 			existingFilter.UpdatedOn = now
 			existingFilter.FilterName = filter.FilterName
 			existingFilter.Patterns = filter.Patterns
+			existingFilter.Action = filter.Action
+			existingFilter.Weight = filter.Weight
+			existingFilter.BanReason = filter.BanReason
+			existingFilter.Duration = filter.Duration
+			existingFilter.PingDiscord = filter.PingDiscord
 			if errSave := database.SaveFilter(ctx, &existingFilter); errSave != nil {
 				responseErr(ctx, http.StatusInternalServerError, nil)
 				return
 			}
 			filter = existingFilter
 		} else {
-			newFilter := model.Filter{
-				WordID:           0,
-				Patterns:         filter.Patterns,
-				CreatedOn:        now,
-				UpdatedOn:        now,
-				DiscordId:        "",
-				DiscordCreatedOn: nil,
-				FilterName:       filter.FilterName,
-			}
+			newFilter := model.NewFilter(filter.FilterName, filter.Patterns)
+			newFilter.Action = filter.Action
+			newFilter.Weight = filter.Weight
+			newFilter.BanReason = filter.BanReason
+			newFilter.Duration = filter.Duration
+			newFilter.PingDiscord = filter.PingDiscord
 			if errSave := database.SaveFilter(ctx, &newFilter); errSave != nil {
 				responseErr(ctx, http.StatusInternalServerError, nil)
 				return
@@ -1069,6 +1557,11 @@ func loadBanMeta(_ *model.BannedPerson) {
 }
 
 func (web *web) onAPIGetBanByID(database store.Store) gin.HandlerFunc {
+	type banResponse struct {
+		model.BannedPerson
+		// JoinAttempts is only included for moderators
+		JoinAttempts *int `json:"join_attempts,omitempty"`
+	}
 	return func(ctx *gin.Context) {
 		curUser := currentUserProfile(ctx)
 		banId, errId := getInt64Param(ctx, "ban_id")
@@ -1098,7 +1591,80 @@ func (web *web) onAPIGetBanByID(database store.Store) gin.HandlerFunc {
 			return
 		}
 		loadBanMeta(&bannedPerson)
-		responseOK(ctx, http.StatusOK, bannedPerson)
+		response := banResponse{BannedPerson: bannedPerson}
+		if curUser.PermissionLevel >= model.PModerator {
+			joinAttempts, errJoinAttempts := database.GetBanJoinAttemptCount(ctx, model.BanKindSteam, bannedPerson.Ban.BanID)
+			if errJoinAttempts != nil {
+				log.Errorf("Failed to fetch ban join attempt count: %v", errJoinAttempts)
+			}
+			response.JoinAttempts = &joinAttempts
+		}
+		responseOK(ctx, http.StatusOK, response)
+	}
+}
+
+func (web *web) onAPIGetBanJoinAttempts(database store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var queryFilter store.BanJoinAttemptQueryFilter
+		if errBind := ctx.BindJSON(&queryFilter); errBind != nil {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			return
+		}
+		attempts, errAttempts := database.GetBanJoinAttempts(ctx, queryFilter)
+		if errAttempts != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Failed to fetch ban join attempts: %v", errAttempts)
+			return
+		}
+		responseOK(ctx, http.StatusOK, attempts)
+	}
+}
+
+type banSearchRequest struct {
+	Query   string `json:"query"`
+	Deleted bool   `json:"deleted"`
+}
+
+func (web *web) onAPISearchBans(database store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req banSearchRequest
+		if errBind := ctx.BindJSON(&req); errBind != nil {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			return
+		}
+		result, errSearch := web.app.SearchBans(ctx, database, req.Query, req.Deleted)
+		if errSearch != nil {
+			if errors.Is(errSearch, consts.ErrInvalidSID) {
+				responseErr(ctx, http.StatusBadRequest, "Invalid query")
+				return
+			}
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Failed to search bans: %v", errSearch)
+			return
+		}
+		responseOK(ctx, http.StatusOK, result)
+	}
+}
+
+type banAuditRequest struct {
+	Kind  model.BanKind `json:"kind"`
+	BanId int64         `json:"ban_id"`
+}
+
+func (web *web) onAPIGetBanAudit(database store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req banAuditRequest
+		if errBind := ctx.BindJSON(&req); errBind != nil {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			return
+		}
+		entries, errEntries := database.GetBanAuditEntries(ctx, req.Kind, req.BanId)
+		if errEntries != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Failed to fetch ban audit entries: %v", errEntries)
+			return
+		}
+		responseOK(ctx, http.StatusOK, entries)
 	}
 }
 
@@ -1171,9 +1737,7 @@ func (web *web) onAPIDeleteBansCIDR(database store.Store) gin.HandlerFunc {
 			responseErr(ctx, http.StatusBadRequest, nil)
 			return
 		}
-		banCidr.UnbanReasonText = req.UnbanReasonText
-		banCidr.Deleted = true
-		if errSave := database.SaveBanNet(ctx, &banCidr); errSave != nil {
+		if errSave := web.app.unbanCIDR(ctx, database, &banCidr, req.UnbanReasonText); errSave != nil {
 			responseErr(ctx, http.StatusInternalServerError, nil)
 			log.Errorf("Failed to delete cidr ban: %v", errSave)
 			return
@@ -1218,11 +1782,9 @@ func (web *web) onAPIDeleteBansGroup(database store.Store) gin.HandlerFunc {
 			responseErr(ctx, http.StatusBadRequest, nil)
 			return
 		}
-		banGroup.UnbanReasonText = req.UnbanReasonText
-		banGroup.Deleted = true
-		if errSave := database.SaveBanGroup(ctx, &banGroup); errSave != nil {
+		if errSave := web.app.unbanSteamGroup(ctx, database, &banGroup, req.UnbanReasonText); errSave != nil {
 			responseErr(ctx, http.StatusInternalServerError, nil)
-			log.Errorf("Failed to delete asn ban: %v", errSave)
+			log.Errorf("Failed to delete group ban: %v", errSave)
 			return
 		}
 		banGroup.BanGroupId = 0
@@ -1265,9 +1827,7 @@ func (web *web) onAPIDeleteBansASN(database store.Store) gin.HandlerFunc {
 			responseErr(ctx, http.StatusBadRequest, nil)
 			return
 		}
-		banAsn.UnbanReasonText = req.UnbanReasonText
-		banAsn.Deleted = true
-		if errSave := database.SaveBanASN(ctx, &banAsn); errSave != nil {
+		if errSave := web.app.unbanASN(ctx, database, &banAsn, req.UnbanReasonText); errSave != nil {
 			responseErr(ctx, http.StatusInternalServerError, nil)
 			log.Errorf("Failed to delete asn ban: %v", errSave)
 			return
@@ -1337,71 +1897,326 @@ func (web *web) onAPIPostServerUpdate(database store.ServerStore) gin.HandlerFun
 
 		if errSave := database.SaveServer(ctx, &server); errSave != nil {
 			responseErr(ctx, http.StatusInternalServerError, nil)
-			log.Errorf("Failed to update server: %v", errSave)
+			log.Errorf("Failed to update server: %v", errSave)
+			return
+		}
+		responseOK(ctx, http.StatusOK, server)
+		log.WithFields(log.Fields{
+			"server_id": server.ServerID,
+			"name":      server.ServerNameShort,
+		}).Infof("Server updated")
+	}
+}
+
+func (web *web) onAPIPostServerDelete(database store.ServerStore) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		serverId, idErr := getIntParam(ctx, "server_id")
+		if idErr != nil {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			return
+		}
+		var server model.Server
+		if errServer := database.GetServer(ctx, serverId, &server); errServer != nil {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			return
+		}
+		server.Deleted = true
+		if errSave := database.SaveServer(ctx, &server); errSave != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Failed to delete server: %v", errSave)
+			return
+		}
+		responseOK(ctx, http.StatusOK, server)
+		log.WithFields(log.Fields{
+			"server_id": server.ServerID,
+			"name":      server.ServerNameShort,
+		}).Infof("Server deleted")
+	}
+}
+
+func (web *web) onAPIPostServer(database store.ServerStore) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var serverReq serverUpdateRequest
+		if errBind := ctx.BindJSON(&serverReq); errBind != nil {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			log.Errorf("Failed to parse request for new server: %v", errBind)
+			return
+		}
+		server := model.NewServer(serverReq.NameShort, serverReq.Host, serverReq.Port)
+		server.ServerNameLong = serverReq.Name
+		server.ReservedSlots = serverReq.ReservedSlots
+		server.RCON = serverReq.RCON
+		server.Latitude = serverReq.Lat
+		server.Longitude = serverReq.Lon
+		server.CC = serverReq.CC
+		server.DefaultMap = serverReq.DefaultMap
+		server.Region = serverReq.Region
+		server.IsEnabled = serverReq.IsEnabled
+		if errSave := database.SaveServer(ctx, &server); errSave != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Failed to save new server: %v", errSave)
+			return
+		}
+		responseOK(ctx, http.StatusOK, server)
+		log.WithFields(log.Fields{
+			"server_id": server.ServerID,
+			"name":      server.ServerNameShort,
+		}).Infof("Server created")
+	}
+}
+
+type watchedServerRequest struct {
+	Name      string `json:"name"`
+	Host      string `json:"host"`
+	Port      int    `json:"port"`
+	Region    string `json:"region"`
+	CC        string `json:"cc"`
+	IsEnabled bool   `json:"is_enabled"`
+}
+
+func (web *web) onAPIGetWatchedServers(database store.WatchedServerStore) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		servers, errServers := database.GetWatchedServers(ctx, true)
+		if errServers != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Failed to load watched servers: %v", errServers)
+			return
+		}
+		responseOK(ctx, http.StatusOK, servers)
+	}
+}
+
+// onAPIPostWatchedServer creates a new watched server when no id is given, otherwise updates the existing one
+func (web *web) onAPIPostWatchedServer(database store.WatchedServerStore) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req watchedServerRequest
+		if errBind := ctx.BindJSON(&req); errBind != nil {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			return
+		}
+		if req.Host == "" || req.Port <= 0 || req.Port > 65535 {
+			responseErr(ctx, http.StatusBadRequest, "Invalid address")
+			return
+		}
+		server := model.NewWatchedServer(req.Host, req.Port)
+		if ctx.Param("watched_server_id") != "" {
+			watchedServerId, errId := getIntParam(ctx, "watched_server_id")
+			if errId != nil {
+				responseErr(ctx, http.StatusBadRequest, nil)
+				return
+			}
+			if errGet := database.GetWatchedServer(ctx, watchedServerId, &server); errGet != nil {
+				if errors.Is(errGet, store.ErrNoResult) {
+					responseErr(ctx, http.StatusNotFound, nil)
+					return
+				}
+				responseErr(ctx, http.StatusInternalServerError, nil)
+				return
+			}
+			server.Address = req.Host
+			server.Port = req.Port
+		}
+		server.Name = req.Name
+		server.Region = req.Region
+		server.CC = req.CC
+		server.IsEnabled = req.IsEnabled
+		if errSave := database.SaveWatchedServer(ctx, &server); errSave != nil {
+			if errors.Is(errSave, store.ErrDuplicate) {
+				responseErr(ctx, http.StatusConflict, "Server is already watched")
+				return
+			}
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Failed to save watched server: %v", errSave)
+			return
+		}
+		responseOK(ctx, http.StatusOK, server)
+		log.WithFields(log.Fields{"watched_server_id": server.WatchedServerId, "addr": server.Addr()}).
+			Infof("Watched server saved")
+	}
+}
+
+func (web *web) onAPIDeleteWatchedServer(database store.WatchedServerStore) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		watchedServerId, errId := getIntParam(ctx, "watched_server_id")
+		if errId != nil {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			return
+		}
+		var server model.WatchedServer
+		if errGet := database.GetWatchedServer(ctx, watchedServerId, &server); errGet != nil {
+			if errors.Is(errGet, store.ErrNoResult) {
+				responseErr(ctx, http.StatusNotFound, nil)
+				return
+			}
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			return
+		}
+		if errDrop := database.DropWatchedServer(ctx, watchedServerId); errDrop != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Failed to delete watched server: %v", errDrop)
+			return
+		}
+		responseOK(ctx, http.StatusOK, server)
+		log.WithFields(log.Fields{"watched_server_id": server.WatchedServerId, "addr": server.Addr()}).
+			Infof("Watched server deleted")
+	}
+}
+
+type scheduledTaskRequest struct {
+	Name             string                  `json:"name"`
+	Schedule         string                  `json:"schedule"`
+	Kind             model.ScheduledTaskKind `json:"kind"`
+	Command          string                  `json:"command"`
+	ServerId         int                     `json:"server_id"`
+	Region           string                  `json:"region"`
+	OnlyWhenEmpty    bool                    `json:"only_when_empty"`
+	OnlyWhenOutdated bool                    `json:"only_when_outdated"`
+	IsEnabled        bool                    `json:"is_enabled"`
+}
+
+func (web *web) onAPIGetScheduledTasks(database store.ScheduledTaskStore) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		tasks, errTasks := database.GetScheduledTasks(ctx, true)
+		if errTasks != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Failed to load scheduled tasks: %v", errTasks)
+			return
+		}
+		responseOK(ctx, http.StatusOK, tasks)
+	}
+}
+
+// onAPIPostScheduledTask creates a new scheduled task when no id is given, otherwise updates the existing one
+func (web *web) onAPIPostScheduledTask(database store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req scheduledTaskRequest
+		if errBind := ctx.BindJSON(&req); errBind != nil {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			return
+		}
+		task := model.NewScheduledTask(req.Name, req.Schedule, req.Kind, req.Command)
+		if ctx.Param("scheduled_task_id") != "" {
+			scheduledTaskId, errId := getIntParam(ctx, "scheduled_task_id")
+			if errId != nil {
+				responseErr(ctx, http.StatusBadRequest, nil)
+				return
+			}
+			if errGet := database.GetScheduledTask(ctx, scheduledTaskId, &task); errGet != nil {
+				if errors.Is(errGet, store.ErrNoResult) {
+					responseErr(ctx, http.StatusNotFound, nil)
+					return
+				}
+				responseErr(ctx, http.StatusInternalServerError, nil)
+				return
+			}
+			task.Name = req.Name
+			task.Schedule = req.Schedule
+			task.Kind = req.Kind
+			task.Command = req.Command
+		}
+		task.ServerId = req.ServerId
+		task.Region = req.Region
+		task.OnlyWhenEmpty = req.OnlyWhenEmpty
+		task.OnlyWhenOutdated = req.OnlyWhenOutdated
+		task.IsEnabled = req.IsEnabled
+		if errValidate := task.Validate(); errValidate != nil {
+			responseErr(ctx, http.StatusBadRequest, errValidate.Error())
+			return
+		}
+		if errSchedule := validateSchedule(task.Schedule); errSchedule != nil {
+			responseErr(ctx, http.StatusBadRequest, errSchedule.Error())
+			return
+		}
+		if task.ServerId > 0 {
+			var server model.Server
+			if errServer := database.GetServer(ctx, task.ServerId, &server); errServer != nil {
+				responseErr(ctx, http.StatusBadRequest, "Unknown server")
+				return
+			}
+		}
+		if errSave := database.SaveScheduledTask(ctx, &task); errSave != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Failed to save scheduled task: %v", errSave)
+			return
+		}
+		web.app.reloadScheduledTasks()
+		responseOK(ctx, http.StatusOK, task)
+		log.WithFields(log.Fields{"scheduled_task_id": task.ScheduledTaskId, "name": task.Name}).
+			Infof("Scheduled task saved")
+	}
+}
+
+func (web *web) onAPIDeleteScheduledTask(database store.ScheduledTaskStore) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		scheduledTaskId, errId := getIntParam(ctx, "scheduled_task_id")
+		if errId != nil {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			return
+		}
+		var task model.ScheduledTask
+		if errGet := database.GetScheduledTask(ctx, scheduledTaskId, &task); errGet != nil {
+			if errors.Is(errGet, store.ErrNoResult) {
+				responseErr(ctx, http.StatusNotFound, nil)
+				return
+			}
+			responseErr(ctx, http.StatusInternalServerError, nil)
 			return
 		}
-		responseOK(ctx, http.StatusOK, server)
-		log.WithFields(log.Fields{
-			"server_id": server.ServerID,
-			"name":      server.ServerNameShort,
-		}).Infof("Server updated")
+		if errDrop := database.DropScheduledTask(ctx, scheduledTaskId); errDrop != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Failed to delete scheduled task: %v", errDrop)
+			return
+		}
+		web.app.reloadScheduledTasks()
+		responseOK(ctx, http.StatusOK, task)
+		log.WithFields(log.Fields{"scheduled_task_id": task.ScheduledTaskId, "name": task.Name}).
+			Infof("Scheduled task deleted")
 	}
 }
 
-func (web *web) onAPIPostServerDelete(database store.ServerStore) gin.HandlerFunc {
+// onAPIPostScheduledTaskRun runs the task immediately, regardless of its schedule or whether it is enabled
+func (web *web) onAPIPostScheduledTaskRun(database store.Store) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		serverId, idErr := getIntParam(ctx, "server_id")
-		if idErr != nil {
-			responseErr(ctx, http.StatusBadRequest, nil)
-			return
-		}
-		var server model.Server
-		if errServer := database.GetServer(ctx, serverId, &server); errServer != nil {
+		scheduledTaskId, errId := getIntParam(ctx, "scheduled_task_id")
+		if errId != nil {
 			responseErr(ctx, http.StatusBadRequest, nil)
 			return
 		}
-		server.Deleted = true
-		if errSave := database.SaveServer(ctx, &server); errSave != nil {
+		var task model.ScheduledTask
+		if errGet := database.GetScheduledTask(ctx, scheduledTaskId, &task); errGet != nil {
+			if errors.Is(errGet, store.ErrNoResult) {
+				responseErr(ctx, http.StatusNotFound, nil)
+				return
+			}
 			responseErr(ctx, http.StatusInternalServerError, nil)
-			log.Errorf("Failed to delete server: %v", errSave)
 			return
 		}
-		responseOK(ctx, http.StatusOK, server)
-		log.WithFields(log.Fields{
-			"server_id": server.ServerID,
-			"name":      server.ServerNameShort,
-		}).Infof("Server deleted")
+		runs := web.app.runScheduledTask(ctx, database, task)
+		if runs == nil {
+			runs = []model.ScheduledTaskRun{}
+		}
+		responseOK(ctx, http.StatusOK, runs)
 	}
 }
 
-func (web *web) onAPIPostServer(database store.ServerStore) gin.HandlerFunc {
+func (web *web) onAPIQueryScheduledTaskRuns(database store.ScheduledTaskStore) gin.HandlerFunc {
+	const maxRuns = 1000
 	return func(ctx *gin.Context) {
-		var serverReq serverUpdateRequest
-		if errBind := ctx.BindJSON(&serverReq); errBind != nil {
+		var filter store.ScheduledTaskRunQueryFilter
+		if errBind := ctx.BindJSON(&filter); errBind != nil {
 			responseErr(ctx, http.StatusBadRequest, nil)
-			log.Errorf("Failed to parse request for new server: %v", errBind)
 			return
 		}
-		server := model.NewServer(serverReq.NameShort, serverReq.Host, serverReq.Port)
-		server.ServerNameLong = serverReq.Name
-		server.ReservedSlots = serverReq.ReservedSlots
-		server.RCON = serverReq.RCON
-		server.Latitude = serverReq.Lat
-		server.Longitude = serverReq.Lon
-		server.CC = serverReq.CC
-		server.DefaultMap = serverReq.DefaultMap
-		server.Region = serverReq.Region
-		server.IsEnabled = serverReq.IsEnabled
-		if errSave := database.SaveServer(ctx, &server); errSave != nil {
+		if filter.Limit == 0 || filter.Limit > maxRuns {
+			filter.Limit = maxRuns
+		}
+		runs, errRuns := database.GetScheduledTaskRuns(ctx, filter)
+		if errRuns != nil {
 			responseErr(ctx, http.StatusInternalServerError, nil)
-			log.Errorf("Failed to save new server: %v", errSave)
+			log.Errorf("Failed to load scheduled task runs: %v", errRuns)
 			return
 		}
-		responseOK(ctx, http.StatusOK, server)
-		log.WithFields(log.Fields{
-			"server_id": server.ServerID,
-			"name":      server.ServerNameShort,
-		}).Infof("Server created")
+		responseOK(ctx, http.StatusOK, runs)
 	}
 }
 
@@ -1671,9 +2486,11 @@ type AuthorMessage struct {
 	Message model.UserMessage `json:"message"`
 }
 
-func (web *web) onAPISetReportStatus(database store.ReportStore) gin.HandlerFunc {
+func (web *web) onAPISetReportStatus(database store.Store) gin.HandlerFunc {
 	type stateUpdateReq struct {
 		Status model.ReportStatus `json:"status"`
+		// BanId optionally links an existing ban against the reported player when closing with action
+		BanId int64 `json:"ban_id"`
 	}
 	return func(c *gin.Context) {
 		reportId, errParam := getInt64Param(c, "report_id")
@@ -1696,8 +2513,25 @@ func (web *web) onAPISetReportStatus(database store.ReportStore) gin.HandlerFunc
 			responseOK(c, http.StatusConflict, nil)
 			return
 		}
+		if newStatus.BanId > 0 {
+			if newStatus.Status != model.ClosedWithAction {
+				responseErr(c, http.StatusBadRequest, "Bans can only be linked when closing with action")
+				return
+			}
+			if !checkPrivilege(c, currentUserProfile(c), nil, model.PModerator) {
+				return
+			}
+			if errLink := linkReportBan(c, database, report, newStatus.BanId); errLink != nil {
+				if errors.Is(errLink, store.ErrNoResult) {
+					responseErr(c, http.StatusNotFound, "Unknown ban_id")
+					return
+				}
+				responseErr(c, http.StatusBadRequest, errLink.Error())
+				return
+			}
+		}
 		original := report.ReportStatus
-		report.ReportStatus = newStatus.Status
+		report.SetStatus(newStatus.Status)
 		if errSave := database.SaveReport(c, &report); errSave != nil {
 			responseErr(c, http.StatusInternalServerError, nil)
 			log.Errorf("Failed to save report state: %v", errSave)
@@ -1712,6 +2546,91 @@ func (web *web) onAPISetReportStatus(database store.ReportStore) gin.HandlerFunc
 	}
 }
 
+// linkReportBan sets the ReportId of an existing ban made against the reported player
+func linkReportBan(ctx context.Context, database store.Store, report model.Report, banId int64) error {
+	bannedPerson := model.NewBannedPerson()
+	if errBan := database.GetBanByBanID(ctx, banId, &bannedPerson, false); errBan != nil {
+		return errBan
+	}
+	if bannedPerson.Ban.TargetId != report.ReportedId {
+		return errors.New("Ban target does not match the reported player")
+	}
+	bannedPerson.Ban.ReportId = report.ReportId
+	return database.SaveBan(ctx, &bannedPerson.Ban)
+}
+
+func (web *web) onAPIPostReportAssign(database store.Store) gin.HandlerFunc {
+	type assignReq struct {
+		// AssigneeId is the moderator to assign the report to, the current user claims the report when empty
+		AssigneeId model.StringSID `json:"assignee_id"`
+		Unassign   bool            `json:"unassign"`
+	}
+	return func(ctx *gin.Context) {
+		reportId, errParam := getInt64Param(ctx, "report_id")
+		if errParam != nil {
+			responseErr(ctx, http.StatusNotFound, nil)
+			return
+		}
+		var req assignReq
+		if errBind := ctx.BindJSON(&req); errBind != nil {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			return
+		}
+		var report model.Report
+		if errGet := database.GetReport(ctx, reportId, &report); errGet != nil {
+			if errors.Is(errGet, store.ErrNoResult) {
+				responseErr(ctx, http.StatusNotFound, nil)
+				return
+			}
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			return
+		}
+		currentUser := currentUserProfile(ctx)
+		var assignee model.Person
+		if !req.Unassign {
+			assigneeId := currentUser.SteamID
+			if req.AssigneeId != "" {
+				sid64, errSid := req.AssigneeId.SID64()
+				if errSid != nil {
+					responseErr(ctx, http.StatusBadRequest, "Invalid assignee_id")
+					return
+				}
+				assigneeId = sid64
+			}
+			if errAssignee := database.GetPersonBySteamID(ctx, assigneeId, &assignee); errAssignee != nil {
+				responseErr(ctx, http.StatusNotFound, "Unknown assignee")
+				return
+			}
+			if assignee.PermissionLevel < model.PModerator {
+				responseErr(ctx, http.StatusBadRequest, "Assignee must be a moderator")
+				return
+			}
+		}
+		report.AssigneeId = assignee.SteamID
+		if errSave := database.SaveReport(ctx, &report); errSave != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Failed to save report assignee: %v", errSave)
+			return
+		}
+		responseOK(ctx, http.StatusAccepted, report)
+		log.WithFields(log.Fields{
+			"report_id": report.ReportId,
+			"assignee":  report.AssigneeId.String(),
+			"by":        currentUser.SteamID.String(),
+		}).Infof("Report assignee changed")
+
+		embed := respOk(nil, "Report assignee changed")
+		embed.URL = report.ToURL()
+		if assignee.SteamID.Valid() {
+			addField(embed, "Assignee", assignee.PersonaName)
+		} else {
+			addField(embed, "Assignee", "Unassigned")
+		}
+		addLink(embed, report)
+		sendDiscordPayload(web.botSendMessageChan, discordPayload{channelId: config.Discord.ReportLogChannelId, embed: embed})
+	}
+}
+
 func (web *web) onAPIGetReportMessages(database store.Store) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		reportId, errParam := getInt64Param(ctx, "report_id")
@@ -1756,14 +2675,17 @@ func (web *web) onAPIGetReportMessages(database store.Store) gin.HandlerFunc {
 }
 
 type reportWithAuthor struct {
-	Author  model.Person `json:"author"`
-	Subject model.Person `json:"subject"`
-	Report  model.Report `json:"report"`
+	Author   model.Person `json:"author"`
+	Subject  model.Person `json:"subject"`
+	Assignee model.Person `json:"assignee"`
+	Report   model.Report `json:"report"`
+	// TimeInState is the number of seconds the report has been in its current status
+	TimeInState int64 `json:"time_in_state"`
 }
 
 func (web *web) onAPIGetReports(database store.Store) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		var opts store.AuthorQueryFilter
+		opts := store.NewReportQueryFilter()
 		if errBind := ctx.BindJSON(&opts); errBind != nil {
 			responseErr(ctx, http.StatusBadRequest, nil)
 			return
@@ -1784,6 +2706,9 @@ func (web *web) onAPIGetReports(database store.Store) gin.HandlerFunc {
 		var authorIds steamid.Collection
 		for _, report := range reports {
 			authorIds = append(authorIds, report.AuthorId)
+			if report.AssigneeId.Valid() {
+				authorIds = append(authorIds, report.AssigneeId)
+			}
 		}
 		authors, errAuthors := database.GetPeopleBySteamID(ctx, fp.Uniq[steamid.SID64](authorIds))
 		if errAuthors != nil {
@@ -1805,9 +2730,11 @@ func (web *web) onAPIGetReports(database store.Store) gin.HandlerFunc {
 
 		for _, report := range reports {
 			userReports = append(userReports, reportWithAuthor{
-				Author:  authorMap[report.AuthorId],
-				Report:  report,
-				Subject: subjectMap[report.ReportedId],
+				Author:      authorMap[report.AuthorId],
+				Report:      report,
+				Subject:     subjectMap[report.ReportedId],
+				Assignee:    authorMap[report.AssigneeId],
+				TimeInState: int64(report.TimeInState(config.Now()).Seconds()),
 			})
 		}
 		sort.SliceStable(userReports, func(i, j int) bool {
@@ -1858,6 +2785,12 @@ func (web *web) onAPIGetReport(database store.Store) gin.HandlerFunc {
 			log.Errorf("Failed to load report subject: %v", errSubject)
 			return
 		}
+		if report.Report.AssigneeId.Valid() {
+			if errAssignee := database.GetPersonBySteamID(ctx, report.Report.AssigneeId, &report.Assignee); errAssignee != nil {
+				log.Errorf("Failed to load report assignee: %v", errAssignee)
+			}
+		}
+		report.TimeInState = int64(report.Report.TimeInState(config.Now()).Seconds())
 
 		responseOK(ctx, http.StatusOK, report)
 	}
@@ -2132,6 +3065,73 @@ func (web *web) onAPIQueryMessages(database store.Store) gin.HandlerFunc {
 	}
 }
 
+// onAPISearchMessages searches the chat logs, returning each hit with its surrounding messages. When the format
+// query parameter is set the results are returned as a file download instead.
+func (web *web) onAPISearchMessages(database store.Store) gin.HandlerFunc {
+	type searchRequest struct {
+		store.ChatSearchQueryFilter
+		SteamIds []model.StringSID `json:"steam_ids"`
+	}
+	return func(ctx *gin.Context) {
+		var exportFormat ChatExportFormat
+		if format := ctx.Query("format"); format != "" {
+			parsedFormat, errFormat := ParseChatExportFormat(format)
+			if errFormat != nil {
+				responseErr(ctx, http.StatusBadRequest, "Invalid format")
+				return
+			}
+			exportFormat = parsedFormat
+		}
+		var req searchRequest
+		if errBind := ctx.BindJSON(&req); errBind != nil {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			return
+		}
+		query := req.ChatSearchQueryFilter
+		for _, steamId := range req.SteamIds {
+			sid, errSid := steamId.SID64()
+			if errSid != nil {
+				responseErr(ctx, http.StatusBadRequest, "Invalid steam id")
+				return
+			}
+			query.SteamIds = append(query.SteamIds, sid)
+		}
+		if query.Limit <= 0 || query.Limit > 1000 {
+			query.Limit = 1000
+		}
+		if query.Context < 0 {
+			query.Context = 0
+		}
+		hits, errSearch := database.SearchChat(ctx, query)
+		if errSearch != nil {
+			if errors.Is(errSearch, store.ErrChatSearchMode) || errors.Is(errSearch, store.ErrChatSearchRegex) {
+				responseErr(ctx, http.StatusBadRequest, errSearch.Error())
+				return
+			}
+			log.Errorf("Failed to search chat history: %v", errSearch)
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			return
+		}
+		if exportFormat == "" {
+			responseOK(ctx, http.StatusOK, hits)
+			return
+		}
+		var buf bytes.Buffer
+		if errExport := ExportChatSearch(hits, exportFormat, &buf); errExport != nil {
+			log.Errorf("Failed to export chat search: %v", errExport)
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			return
+		}
+		contentType := "application/json; charset=utf-8"
+		if exportFormat == ChatExportCSV {
+			contentType = "text/csv; charset=utf-8"
+		}
+		ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=chat_search_%d.%s",
+			config.Now().Unix(), exportFormat))
+		ctx.Data(http.StatusOK, contentType, buf.Bytes())
+	}
+}
+
 func (web *web) onAPIGetMessageContext(database store.Store) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		messageId, errId := getInt64Param(ctx, "person_message_id")
@@ -2170,6 +3170,72 @@ func (web *web) onAPIGetMessageContext(database store.Store) gin.HandlerFunc {
 	}
 }
 
+func (web *web) onAPIGetWarnings(database store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		steamId, errId := getSID64Param(ctx, "steam_id")
+		if errId != nil {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			return
+		}
+		warnings, errWarnings := database.GetWarnings(ctx, steamId, ctx.Query("all") == "true")
+		if errWarnings != nil && !errors.Is(errWarnings, store.ErrNoResult) {
+			log.WithFields(log.Fields{"sid": steamId}).Errorf("Failed to query warnings: %v", errWarnings)
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			return
+		}
+		responseOK(ctx, http.StatusOK, warnings)
+	}
+}
+
+func (web *web) onAPIPostWarning(database store.Store) gin.HandlerFunc {
+	type warningRequest struct {
+		SteamId  model.StringSID `json:"steam_id"`
+		Reason   model.Reason    `json:"reason"`
+		Message  string          `json:"message"`
+		ServerId int             `json:"server_id"`
+	}
+	return func(ctx *gin.Context) {
+		var req warningRequest
+		if errBind := ctx.BindJSON(&req); errBind != nil {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			return
+		}
+		steamId, errSteamId := req.SteamId.SID64()
+		if errSteamId != nil {
+			responseErr(ctx, http.StatusBadRequest, "Invalid steam_id")
+			return
+		}
+		warning, errWarn := web.app.WarnAdd(ctx, database, currentUserProfile(ctx).SteamID, steamId,
+			req.Reason, req.Message, req.ServerId)
+		if errWarn != nil {
+			log.WithError(errWarn).Errorf("Failed to add warning")
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			return
+		}
+		responseOK(ctx, http.StatusCreated, warning)
+	}
+}
+
+func (web *web) onAPIDeleteWarnings(database store.Store) gin.HandlerFunc {
+	type clearResponse struct {
+		Removed int64 `json:"removed"`
+	}
+	return func(ctx *gin.Context) {
+		steamId, errId := getSID64Param(ctx, "steam_id")
+		if errId != nil {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			return
+		}
+		count, errClear := web.app.WarnClear(ctx, database, steamId)
+		if errClear != nil {
+			log.WithError(errClear).Errorf("Failed to clear warnings")
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			return
+		}
+		responseOK(ctx, http.StatusOK, clearResponse{Removed: count})
+	}
+}
+
 func (web *web) onAPIGetPersonMessages(database store.Store) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		steamId, errId := getSID64Param(ctx, "steam_id")
@@ -2281,7 +3347,7 @@ func (web *web) onAPIDeleteBanMessage(database store.BanStore) gin.HandlerFunc {
 	}
 }
 
-func (web *web) onAPIPostBanMessage(database store.BanStore) gin.HandlerFunc {
+func (web *web) onAPIPostBanMessage(database store.Store) gin.HandlerFunc {
 	type req struct {
 		Message string `json:"message"`
 	}
@@ -2311,6 +3377,26 @@ func (web *web) onAPIPostBanMessage(database store.BanStore) gin.HandlerFunc {
 			return
 		}
 		userProfile := currentUserProfile(ctx)
+		if bp.Ban.AppealState == model.Denied && userProfile.PermissionLevel < model.PModerator {
+			// Denied appeals are reopened by the player posting again once the cooldown has passed
+			remaining, errCooldown := AppealCooldownRemaining(ctx, database, bp.Ban)
+			if errCooldown != nil {
+				responseErr(ctx, http.StatusInternalServerError, nil)
+				log.Errorf("Failed to get appeal cooldown: %v", errCooldown)
+				return
+			}
+			if remaining > 0 {
+				responseErr(ctx, http.StatusForbidden, fmt.Sprintf("You may appeal again in %s",
+					remaining.Round(time.Minute).String()))
+				return
+			}
+			if _, errReopen := web.app.SetAppealState(ctx, database, &bp, userProfile.SteamID, model.Open,
+				"", "Reopened by player"); errReopen != nil {
+				responseErr(ctx, http.StatusInternalServerError, nil)
+				log.Errorf("Failed to reopen appeal: %v", errReopen)
+				return
+			}
+		}
 		if bp.Ban.AppealState != model.Open && userProfile.PermissionLevel < model.PModerator {
 			responseErr(ctx, http.StatusForbidden, nil)
 			log.WithFields(log.Fields{
@@ -2422,6 +3508,7 @@ func (web *web) onAPIPostServerQuery(database store.Store) gin.HandlerFunc {
 		Latitude   float64  `json:"latitude"`
 		Longitude  float64  `json:"longitude"`
 		Distance   float64  `json:"distance"`
+		Watched    bool     `json:"watched"`
 	}
 
 	var distance = func(lat1 float64, lng1 float64, lat2 float64, lng2 float64) float64 {
@@ -2500,9 +3587,15 @@ func (web *web) onAPIPostServerQuery(database store.Store) gin.HandlerFunc {
 			responseErr(ctx, http.StatusBadRequest, nil)
 			return
 		}
+		watched := web.app.WatchedServerState()
 		web.app.masterServerListMu.RLock()
-		filtered := web.app.masterServerList
+		filtered := quickplayServers(web.app.masterServerList, watched,
+			config.General.BannedServersAddresses, config.General.BannedServerPatterns)
 		web.app.masterServerListMu.RUnlock()
+		watchedAddrs := map[string]bool{}
+		for _, state := range watched {
+			watchedAddrs[fmt.Sprintf("%s:%d", state.Host, state.Port)] = true
+		}
 
 		if len(req.GameTypes) > 0 {
 			filtered = filterGameTypes(filtered, req.GameTypes)
@@ -2522,8 +3615,9 @@ func (web *web) onAPIPostServerQuery(database store.Store) gin.HandlerFunc {
 			if dist <= 0 || dist > 5000 {
 				continue
 			}
+			addr := fmt.Sprintf("%s:%d", server.Addr, server.Gameport)
 			slim = append(slim, slimServer{
-				Addr:       fmt.Sprintf("%s:%d", server.Addr, server.Gameport),
+				Addr:       addr,
 				Name:       server.Name,
 				Region:     server.Region,
 				Players:    server.Players,
@@ -2534,6 +3628,7 @@ func (web *web) onAPIPostServerQuery(database store.Store) gin.HandlerFunc {
 				Latitude:   server.Latitude,
 				Longitude:  server.Longitude,
 				Distance:   dist,
+				Watched:    watchedAddrs[addr],
 			})
 		}
 		sort.SliceStable(slim, func(i, j int) bool {
@@ -2581,3 +3676,166 @@ func (web *web) onAPIGetTF2Stats(database store.StatStore) gin.HandlerFunc {
 		}
 	}
 }
+
+// getEvidenceParent loads the parent params of the evidence routes and checks the current user is
+// allowed to access the thread
+func getEvidenceParent(ctx *gin.Context, database store.Store) (model.EvidenceParent, int64, bool) {
+	parentKind := model.EvidenceParent(ctx.Param("parent_kind"))
+	parentId, errParentId := getInt64Param(ctx, "parent_id")
+	if errParentId != nil || parentId <= 0 {
+		responseErr(ctx, http.StatusBadRequest, nil)
+		return "", 0, false
+	}
+	participants, errParticipants := evidenceParticipants(ctx, database, parentKind, parentId)
+	if errParticipants != nil {
+		if errors.Is(errParticipants, store.ErrNoResult) {
+			responseErr(ctx, http.StatusNotFound, nil)
+			return "", 0, false
+		}
+		if errors.Is(errParticipants, model.ErrEvidenceParent) {
+			responseErr(ctx, http.StatusBadRequest, "Invalid parent kind")
+			return "", 0, false
+		}
+		responseErr(ctx, http.StatusInternalServerError, nil)
+		log.Errorf("Failed to load evidence parent: %v", errParticipants)
+		return "", 0, false
+	}
+	if !checkPrivilege(ctx, currentUserProfile(ctx), participants, model.PModerator) {
+		return "", 0, false
+	}
+	return parentKind, parentId, true
+}
+
+func (web *web) onAPIGetEvidence(database store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		parentKind, parentId, ok := getEvidenceParent(ctx, database)
+		if !ok {
+			return
+		}
+		evidence, errEvidence := database.GetEvidenceByParent(ctx, parentKind, parentId)
+		if errEvidence != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Failed to load evidence: %v", errEvidence)
+			return
+		}
+		details, errDetails := evidenceDetails(ctx, database, evidence)
+		if errDetails != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Failed to load evidence details: %v", errDetails)
+			return
+		}
+		responseOK(ctx, http.StatusOK, details)
+	}
+}
+
+func (web *web) onAPIPostEvidence(database store.Store) gin.HandlerFunc {
+	type evidenceRequest struct {
+		Kind      model.EvidenceKind `json:"kind"`
+		MessageId int64              `json:"message_id"`
+		// Media is a new upload, otherwise MediaId refers to an existing upload
+		Media           *model.UserUploadedFile `json:"media"`
+		MediaId         int                     `json:"media_id"`
+		PersonMessageId int64                   `json:"person_message_id"`
+		DemoId          int64                   `json:"demo_id"`
+		TickStart       int                     `json:"tick_start"`
+		TickEnd         int                     `json:"tick_end"`
+		MatchId         int                     `json:"match_id"`
+		Note            string                  `json:"note"`
+	}
+	return func(ctx *gin.Context) {
+		parentKind, parentId, ok := getEvidenceParent(ctx, database)
+		if !ok {
+			return
+		}
+		var req evidenceRequest
+		if errBind := ctx.BindJSON(&req); errBind != nil {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			return
+		}
+		currentUser := currentUserProfile(ctx)
+		var upload *model.Media
+		if req.Kind == model.EvidenceMedia && req.Media != nil {
+			content, decodeErr := base64.StdEncoding.DecodeString(req.Media.Content)
+			if decodeErr != nil {
+				responseErr(ctx, http.StatusUnprocessableEntity, nil)
+				return
+			}
+			media := model.NewMedia(currentUser.SteamID, req.Media.Name, req.Media.Mime, content)
+			upload = &media
+		}
+		evidence := model.NewEvidence(parentKind, parentId, currentUser.SteamID, req.Kind)
+		evidence.MessageId = req.MessageId
+		evidence.Note = req.Note
+		switch req.Kind {
+		case model.EvidenceMedia:
+			evidence.MediaId = req.MediaId
+		case model.EvidenceMessage:
+			evidence.PersonMessageId = req.PersonMessageId
+		case model.EvidenceDemo:
+			evidence.DemoId = req.DemoId
+			evidence.TickStart = req.TickStart
+			evidence.TickEnd = req.TickEnd
+		case model.EvidenceMatch:
+			evidence.MatchId = req.MatchId
+		}
+		if errValidate := validateEvidence(ctx, database, evidence, upload,
+			currentUser.PermissionLevel >= model.PModerator); errValidate != nil {
+			if errors.Is(errValidate, store.ErrNoResult) {
+				responseErr(ctx, http.StatusNotFound, "Evidence subject not found")
+				return
+			}
+			if errors.Is(errValidate, errEvidenceMimeType) && upload != nil {
+				log.WithFields(log.Fields{"mime": upload.MimeType, "name": upload.Name}).
+					Errorf("User tried uploading evidence with forbidden mimetype")
+			}
+			responseErr(ctx, http.StatusBadRequest, errValidate.Error())
+			return
+		}
+		if upload != nil {
+			if errSave := database.SaveMedia(ctx, upload); errSave != nil {
+				if errors.Is(store.Err(errSave), store.ErrDuplicate) {
+					responseErrUser(ctx, http.StatusConflict, nil, "Duplicate media name")
+					return
+				}
+				responseErr(ctx, http.StatusInternalServerError, nil)
+				log.Errorf("Failed to save evidence media: %v", errSave)
+				return
+			}
+			evidence.MediaId = upload.MediaId
+		}
+		if errSave := database.SaveEvidence(ctx, &evidence); errSave != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Failed to save evidence: %v", errSave)
+			return
+		}
+		responseOK(ctx, http.StatusCreated, evidence)
+	}
+}
+
+func (web *web) onAPIDeleteEvidence(database store.Store) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		evidenceId, errId := getInt64Param(ctx, "evidence_id")
+		if errId != nil || evidenceId <= 0 {
+			responseErr(ctx, http.StatusBadRequest, nil)
+			return
+		}
+		var evidence model.Evidence
+		if errEvidence := database.GetEvidence(ctx, evidenceId, &evidence); errEvidence != nil {
+			if errors.Is(errEvidence, store.ErrNoResult) {
+				responseErr(ctx, http.StatusNotFound, nil)
+				return
+			}
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			return
+		}
+		if !checkPrivilege(ctx, currentUserProfile(ctx), steamid.Collection{evidence.AuthorId}, model.PModerator) {
+			return
+		}
+		if errDrop := database.DropEvidence(ctx, &evidence); errDrop != nil {
+			responseErr(ctx, http.StatusInternalServerError, nil)
+			log.Errorf("Failed to drop evidence: %v", errDrop)
+			return
+		}
+		responseOK(ctx, http.StatusNoContent, nil)
+	}
+}
@@ -67,6 +67,7 @@ func (web *web) authServerMiddleWare(database store.Store) gin.HandlerFunc {
 			ctx.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
+		ctx.Set(ctxKeyServerId, server.ServerID)
 		ctx.Next()
 	}
 }
@@ -325,6 +326,24 @@ func authMiddleware(database store.Store, level model.Privilege) gin.HandlerFunc
 	}
 }
 
+// viewerPermissionLevel returns the permission level of the user making the request to a public route,
+// which has no authMiddleware. Requests without a valid access token are treated as guests.
+func viewerPermissionLevel(ctx *gin.Context, database store.PersonStore) model.Privilege {
+	pcs := strings.Split(ctx.GetHeader("Authorization"), " ")
+	if len(pcs) != 2 || pcs[1] == "" {
+		return model.PGuest
+	}
+	sid, errFromToken := sid64FromJWTToken(pcs[1])
+	if errFromToken != nil {
+		return model.PGuest
+	}
+	viewer := model.NewPerson(sid)
+	if errGetPerson := database.GetPersonBySteamID(ctx, sid, &viewer); errGetPerson != nil {
+		return model.PGuest
+	}
+	return viewer.PermissionLevel
+}
+
 func sid64FromJWTToken(token string) (steamid.SID64, error) {
 	claims := &personAuthClaims{}
 	tkn, errParseClaims := jwt.ParseWithClaims(token, claims, getTokenKey)
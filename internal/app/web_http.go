@@ -16,7 +16,10 @@ import (
 	"time"
 )
 
-const ctxKeyUserProfile = "user_profile"
+const (
+	ctxKeyUserProfile = "user_profile"
+	ctxKeyServerId    = "server_id"
+)
 
 type WebHandler interface {
 	ListenAndServe(context.Context) error
@@ -86,6 +89,11 @@ func NewWeb(app *App, database store.Store, botSendMessageChan chan discordPaylo
 	return &webHandler, nil
 }
 
+// currentServerId returns the id of the server authenticated by authServerMiddleWare, or 0 if there is none
+func currentServerId(ctx *gin.Context) int {
+	return ctx.GetInt(ctxKeyServerId)
+}
+
 func currentUserProfile(ctx *gin.Context) model.UserProfile {
 	maybePerson, found := ctx.Get(ctxKeyUserProfile)
 	if !found {
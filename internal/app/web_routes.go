@@ -121,7 +121,10 @@ func (web *web) setupRouter(database store.Store, engine *gin.Engine, logFileC c
 		serverAuth := srvGrp.Use(web.authServerMiddleWare(database))
 		serverAuth.GET("/api/server/admins", web.onAPIGetServerAdmins(database))
 		serverAuth.POST("/api/ping_mod", web.onAPIPostPingMod(database))
+		serverAuth.POST("/api/sm/report", web.onAPIPostServerReport(database))
 		serverAuth.POST("/api/check", web.onAPIPostServerCheck(database))
+		serverAuth.GET("/api/sm/actions", web.onAPIGetServerActions(database))
+		serverAuth.POST("/api/sm/actions/:server_action_id", web.onAPIPostServerActionConfirm(database))
 		serverAuth.POST("/api/demo", web.onAPIPostDemo(database))
 		serverAuth.POST("/api/log", web.onAPIPostLog(database, logFileC))
 		serverAuth.POST("/api/sm/bans/steam/create", web.onAPIPostBanSteamCreate(database))
@@ -152,6 +155,10 @@ func (web *web) setupRouter(database store.Store, engine *gin.Engine, logFileC c
 		authed.POST("/api/bans/:ban_id/messages", web.onAPIPostBanMessage(database))
 		authed.POST("/api/bans/message/:ban_message_id", web.onAPIEditBanMessage(database))
 		authed.DELETE("/api/bans/message/:ban_message_id", web.onAPIDeleteBanMessage(database))
+
+		authed.GET("/api/evidence/:parent_kind/:parent_id", web.onAPIGetEvidence(database))
+		authed.POST("/api/evidence/:parent_kind/:parent_id", web.onAPIPostEvidence(database))
+		authed.DELETE("/api/evidence/:evidence_id", web.onAPIDeleteEvidence(database))
 	}
 
 	editorGrp := engine.Group("/")
@@ -173,15 +180,25 @@ func (web *web) setupRouter(database store.Store, engine *gin.Engine, logFileC c
 		// Moderator access
 		modRoute := modGrp.Use(authMiddleware(database, model.PModerator))
 		modRoute.POST("/api/report/:report_id/state", web.onAPIPostBanState(database))
+		modRoute.POST("/api/report/:report_id/assign", web.onAPIPostReportAssign(database))
 		modRoute.GET("/api/connections/:steam_id", web.onAPIGetPersonConnections(database))
 		modRoute.GET("/api/messages/:steam_id", web.onAPIGetPersonMessages(database))
+		modRoute.GET("/api/warnings/:steam_id", web.onAPIGetWarnings(database))
+		modRoute.DELETE("/api/warnings/:steam_id", web.onAPIDeleteWarnings(database))
+		modRoute.POST("/api/warnings", web.onAPIPostWarning(database))
 		modRoute.GET("/api/message/:person_message_id/context", web.onAPIGetMessageContext(database))
 		modRoute.POST("/api/messages", web.onAPIQueryMessages(database))
+		modRoute.POST("/api/messages/search", web.onAPISearchMessages(database))
 		modRoute.POST("/api/appeals", web.onAPIGetAppeals(database))
 		modRoute.POST("/api/bans/steam", web.onAPIGetBansSteam(database))
 		modRoute.POST("/api/bans/steam/create", web.onAPIPostBanSteamCreate(database))
+		modRoute.POST("/api/bans/steam/escalation", web.onAPIPostBanEscalation(database))
+		modRoute.POST("/api/bans/join_attempts", web.onAPIGetBanJoinAttempts(database))
+		modRoute.POST("/api/bans/audit", web.onAPIGetBanAudit(database))
+		modRoute.POST("/api/bans/search", web.onAPISearchBans(database))
 		modRoute.DELETE("/api/bans/steam/:ban_id", web.onAPIPostBanDelete(database))
 		modRoute.POST("/api/bans/steam/:ban_id/status", web.onAPIPostSetBanAppealStatus(database))
+		modRoute.GET("/api/bans/steam/:ban_id/appeal_history", web.onAPIGetBanAppealHistory(database))
 		modRoute.POST("/api/bans/cidr/create", web.onAPIPostBansCIDRCreate(database))
 		modRoute.POST("/api/bans/cidr", web.onAPIGetBansCIDR(database))
 		modRoute.DELETE("/api/bans/cidr/:net_id", web.onAPIDeleteBansCIDR(database))
@@ -201,5 +218,21 @@ func (web *web) setupRouter(database store.Store, engine *gin.Engine, logFileC c
 		adminRoute.POST("/api/servers/:server_id", web.onAPIPostServerUpdate(database))
 		adminRoute.DELETE("/api/servers/:server_id", web.onAPIPostServerDelete(database))
 		adminRoute.GET("/api/servers", web.onAPIGetServers(database))
+		adminRoute.GET("/api/servers/rcon", web.onAPIGetRCONStats())
+		adminRoute.GET("/api/servers/health", web.onAPIGetServerHealth())
+		adminRoute.GET("/api/servers/:server_id/uptime", web.onAPIGetServerUptime(database))
+		adminRoute.GET("/api/watched_servers", web.onAPIGetWatchedServers(database))
+		adminRoute.POST("/api/watched_servers", web.onAPIPostWatchedServer(database))
+		adminRoute.POST("/api/watched_servers/:watched_server_id", web.onAPIPostWatchedServer(database))
+		adminRoute.DELETE("/api/watched_servers/:watched_server_id", web.onAPIDeleteWatchedServer(database))
+		adminRoute.GET("/api/scheduled_tasks", web.onAPIGetScheduledTasks(database))
+		adminRoute.POST("/api/scheduled_tasks", web.onAPIPostScheduledTask(database))
+		adminRoute.POST("/api/scheduled_tasks/:scheduled_task_id", web.onAPIPostScheduledTask(database))
+		adminRoute.DELETE("/api/scheduled_tasks/:scheduled_task_id", web.onAPIDeleteScheduledTask(database))
+		adminRoute.POST("/api/scheduled_tasks/:scheduled_task_id/run", web.onAPIPostScheduledTaskRun(database))
+		adminRoute.POST("/api/scheduled_task_runs", web.onAPIQueryScheduledTaskRuns(database))
+		adminRoute.POST("/api/bans/import/:format", web.onAPIPostBansImport(database))
+		adminRoute.GET("/api/bans/export/:format", web.onAPIGetBansExport(database))
+		adminRoute.GET("/api/bans/external", web.onAPIGetExternalBanSources(database))
 	}
 }
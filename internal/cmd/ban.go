@@ -10,6 +10,7 @@ import (
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"os"
 	"time"
 )
 
@@ -19,6 +20,8 @@ var (
 	cidr         = ""
 	reason       = ""
 	duration     = ""
+	banFormat    = ""
+	banFile      = ""
 )
 
 // serverCmd represents the addserver command
@@ -157,6 +160,76 @@ var banASNCmd = &cobra.Command{
 	},
 }
 
+var banImportCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import bans from a file",
+	Long: `Import bans from a SourceBans++ mysql dump (sourcebans), srcds banned_user.cfg (banned_user) 
+	or banned_ip.cfg (banned_ip) file, or a gbans json export (json)`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute*10)
+		defer cancel()
+		format, errFormat := app.ParseBanFormat(banFormat)
+		if errFormat != nil {
+			log.Fatalf("Invalid format: %v", errFormat)
+		}
+		if banFile == "" {
+			log.Fatal("File cannot be empty")
+		}
+		body, errRead := os.ReadFile(banFile)
+		if errRead != nil {
+			log.Fatalf("Failed to read file: %v", errRead)
+		}
+		db, errNewStore := store.New(ctx, config.DB.DSN)
+		if errNewStore != nil {
+			log.Fatalf("Failed to setup db connection: %v", errNewStore)
+		}
+		result, errImport := app.ImportBans(ctx, db, format, body)
+		if errImport != nil {
+			log.Fatalf("Failed to import bans: %v", errImport)
+		}
+		for _, errMsg := range result.Errors {
+			log.Warnf("Failed to import ban: %s", errMsg)
+		}
+		log.WithFields(log.Fields{"steam": result.Steam, "cidr": result.CIDR, "skipped": result.Skipped,
+			"errors": len(result.Errors)}).Infof("Bans imported successfully")
+	},
+}
+
+var banExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export bans to a file",
+	Long: `Export all active bans in the SourceBans++ (sourcebans), srcds banned_user.cfg (banned_user) 
+	or banned_ip.cfg (banned_ip), or gbans json (json) formats. Writes to stdout when no file is given`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute*10)
+		defer cancel()
+		format, errFormat := app.ParseBanFormat(banFormat)
+		if errFormat != nil {
+			log.Fatalf("Invalid format: %v", errFormat)
+		}
+		db, errNewStore := store.New(ctx, config.DB.DSN)
+		if errNewStore != nil {
+			log.Fatalf("Failed to setup db connection: %v", errNewStore)
+		}
+		output := os.Stdout
+		if banFile != "" {
+			outFile, errCreate := os.Create(banFile)
+			if errCreate != nil {
+				log.Fatalf("Failed to create file: %v", errCreate)
+			}
+			defer func() {
+				if errClose := outFile.Close(); errClose != nil {
+					log.Errorf("Failed to close file: %v", errClose)
+				}
+			}()
+			output = outFile
+		}
+		if errExport := app.ExportBans(ctx, db, format, output); errExport != nil {
+			log.Fatalf("Failed to export bans: %v", errExport)
+		}
+	},
+}
+
 func init() {
 	banSteamCmd.Flags().StringVarP(&steamProfile, "sid", "s", "", "SteamID or profile to ban")
 	banSteamCmd.Flags().StringVarP(&reason, "reason", "r", "", "Ban reason")
@@ -171,9 +244,17 @@ func init() {
 	banASNCmd.Flags().StringVarP(&reason, "reason", "r", "", "Ban reason")
 	banASNCmd.Flags().StringVarP(&duration, "duration", "d", "0", "Duration of ban")
 
+	banImportCmd.Flags().StringVarP(&banFormat, "format", "f", "json", "Format: json, sourcebans, banned_user, banned_ip")
+	banImportCmd.Flags().StringVarP(&banFile, "input", "i", "", "File to import")
+
+	banExportCmd.Flags().StringVarP(&banFormat, "format", "f", "json", "Format: json, sourcebans, banned_user, banned_ip")
+	banExportCmd.Flags().StringVarP(&banFile, "output", "o", "", "File to write, defaults to stdout")
+
 	banCmd.AddCommand(banSteamCmd)
 	banCmd.AddCommand(banCIDRCmd)
 	banCmd.AddCommand(banASNCmd)
+	banCmd.AddCommand(banImportCmd)
+	banCmd.AddCommand(banExportCmd)
 
 	rootCmd.AddCommand(banCmd)
 
@@ -2,6 +2,8 @@
 //
 // ban asn - Ban based on ASN
 // ban cidr - Ban a IP or network with CIDR notation
+// ban export - Export bans in the sourcebans, banned_user.cfg, banned_ip.cfg or json formats
+// ban import - Import bans in the sourcebans, banned_user.cfg, banned_ip.cfg or json formats
 // ban steam - Ban a player via steamid or vanity name
 // import - Imports bans from a folder in json format
 // migrate - Initiate a database migration manually
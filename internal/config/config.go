@@ -14,6 +14,7 @@ import (
 	"fmt"
 	"github.com/leighmacdonald/steamweb"
 	"os"
+	"path"
 	"strings"
 	"time"
 
@@ -46,12 +47,87 @@ type BanList struct {
 	Type BanListType `mapstructure:"type"`
 }
 
+// WordList holds details to load an external list of filtered words. Lists are either plain text, with one
+// word per line, or a JSON array of strings.
+type WordList struct {
+	URL  string `mapstructure:"url"`
+	Name string `mapstructure:"name"`
+}
+
 type filterConfig struct {
-	Enabled         bool     `mapstructure:"enabled"`
-	IsWarning       bool     `mapstructure:"is_warning"`
-	PingDiscord     bool     `mapstructure:"ping_discord"`
-	ExternalEnabled bool     `mapstructure:"external_enabled"`
-	ExternalSource  []string `mapstructure:"external_source"`
+	Enabled         bool       `mapstructure:"enabled"`
+	IsWarning       bool       `mapstructure:"is_warning"`
+	PingDiscord     bool       `mapstructure:"ping_discord"`
+	ExternalEnabled bool       `mapstructure:"external_enabled"`
+	ExternalSources []WordList `mapstructure:"external_sources"`
+	// ExternalSource is the previous list of external word list urls.
+	// Deprecated: Use ExternalSources, the urls are appended to it when loading the config.
+	ExternalSource []string `mapstructure:"external_source"`
+	// ExternalUpdateFreq is how often the external lists are fetched
+	ExternalUpdateFreq string `mapstructure:"external_update_freq"`
+	// CachePath is where the last successfully fetched copy of each external list is kept
+	CachePath string `mapstructure:"cache_path"`
+	// Normalise enables matching the filters against normalised variants of messages, which catches
+	// leetspeak, look-alike characters and spaced out words
+	Normalise bool `mapstructure:"normalise"`
+}
+
+// WordLists returns the configured external word lists, including those still configured with the
+// deprecated external_source url list. Those lists are named after the file name of their url.
+func (c filterConfig) WordLists() []WordList {
+	lists := append([]WordList{}, c.ExternalSources...)
+	names := map[string]bool{}
+	for _, list := range lists {
+		names[list.Name] = true
+	}
+	for idx, url := range c.ExternalSource {
+		name := strings.TrimSuffix(path.Base(url), path.Ext(url))
+		if name == "" || name == "." || name == "/" || names[name] {
+			name = fmt.Sprintf("external-source-%d", idx)
+		}
+		names[name] = true
+		lists = append(lists, WordList{URL: url, Name: name})
+	}
+	return lists
+}
+
+// BanEscalationPolicy defines the successive ban durations applied for repeat offences of the same
+// ban reason. The last duration is reused once a player has exhausted the list.
+type BanEscalationPolicy struct {
+	Reason    int      `mapstructure:"reason"`
+	Durations []string `mapstructure:"durations"`
+}
+
+// Duration returns the duration to apply to a new offence given the number of prior offences
+func (policy BanEscalationPolicy) Duration(priorOffences int) string {
+	if len(policy.Durations) == 0 {
+		return ""
+	}
+	if priorOffences >= len(policy.Durations) {
+		return policy.Durations[len(policy.Durations)-1]
+	}
+	if priorOffences < 0 {
+		priorOffences = 0
+	}
+	return policy.Durations[priorOffences]
+}
+
+type banEscalationConfig struct {
+	Enabled  bool                  `mapstructure:"enabled"`
+	Policies []BanEscalationPolicy `mapstructure:"policies"`
+}
+
+// Policy returns the escalation policy for the ban reason, if any
+func (c banEscalationConfig) Policy(reason int) (BanEscalationPolicy, bool) {
+	if !c.Enabled {
+		return BanEscalationPolicy{}, false
+	}
+	for _, policy := range c.Policies {
+		if policy.Reason == reason && len(policy.Durations) > 0 {
+			return policy, true
+		}
+	}
+	return BanEscalationPolicy{}, false
 }
 
 type rootConfig struct {
@@ -64,6 +140,148 @@ type rootConfig struct {
 	NetBans netBans       `mapstructure:"network_bans"`
 	Debug   debugConfig   `mapstructure:"debug"`
 	Patreon patreonConfig `mapstructure:"patreon"`
+
+	BanEscalation banEscalationConfig `mapstructure:"ban_escalation"`
+	AltDetection  altDetectionConfig  `mapstructure:"alt_detection"`
+	Appeals       appealsConfig       `mapstructure:"appeals"`
+	Reports       reportsConfig       `mapstructure:"reports"`
+	Spam          spamConfig          `mapstructure:"spam"`
+	Health        healthConfig        `mapstructure:"health"`
+}
+
+type reportsConfig struct {
+	// SLA is how long a report can stay opened before moderators are alerted. 0 disables escalation.
+	SLA time.Duration `mapstructure:"sla"`
+	// NeedMoreInfoTimeout is how long to wait for a reporter to respond to a report in the need more info
+	// state before it is closed without action. 0 disables auto closing.
+	NeedMoreInfoTimeout time.Duration `mapstructure:"need_more_info_timeout"`
+}
+
+// SpamThresholds defines the limits used to detect chat spam. A zero value disables the individual check.
+type SpamThresholds struct {
+	// FloodMessages is the number of messages sent within FloodWindow that is considered a flood
+	FloodMessages int           `mapstructure:"flood_messages"`
+	FloodWindow   time.Duration `mapstructure:"flood_window"`
+	// RepeatMessages is the number of identical messages, across all servers, sent within RepeatWindow
+	// that is considered spam
+	RepeatMessages int           `mapstructure:"repeat_messages"`
+	RepeatWindow   time.Duration `mapstructure:"repeat_window"`
+	// MaxMentions is the number of other players that can be named in a single message
+	MaxMentions int `mapstructure:"max_mentions"`
+	// Advertising flags messages containing links or server addresses
+	Advertising bool `mapstructure:"advertising"`
+}
+
+// SpamThresholdOverrides replaces individual default thresholds for a server. Unset values keep the default.
+type SpamThresholdOverrides struct {
+	FloodMessages  *int           `mapstructure:"flood_messages"`
+	FloodWindow    *time.Duration `mapstructure:"flood_window"`
+	RepeatMessages *int           `mapstructure:"repeat_messages"`
+	RepeatWindow   *time.Duration `mapstructure:"repeat_window"`
+	MaxMentions    *int           `mapstructure:"max_mentions"`
+	Advertising    *bool          `mapstructure:"advertising"`
+}
+
+type spamConfig struct {
+	Enabled  bool           `mapstructure:"enabled"`
+	Defaults SpamThresholds `mapstructure:"defaults"`
+	// Servers overrides the default thresholds for individual servers, keyed by the lowercase server short name
+	Servers map[string]SpamThresholdOverrides `mapstructure:"servers"`
+	// AllowedDomains are domains which are not considered advertising, such as the community website
+	AllowedDomains []string `mapstructure:"allowed_domains"`
+}
+
+// Thresholds returns the default spam thresholds with the overrides of the server applied
+func (c spamConfig) Thresholds(serverName string) SpamThresholds {
+	thresholds := c.Defaults
+	// Map keys are lowercased when the config is loaded
+	overrides, found := c.Servers[strings.ToLower(serverName)]
+	if !found {
+		return thresholds
+	}
+	if overrides.FloodMessages != nil {
+		thresholds.FloodMessages = *overrides.FloodMessages
+	}
+	if overrides.FloodWindow != nil {
+		thresholds.FloodWindow = *overrides.FloodWindow
+	}
+	if overrides.RepeatMessages != nil {
+		thresholds.RepeatMessages = *overrides.RepeatMessages
+	}
+	if overrides.RepeatWindow != nil {
+		thresholds.RepeatWindow = *overrides.RepeatWindow
+	}
+	if overrides.MaxMentions != nil {
+		thresholds.MaxMentions = *overrides.MaxMentions
+	}
+	if overrides.Advertising != nil {
+		thresholds.Advertising = *overrides.Advertising
+	}
+	return thresholds
+}
+
+type healthConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// DownAfter is how long a check must keep failing before the server is considered down
+	DownAfter time.Duration `mapstructure:"down_after"`
+	// LogTimeout is how long a server can go without sending any udp log lines before its log stream
+	// is considered down
+	LogTimeout time.Duration `mapstructure:"log_timeout"`
+	// PlayerDropPercent is the drop in player count, between two status updates, which is reported as
+	// an anomaly. Only applies when at least PlayerDropMin players were connected. 0 disables the check.
+	PlayerDropPercent int `mapstructure:"player_drop_percent"`
+	PlayerDropMin     int `mapstructure:"player_drop_min"`
+	// AlertChannelId is the discord channel alerts are sent to, defaults to the mod log channel
+	AlertChannelId string `mapstructure:"alert_channel_id"`
+}
+
+// AlertChannel returns the discord channel used for health alerts
+func (c healthConfig) AlertChannel() string {
+	if c.AlertChannelId != "" {
+		return c.AlertChannelId
+	}
+	return Discord.ModLogChannelId
+}
+
+// AppealCooldown overrides the default appeal cooldown for a ban reason
+type AppealCooldown struct {
+	Reason   int           `mapstructure:"reason"`
+	Cooldown time.Duration `mapstructure:"cooldown"`
+}
+
+type appealsConfig struct {
+	// Cooldown is how long a player must wait after a denied appeal before they can appeal again
+	Cooldown        time.Duration    `mapstructure:"cooldown"`
+	ReasonCooldowns []AppealCooldown `mapstructure:"reason_cooldowns"`
+}
+
+// ReasonCooldown returns the appeal cooldown for the ban reason
+func (c appealsConfig) ReasonCooldown(reason int) time.Duration {
+	for _, reasonCooldown := range c.ReasonCooldowns {
+		if reasonCooldown.Reason == reason {
+			return reasonCooldown.Cooldown
+		}
+	}
+	return c.Cooldown
+}
+
+// AltAction defines what happens when a connecting player is linked to a banned account
+type AltAction string
+
+const (
+	// AltAlert sends a notice to the discord mod log channel
+	AltAlert AltAction = "alert"
+	// AltBan automatically bans the player for ban evasion, in addition to the alert
+	AltBan AltAction = "ban"
+)
+
+type altDetectionConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Window is how far back connection history is searched for other accounts
+	Window time.Duration `mapstructure:"window"`
+	// MatchSubnet also links accounts seen within the same /24 network instead of only the exact address
+	MatchSubnet bool      `mapstructure:"match_subnet"`
+	Action      AltAction `mapstructure:"action"`
 }
 
 type dbConfig struct {
@@ -141,6 +359,9 @@ type generalConfig struct {
 	ExternalUrl                  string        `mapstructure:"external_url"`
 	BannedSteamGroupIds          []steamid.GID `mapstructure:"banned_steam_group_ids"`
 	BannedServersAddresses       []string      `mapstructure:"banned_server_addresses"`
+	// BannedServerPatterns are glob patterns matched against the address and name of master list and
+	// watched servers
+	BannedServerPatterns []string `mapstructure:"banned_server_patterns"`
 }
 
 type discordConfig struct {
@@ -158,6 +379,9 @@ type discordConfig struct {
 	PublicLogChannelId     string   `mapstructure:"public_log_channel_id"`
 	ModLogChannelId        string   `mapstructure:"mod_log_channel_id"`
 	ReportLogChannelId     string   `mapstructure:"report_log_channel_id"`
+	ChatRelayEnabled       bool     `mapstructure:"chat_relay_enabled"`
+	// ChatRelayChannels maps server short names to the channel their chat is relayed to and from
+	ChatRelayChannels map[string]string `mapstructure:"chat_relay_channels"`
 }
 
 type logConfig struct {
@@ -204,6 +428,13 @@ var (
 	Net     netBans
 	Debug   debugConfig
 	Patreon patreonConfig
+
+	BanEscalation banEscalationConfig
+	AltDetection  altDetectionConfig
+	Appeals       appealsConfig
+	Reports       reportsConfig
+	Spam          spamConfig
+	Health        healthConfig
 )
 
 // Read reads in config file and ENV variables if set.
@@ -249,6 +480,11 @@ func Read(cfgFiles ...string) {
 	root.General.WarningExceededDuration = warningDuration
 	HTTP = root.HTTP
 	General = root.General
+	if len(root.Filter.ExternalSource) > 0 {
+		log.Warnf("word_filter.external_source is deprecated, use word_filter.external_sources")
+		root.Filter.ExternalSources = root.Filter.WordLists()
+		root.Filter.ExternalSource = nil
+	}
 	Filter = root.Filter
 	Discord = root.Discord
 	DB = root.DB
@@ -256,6 +492,12 @@ func Read(cfgFiles ...string) {
 	Net = root.NetBans
 	Debug = root.Debug
 	Patreon = root.Patreon
+	BanEscalation = root.BanEscalation
+	AltDetection = root.AltDetection
+	Appeals = root.Appeals
+	Reports = root.Reports
+	Spam = root.Spam
+	Health = root.Health
 	configureLogger(log.StandardLogger())
 	gin.SetMode(General.Mode.String())
 	if errSteam := steamid.SetKey(General.SteamKey); errSteam != nil {
@@ -272,6 +514,17 @@ func Read(cfgFiles ...string) {
 	if errSteamWeb := steamweb.SetKey(General.SteamKey); errSteamWeb != nil {
 		log.Errorf("Failed to set steam api key: %v", errHomeDir)
 	}
+	for _, policy := range BanEscalation.Policies {
+		for _, duration := range policy.Durations {
+			if _, errPolicyDuration := ParseDuration(duration); errPolicyDuration != nil {
+				log.Errorf("Invalid ban_escalation duration for reason %d: %s", policy.Reason, duration)
+			}
+		}
+	}
+	if AltDetection.Action != AltAlert && AltDetection.Action != AltBan {
+		log.Errorf("Invalid alt_detection action, using alert: %s", AltDetection.Action)
+		AltDetection.Action = AltAlert
+	}
 	if found {
 		log.Debugf("Using config file: %s", viper.ConfigFileUsed())
 	} else {
@@ -297,6 +550,32 @@ var defaultConfig = map[string]any{
 	"general.external_url":                     "http://gbans.localhost:6006",
 	"general.banned_steam_group_ids":           []steamid.GID{},
 	"general.banned_server_addresses":          []string{},
+	"general.banned_server_patterns":           []string{},
+	"ban_escalation.enabled":                   false,
+	"ban_escalation.policies":                  nil,
+	"alt_detection.enabled":                    false,
+	"alt_detection.window":                     time.Hour * 24 * 30,
+	"alt_detection.match_subnet":               false,
+	"alt_detection.action":                     AltAlert,
+	"appeals.cooldown":                         time.Hour * 24 * 7,
+	"appeals.reason_cooldowns":                 nil,
+	"reports.sla":                              0,
+	"reports.need_more_info_timeout":           0,
+	"spam.enabled":                             false,
+	"spam.defaults.flood_messages":             6,
+	"spam.defaults.flood_window":               time.Second * 5,
+	"spam.defaults.repeat_messages":            3,
+	"spam.defaults.repeat_window":              time.Minute,
+	"spam.defaults.max_mentions":               4,
+	"spam.defaults.advertising":                true,
+	"spam.servers":                             map[string]any{},
+	"spam.allowed_domains":                     []string{},
+	"health.enabled":                           false,
+	"health.down_after":                        time.Minute * 2,
+	"health.log_timeout":                       time.Minute * 5,
+	"health.player_drop_percent":               50,
+	"health.player_drop_min":                   8,
+	"health.alert_channel_id":                  "",
 	"patreon.enabled":                          false,
 	"patreon.client_id":                        "",
 	"patreon.client_secret":                    "",
@@ -316,7 +595,11 @@ var defaultConfig = map[string]any{
 	"filter.is_warning":                        true,
 	"filter.ping_discord":                      false,
 	"filter.external_enabled":                  false,
-	"filter.external_source":                   []string{},
+	"word_filter.external_sources":             nil,
+	"word_filter.external_source":              nil,
+	"word_filter.external_update_freq":         "1d",
+	"word_filter.cache_path":                   ".cache/word_lists",
+	"word_filter.normalise":                    false,
 	"discord.enabled":                          false,
 	"discord.app_id":                           0,
 	"discord.token":                            "",
@@ -328,6 +611,8 @@ var defaultConfig = map[string]any{
 	"discord.public_log_channel_enable":        false,
 	"discord.public_log_channel_id":            "",
 	"discord.report_log_channel_id":            "",
+	"discord.chat_relay_enabled":               false,
+	"discord.chat_relay_channels":              map[string]string{},
 	"network_bans.enabled":                     false,
 	"network_bans.max_age":                     "1d",
 	"network_bans.cache_path":                  ".cache",
@@ -0,0 +1,63 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestBanEscalationPolicy_Duration(t *testing.T) {
+	policy := BanEscalationPolicy{Reason: 7, Durations: []string{"1d", "7d", "30d"}}
+	require.Equal(t, "1d", policy.Duration(0))
+	require.Equal(t, "7d", policy.Duration(1))
+	require.Equal(t, "30d", policy.Duration(2))
+	require.Equal(t, "30d", policy.Duration(10))
+	require.Equal(t, "", BanEscalationPolicy{Reason: 7}.Duration(0))
+
+	cfg := banEscalationConfig{Enabled: true, Policies: []BanEscalationPolicy{policy}}
+	found, ok := cfg.Policy(7)
+	require.True(t, ok)
+	require.Equal(t, policy, found)
+	_, missing := cfg.Policy(9)
+	require.False(t, missing)
+	cfg.Enabled = false
+	_, disabled := cfg.Policy(7)
+	require.False(t, disabled)
+}
+
+func TestAppealsConfig_ReasonCooldown(t *testing.T) {
+	cfg := appealsConfig{
+		Cooldown:        time.Hour,
+		ReasonCooldowns: []AppealCooldown{{Reason: 3, Cooldown: time.Hour * 24}},
+	}
+	require.Equal(t, time.Hour*24, cfg.ReasonCooldown(3))
+	require.Equal(t, time.Hour, cfg.ReasonCooldown(9))
+}
+
+func TestSpamConfig_Thresholds(t *testing.T) {
+	floodMessages := 10
+	advertising := false
+	cfg := spamConfig{
+		Defaults: SpamThresholds{FloodMessages: 6, FloodWindow: time.Second * 5, MaxMentions: 4, Advertising: true},
+		Servers: map[string]SpamThresholdOverrides{
+			"us-1": {FloodMessages: &floodMessages, Advertising: &advertising},
+		},
+	}
+	require.Equal(t, cfg.Defaults, cfg.Thresholds("eu-1"))
+	require.Equal(t, SpamThresholds{FloodMessages: 10, FloodWindow: time.Second * 5, MaxMentions: 4},
+		cfg.Thresholds("US-1"))
+}
+
+func TestFilterConfig_WordLists(t *testing.T) {
+	cfg := filterConfig{
+		ExternalSources: []WordList{{Name: "list", URL: "http://localhost/words.txt"}},
+		ExternalSource:  []string{"http://localhost/list.txt", "http://localhost/other/list.json"},
+	}
+	require.Equal(t, []WordList{
+		{Name: "list", URL: "http://localhost/words.txt"},
+		{Name: "external-source-0", URL: "http://localhost/list.txt"},
+		{Name: "external-source-1", URL: "http://localhost/other/list.json"},
+	}, cfg.WordLists())
+	require.Equal(t, []WordList{{Name: "words", URL: "http://localhost/words.txt"}},
+		filterConfig{ExternalSource: []string{"http://localhost/words.txt"}}.WordLists())
+}
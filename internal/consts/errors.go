@@ -12,4 +12,5 @@ var (
 	ErrPermissionDenied = errors.New("Permission denied")
 	ErrInternal         = errors.New("Internal error :(")
 	ErrUnknownID        = errors.New("Could not find matching server/player/steamid")
+	ErrInvalidState     = errors.New("Invalid state transition")
 )
@@ -79,6 +79,8 @@ const (
 	Web
 	// InGame is a ban using the sourcemod plugin
 	InGame
+	// Imported is a ban imported from another ban system
+	Imported
 )
 
 func (s Origin) String() string {
@@ -91,6 +93,8 @@ func (s Origin) String() string {
 		return "Web"
 	case InGame:
 		return "In-Game"
+	case Imported:
+		return "Imported"
 	default:
 		return "Unknown"
 	}
@@ -113,6 +117,7 @@ const (
 	Profile
 	ItemDescriptions
 	BotHost
+	Evading
 )
 
 var reasonStr = map[Reason]string{
@@ -128,6 +133,7 @@ var reasonStr = map[Reason]string{
 	Profile:          "Profile",
 	ItemDescriptions: "Item Name or Descriptions",
 	BotHost:          "BotHost",
+	Evading:          "Ban Evasion",
 }
 
 func (r Reason) String() string {
@@ -226,6 +232,51 @@ const (
 	NoAppeal
 )
 
+var appealStateStr = map[AppealState]string{
+	Open:     "Open",
+	Denied:   "Denied",
+	Accepted: "Accepted",
+	Reduced:  "Reduced",
+	NoAppeal: "No Appeal",
+}
+
+func (state AppealState) String() string {
+	return appealStateStr[state]
+}
+
+// appealTransitions defines the states an appeal is allowed to move to from its current state.
+// Accepted appeals are final as the ban is removed.
+var appealTransitions = map[AppealState][]AppealState{
+	Open:     {Denied, Accepted, Reduced, NoAppeal},
+	Denied:   {Open, Accepted, Reduced},
+	Reduced:  {Open, Accepted, NoAppeal},
+	NoAppeal: {Open},
+	Accepted: {},
+}
+
+// CanTransition returns true if the appeal is allowed to move to the new state
+func (state AppealState) CanTransition(newState AppealState) bool {
+	for _, allowed := range appealTransitions[state] {
+		if allowed == newState {
+			return true
+		}
+	}
+	return false
+}
+
+// AppealStateChange is an audit record of an appeal changing state
+type AppealStateChange struct {
+	AppealStateChangeId int64         `json:"appeal_state_change_id"`
+	BanId               int64         `json:"ban_id"`
+	SourceId            steamid.SID64 `json:"source_id,string"`
+	FromState           AppealState   `json:"from_state"`
+	ToState             AppealState   `json:"to_state"`
+	Note                string        `json:"note"`
+	ValidUntilBefore    time.Time     `json:"valid_until_before"`
+	ValidUntilAfter     time.Time     `json:"valid_until_after"`
+	CreatedOn           time.Time     `json:"created_on"`
+}
+
 // BanBase provides a common struct shared between all ban types, it should not be used
 // directly
 type BanBase struct {
@@ -290,6 +341,16 @@ func (banSteam *BanSteam) String() string {
 	return fmt.Sprintf("SID: %d Origin: %s Reason: %s Type: %v", banSteam.TargetId.Int64(), banSteam.Origin, banSteam.ReasonText, banSteam.BanType)
 }
 
+// BanEscalation is the result of applying a ban escalation policy for a players next offence
+type BanEscalation struct {
+	TargetId steamid.SID64 `json:"target_id,string"`
+	Reason   Reason        `json:"reason"`
+	// PriorOffences is the count of previous bans, including expired and deleted, for the same reason
+	PriorOffences int       `json:"prior_offences"`
+	Duration      Duration  `json:"duration"`
+	ValidUntil    time.Time `json:"valid_until"`
+}
+
 type BannedPerson struct {
 	Ban    BanSteam `json:"ban"`
 	Person Person   `json:"person"`
@@ -331,3 +392,125 @@ func NewUserMessage(parentId int64, authorId steamid.SID64, message string) User
 		UpdatedOn: config.Now(),
 	}
 }
+
+// BanKind identifies which of the ban tables a ban belongs to
+type BanKind string
+
+const (
+	BanKindSteam BanKind = "steam"
+	BanKindCIDR  BanKind = "cidr"
+	BanKindASN   BanKind = "asn"
+	BanKindGroup BanKind = "group"
+)
+
+// BanJoinAttempt records a player being rejected from a server due to an active ban. BanId refers to
+// the id of the ban matching Type. Group bans defined in the config have no ban, so their BanId is 0.
+type BanJoinAttempt struct {
+	BanJoinAttemptId int64         `json:"ban_join_attempt_id"`
+	Type             BanKind       `json:"type"`
+	BanId            int64         `json:"ban_id"`
+	SteamId          steamid.SID64 `json:"steam_id,string"`
+	ServerId         int           `json:"server_id"`
+	ServerName       string        `json:"server_name"`
+	IPAddr           net.IP        `json:"ip_addr"`
+	CreatedOn        time.Time     `json:"created_on"`
+}
+
+func NewBanJoinAttempt(attemptType BanKind, banId int64, steamId steamid.SID64, serverId int, addr net.IP) BanJoinAttempt {
+	return BanJoinAttempt{
+		Type:      attemptType,
+		BanId:     banId,
+		SteamId:   steamId,
+		ServerId:  serverId,
+		IPAddr:    addr,
+		CreatedOn: config.Now(),
+	}
+}
+
+// BanAuditAction describes a lifecycle event of a ban
+type BanAuditAction string
+
+const (
+	BanAuditCreated  BanAuditAction = "created"
+	BanAuditUnbanned BanAuditAction = "unbanned"
+	BanAuditExpired  BanAuditAction = "expired"
+)
+
+// BanAuditEntry records a lifecycle event for a ban of any kind. Target is the human-readable
+// subject of the ban, such as the steam id, cidr block, as number or steam group id.
+type BanAuditEntry struct {
+	BanAuditId int64          `json:"ban_audit_id"`
+	Kind       BanKind        `json:"kind"`
+	BanId      int64          `json:"ban_id"`
+	Target     string         `json:"target"`
+	Action     BanAuditAction `json:"action"`
+	Note       string         `json:"note"`
+	CreatedOn  time.Time      `json:"created_on"`
+}
+
+func NewBanAuditEntry(kind BanKind, banId int64, target string, action BanAuditAction, note string) BanAuditEntry {
+	return BanAuditEntry{
+		Kind:      kind,
+		BanId:     banId,
+		Target:    target,
+		Action:    action,
+		Note:      note,
+		CreatedOn: config.Now(),
+	}
+}
+
+// BanMatch is a ban, of any kind, which affects the subject of a ban search. Target is the subject of
+// the ban itself while MatchedOn describes which part of the searched subject matched it, such as the
+// address which falls within a banned cidr block.
+type BanMatch struct {
+	BanBase
+	Kind      BanKind `json:"kind"`
+	BanId     int64   `json:"ban_id"`
+	Target    string  `json:"target"`
+	MatchedOn string  `json:"matched_on"`
+	Active    bool    `json:"active"`
+}
+
+// BanSearchResult holds all bans found for a ban search along with the steam ids and addresses
+// the search query was expanded into.
+type BanSearchResult struct {
+	Query     string             `json:"query"`
+	SteamIds  steamid.Collection `json:"steam_ids"`
+	Addresses []net.IP           `json:"addresses"`
+	Bans      []BanMatch         `json:"bans"`
+}
+
+// BanArchive is the native gbans format used to export and import bans
+type BanArchive struct {
+	Version    int        `json:"version"`
+	ExportedOn time.Time  `json:"exported_on"`
+	Steam      []BanSteam `json:"steam"`
+	CIDR       []BanCIDR  `json:"cidr"`
+}
+
+// BanImportResult summarises the outcome of a bulk ban import
+type BanImportResult struct {
+	Steam int `json:"steam"`
+	CIDR  int `json:"cidr"`
+	// Skipped counts entries which were expired, removed or already banned
+	Skipped int      `json:"skipped"`
+	Errors  []string `json:"errors"`
+}
+
+// ExternalBanEntry links an entry of an external ban list to the ban created for it. BanId is 0 when
+// the subject was already banned by other means when the entry was first seen.
+type ExternalBanEntry struct {
+	Source    string    `json:"source"`
+	Kind      BanKind   `json:"kind"`
+	Entry     string    `json:"entry"`
+	BanId     int64     `json:"ban_id"`
+	CreatedOn time.Time `json:"created_on"`
+}
+
+// ExternalBanSource summarises the entries currently synced from an external ban list
+type ExternalBanSource struct {
+	Source    string    `json:"source"`
+	Steam     int       `json:"steam"`
+	CIDR      int       `json:"cidr"`
+	UpdatedOn time.Time `json:"updated_on"`
+}
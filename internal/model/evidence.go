@@ -0,0 +1,136 @@
+package model
+
+import (
+	"bytes"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/pkg/errors"
+	"time"
+)
+
+// MimeTypeDemo is the mimetype assigned to source engine demo files
+const MimeTypeDemo = "application/x-hl2demo"
+
+// MediaSafeMimeTypesDemos are the non-image mimetypes allowed to be uploaded as evidence
+var MediaSafeMimeTypesDemos = []string{
+	MimeTypeDemo,
+}
+
+// MediaSafeMimeTypesEvidence are all the mimetypes allowed to be uploaded as evidence
+var MediaSafeMimeTypesEvidence = append(append([]string{}, MediaSafeMimeTypesImages...), MediaSafeMimeTypesDemos...)
+
+var demoHeader = []byte("HL2DEMO\x00")
+
+func init() {
+	mimetype.Extend(func(raw []byte, _ uint32) bool {
+		return bytes.HasPrefix(raw, demoHeader)
+	}, MimeTypeDemo, ".dem")
+}
+
+var (
+	ErrEvidenceKind     = errors.New("Invalid evidence kind")
+	ErrEvidenceParent   = errors.New("Invalid evidence parent")
+	ErrEvidenceRequired = errors.New("Evidence is missing its subject")
+	ErrEvidenceTicks    = errors.New("Invalid demo tick range")
+)
+
+// EvidenceKind defines what an Evidence entry points to
+type EvidenceKind string
+
+const (
+	// EvidenceMedia is an uploaded image or demo file
+	EvidenceMedia EvidenceKind = "media"
+	// EvidenceMessage is a single chat message sent in-game
+	EvidenceMessage EvidenceKind = "message"
+	// EvidenceDemo is a tick range within a demo recorded on one of our servers
+	EvidenceDemo EvidenceKind = "demo"
+	// EvidenceMatch is a parsed match log
+	EvidenceMatch EvidenceKind = "match"
+)
+
+// EvidenceParent defines the thread type an Evidence entry is attached to
+type EvidenceParent string
+
+const (
+	EvidenceParentReport EvidenceParent = "report"
+	EvidenceParentBan    EvidenceParent = "ban"
+)
+
+// Evidence is an attachment on a report, or a ban appeal thread. Only the id field matching the Kind is set.
+type Evidence struct {
+	EvidenceId int64          `json:"evidence_id"`
+	ParentKind EvidenceParent `json:"parent_kind"`
+	ParentId   int64          `json:"parent_id"`
+	// MessageId optionally links the evidence to the report or ban UserMessage it was posted with
+	MessageId       int64         `json:"message_id"`
+	AuthorId        steamid.SID64 `json:"author_id,string"`
+	Kind            EvidenceKind  `json:"kind"`
+	MediaId         int           `json:"media_id"`
+	PersonMessageId int64         `json:"person_message_id"`
+	DemoId          int64         `json:"demo_id"`
+	TickStart       int           `json:"tick_start"`
+	TickEnd         int           `json:"tick_end"`
+	MatchId         int           `json:"match_id"`
+	Note            string        `json:"note"`
+	Deleted         bool          `json:"deleted"`
+	CreatedOn       time.Time     `json:"created_on"`
+	UpdatedOn       time.Time     `json:"updated_on"`
+}
+
+func NewEvidence(parentKind EvidenceParent, parentId int64, authorId steamid.SID64, kind EvidenceKind) Evidence {
+	t0 := config.Now()
+	return Evidence{
+		ParentKind: parentKind,
+		ParentId:   parentId,
+		AuthorId:   authorId,
+		Kind:       kind,
+		CreatedOn:  t0,
+		UpdatedOn:  t0,
+	}
+}
+
+// Validate checks that the evidence has a valid parent and that the subject matching its kind is set
+func (evidence Evidence) Validate() error {
+	if evidence.ParentKind != EvidenceParentReport && evidence.ParentKind != EvidenceParentBan {
+		return ErrEvidenceParent
+	}
+	if evidence.ParentId <= 0 {
+		return ErrEvidenceParent
+	}
+	switch evidence.Kind {
+	case EvidenceMedia:
+		if evidence.MediaId <= 0 {
+			return ErrEvidenceRequired
+		}
+	case EvidenceMessage:
+		if evidence.PersonMessageId <= 0 {
+			return ErrEvidenceRequired
+		}
+	case EvidenceDemo:
+		if evidence.DemoId <= 0 {
+			return ErrEvidenceRequired
+		}
+		if evidence.TickStart < 0 || evidence.TickEnd < 0 ||
+			(evidence.TickEnd > 0 && evidence.TickEnd < evidence.TickStart) {
+			return ErrEvidenceTicks
+		}
+	case EvidenceMatch:
+		if evidence.MatchId <= 0 {
+			return ErrEvidenceRequired
+		}
+	default:
+		return ErrEvidenceKind
+	}
+	return nil
+}
+
+// EvidenceDetail is an Evidence entry along with the resolved subject it points to
+type EvidenceDetail struct {
+	Evidence
+	Author  Person         `json:"author"`
+	Media   *Media         `json:"media,omitempty"`
+	Message *PersonMessage `json:"message,omitempty"`
+	Demo    *DemoFile      `json:"demo,omitempty"`
+	Match   *MatchSummary  `json:"match,omitempty"`
+}
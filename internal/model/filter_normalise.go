@@ -0,0 +1,176 @@
+package model
+
+import (
+	"golang.org/x/text/unicode/norm"
+	"strings"
+	"unicode"
+)
+
+// NormaliseStep identifies the normalisation applied to a message before it was matched against the filters
+type NormaliseStep string
+
+const (
+	// NormaliseNone is the lower cased message without any further changes
+	NormaliseNone NormaliseStep = "none"
+	// NormaliseConfusables folds accented characters and look-alike characters from other scripts into
+	// their latin equivalents and removes invisible characters
+	NormaliseConfusables NormaliseStep = "confusables"
+	// NormaliseSubstitutions maps common leetspeak substitutions back to letters
+	NormaliseSubstitutions NormaliseStep = "substitutions"
+	// NormaliseSeparators joins letters which have been spaced out using separators, eg: "b.a.d" -> "bad"
+	NormaliseSeparators NormaliseStep = "separators"
+)
+
+// confusables maps characters which render similarly to latin letters. Characters with diacritics
+// are handled separately by decomposing them.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p', 'с': 'c',
+	'т': 't', 'у': 'y', 'х': 'x', 'ѕ': 's', 'і': 'i', 'ї': 'i', 'ј': 'j', 'һ': 'h', 'ԁ': 'd', 'ԛ': 'q',
+	'ԝ': 'w', 'ɡ': 'g',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p', 'τ': 't',
+	'υ': 'u', 'χ': 'x', 'γ': 'y',
+	// Latin extensions
+	'ı': 'i', 'ł': 'l', 'ø': 'o', 'đ': 'd', 'ß': 's', 'ſ': 's',
+}
+
+// substitutions maps the characters commonly used in place of letters
+var substitutions = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '6': 'g', '7': 't', '8': 'b', '9': 'g',
+	'@': 'a', '$': 's', '!': 'i', '|': 'l', '+': 't', '€': 'e', '£': 'l',
+}
+
+// NormalisedText is the message after a normalisation step, and all the steps before it, have been applied
+type NormalisedText struct {
+	Step NormaliseStep `json:"step"`
+	Text string        `json:"text"`
+}
+
+// Normalise runs the message through the normalisation pipeline, returning the text after each successive
+// step. The steps are cumulative, so each entry includes the changes of the ones before it.
+func Normalise(message string) []NormalisedText {
+	lowered := strings.ToLower(message)
+	folded := foldConfusables(lowered)
+	substituted := strings.Map(func(r rune) rune {
+		if sub, found := substitutions[r]; found {
+			return sub
+		}
+		return r
+	}, folded)
+	return []NormalisedText{
+		{Step: NormaliseNone, Text: lowered},
+		{Step: NormaliseConfusables, Text: folded},
+		{Step: NormaliseSubstitutions, Text: substituted},
+		{Step: NormaliseSeparators, Text: joinSeparated(substituted)},
+	}
+}
+
+func foldConfusables(message string) string {
+	var builder strings.Builder
+	for _, r := range norm.NFKD.String(message) {
+		switch {
+		case unicode.Is(unicode.Mn, r), unicode.Is(unicode.Cf, r):
+			// Combining marks left over from decomposition and zero-width/formatting characters
+			continue
+		case unicode.IsSpace(r):
+			builder.WriteRune(' ')
+		default:
+			if folded, found := confusables[unicode.ToLower(r)]; found {
+				r = folded
+			}
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
+
+// minSpacedRun is the number of whitespace separated single letters which are joined into a word. Shorter
+// runs are left alone so that words such as "a" and "i" are not merged with their neighbours.
+const minSpacedRun = 3
+
+// joinSeparated joins single characters which have been spaced out to avoid the filters, while leaving the
+// spaces between regular words intact. Characters separated by punctuation within a word are always joined, eg:
+// "you are a b-a-d . person" -> "you are a bad person", while characters separated only by whitespace are joined
+// in runs of at least minSpacedRun letters, eg: "a b a d word" -> "abad word" but "i am a b" is unchanged.
+func joinSeparated(message string) string {
+	var words []string
+	var spaced []string
+	var flushSpaced = func() {
+		if len(spaced) >= minSpacedRun {
+			words = append(words, strings.Join(spaced, ""))
+		} else {
+			words = append(words, spaced...)
+		}
+		spaced = nil
+	}
+	for _, field := range strings.Fields(message) {
+		parts := strings.FieldsFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(parts) == 1 && len([]rune(parts[0])) == 1 {
+			spaced = append(spaced, parts[0])
+			continue
+		}
+		if len(parts) == 0 {
+			// Lone punctuation does not break up a run of spaced out letters
+			continue
+		}
+		flushSpaced()
+		var run []string
+		var flush = func() {
+			if len(run) > 1 {
+				words = append(words, strings.Join(run, ""))
+			} else {
+				words = append(words, run...)
+			}
+			run = nil
+		}
+		for _, part := range parts {
+			if len([]rune(part)) == 1 {
+				run = append(run, part)
+				continue
+			}
+			flush()
+			words = append(words, part)
+		}
+		flush()
+	}
+	flushSpaced()
+	return strings.Join(words, " ")
+}
+
+// FilterMatch describes the filter and word that matched a message
+type FilterMatch struct {
+	Filter *Filter
+	// Word is the matched word, as it was after normalisation
+	Word string
+	// Step is the normalisation step which led to the match
+	Step NormaliseStep
+}
+
+// MatchNormalised checks each of the words of the normalised message variants against the filter, returning
+// the first matching word and the step it was found at. When normalise is false only the lower cased message
+// is checked, which is equivalent to Match. Filters from external word lists are matched against the entire
+// text of each variant instead, which allows them to match phrases.
+func (f *Filter) MatchNormalised(normalised []NormalisedText, normalise bool) (string, NormaliseStep, bool) {
+	for _, variant := range normalised {
+		if !normalise && variant.Step != NormaliseNone {
+			continue
+		}
+		if f.ReadOnly() {
+			for _, pattern := range f.Patterns {
+				if match := pattern.FindStringSubmatch(variant.Text); match != nil {
+					return match[1], variant.Step, true
+				}
+			}
+			continue
+		}
+		for _, word := range strings.Split(variant.Text, " ") {
+			if word != "" && f.Match(word) {
+				return word, variant.Step, true
+			}
+		}
+	}
+	return "", "", false
+}
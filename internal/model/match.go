@@ -1,6 +1,8 @@
 package model
 
 import (
+	"bytes"
+	"encoding/gob"
 	"fmt"
 	"github.com/leighmacdonald/gbans/internal/config"
 	"github.com/leighmacdonald/gbans/internal/consts"
@@ -133,6 +135,50 @@ type Match struct {
 	curRound   int
 }
 
+// InProgress returns true when at least one round has started and the match has not yet ended
+func (match *Match) InProgress() bool {
+	return match.inMatch
+}
+
+// matchState mirrors Match with its unexported round tracking fields exposed so that
+// an in-progress match can be persisted and resumed.
+type matchState struct {
+	Match
+	InMatch    bool
+	InRound    bool
+	UseRealDmg bool
+	CurRound   int
+}
+
+// MarshalState serialises the full match state, including the internal round tracking values
+func (match *Match) MarshalState() ([]byte, error) {
+	var buf bytes.Buffer
+	if errEncode := gob.NewEncoder(&buf).Encode(matchState{
+		Match:      *match,
+		InMatch:    match.inMatch,
+		InRound:    match.inRound,
+		UseRealDmg: match.useRealDmg,
+		CurRound:   match.curRound,
+	}); errEncode != nil {
+		return nil, errors.Wrap(errEncode, "Failed to encode match state")
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalMatchState restores a match previously serialised with MarshalState
+func UnmarshalMatchState(data []byte, match *Match) error {
+	state := matchState{Match: NewMatch()}
+	if errDecode := gob.NewDecoder(bytes.NewReader(data)).Decode(&state); errDecode != nil {
+		return errors.Wrap(errDecode, "Failed to decode match state")
+	}
+	*match = state.Match
+	match.inMatch = state.InMatch
+	match.inRound = state.InRound
+	match.useRealDmg = state.UseRealDmg
+	match.curRound = state.CurRound
+	return nil
+}
+
 type MatchWeaponSum struct {
 	Weapon    logparse.Weapon
 	MatchId   int
@@ -314,7 +360,8 @@ func (match *Match) Apply(event ServerEvent) error {
 
 func (match *Match) getPlayer(sid steamid.SID64) *MatchPlayerSum {
 	if !sid.Valid() {
-		log.Fatalf("err")
+		// Bots and world events have no valid steam id, their stats are discarded
+		return &MatchPlayerSum{}
 	}
 	m, err := match.PlayerSums.GetBySteamId(sid)
 	if err != nil {
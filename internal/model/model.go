@@ -99,6 +99,21 @@ func (c ServerStateCollection) ByRegion() map[string][]ServerState {
 	return rm
 }
 
+// Public returns a copy of the states with the player details only known through rcon removed, leaving
+// the same view of the players that a2s gives to anyone
+func (c ServerStateCollection) Public() ServerStateCollection {
+	public := make(ServerStateCollection, len(c))
+	for i, state := range c {
+		players := make([]ServerStatePlayer, len(state.Players))
+		for j, player := range state.Players {
+			players[j] = ServerStatePlayer{Name: player.Name, Score: player.Score, ConnectedTime: player.ConnectedTime}
+		}
+		state.Players = players
+		public[i] = state
+	}
+	return public
+}
+
 func NewServer(name string, address string, port int) Server {
 	return Server{
 		ServerNameShort: name,
@@ -132,6 +147,12 @@ type ServerState struct {
 	Longitude   float32   `json:"longitude"`
 	Reserved    int       `json:"reserved"`
 	LastUpdate  time.Time `json:"last_update"`
+	// Watched servers are third party servers, see WatchedServer
+	Watched         bool `json:"watched"`
+	WatchedServerId int  `json:"watched_server_id"`
+	// Flagged is set when a watched server is banned, see BannedServerReason
+	Flagged       bool   `json:"flagged"`
+	FlaggedReason string `json:"flagged_reason"`
 	// A2S
 	NameA2S  string `json:"name_a2s"` // The live name can differ from default
 	Protocol uint8  `json:"protocol"`
@@ -169,15 +190,35 @@ type ServerState struct {
 	STVPort uint16 `json:"stv_port"`
 	// Name of the spectator server for SourceTV.
 	STVName string `json:"stv_name"`
+	// Selected server cvars from the a2s rules query, see FilterServerRules
+	Rules map[string]string `json:"rules"`
 
-	// RCON Sourced
+	// RCON Sourced, falling back to the a2s player list when rcon status is unavailable
 	Players []ServerStatePlayer `json:"players"`
 }
 
+// publicServerRules are the cvars exposed in the server state. Entries ending in * match by prefix.
+var publicServerRules = []string{"tf_gamemode_*", "sv_tags", "nextlevel", "mp_timelimit", "mp_winlimit", "mp_maxrounds"}
+
+// FilterServerRules returns only the cvars from the a2s rules response which are useful to show publicly
+func FilterServerRules(rules map[string]string) map[string]string {
+	filtered := map[string]string{}
+	for key, value := range rules {
+		for _, rule := range publicServerRules {
+			if prefix := strings.TrimSuffix(rule, "*"); prefix != rule && strings.HasPrefix(key, prefix) || key == rule {
+				filtered[key] = value
+				break
+			}
+		}
+	}
+	return filtered
+}
+
 type ServerStatePlayer struct {
 	UserID        int           `json:"user_id"`
 	Name          string        `json:"name"`
 	SID           steamid.SID64 `json:"steam_id"`
+	Score         int           `json:"score"`
 	ConnectedTime time.Duration `json:"connected_time"`
 	State         string        `json:"state"`
 	Ping          int           `json:"ping"`
@@ -202,6 +243,27 @@ type Stats struct {
 	ServersTotal  int `json:"servers_total"`
 }
 
+// FilterAction defines what happens to a player when they trigger a word filter
+type FilterAction string
+
+const (
+	// FilterActionWarn issues a warning, which counts towards the global warning limit
+	FilterActionWarn FilterAction = "warn"
+	FilterActionGag  FilterAction = "gag"
+	FilterActionKick FilterAction = "kick"
+	FilterActionBan  FilterAction = "ban"
+)
+
+// Valid checks if the action is one of the known filter actions
+func (action FilterAction) Valid() bool {
+	switch action {
+	case FilterActionWarn, FilterActionGag, FilterActionKick, FilterActionBan:
+		return true
+	default:
+		return false
+	}
+}
+
 type Filter struct {
 	WordID           int64       `json:"word_id,omitempty"`
 	Patterns         WordFilters `json:"patterns,omitempty"`
@@ -210,6 +272,85 @@ type Filter struct {
 	DiscordId        string      `json:"discord_id,omitempty"`
 	DiscordCreatedOn *time.Time  `json:"discord_created_on"`
 	FilterName       string      `json:"filter_name"`
+	// Action is applied immediately when the filter is matched, in addition to the warning being recorded
+	Action FilterAction `json:"action"`
+	// Weight is the number of warnings a match counts as towards the warning limit
+	Weight    int    `json:"weight"`
+	BanReason Reason `json:"ban_reason"`
+	// Duration of gags and bans issued by the filter. When empty, general.warning_exceeded_duration is used
+	Duration string `json:"duration"`
+	// PingDiscord alerts the moderators when the filter is matched
+	PingDiscord bool `json:"ping_discord"`
+	// Source is the name of the external word list the filter was loaded from. Filters from external
+	// sources are not stored in the database and are read-only.
+	Source string `json:"source"`
+}
+
+// ReadOnly returns true for filters loaded from external word lists
+func (filter Filter) ReadOnly() bool {
+	return filter.Source != ""
+}
+
+var (
+	ErrFilterAction   = errors.New("Invalid filter action")
+	ErrFilterWeight   = errors.New("Filter weight must be at least 1")
+	ErrFilterDuration = errors.New("Invalid filter duration")
+)
+
+// Validate checks the action settings of the filter
+func (filter Filter) Validate() error {
+	if !filter.Action.Valid() {
+		return ErrFilterAction
+	}
+	if filter.Weight < 1 {
+		return ErrFilterWeight
+	}
+	if filter.Duration != "" {
+		if _, errDuration := config.ParseDuration(filter.Duration); errDuration != nil {
+			return ErrFilterDuration
+		}
+	}
+	return nil
+}
+
+// NewFilter allocates a filter with the default warn only behaviour
+func NewFilter(name string, patterns WordFilters) Filter {
+	t0 := config.Now()
+	return Filter{
+		Patterns:   patterns,
+		CreatedOn:  t0,
+		UpdatedOn:  t0,
+		FilterName: name,
+		Action:     FilterActionWarn,
+		Weight:     1,
+		BanReason:  Language,
+	}
+}
+
+// NewExternalFilter compiles the words of an external word list into a read-only filter. The words are combined
+// into a single pattern where each entry must match entire words of the message. Entries consisting of several
+// words match the phrase, regardless of the amount of whitespace between the words.
+func NewExternalFilter(source string, words []string) (Filter, error) {
+	var entries []string
+	for _, word := range words {
+		var parts []string
+		for _, part := range strings.Fields(strings.ToLower(word)) {
+			parts = append(parts, regexp.QuoteMeta(part))
+		}
+		if len(parts) > 0 {
+			entries = append(entries, strings.Join(parts, `\s+`))
+		}
+	}
+	if len(entries) == 0 {
+		return Filter{}, errors.New("Empty word list")
+	}
+	pattern, errPattern := regexp.Compile(`(?:^|\s)(` + strings.Join(entries, "|") + `)(?:\s|$)`)
+	if errPattern != nil {
+		return Filter{}, errors.Wrap(errPattern, "Failed to compile word list")
+	}
+	filter := NewFilter(source, WordFilters{pattern})
+	filter.Source = source
+	return filter, nil
 }
 
 type WordFilters []*regexp.Regexp
@@ -424,6 +565,11 @@ type ServerStats struct {
 
 type ReportStatus int
 
+const (
+	// AnyStatus is only used for filtering queries and is never assigned to a report
+	AnyStatus ReportStatus = -1
+)
+
 const (
 	Opened ReportStatus = iota
 	NeedMoreInfo
@@ -452,9 +598,30 @@ type Report struct {
 	ReportStatus ReportStatus  `json:"report_status"`
 	Reason       Reason        `json:"reason"`
 	ReasonText   string        `json:"reason_text"`
-	Deleted      bool          `json:"deleted"`
-	CreatedOn    time.Time     `json:"created_on"`
-	UpdatedOn    time.Time     `json:"updated_on"`
+	// DemoName is the demo that was recording on the server when an in-game report was created
+	DemoName string `json:"demo_name"`
+	// AssigneeId is the moderator who has claimed the report, 0 when unassigned
+	AssigneeId steamid.SID64 `json:"assignee_id,string"`
+	// StatusChangedOn is when the report moved into its current ReportStatus
+	StatusChangedOn time.Time `json:"status_changed_on"`
+	// Escalated is set once moderators have been alerted that the report has exceeded the SLA in its
+	// current status
+	Escalated bool      `json:"escalated"`
+	Deleted   bool      `json:"deleted"`
+	CreatedOn time.Time `json:"created_on"`
+	UpdatedOn time.Time `json:"updated_on"`
+}
+
+// SetStatus moves the report into a new status, resetting the time in state
+func (report *Report) SetStatus(status ReportStatus) {
+	report.ReportStatus = status
+	report.StatusChangedOn = config.Now()
+	report.Escalated = false
+}
+
+// TimeInState returns how long the report has been in its current status
+func (report Report) TimeInState(now time.Time) time.Duration {
+	return now.Sub(report.StatusChangedOn)
 }
 
 func (report Report) ToURL() string {
@@ -463,12 +630,13 @@ func (report Report) ToURL() string {
 
 func NewReport() Report {
 	return Report{
-		ReportId:     0,
-		AuthorId:     0,
-		Description:  "",
-		ReportStatus: 0,
-		CreatedOn:    config.Now(),
-		UpdatedOn:    config.Now(),
+		ReportId:        0,
+		AuthorId:        0,
+		Description:     "",
+		ReportStatus:    0,
+		StatusChangedOn: config.Now(),
+		CreatedOn:       config.Now(),
+		UpdatedOn:       config.Now(),
 	}
 }
 
@@ -512,6 +680,154 @@ type PersonMessage struct {
 
 type PersonMessages []PersonMessage
 
+// ChatSearchHit is a message matching a chat search along with the messages sent on the same server
+// immediately before and after it
+type ChatSearchHit struct {
+	Message PersonMessage  `json:"message"`
+	Before  PersonMessages `json:"before"`
+	After   PersonMessages `json:"after"`
+}
+
+// UserWarning is a single warning issued to a player, either automatically by a word filter match
+// or manually by a moderator. Warnings only count towards the warning limit until ValidUntil.
+type UserWarning struct {
+	WarningId   int64         `json:"warning_id"`
+	SteamId     steamid.SID64 `json:"steam_id,string"`
+	SourceId    steamid.SID64 `json:"source_id,string"`
+	ServerId    int           `json:"server_id"`
+	ServerName  string        `json:"server_name"`
+	WarnReason  Reason        `json:"warn_reason"`
+	Message     string        `json:"message"`
+	FilterId    int64         `json:"filter_id"`
+	MatchedWord string        `json:"matched_word"`
+	// MatchedStep is the normalisation step which led to the filter match
+	MatchedStep NormaliseStep `json:"matched_step"`
+	CreatedOn   time.Time     `json:"created_on"`
+	ValidUntil  time.Time     `json:"valid_until"`
+	// Weight is the number of warnings this warning counts as towards the warning limit
+	Weight int `json:"weight"`
+}
+
+// Expired returns true once the warning no longer counts towards the warning limit
+func (warning UserWarning) Expired(now time.Time) bool {
+	return now.After(warning.ValidUntil)
+}
+
+type UserWarnings []UserWarning
+
+// TotalWeight returns the sum of the weights of the warnings
+func (warnings UserWarnings) TotalWeight() int {
+	total := 0
+	for _, warning := range warnings {
+		total += warning.Weight
+	}
+	return total
+}
+
+// ServerActionKind is the action the game server plugin is asked to apply to a connected player
+type ServerActionKind string
+
+const (
+	ServerActionGag   ServerActionKind = "gag"
+	ServerActionUngag ServerActionKind = "ungag"
+	ServerActionKick  ServerActionKind = "kick"
+)
+
+// ServerActionStatus tracks the delivery of a ServerAction to the game server
+type ServerActionStatus string
+
+const (
+	// ServerActionPending actions are waiting to be fetched and confirmed by the plugin
+	ServerActionPending ServerActionStatus = "pending"
+	// ServerActionApplied actions were confirmed as applied by the plugin
+	ServerActionApplied ServerActionStatus = "applied"
+	// ServerActionFailed actions could not be applied, eg: the player was no longer connected
+	ServerActionFailed ServerActionStatus = "failed"
+	// ServerActionExpired actions were never confirmed by the plugin
+	ServerActionExpired ServerActionStatus = "expired"
+)
+
+// ServerAction is a gag, ungag or kick pushed to a game server. Actions are queued until the plugin
+// fetches them and confirms the result.
+type ServerAction struct {
+	ServerActionId int64              `json:"server_action_id"`
+	ServerId       int                `json:"server_id"`
+	SteamId        steamid.SID64      `json:"steam_id,string"`
+	Kind           ServerActionKind   `json:"kind"`
+	Reason         string             `json:"reason"`
+	Status         ServerActionStatus `json:"status"`
+	// Message is the response of the plugin when confirming the action
+	Message   string    `json:"message"`
+	CreatedOn time.Time `json:"created_on"`
+	UpdatedOn time.Time `json:"updated_on"`
+}
+
+func NewServerAction(serverId int, steamId steamid.SID64, kind ServerActionKind, reason string) ServerAction {
+	t0 := config.Now()
+	return ServerAction{
+		ServerId:  serverId,
+		SteamId:   steamId,
+		Kind:      kind,
+		Reason:    reason,
+		Status:    ServerActionPending,
+		CreatedOn: t0,
+		UpdatedOn: t0,
+	}
+}
+
+// HealthCheck identifies one of the checks used to monitor the availability of a server
+type HealthCheck string
+
+const (
+	HealthCheckA2S  HealthCheck = "a2s"
+	HealthCheckRCON HealthCheck = "rcon"
+	// HealthCheckLogs tracks whether the server is still sending its udp log stream
+	HealthCheckLogs HealthCheck = "logs"
+)
+
+var HealthChecks = []HealthCheck{HealthCheckA2S, HealthCheckRCON, HealthCheckLogs}
+
+// ServerUptime is an interval during which a health check of a server was continuously up, or down.
+// The current interval of each check is still open and has a zero EndedOn.
+type ServerUptime struct {
+	ServerUptimeId int64       `json:"server_uptime_id"`
+	ServerId       int         `json:"server_id"`
+	Check          HealthCheck `json:"check"`
+	Up             bool        `json:"up"`
+	StartedOn      time.Time   `json:"started_on"`
+	EndedOn        time.Time   `json:"ended_on"`
+}
+
+// UptimePercent returns the percentage of time between since and now that the check was up. Time not covered
+// by any interval, such as before monitoring was enabled, is excluded. Returns false when no intervals overlap.
+func UptimePercent(intervals []ServerUptime, check HealthCheck, since time.Time, now time.Time) (float64, bool) {
+	var total, up time.Duration
+	for _, interval := range intervals {
+		if interval.Check != check {
+			continue
+		}
+		start := interval.StartedOn
+		if start.Before(since) {
+			start = since
+		}
+		end := interval.EndedOn
+		if end.IsZero() || end.After(now) {
+			end = now
+		}
+		if !end.After(start) {
+			continue
+		}
+		total += end.Sub(start)
+		if interval.Up {
+			up += end.Sub(start)
+		}
+	}
+	if total == 0 {
+		return 0, false
+	}
+	return float64(up) / float64(total) * 100, true
+}
+
 type Media struct {
 	MediaId   int           `json:"media_id"`
 	AuthorId  steamid.SID64 `json:"author_id,string"`
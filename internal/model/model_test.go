@@ -2,9 +2,13 @@ package model
 
 import (
 	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/leighmacdonald/gbans/pkg/logparse"
+	"github.com/leighmacdonald/steamid/v2/steamid"
 	"github.com/stretchr/testify/require"
+	"net"
 	"regexp"
 	"testing"
+	"time"
 )
 
 func TestFilter_Match(t *testing.T) {
@@ -16,6 +20,116 @@ func TestFilter_Match(t *testing.T) {
 	require.True(t, filter.Match("super pooooooper"))
 }
 
+func TestFilter_Validate(t *testing.T) {
+	filter := NewFilter("test", WordFiltersFromString("test"))
+	require.NoError(t, filter.Validate())
+	filter.Action = "mute"
+	require.ErrorIs(t, filter.Validate(), ErrFilterAction)
+	filter.Action = FilterActionBan
+	filter.Weight = 0
+	require.ErrorIs(t, filter.Validate(), ErrFilterWeight)
+	filter.Weight = 2
+	filter.Duration = "1x"
+	require.ErrorIs(t, filter.Validate(), ErrFilterDuration)
+	filter.Duration = "2w"
+	require.NoError(t, filter.Validate())
+}
+
+func TestNormalise(t *testing.T) {
+	steps := map[NormaliseStep]string{}
+	for _, variant := range Normalise("Thé В\u200bАD w0rd i$ s-p-a-c-e-d") {
+		steps[variant.Step] = variant.Text
+	}
+	require.Equal(t, "thé в\u200bаd w0rd i$ s-p-a-c-e-d", steps[NormaliseNone])
+	require.Equal(t, "the bad w0rd i$ s-p-a-c-e-d", steps[NormaliseConfusables])
+	require.Equal(t, "the bad word is s-p-a-c-e-d", steps[NormaliseSubstitutions])
+	require.Equal(t, "the bad word is spaced", steps[NormaliseSeparators])
+	require.Equal(t, "you are a bad person", joinSeparated("you are a b-a-d . person"))
+	require.Equal(t, "i am a b", joinSeparated("i am a b"))
+	require.Equal(t, "badword here", joinSeparated("b a d w o r d here"))
+	require.Equal(t, "so i am a cat", joinSeparated("so i am a cat"))
+	require.Equal(t, "bad word x", joinSeparated("b.a.d-word, x!"))
+}
+
+func TestFilter_MatchNormalised(t *testing.T) {
+	filter := NewFilter("test", WordFiltersFromString("^bad$"))
+	testCases := []struct {
+		message string
+		step    NormaliseStep
+	}{
+		{"you are bad", NormaliseNone},
+		{"you are bаd", NormaliseConfusables},
+		{"you are b@d", NormaliseSubstitutions},
+		{"you are b.a.d", NormaliseSeparators},
+		{"you are b a d", NormaliseSeparators},
+	}
+	for _, testCase := range testCases {
+		word, step, matched := filter.MatchNormalised(Normalise(testCase.message), true)
+		require.True(t, matched, testCase.message)
+		require.Equal(t, "bad", word)
+		require.Equal(t, testCase.step, step, testCase.message)
+	}
+	_, _, matched := filter.MatchNormalised(Normalise("you are b.a.d"), false)
+	require.False(t, matched)
+	_, _, matched = filter.MatchNormalised(Normalise("you are a badger"), true)
+	require.False(t, matched)
+}
+
+func TestUserWarnings_TotalWeight(t *testing.T) {
+	require.Equal(t, 0, UserWarnings{}.TotalWeight())
+	require.Equal(t, 4, UserWarnings{{Weight: 1}, {Weight: 3}}.TotalWeight())
+}
+
+func TestUptimePercent(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	since := now.Add(-time.Hour * 10)
+	intervals := []ServerUptime{
+		// Clipped to the 2 hours after since
+		{Check: HealthCheckA2S, Up: true, StartedOn: since.Add(-time.Hour * 5), EndedOn: since.Add(time.Hour * 2)},
+		{Check: HealthCheckA2S, Up: false, StartedOn: since.Add(time.Hour * 2), EndedOn: since.Add(time.Hour * 3)},
+		// Still open
+		{Check: HealthCheckA2S, Up: true, StartedOn: since.Add(time.Hour * 3)},
+		{Check: HealthCheckRCON, Up: false, StartedOn: since.Add(time.Hour * 5)},
+	}
+	percent, found := UptimePercent(intervals, HealthCheckA2S, since, now)
+	require.True(t, found)
+	require.InDelta(t, 90.0, percent, 0.001)
+	percent, found = UptimePercent(intervals, HealthCheckRCON, since, now)
+	require.True(t, found)
+	require.InDelta(t, 0.0, percent, 0.001)
+	_, found = UptimePercent(intervals, HealthCheckLogs, since, now)
+	require.False(t, found)
+}
+
+func TestMatch_MarshalState(t *testing.T) {
+	match := NewMatch()
+	match.ServerId = 1
+	require.NoError(t, match.Apply(ServerEvent{EventType: logparse.WRoundStart}))
+	require.NoError(t, match.Apply(ServerEvent{
+		EventType: logparse.Killed,
+		Source:    Person{SteamID: steamid.SID64(76561198084134025)},
+		Target:    Person{SteamID: steamid.SID64(76561197961279983)},
+		Team:      logparse.RED,
+	}))
+	state, errState := match.MarshalState()
+	require.NoError(t, errState)
+	var restored Match
+	require.NoError(t, UnmarshalMatchState(state, &restored))
+	require.True(t, restored.InProgress())
+	require.Equal(t, match.ServerId, restored.ServerId)
+	require.Equal(t, len(match.Rounds), len(restored.Rounds))
+	// Further events must apply to the restored round
+	require.NoError(t, restored.Apply(ServerEvent{
+		EventType: logparse.Killed,
+		Source:    Person{SteamID: steamid.SID64(76561198084134025)},
+		Target:    Person{SteamID: steamid.SID64(76561197961279983)},
+		Team:      logparse.RED,
+	}))
+	killer, errKiller := restored.PlayerSums.GetBySteamId(76561198084134025)
+	require.NoError(t, errKiller)
+	require.Equal(t, 2, killer.Kills)
+}
+
 //
 //func TestServerEvent(t *testing.T) {
 //	se := ServerEvent{
@@ -25,3 +139,120 @@ func TestFilter_Match(t *testing.T) {
 //		},
 //	}
 //}
+
+func TestNewPersonLink(t *testing.T) {
+	low := steamid.SID64(76561198003911389)
+	high := steamid.SID64(76561198083950960)
+	link := NewPersonLink(high, low, net.ParseIP("10.0.0.1"))
+	require.Equal(t, low, link.SteamId)
+	require.Equal(t, high, link.LinkedId)
+	require.Equal(t, high, link.Other(low))
+	require.Equal(t, low, link.Other(high))
+}
+
+func TestAppealState_CanTransition(t *testing.T) {
+	require.True(t, Open.CanTransition(Denied))
+	require.True(t, Open.CanTransition(Reduced))
+	require.True(t, Denied.CanTransition(Open))
+	require.False(t, Open.CanTransition(Open))
+	require.False(t, Accepted.CanTransition(Open))
+	require.False(t, NoAppeal.CanTransition(Accepted))
+}
+
+func TestEvidence_Validate(t *testing.T) {
+	evidence := NewEvidence(EvidenceParentReport, 1, steamid.SID64(76561198084134025), EvidenceDemo)
+	require.ErrorIs(t, evidence.Validate(), ErrEvidenceRequired)
+	evidence.DemoId = 10
+	evidence.TickStart = 500
+	evidence.TickEnd = 100
+	require.ErrorIs(t, evidence.Validate(), ErrEvidenceTicks)
+	evidence.TickEnd = 1000
+	require.NoError(t, evidence.Validate())
+	evidence.ParentKind = "wiki"
+	require.ErrorIs(t, evidence.Validate(), ErrEvidenceParent)
+	evidence.ParentKind = EvidenceParentBan
+	evidence.Kind = "url"
+	require.ErrorIs(t, evidence.Validate(), ErrEvidenceKind)
+}
+
+func TestNewMedia_Demo(t *testing.T) {
+	media := NewMedia(steamid.SID64(76561198084134025), "test demo.dem", MimeTypeDemo,
+		append([]byte("HL2DEMO\x00"), make([]byte, 64)...))
+	require.Equal(t, MimeTypeDemo, media.MimeType)
+	require.Equal(t, "test_demo.dem", media.Name)
+}
+
+func TestReport_SetStatus(t *testing.T) {
+	report := NewReport()
+	report.StatusChangedOn = config.Now().Add(-time.Hour)
+	report.Escalated = true
+	require.True(t, report.TimeInState(config.Now()) >= time.Hour)
+	report.SetStatus(NeedMoreInfo)
+	require.Equal(t, NeedMoreInfo, report.ReportStatus)
+	require.False(t, report.Escalated)
+	require.True(t, report.TimeInState(config.Now()) < time.Minute)
+}
+
+func TestFilterServerRules(t *testing.T) {
+	rules := map[string]string{
+		"tf_gamemode_cp":   "1",
+		"tf_gamemode_ctf":  "0",
+		"sv_tags":          "cp,uncletopia",
+		"nextlevel":        "cp_process_final",
+		"sv_gravity":       "800",
+		"tf_gamemode":      "x",
+		"mp_timelimit":     "30",
+		"sm_nextmap":       "pl_upward",
+		"sv_tags_override": "1",
+	}
+	require.Equal(t, map[string]string{
+		"tf_gamemode_cp":  "1",
+		"tf_gamemode_ctf": "0",
+		"sv_tags":         "cp,uncletopia",
+		"nextlevel":       "cp_process_final",
+		"mp_timelimit":    "30",
+	}, FilterServerRules(rules))
+	require.Empty(t, FilterServerRules(nil))
+}
+
+func TestServerStateCollection_Public(t *testing.T) {
+	states := ServerStateCollection{{NameShort: "test-1", Players: []ServerStatePlayer{
+		{UserID: 3, Name: "player", SID: 76561198084134025, Score: 4, ConnectedTime: time.Minute, State: "active", Ping: 50},
+	}}}
+	public := states.Public()
+	require.Equal(t, []ServerStatePlayer{{Name: "player", Score: 4, ConnectedTime: time.Minute}}, public[0].Players)
+	require.Equal(t, 3, states[0].Players[0].UserID, "Original state should be unchanged")
+}
+
+func TestBannedServerReason(t *testing.T) {
+	addresses := []string{"1.2.3.4", "5.6.7.8:27016"}
+	patterns := []string{"*free items*", "9.9.9.*"}
+	require.Equal(t, "Banned address: 1.2.3.4", BannedServerReason("1.2.3.4", 27015, "", addresses, patterns))
+	require.Equal(t, "Banned address: 5.6.7.8:27016", BannedServerReason("5.6.7.8", 27016, "", addresses, patterns))
+	require.Equal(t, "", BannedServerReason("5.6.7.8", 27015, "Normal Server", addresses, patterns))
+	require.Equal(t, "Matches banned pattern: *free items*",
+		BannedServerReason("10.0.0.1", 27015, "FREE ITEMS | 24/7 2fort", addresses, patterns))
+	require.Equal(t, "Matches banned pattern: 9.9.9.*", BannedServerReason("9.9.9.1", 27015, "", addresses, patterns))
+	require.Equal(t, "", BannedServerReason("10.0.0.1", 27015, "", nil, nil))
+}
+
+func TestScheduledTask(t *testing.T) {
+	task := NewScheduledTask("announce", "0 */10 * * * *", ScheduledTaskAnnounce, "Join our discord")
+	require.Error(t, task.Validate(), "Target is required")
+	task.Region = "na"
+	require.NoError(t, task.Validate())
+	task.ServerId = 1
+	require.Error(t, task.Validate(), "Only one target is allowed")
+	task.Region = ""
+	require.NoError(t, task.Validate())
+	require.Equal(t, "sm_csay Join our discord", task.RCONCommand())
+
+	task.Kind = ScheduledTaskCommand
+	task.Command = "changelevel pl_upward"
+	require.Equal(t, "changelevel pl_upward", task.RCONCommand())
+	task.Kind = "invalid"
+	require.Error(t, task.Validate())
+	task.Kind = ScheduledTaskCommand
+	task.Command = " "
+	require.Error(t, task.Validate())
+}
@@ -153,3 +153,52 @@ func NewPersonAuth(sid64 steamid.SID64, addr net.IP) PersonAuth {
 		CreatedOn:    config.Now(),
 	}
 }
+
+// PersonLink is an edge in the linked accounts graph, connecting two accounts which have been
+// seen connecting from the same address or network. SteamId is always the lower of the two ids.
+type PersonLink struct {
+	SteamId   steamid.SID64 `json:"steam_id,string"`
+	LinkedId  steamid.SID64 `json:"linked_id,string"`
+	IPAddr    net.IP        `json:"ip_addr"`
+	CreatedOn time.Time     `json:"created_on"`
+	UpdatedOn time.Time     `json:"updated_on"`
+}
+
+// NewPersonLink creates a link between two accounts, ordering the ids so each pair has a single edge
+func NewPersonLink(sid64 steamid.SID64, linked steamid.SID64, addr net.IP) PersonLink {
+	if linked < sid64 {
+		sid64, linked = linked, sid64
+	}
+	t0 := config.Now()
+	return PersonLink{
+		SteamId:   sid64,
+		LinkedId:  linked,
+		IPAddr:    addr,
+		CreatedOn: t0,
+		UpdatedOn: t0,
+	}
+}
+
+// Other returns the id on the opposite side of the link from sid64
+func (link PersonLink) Other(sid64 steamid.SID64) steamid.SID64 {
+	if link.SteamId == sid64 {
+		return link.LinkedId
+	}
+	return link.SteamId
+}
+
+// LinkedAccount is a node in the linked accounts graph
+type LinkedAccount struct {
+	SteamId     steamid.SID64 `json:"steam_id,string"`
+	PersonaName string        `json:"persona_name"`
+	Avatar      string        `json:"avatar"`
+	BanType     BanType       `json:"ban_type"`
+	// Depth is the number of links between this account and the account the graph was built from
+	Depth int `json:"depth"`
+}
+
+// LinkedAccounts is the graph of accounts linked to an account
+type LinkedAccounts struct {
+	Nodes []LinkedAccount `json:"nodes"`
+	Links []PersonLink    `json:"links"`
+}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"fmt"
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/pkg/errors"
+	"strings"
+	"time"
+)
+
+// ScheduledTaskKind determines how the task command is sent to the servers
+type ScheduledTaskKind string
+
+const (
+	// ScheduledTaskCommand runs the command as is, eg: changelevel, exec or _restart
+	ScheduledTaskCommand ScheduledTaskKind = "command"
+	// ScheduledTaskAnnounce shows the command as a centered message via sm_csay
+	ScheduledTaskAnnounce ScheduledTaskKind = "announce"
+)
+
+// ScheduledTaskRunStatus is the result of running a task on a single server
+type ScheduledTaskRunStatus string
+
+const (
+	ScheduledTaskRunSuccess ScheduledTaskRunStatus = "success"
+	ScheduledTaskRunFailed  ScheduledTaskRunStatus = "failed"
+	// ScheduledTaskRunSkipped is used when the conditions of the task were not met
+	ScheduledTaskRunSkipped ScheduledTaskRunStatus = "skipped"
+)
+
+// ScheduledTask is a rcon command run on a cron schedule against a single server or every server in a region
+type ScheduledTask struct {
+	ScheduledTaskId int    `json:"scheduled_task_id"`
+	Name            string `json:"name"`
+	// Schedule is a cron expression with an optional leading seconds field and TZ= prefix, eg: "0 0 4 * * *"
+	Schedule string            `json:"schedule"`
+	Kind     ScheduledTaskKind `json:"kind"`
+	Command  string            `json:"command"`
+	// Only one of ServerId or Region is set
+	ServerId int    `json:"server_id"`
+	Region   string `json:"region"`
+	// OnlyWhenEmpty skips servers with any human players connected
+	OnlyWhenEmpty bool `json:"only_when_empty"`
+	// OnlyWhenOutdated skips servers whose game version is still current according to steam
+	OnlyWhenOutdated bool      `json:"only_when_outdated"`
+	IsEnabled        bool      `json:"is_enabled"`
+	CreatedOn        time.Time `json:"created_on"`
+	UpdatedOn        time.Time `json:"updated_on"`
+}
+
+func NewScheduledTask(name string, schedule string, kind ScheduledTaskKind, command string) ScheduledTask {
+	t0 := config.Now()
+	return ScheduledTask{
+		Name:      name,
+		Schedule:  schedule,
+		Kind:      kind,
+		Command:   command,
+		IsEnabled: true,
+		CreatedOn: t0,
+		UpdatedOn: t0,
+	}
+}
+
+// Validate checks everything but the schedule, which is parsed when the task is started
+func (task ScheduledTask) Validate() error {
+	if strings.TrimSpace(task.Name) == "" {
+		return errors.New("Name is required")
+	}
+	if strings.TrimSpace(task.Command) == "" {
+		return errors.New("Command is required")
+	}
+	if task.Kind != ScheduledTaskCommand && task.Kind != ScheduledTaskAnnounce {
+		return errors.Errorf("Invalid kind: %s", task.Kind)
+	}
+	if (task.ServerId > 0) == (task.Region != "") {
+		return errors.New("Exactly one of server or region is required")
+	}
+	return nil
+}
+
+// RCONCommand returns the command sent to the servers
+func (task ScheduledTask) RCONCommand() string {
+	if task.Kind == ScheduledTaskAnnounce {
+		return fmt.Sprintf("sm_csay %s", task.Command)
+	}
+	return task.Command
+}
+
+// ScheduledTaskRun records the outcome of a task on a server
+type ScheduledTaskRun struct {
+	ScheduledTaskRunId int64                  `json:"scheduled_task_run_id"`
+	ScheduledTaskId    int                    `json:"scheduled_task_id"`
+	ServerId           int                    `json:"server_id"`
+	Status             ScheduledTaskRunStatus `json:"status"`
+	// Output is the rcon response, or the error or skip reason
+	Output    string    `json:"output"`
+	CreatedOn time.Time `json:"created_on"`
+}
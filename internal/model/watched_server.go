@@ -0,0 +1,58 @@
+package model
+
+import (
+	"fmt"
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/leighmacdonald/gbans/pkg/util"
+	"strings"
+	"time"
+)
+
+// WatchedServer is a third party server which is only queried over a2s. Unlike Server, we don't own it, so
+// nothing beyond the address is required.
+type WatchedServer struct {
+	WatchedServerId int `json:"watched_server_id"`
+	// Name is used until the server responds with its own name
+	Name      string    `json:"name"`
+	Address   string    `json:"address"`
+	Port      int       `json:"port"`
+	Region    string    `json:"region"`
+	CC        string    `json:"cc"`
+	IsEnabled bool      `json:"is_enabled"`
+	CreatedOn time.Time `json:"created_on"`
+	UpdatedOn time.Time `json:"updated_on"`
+}
+
+func NewWatchedServer(address string, port int) WatchedServer {
+	return WatchedServer{
+		Address:   address,
+		Port:      port,
+		IsEnabled: true,
+		CreatedOn: config.Now(),
+		UpdatedOn: config.Now(),
+	}
+}
+
+func (s WatchedServer) Addr() string {
+	return fmt.Sprintf("%s:%d", s.Address, s.Port)
+}
+
+// BannedServerReason checks the server against the banned addresses, which can be either a bare host or
+// host:port, and the banned glob patterns, which are matched against both the address and the server name.
+// An empty string is returned when the server is not banned.
+func BannedServerReason(host string, port int, name string, bannedAddresses []string, bannedPatterns []string) string {
+	addr := fmt.Sprintf("%s:%d", host, port)
+	for _, banned := range bannedAddresses {
+		if strings.EqualFold(banned, host) || strings.EqualFold(banned, addr) {
+			return fmt.Sprintf("Banned address: %s", banned)
+		}
+	}
+	lowerName := strings.ToLower(name)
+	for _, pattern := range bannedPatterns {
+		lowerPattern := strings.ToLower(pattern)
+		if util.GlobString(lowerPattern, strings.ToLower(addr)) || name != "" && util.GlobString(lowerPattern, lowerName) {
+			return fmt.Sprintf("Matches banned pattern: %s", pattern)
+		}
+	}
+	return ""
+}
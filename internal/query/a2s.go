@@ -8,20 +8,49 @@ import (
 	"time"
 )
 
-func A2SQueryServer(server model.Server) (*a2s.ServerInfo, error) {
-	client, errClient := a2s.NewClient(server.Addr(), a2s.TimeoutOption(time.Second*5))
+// A2SResult holds the responses to the a2s info, player and rules queries of a server
+type A2SResult struct {
+	Info *a2s.ServerInfo
+	// Players and Rules are nil when the server does not respond to those queries
+	Players *a2s.PlayerInfo
+	Rules   *a2s.RulesInfo
+}
+
+// A2SQueryServer queries the server info, players and rules. Only the info query is required to succeed,
+// as servers can disable the player and rules queries.
+func A2SQueryServer(server model.Server) (*A2SResult, error) {
+	return A2SQueryAddress(server.Addr(), server.ServerNameShort)
+}
+
+// A2SQueryAddress queries a server by address alone, used for servers we don't own. The name is only
+// used for logging.
+func A2SQueryAddress(addr string, name string) (*A2SResult, error) {
+	client, errClient := a2s.NewClient(addr, a2s.TimeoutOption(time.Second*5))
 	if errClient != nil {
 		return nil, errors.Wrapf(errClient, "Failed to create a2s client")
 	}
 
 	defer func() {
 		if errClose := client.Close(); errClose != nil {
-			log.WithFields(log.Fields{"server": server.ServerNameShort}).Errorf("Failed to close a2s client: %v", errClose)
+			log.WithFields(log.Fields{"server": name}).Errorf("Failed to close a2s client: %v", errClose)
 		}
 	}()
-	info, errQuery := client.QueryInfo() // QueryInfo, QueryPlayer, QueryRules
+	info, errQuery := client.QueryInfo()
 	if errQuery != nil {
 		return nil, errors.Wrapf(errQuery, "Failed to query server info")
 	}
-	return info, nil
+	result := A2SResult{Info: info}
+	players, errPlayers := client.QueryPlayer()
+	if errPlayers != nil {
+		log.WithFields(log.Fields{"server": name}).Tracef("Failed to query players: %v", errPlayers)
+	} else {
+		result.Players = players
+	}
+	rules, errRules := client.QueryRules()
+	if errRules != nil {
+		log.WithFields(log.Fields{"server": name}).Tracef("Failed to query rules: %v", errRules)
+	} else {
+		result.Rules = rules
+	}
+	return &result, nil
 }
@@ -3,38 +3,39 @@ package query
 
 import (
 	"context"
-	"fmt"
 	"github.com/leighmacdonald/gbans/internal/model"
-	"github.com/leighmacdonald/rcon/rcon"
 	"github.com/leighmacdonald/steamid/v2/extra"
-	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"strings"
 	"sync"
 	"time"
 )
 
+// rconPool is shared by all rcon callers so that each server only has a single connection
+var rconPool = NewRCONPool()
+
+// StartRCONPool runs the health checks of the shared rcon pool until the context is done
+func StartRCONPool(ctx context.Context) {
+	rconPool.Start(ctx)
+}
+
+// GetRCONStats returns the connection statistics of the shared rcon pool
+func GetRCONStats() []RCONStats {
+	return rconPool.Stats()
+}
+
 // ExecRCON executes the given command against the server provided. It returns the command
 // output.
 func ExecRCON(ctx context.Context, server model.Server, cmd string) (string, error) {
 	execCtx, cancelExec := context.WithTimeout(ctx, time.Second*15)
 	defer cancelExec()
-	console, errDial := rcon.Dial(execCtx, server.Addr(), server.RCON, time.Second*10)
-	if errDial != nil {
-		return "", errors.Errorf("Failed to dial server: %s (%v)", server.ServerNameShort, errDial)
-	}
-	resp, errExec := console.Exec(sanitizeRCONCommand(cmd))
-	if errExec != nil {
-		return "", errors.Errorf("Failed to exec command: %v", errExec)
-	}
-	return resp, nil
+	return rconPool.Exec(execCtx, server, cmd)
 }
 
 // RCON is used to execute rcon commands against multiple servers
 func RCON(ctx context.Context, servers []model.Server, commands ...string) map[string]string {
 	responses := make(map[string]string)
 	rwMutex := &sync.RWMutex{}
-	timeout := time.Second * 10
 	waitGroup := &sync.WaitGroup{}
 	for _, server := range servers {
 		waitGroup.Add(1)
@@ -42,14 +43,8 @@ func RCON(ctx context.Context, servers []model.Server, commands ...string) map[s
 			defer waitGroup.Done()
 			rconCtx, cancelExec := context.WithTimeout(ctx, time.Second*20)
 			defer cancelExec()
-			addr := fmt.Sprintf("%s:%d", server.Address, server.Port)
-			conn, errDial := rcon.Dial(rconCtx, addr, server.RCON, timeout)
-			if errDial != nil {
-				log.Errorf("Failed to connect to server %s: %v", server.ServerNameShort, errDial)
-				return
-			}
 			for _, command := range commands {
-				resp, errExec := conn.Exec(sanitizeRCONCommand(command))
+				resp, errExec := rconPool.Exec(rconCtx, server, command)
 				if errExec != nil {
 					log.Tracef("Failed to exec rcon command %s: %v", server.ServerNameShort, errExec)
 				}
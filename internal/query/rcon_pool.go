@@ -0,0 +1,359 @@
+package query
+
+import (
+	"context"
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/rcon/rcon"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	rconQueueSize      = 32
+	rconDialTimeout    = time.Second * 10
+	rconCommandTimeout = time.Second * 15
+	rconBackoffMin     = time.Second * 2
+	rconBackoffMax     = time.Minute * 5
+	rconHealthInterval = time.Second * 30
+	// rconHealthCommand is a cheap command used to check that idle connections are still alive
+	rconHealthCommand = "echo"
+)
+
+var (
+	ErrRCONQueueFull   = errors.New("RCON command queue full")
+	ErrRCONUnavailable = errors.New("RCON unavailable")
+	ErrRCONPoolClosed  = errors.New("RCON pool closed")
+)
+
+var (
+	rconLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{Name: "gbans_rcon_latency_seconds", Help: "RCON command round trip time"},
+		[]string{"server_name"})
+
+	rconErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "gbans_rcon_errors", Help: "Total failed rcon connections and commands"},
+		[]string{"server_name"})
+
+	rconReconnects = prometheus.NewCounterVec(
+		prometheus.CounterOpts{Name: "gbans_rcon_reconnects", Help: "Total rcon connections established"},
+		[]string{"server_name"})
+
+	rconConnected = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{Name: "gbans_rcon_connected", Help: "Whether the rcon connection is established"},
+		[]string{"server_name"})
+)
+
+func init() {
+	for _, m := range []prometheus.Collector{rconLatency, rconErrors, rconReconnects, rconConnected} {
+		_ = prometheus.Register(m)
+	}
+}
+
+// RCONStats holds the connection state and command statistics of a server's rcon session
+type RCONStats struct {
+	ServerId   int    `json:"server_id"`
+	ServerName string `json:"server_name"`
+	Connected  bool   `json:"connected"`
+	Commands   int64  `json:"commands"`
+	Errors     int64  `json:"errors"`
+	Reconnects int64  `json:"reconnects"`
+	// Failures is the number of consecutive failures, which determines the reconnect backoff
+	Failures      int       `json:"failures"`
+	LastLatencyMs int64     `json:"last_latency_ms"`
+	LastError     string    `json:"last_error"`
+	LastErrorOn   time.Time `json:"last_error_on"`
+	RetryAt       time.Time `json:"retry_at"`
+}
+
+type rconResult struct {
+	response string
+	err      error
+}
+
+type rconCommand struct {
+	ctx     context.Context
+	command string
+	result  chan rconResult
+}
+
+// rconSession owns the connection to a single server. Commands are queued and executed one at a
+// time by the session's worker, which is the only goroutine touching the connection.
+type rconSession struct {
+	queue chan rconCommand
+	mu    *sync.RWMutex
+	// server is the latest known server config, the connection is re-established when the address or
+	// password changes
+	server model.Server
+	stats  RCONStats
+
+	// Only accessed by the worker
+	console     *rcon.RemoteConsole
+	connectedTo model.Server
+}
+
+// RCONPool maintains a persistent, authenticated rcon connection per server. Failed connections are
+// re-established on demand with an exponential backoff, and idle connections are periodically checked.
+type RCONPool struct {
+	sessions   map[int]*rconSession
+	sessionsMu *sync.Mutex
+	done       chan struct{}
+	closeOnce  *sync.Once
+}
+
+func NewRCONPool() *RCONPool {
+	return &RCONPool{
+		sessions:   map[int]*rconSession{},
+		sessionsMu: &sync.Mutex{},
+		done:       make(chan struct{}),
+		closeOnce:  &sync.Once{},
+	}
+}
+
+// Start runs the periodic health checks, closing all connections once the context is done
+func (pool *RCONPool) Start(ctx context.Context) {
+	ticker := time.NewTicker(rconHealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			pool.healthCheck(ctx)
+		case <-ctx.Done():
+			pool.Close()
+			log.Debugf("rconPool shutting down")
+			return
+		case <-pool.done:
+			return
+		}
+	}
+}
+
+// Close stops the session workers and closes their connections
+func (pool *RCONPool) Close() {
+	pool.closeOnce.Do(func() {
+		close(pool.done)
+	})
+}
+
+// Exec queues the command on the server's session and waits for the response
+func (pool *RCONPool) Exec(ctx context.Context, server model.Server, command string) (string, error) {
+	session, errSession := pool.session(server)
+	if errSession != nil {
+		return "", errSession
+	}
+	cmd := rconCommand{ctx: ctx, command: sanitizeRCONCommand(command), result: make(chan rconResult, 1)}
+	select {
+	case session.queue <- cmd:
+	default:
+		return "", errors.Wrapf(ErrRCONQueueFull, "Cannot exec command on %s", server.ServerNameShort)
+	}
+	select {
+	case result := <-cmd.result:
+		return result.response, result.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Stats returns the current statistics of each server session, ordered by server name
+func (pool *RCONPool) Stats() []RCONStats {
+	pool.sessionsMu.Lock()
+	defer pool.sessionsMu.Unlock()
+	stats := []RCONStats{}
+	for _, session := range pool.sessions {
+		session.mu.RLock()
+		stats = append(stats, session.stats)
+		session.mu.RUnlock()
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].ServerName < stats[j].ServerName
+	})
+	return stats
+}
+
+func (pool *RCONPool) session(server model.Server) (*rconSession, error) {
+	pool.sessionsMu.Lock()
+	defer pool.sessionsMu.Unlock()
+	select {
+	case <-pool.done:
+		return nil, ErrRCONPoolClosed
+	default:
+	}
+	session, found := pool.sessions[server.ServerID]
+	if !found {
+		session = &rconSession{
+			queue:  make(chan rconCommand, rconQueueSize),
+			mu:     &sync.RWMutex{},
+			server: server,
+			stats:  RCONStats{ServerId: server.ServerID, ServerName: server.ServerNameShort},
+		}
+		pool.sessions[server.ServerID] = session
+		go session.run(pool.done)
+		return session, nil
+	}
+	session.mu.Lock()
+	session.server = server
+	session.stats.ServerName = server.ServerNameShort
+	session.mu.Unlock()
+	return session, nil
+}
+
+// healthCheck queues a check on each idle session. Sessions which are backing off are skipped until
+// their retry time, at which point the check doubles as the reconnect attempt.
+func (pool *RCONPool) healthCheck(ctx context.Context) {
+	pool.sessionsMu.Lock()
+	var sessions []*rconSession
+	for _, session := range pool.sessions {
+		sessions = append(sessions, session)
+	}
+	pool.sessionsMu.Unlock()
+	now := time.Now()
+	for _, session := range sessions {
+		session.mu.RLock()
+		retryAt := session.stats.RetryAt
+		server := session.server
+		session.mu.RUnlock()
+		if len(session.queue) > 0 || now.Before(retryAt) {
+			continue
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, rconCommandTimeout)
+		if _, errCheck := pool.Exec(checkCtx, server, rconHealthCommand); errCheck != nil {
+			log.WithFields(log.Fields{"server": server.ServerNameShort}).
+				Debugf("RCON health check failed: %v", errCheck)
+		}
+		cancel()
+	}
+}
+
+func (session *rconSession) run(done <-chan struct{}) {
+	for {
+		select {
+		case cmd := <-session.queue:
+			if errCtx := cmd.ctx.Err(); errCtx != nil {
+				// The caller has already given up
+				cmd.result <- rconResult{err: errCtx}
+				continue
+			}
+			response, errExec := session.exec(cmd.ctx, cmd.command)
+			cmd.result <- rconResult{response: response, err: errExec}
+		case <-done:
+			session.disconnect()
+			return
+		}
+	}
+}
+
+// exec runs the command, connecting first if required. A command which fails on a connection that was
+// reused from an earlier command is retried once on a new connection, as the server may have silently dropped
+// the idle connection, before the failure counts towards the reconnect backoff.
+func (session *rconSession) exec(ctx context.Context, command string) (string, error) {
+	session.mu.RLock()
+	server := session.server
+	retryAt := session.stats.RetryAt
+	session.mu.RUnlock()
+	if session.console != nil && (session.connectedTo.Addr() != server.Addr() || session.connectedTo.RCON != server.RCON) {
+		session.disconnect()
+	}
+	for {
+		reused := session.console != nil
+		if !reused {
+			if time.Now().Before(retryAt) {
+				return "", errors.Wrapf(ErrRCONUnavailable, "%s retrying in %s", server.ServerNameShort,
+					time.Until(retryAt).Round(time.Second))
+			}
+			dialCtx, cancelDial := context.WithTimeout(ctx, rconDialTimeout)
+			console, errDial := rcon.Dial(dialCtx, server.Addr(), server.RCON, rconDialTimeout)
+			cancelDial()
+			if errDial != nil {
+				session.failed(server, errDial)
+				return "", errors.Errorf("Failed to dial server: %s (%v)", server.ServerNameShort, errDial)
+			}
+			session.console = console
+			session.connectedTo = server
+			session.connected(server)
+		}
+		timeout := rconCommandTimeout
+		if deadline, hasDeadline := ctx.Deadline(); hasDeadline && time.Until(deadline) < timeout {
+			timeout = time.Until(deadline)
+		}
+		// The console has no way to cancel a read other than closing the connection
+		console := session.console
+		timer := time.AfterFunc(timeout, func() {
+			_ = console.Close()
+		})
+		start := time.Now()
+		response, errExec := console.Exec(command)
+		timer.Stop()
+		if errExec != nil {
+			session.disconnect()
+			if reused && ctx.Err() == nil {
+				log.WithFields(log.Fields{"server": server.ServerNameShort}).
+					Debugf("RCON command failed on existing connection, reconnecting: %v", errExec)
+				continue
+			}
+			session.failed(server, errExec)
+			return "", errors.Errorf("Failed to exec command: %v", errExec)
+		}
+		session.succeeded(server, time.Since(start))
+		return response, nil
+	}
+}
+
+func (session *rconSession) disconnect() {
+	if session.console == nil {
+		return
+	}
+	_ = session.console.Close()
+	session.console = nil
+	session.mu.Lock()
+	session.stats.Connected = false
+	session.mu.Unlock()
+	rconConnected.With(prometheus.Labels{"server_name": session.connectedTo.ServerNameShort}).Set(0)
+}
+
+func (session *rconSession) connected(server model.Server) {
+	session.mu.Lock()
+	session.stats.Connected = true
+	session.stats.Reconnects++
+	session.mu.Unlock()
+	labels := prometheus.Labels{"server_name": server.ServerNameShort}
+	rconReconnects.With(labels).Inc()
+	rconConnected.With(labels).Set(1)
+	log.WithFields(log.Fields{"server": server.ServerNameShort}).Debugf("RCON connected")
+}
+
+func (session *rconSession) succeeded(server model.Server, latency time.Duration) {
+	session.mu.Lock()
+	session.stats.Commands++
+	session.stats.Failures = 0
+	session.stats.LastLatencyMs = latency.Milliseconds()
+	session.stats.RetryAt = time.Time{}
+	session.mu.Unlock()
+	rconLatency.With(prometheus.Labels{"server_name": server.ServerNameShort}).Observe(latency.Seconds())
+}
+
+func (session *rconSession) failed(server model.Server, err error) {
+	session.mu.Lock()
+	session.stats.Errors++
+	session.stats.Failures++
+	session.stats.LastError = err.Error()
+	session.stats.LastErrorOn = time.Now()
+	session.stats.RetryAt = time.Now().Add(rconBackoff(session.stats.Failures))
+	session.mu.Unlock()
+	rconErrors.With(prometheus.Labels{"server_name": server.ServerNameShort}).Inc()
+}
+
+// rconBackoff returns the delay before reconnecting after the given number of consecutive failures
+func rconBackoff(failures int) time.Duration {
+	delay := rconBackoffMin
+	for i := 1; i < failures && delay < rconBackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > rconBackoffMax {
+		delay = rconBackoffMax
+	}
+	return delay
+}
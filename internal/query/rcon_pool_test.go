@@ -0,0 +1,171 @@
+package query
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRCONServer accepts any password and responds to commands with "resp:<command>". When failing is set,
+// the connection is closed instead of responding to commands.
+type fakeRCONServer struct {
+	listener net.Listener
+	mu       *sync.Mutex
+	accepted int
+	conns    []net.Conn
+	failing  bool
+}
+
+func newFakeRCONServer(t *testing.T) *fakeRCONServer {
+	listener, errListen := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, errListen)
+	server := &fakeRCONServer{listener: listener, mu: &sync.Mutex{}}
+	go func() {
+		for {
+			conn, errAccept := listener.Accept()
+			if errAccept != nil {
+				return
+			}
+			server.mu.Lock()
+			server.accepted++
+			server.conns = append(server.conns, conn)
+			server.mu.Unlock()
+			go server.handle(conn)
+		}
+	}()
+	t.Cleanup(func() {
+		_ = listener.Close()
+		server.dropConnections()
+	})
+	return server
+}
+
+func (server *fakeRCONServer) handle(conn net.Conn) {
+	for {
+		var size int32
+		if errSize := binary.Read(conn, binary.LittleEndian, &size); errSize != nil {
+			return
+		}
+		packet := make([]byte, size)
+		if _, errRead := io.ReadFull(conn, packet); errRead != nil {
+			return
+		}
+		requestId := int32(binary.LittleEndian.Uint32(packet[0:4]))
+		cmdType := int32(binary.LittleEndian.Uint32(packet[4:8]))
+		body := string(packet[8 : len(packet)-2])
+		switch cmdType {
+		case 3:
+			server.write(conn, requestId, 2, "")
+		case 2:
+			server.mu.Lock()
+			failing := server.failing
+			server.mu.Unlock()
+			if failing {
+				_ = conn.Close()
+				return
+			}
+			server.write(conn, requestId, 0, "resp:"+body)
+		}
+	}
+}
+
+func (server *fakeRCONServer) write(conn net.Conn, requestId int32, respType int32, body string) {
+	buf := make([]byte, 14+len(body))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(10+len(body)))
+	binary.LittleEndian.PutUint32(buf[4:8], uint32(requestId))
+	binary.LittleEndian.PutUint32(buf[8:12], uint32(respType))
+	copy(buf[12:], body)
+	_, _ = conn.Write(buf)
+}
+
+func (server *fakeRCONServer) dropConnections() {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	for _, conn := range server.conns {
+		_ = conn.Close()
+	}
+	server.conns = nil
+}
+
+func (server *fakeRCONServer) setFailing(failing bool) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.failing = failing
+}
+
+func (server *fakeRCONServer) acceptCount() int {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	return server.accepted
+}
+
+func (server *fakeRCONServer) model() model.Server {
+	host, portStr, _ := net.SplitHostPort(server.listener.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	srv := model.NewServer("test-1", host, port)
+	srv.ServerID = 1
+	srv.RCON = "test"
+	return srv
+}
+
+func TestRCONPool(t *testing.T) {
+	fakeServer := newFakeRCONServer(t)
+	server := fakeServer.model()
+	pool := NewRCONPool()
+	defer pool.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+
+	for _, command := range []string{"status", "sm_say hi"} {
+		resp, errExec := pool.Exec(ctx, server, command)
+		require.NoError(t, errExec)
+		require.Equal(t, "resp:"+command, resp)
+	}
+	require.Equal(t, 1, fakeServer.acceptCount(), "Connection should be reused")
+
+	// A stale connection is replaced and the command retried without backing off
+	fakeServer.dropConnections()
+	resp, errStale := pool.Exec(ctx, server, "status")
+	require.NoError(t, errStale)
+	require.Equal(t, "resp:status", resp)
+	require.Equal(t, 2, fakeServer.acceptCount())
+
+	// Failing on the new connection as well starts the backoff
+	fakeServer.setFailing(true)
+	_, errFailed := pool.Exec(ctx, server, "status")
+	require.Error(t, errFailed)
+	require.Equal(t, 3, fakeServer.acceptCount())
+	_, errBackoff := pool.Exec(ctx, server, "status")
+	require.ErrorIs(t, errBackoff, ErrRCONUnavailable)
+
+	// Skip the backoff delay
+	fakeServer.setFailing(false)
+	pool.sessions[server.ServerID].mu.Lock()
+	pool.sessions[server.ServerID].stats.RetryAt = time.Now()
+	pool.sessions[server.ServerID].mu.Unlock()
+	resp, errReconnect := pool.Exec(ctx, server, "status")
+	require.NoError(t, errReconnect)
+	require.Equal(t, "resp:status", resp)
+	require.Equal(t, 4, fakeServer.acceptCount())
+
+	stats := pool.Stats()
+	require.Len(t, stats, 1)
+	require.True(t, stats[0].Connected)
+	require.Equal(t, int64(4), stats[0].Commands)
+	require.Equal(t, int64(1), stats[0].Errors)
+	require.Equal(t, int64(4), stats[0].Reconnects)
+	require.Equal(t, 0, stats[0].Failures)
+}
+
+func TestRCONBackoff(t *testing.T) {
+	require.Equal(t, rconBackoffMin, rconBackoff(1))
+	require.Equal(t, rconBackoffMin*4, rconBackoff(3))
+	require.Equal(t, rconBackoffMax, rconBackoff(100))
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/leighmacdonald/steamid/v2/steamid"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"net"
 	"time"
 )
 
@@ -80,6 +81,35 @@ func (database *pgStore) GetBanByBanID(ctx context.Context, banID int64, bannedP
 	return database.getBanByColumn(ctx, "ban_id", banID, bannedPerson, deletedOk)
 }
 
+// GetBanCountsByReason returns the number of bans, including expired and soft-deleted bans, the player has
+// received for the reason, broken down by the kind of ban. Group bans have no reason and are never counted.
+func (database *pgStore) GetBanCountsByReason(ctx context.Context, sid64 steamid.SID64, reason model.Reason) (map[model.BanKind]int, error) {
+	const query = `
+		SELECT $3::text, count(ban_id) FROM ban WHERE target_id = $1 AND reason = $2
+		UNION ALL
+		SELECT $4::text, count(net_id) FROM ban_net WHERE target_id = $1 AND reason = $2
+		UNION ALL
+		SELECT $5::text, count(ban_asn_id) FROM ban_asn WHERE target_id = $1 AND reason = $2`
+	rows, errQuery := database.Query(ctx, query, sid64, reason,
+		model.BanKindSteam, model.BanKindCIDR, model.BanKindASN)
+	if errQuery != nil {
+		return nil, Err(errQuery)
+	}
+	defer rows.Close()
+	counts := map[model.BanKind]int{}
+	for rows.Next() {
+		var (
+			kind  model.BanKind
+			count int
+		)
+		if errScan := rows.Scan(&kind, &count); errScan != nil {
+			return nil, Err(errScan)
+		}
+		counts[kind] = count
+	}
+	return counts, nil
+}
+
 // SaveBan will insert or update the ban record
 // New records will have the Ban.BanID set automatically
 func (database *pgStore) SaveBan(ctx context.Context, ban *model.BanSteam) error {
@@ -128,16 +158,21 @@ func (database *pgStore) insertBan(ctx context.Context, ban *model.BanSteam) err
 	return nil
 }
 
-func (database *pgStore) updateBan(ctx context.Context, ban *model.BanSteam) error {
-	const query = `
+const updateBanQuery = `
 		UPDATE ban
 		SET source_id = $2, reason = $3, reason_text = $4, note = $5, valid_until = $6, updated_on = $7, 
 			origin = $8, ban_type = $9, deleted = $10, report_id = case WHEN $11 = 0 THEN null ELSE $11 END, 
 			unban_reason_text = $12, is_enabled = $13, target_id = $14, appeal_state = $15
 		WHERE ban_id = $1`
-	if errExec := database.Exec(ctx, query, ban.BanID, ban.SourceId, ban.Reason, ban.ReasonText, ban.Note, ban.ValidUntil,
+
+func updateBanArgs(ban *model.BanSteam) []any {
+	return []any{ban.BanID, ban.SourceId, ban.Reason, ban.ReasonText, ban.Note, ban.ValidUntil,
 		ban.UpdatedOn, ban.Origin, ban.BanType, ban.Deleted, ban.ReportId, ban.UnbanReasonText, ban.IsEnabled,
-		ban.TargetId, ban.AppealState); errExec != nil {
+		ban.TargetId, ban.AppealState}
+}
+
+func (database *pgStore) updateBan(ctx context.Context, ban *model.BanSteam) error {
+	if errExec := database.Exec(ctx, updateBanQuery, updateBanArgs(ban)...); errExec != nil {
 		return Err(errExec)
 	}
 	return nil
@@ -436,17 +471,44 @@ func (database *pgStore) DropBanMessage(ctx context.Context, message *model.User
 	return nil
 }
 
-func (database *pgStore) GetBanGroup(ctx context.Context, groupId steamid.GID, banGroup *model.BanGroup) error {
+// GetBanGroup returns the active steam group ban made against the target player
+func (database *pgStore) GetBanGroup(ctx context.Context, targetId steamid.SID64, banGroup *model.BanGroup) error {
 	const q = `
-		SELECT ban_group_id, source_id, target_id, group_name, is_enabled, deleted, 
+		SELECT ban_group_id, source_id, target_id, group_id, group_name, is_enabled, deleted, 
 		       note, unban_reason_text, origin, created_on, updated_on, valid_until, appeal_state
 		FROM ban_group 
 		WHERE target_id = $1 AND is_enabled = true AND deleted = false`
+	return Err(database.QueryRow(ctx, q, targetId).
+		Scan(
+			&banGroup.BanGroupId,
+			&banGroup.SourceId,
+			&banGroup.TargetId,
+			&banGroup.GroupId,
+			&banGroup.GroupName,
+			&banGroup.IsEnabled,
+			&banGroup.Deleted,
+			&banGroup.Note,
+			&banGroup.UnbanReasonText,
+			&banGroup.Origin,
+			&banGroup.CreatedOn,
+			&banGroup.UpdatedOn,
+			&banGroup.ValidUntil,
+			&banGroup.AppealState))
+}
+
+// GetBanGroupByGroupId returns the active ban of a steam group
+func (database *pgStore) GetBanGroupByGroupId(ctx context.Context, groupId steamid.GID, banGroup *model.BanGroup) error {
+	const q = `
+		SELECT ban_group_id, source_id, target_id, group_id, group_name, is_enabled, deleted, 
+		       note, unban_reason_text, origin, created_on, updated_on, valid_until, appeal_state
+		FROM ban_group 
+		WHERE group_id = $1 AND is_enabled = true AND deleted = false`
 	return Err(database.QueryRow(ctx, q, groupId).
 		Scan(
 			&banGroup.BanGroupId,
 			&banGroup.SourceId,
 			&banGroup.TargetId,
+			&banGroup.GroupId,
 			&banGroup.GroupName,
 			&banGroup.IsEnabled,
 			&banGroup.Deleted,
@@ -461,7 +523,7 @@ func (database *pgStore) GetBanGroup(ctx context.Context, groupId steamid.GID, b
 
 func (database *pgStore) GetBanGroupById(ctx context.Context, banGroupId int64, banGroup *model.BanGroup) error {
 	const q = `
-		SELECT ban_group_id, source_id, target_id, group_name, is_enabled, deleted, 
+		SELECT ban_group_id, source_id, target_id, group_id, group_name, is_enabled, deleted, 
 		       note, unban_reason_text, origin, created_on, updated_on, valid_until, appeal_state
 		FROM ban_group 
 		WHERE ban_group_id = $1 AND is_enabled = true AND deleted = false`
@@ -470,6 +532,7 @@ func (database *pgStore) GetBanGroupById(ctx context.Context, banGroupId int64,
 			&banGroup.BanGroupId,
 			&banGroup.SourceId,
 			&banGroup.TargetId,
+			&banGroup.GroupId,
 			&banGroup.GroupName,
 			&banGroup.IsEnabled,
 			&banGroup.Deleted,
@@ -484,7 +547,7 @@ func (database *pgStore) GetBanGroupById(ctx context.Context, banGroupId int64,
 
 func (database *pgStore) GetBanGroups(ctx context.Context) ([]model.BanGroup, error) {
 	const q = `
-		SELECT ban_group_id, source_id, target_id, group_name, is_enabled, deleted, 
+		SELECT ban_group_id, source_id, target_id, group_id, group_name, is_enabled, deleted, 
 		       note, unban_reason_text, origin, created_on, updated_on, valid_until, appeal_state
 		FROM ban_group 
 		WHERE deleted = false`
@@ -499,6 +562,7 @@ func (database *pgStore) GetBanGroups(ctx context.Context) ([]model.BanGroup, er
 		if errScan := rows.Scan(&group.BanGroupId,
 			&group.SourceId,
 			&group.TargetId,
+			&group.GroupId,
 			&group.GroupName,
 			&group.IsEnabled,
 			&group.Deleted,
@@ -516,6 +580,31 @@ func (database *pgStore) GetBanGroups(ctx context.Context) ([]model.BanGroup, er
 	return groups, nil
 }
 
+// GetExpiredBanGroups returns all active steam group bans which have passed their expiry
+func (database *pgStore) GetExpiredBanGroups(ctx context.Context) ([]model.BanGroup, error) {
+	const q = `
+		SELECT ban_group_id, source_id, target_id, group_id, group_name, is_enabled, deleted, 
+		       note, unban_reason_text, origin, created_on, updated_on, valid_until, appeal_state
+		FROM ban_group 
+		WHERE valid_until < $1 AND is_enabled = true AND deleted = false`
+	rows, errRows := database.Query(ctx, q, config.Now())
+	if errRows != nil {
+		return nil, Err(errRows)
+	}
+	defer rows.Close()
+	var groups []model.BanGroup
+	for rows.Next() {
+		var group model.BanGroup
+		if errScan := rows.Scan(&group.BanGroupId, &group.SourceId, &group.TargetId, &group.GroupId,
+			&group.GroupName, &group.IsEnabled, &group.Deleted, &group.Note, &group.UnbanReasonText,
+			&group.Origin, &group.CreatedOn, &group.UpdatedOn, &group.ValidUntil, &group.AppealState); errScan != nil {
+			return nil, Err(errScan)
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
 func (database *pgStore) SaveBanGroup(ctx context.Context, banGroup *model.BanGroup) error {
 	if banGroup.BanGroupId > 0 {
 		return database.updateBanGroup(ctx, banGroup)
@@ -553,3 +642,319 @@ func (database *pgStore) DropBanGroup(ctx context.Context, banGroup *model.BanGr
 	banGroup.IsEnabled = false
 	return database.SaveBanGroup(ctx, banGroup)
 }
+
+// SaveBanGroupMembers replaces the known members of a banned steam group. Members of groups which are no
+// longer banned are kept so that their expired and deleted bans can still be searched.
+func (database *pgStore) SaveBanGroupMembers(ctx context.Context, groupId steamid.GID, members steamid.Collection) error {
+	memberIds := []int64{}
+	for _, member := range members {
+		memberIds = append(memberIds, member.Int64())
+	}
+	tx, errBeginTx := database.conn.Begin(ctx)
+	if errBeginTx != nil {
+		return Err(errBeginTx)
+	}
+	if _, errDelete := tx.Exec(ctx, `DELETE FROM ban_group_member WHERE group_id = $1`, groupId.Int64()); errDelete != nil {
+		_ = tx.Rollback(ctx)
+		return Err(errDelete)
+	}
+	if _, errInsert := tx.Exec(ctx, `
+		INSERT INTO ban_group_member (group_id, steam_id, updated_on) 
+		SELECT $1, steam_id, $3 FROM unnest($2::bigint[]) steam_id
+		ON CONFLICT DO NOTHING`, groupId.Int64(), memberIds, config.Now()); errInsert != nil {
+		_ = tx.Rollback(ctx)
+		return Err(errInsert)
+	}
+	if errCommit := tx.Commit(ctx); errCommit != nil {
+		return Err(errCommit)
+	}
+	return nil
+}
+
+// GetBanGroupMemberships returns the banned steam groups which any of the steam ids are known to be a member of
+func (database *pgStore) GetBanGroupMemberships(ctx context.Context, steamIds steamid.Collection) ([]steamid.GID, error) {
+	ids := []int64{}
+	for _, sid64 := range steamIds {
+		ids = append(ids, sid64.Int64())
+	}
+	rows, errQuery := database.conn.Query(ctx, `
+		SELECT DISTINCT group_id FROM ban_group_member WHERE steam_id = ANY($1::bigint[])`, ids)
+	if errQuery != nil {
+		return nil, Err(errQuery)
+	}
+	defer rows.Close()
+	groupIds := []steamid.GID{}
+	for rows.Next() {
+		var groupId int64
+		if errScan := rows.Scan(&groupId); errScan != nil {
+			return nil, Err(errScan)
+		}
+		groupIds = append(groupIds, steamid.GID(groupId))
+	}
+	return groupIds, nil
+}
+
+type BanJoinAttemptQueryFilter struct {
+	QueryFilter
+	Type    model.BanKind `json:"type,omitempty"`
+	BanId   int64         `json:"ban_id,omitempty"`
+	SteamId steamid.SID64 `json:"steam_id,omitempty"`
+}
+
+func (database *pgStore) SaveBanJoinAttempt(ctx context.Context, attempt *model.BanJoinAttempt) error {
+	const query = `
+		INSERT INTO ban_join_attempt (ban_type, ban_id, steam_id, server_id, ip_addr, created_on)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ban_join_attempt_id`
+	var serverId any
+	if attempt.ServerId > 0 {
+		serverId = attempt.ServerId
+	}
+	return Err(database.QueryRow(ctx, query, attempt.Type, attempt.BanId, attempt.SteamId.Int64(), serverId,
+		attempt.IPAddr, attempt.CreatedOn).Scan(&attempt.BanJoinAttemptId))
+}
+
+// GetBanJoinAttempts returns the join attempts matching the filter, newest first
+func (database *pgStore) GetBanJoinAttempts(ctx context.Context, filter BanJoinAttemptQueryFilter) ([]model.BanJoinAttempt, error) {
+	qb := sb.Select("a.ban_join_attempt_id", "a.ban_type", "a.ban_id", "a.steam_id",
+		"coalesce(a.server_id, 0)", "coalesce(s.short_name, '')", "a.ip_addr", "a.created_on").
+		From("ban_join_attempt a").
+		LeftJoin("server s ON s.server_id = a.server_id").
+		OrderBy("a.created_on DESC")
+	if filter.Type != "" {
+		qb = qb.Where(sq.Eq{"a.ban_type": filter.Type})
+	}
+	if filter.BanId > 0 {
+		qb = qb.Where(sq.Eq{"a.ban_id": filter.BanId})
+	}
+	if filter.SteamId.Valid() {
+		qb = qb.Where(sq.Eq{"a.steam_id": filter.SteamId.Int64()})
+	}
+	if filter.Limit > 0 {
+		qb = qb.Limit(filter.Limit)
+	}
+	if filter.Offset > 0 {
+		qb = qb.Offset(filter.Offset)
+	}
+	query, args, errQueryBuilder := qb.ToSql()
+	if errQueryBuilder != nil {
+		return nil, Err(errQueryBuilder)
+	}
+	rows, errQuery := database.conn.Query(ctx, query, args...)
+	if errQuery != nil {
+		return nil, Err(errQuery)
+	}
+	defer rows.Close()
+	attempts := []model.BanJoinAttempt{}
+	for rows.Next() {
+		var (
+			attempt model.BanJoinAttempt
+			steamId int64
+		)
+		if errScan := rows.Scan(&attempt.BanJoinAttemptId, &attempt.Type, &attempt.BanId, &steamId,
+			&attempt.ServerId, &attempt.ServerName, &attempt.IPAddr, &attempt.CreatedOn); errScan != nil {
+			return nil, Err(errScan)
+		}
+		attempt.SteamId = steamid.SID64(steamId)
+		attempts = append(attempts, attempt)
+	}
+	return attempts, nil
+}
+
+// GetBanJoinAttemptCount returns the total number of times a ban has rejected a join attempt
+func (database *pgStore) GetBanJoinAttemptCount(ctx context.Context, attemptType model.BanKind, banId int64) (int, error) {
+	const query = `SELECT count(*) FROM ban_join_attempt WHERE ban_type = $1 AND ban_id = $2`
+	var count int
+	if errQuery := database.QueryRow(ctx, query, attemptType, banId).Scan(&count); errQuery != nil {
+		return 0, Err(errQuery)
+	}
+	return count, nil
+}
+
+// SaveBanAppealState updates an existing ban and records the appeal state change which caused the update
+// in the same transaction, so the audit trail cannot diverge from the ban itself
+func (database *pgStore) SaveBanAppealState(ctx context.Context, ban *model.BanSteam, change *model.AppealStateChange) error {
+	if ban.BanID <= 0 {
+		return errors.New("Cannot change the appeal state of an unsaved ban")
+	}
+	ban.UpdatedOn = config.Now()
+	tx, errBeginTx := database.conn.Begin(ctx)
+	if errBeginTx != nil {
+		return Err(errBeginTx)
+	}
+	if _, errUpdate := tx.Exec(ctx, updateBanQuery, updateBanArgs(ban)...); errUpdate != nil {
+		_ = tx.Rollback(ctx)
+		return Err(errUpdate)
+	}
+	if errInsert := tx.QueryRow(ctx, `
+		INSERT INTO ban_appeal_state (ban_id, source_id, from_state, to_state, note, valid_until_before, 
+		                              valid_until_after, created_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING appeal_state_change_id`,
+		change.BanId, change.SourceId.Int64(), change.FromState, change.ToState, change.Note,
+		change.ValidUntilBefore, change.ValidUntilAfter, change.CreatedOn).Scan(&change.AppealStateChangeId); errInsert != nil {
+		_ = tx.Rollback(ctx)
+		return Err(errInsert)
+	}
+	if errCommit := tx.Commit(ctx); errCommit != nil {
+		return Err(errCommit)
+	}
+	return nil
+}
+
+// GetAppealStateChanges returns the appeal audit trail for a ban, oldest first
+func (database *pgStore) GetAppealStateChanges(ctx context.Context, banId int64) ([]model.AppealStateChange, error) {
+	const query = `
+		SELECT appeal_state_change_id, ban_id, source_id, from_state, to_state, note, valid_until_before, 
+		       valid_until_after, created_on
+		FROM ban_appeal_state
+		WHERE ban_id = $1
+		ORDER BY created_on`
+	rows, errQuery := database.conn.Query(ctx, query, banId)
+	if errQuery != nil {
+		return nil, Err(errQuery)
+	}
+	defer rows.Close()
+	changes := []model.AppealStateChange{}
+	for rows.Next() {
+		var (
+			change   model.AppealStateChange
+			sourceId int64
+		)
+		if errScan := rows.Scan(&change.AppealStateChangeId, &change.BanId, &sourceId, &change.FromState,
+			&change.ToState, &change.Note, &change.ValidUntilBefore, &change.ValidUntilAfter,
+			&change.CreatedOn); errScan != nil {
+			return nil, Err(errScan)
+		}
+		change.SourceId = steamid.SID64(sourceId)
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// SaveBanAuditEntry records a ban lifecycle event. Entries are never updated
+func (database *pgStore) SaveBanAuditEntry(ctx context.Context, entry *model.BanAuditEntry) error {
+	const query = `
+		INSERT INTO ban_audit (kind, ban_id, target, action, note, created_on) 
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING ban_audit_id`
+	return Err(database.QueryRow(ctx, query, entry.Kind, entry.BanId, entry.Target, entry.Action, entry.Note,
+		entry.CreatedOn).Scan(&entry.BanAuditId))
+}
+
+// GetBanAuditEntries returns the lifecycle events for a ban, oldest first
+func (database *pgStore) GetBanAuditEntries(ctx context.Context, kind model.BanKind, banId int64) ([]model.BanAuditEntry, error) {
+	const query = `
+		SELECT ban_audit_id, kind, ban_id, target, action, note, created_on
+		FROM ban_audit
+		WHERE kind = $1 AND ban_id = $2
+		ORDER BY created_on, ban_audit_id`
+	rows, errQuery := database.conn.Query(ctx, query, kind, banId)
+	if errQuery != nil {
+		return nil, Err(errQuery)
+	}
+	defer rows.Close()
+	entries := []model.BanAuditEntry{}
+	for rows.Next() {
+		var entry model.BanAuditEntry
+		if errScan := rows.Scan(&entry.BanAuditId, &entry.Kind, &entry.BanId, &entry.Target, &entry.Action,
+			&entry.Note, &entry.CreatedOn); errScan != nil {
+			return nil, Err(errScan)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// BanSubjectFilter describes the subject of a ban search. Bans affecting any of the steam ids, addresses
+// or steam groups are matched. Deleted controls if historical bans are included.
+type BanSubjectFilter struct {
+	SteamIds  steamid.Collection
+	Addresses []net.IP
+	GroupIds  []steamid.GID
+	Deleted   bool
+}
+
+// GetBansBySubject returns every ban, of any kind, which affects the subject. Steam bans match the steam ids,
+// CIDR bans match blocks containing the addresses, ASN bans match the networks the addresses belong to
+// and group bans match the group ids. Each ban is only returned once, newest first.
+func (database *pgStore) GetBansBySubject(ctx context.Context, filter BanSubjectFilter) ([]model.BanMatch, error) {
+	const query = `
+		SELECT kind, ban_id, target, matched_on, source_id, target_id, ban_type, reason, reason_text, note, 
+		       origin, deleted, is_enabled, valid_until, created_on, updated_on, appeal_state
+		FROM (
+			SELECT DISTINCT ON (kind, ban_id) * 
+			FROM (
+				SELECT 'steam' as kind, b.ban_id, b.target_id::text as target, b.target_id::text as matched_on, 
+				       b.source_id, b.target_id, b.ban_type, b.reason, b.reason_text, b.note, b.origin, b.deleted, 
+				       b.is_enabled, b.valid_until, b.created_on, b.updated_on, b.appeal_state
+				FROM ban b
+				WHERE b.target_id = ANY($1::bigint[]) AND ($4::bool OR b.deleted = false)
+				UNION ALL
+				SELECT 'cidr', b.net_id, b.cidr::text, host(a.addr), b.source_id, b.target_id, $5::int, b.reason, 
+				       b.reason_text, b.note, b.origin, b.deleted, b.is_enabled, b.valid_until, b.created_on, 
+				       b.updated_on, b.appeal_state
+				FROM ban_net b
+				JOIN unnest($2::inet[]) a(addr) ON a.addr <<= b.cidr
+				WHERE $4::bool OR b.deleted = false
+				UNION ALL
+				SELECT 'asn', b.ban_asn_id, b.as_num::text, concat(host(a.addr), ' (', n.as_name, ')'), b.source_id, 
+				       b.target_id, $5::int, b.reason, b.reason_text, b.note, b.origin, b.deleted, b.is_enabled, 
+				       b.valid_until, b.created_on, b.updated_on, b.appeal_state
+				FROM ban_asn b
+				JOIN net_asn n ON n.as_num = b.as_num
+				JOIN unnest($2::inet[]) a(addr) ON a.addr <@ n.ip_range
+				WHERE $4::bool OR b.deleted = false
+				UNION ALL
+				SELECT 'group', b.ban_group_id, b.group_id::text, b.group_id::text, b.source_id, b.target_id, $5::int, 
+				       0, '', b.note, b.origin, b.deleted, b.is_enabled, b.valid_until, b.created_on, b.updated_on, 
+				       b.appeal_state
+				FROM ban_group b
+				WHERE b.group_id = ANY($3::bigint[]) AND ($4::bool OR b.deleted = false)
+			) matches
+			ORDER BY kind, ban_id
+		) bans
+		ORDER BY created_on DESC`
+	var steamIds, groupIds []int64
+	for _, sid64 := range filter.SteamIds {
+		steamIds = append(steamIds, sid64.Int64())
+	}
+	for _, gid := range filter.GroupIds {
+		groupIds = append(groupIds, gid.Int64())
+	}
+	addresses := filter.Addresses
+	if addresses == nil {
+		addresses = []net.IP{}
+	}
+	if steamIds == nil {
+		steamIds = []int64{}
+	}
+	if groupIds == nil {
+		groupIds = []int64{}
+	}
+	rows, errQuery := database.conn.Query(ctx, query, steamIds, addresses, groupIds, filter.Deleted, model.Banned)
+	if errQuery != nil {
+		return nil, Err(errQuery)
+	}
+	defer rows.Close()
+	now := config.Now()
+	matches := []model.BanMatch{}
+	for rows.Next() {
+		var (
+			match    model.BanMatch
+			sourceId int64
+			targetId int64
+		)
+		if errScan := rows.Scan(&match.Kind, &match.BanId, &match.Target, &match.MatchedOn, &sourceId, &targetId,
+			&match.BanType, &match.Reason, &match.ReasonText, &match.Note, &match.Origin, &match.Deleted,
+			&match.IsEnabled, &match.ValidUntil, &match.CreatedOn, &match.UpdatedOn,
+			&match.AppealState); errScan != nil {
+			return nil, Err(errScan)
+		}
+		match.SourceId = steamid.SID64(sourceId)
+		match.TargetId = steamid.SID64(targetId)
+		match.Active = !match.Deleted && match.IsEnabled && match.ValidUntil.After(now)
+		matches = append(matches, match)
+	}
+	return matches, nil
+}
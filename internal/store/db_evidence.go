@@ -0,0 +1,125 @@
+package store
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/leighmacdonald/gbans/internal/model"
+	log "github.com/sirupsen/logrus"
+)
+
+const evidenceColumns = `evidence_id, parent_kind, parent_id, message_id, author_id, kind, coalesce(media_id, 0),
+	coalesce(person_message_id, 0), coalesce(demo_id, 0), tick_start, tick_end, coalesce(match_id, 0), note,
+	deleted, created_on, updated_on`
+
+func (database *pgStore) SaveEvidence(ctx context.Context, evidence *model.Evidence) error {
+	if evidence.EvidenceId > 0 {
+		return database.updateEvidence(ctx, evidence)
+	}
+	return database.insertEvidence(ctx, evidence)
+}
+
+func (database *pgStore) insertEvidence(ctx context.Context, evidence *model.Evidence) error {
+	// The subject references are nullable foreign keys, so unset ids are stored as NULL
+	const query = `
+		INSERT INTO evidence (
+			parent_kind, parent_id, message_id, author_id, kind, media_id, person_message_id, demo_id,
+			tick_start, tick_end, match_id, note, deleted, created_on, updated_on
+		)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6::int, 0), NULLIF($7::bigint, 0), NULLIF($8::int, 0), $9, $10, NULLIF($11::int, 0),
+		        $12, $13, $14, $15)
+		RETURNING evidence_id`
+	if errQuery := database.conn.QueryRow(ctx, query,
+		evidence.ParentKind,
+		evidence.ParentId,
+		evidence.MessageId,
+		evidence.AuthorId,
+		evidence.Kind,
+		evidence.MediaId,
+		evidence.PersonMessageId,
+		evidence.DemoId,
+		evidence.TickStart,
+		evidence.TickEnd,
+		evidence.MatchId,
+		evidence.Note,
+		evidence.Deleted,
+		evidence.CreatedOn,
+		evidence.UpdatedOn,
+	).Scan(&evidence.EvidenceId); errQuery != nil {
+		return Err(errQuery)
+	}
+	log.WithFields(log.Fields{
+		"evidence_id": evidence.EvidenceId,
+		"parent_kind": evidence.ParentKind,
+		"parent_id":   evidence.ParentId,
+		"kind":        evidence.Kind,
+	}).Debugf("Evidence saved")
+	return nil
+}
+
+func (database *pgStore) updateEvidence(ctx context.Context, evidence *model.Evidence) error {
+	evidence.UpdatedOn = config.Now()
+	const query = `
+		UPDATE evidence
+		SET message_id = $2, tick_start = $3, tick_end = $4, note = $5, deleted = $6, updated_on = $7
+		WHERE evidence_id = $1`
+	return Err(database.Exec(ctx, query, evidence.EvidenceId, evidence.MessageId, evidence.TickStart,
+		evidence.TickEnd, evidence.Note, evidence.Deleted, evidence.UpdatedOn))
+}
+
+func (database *pgStore) DropEvidence(ctx context.Context, evidence *model.Evidence) error {
+	const query = `UPDATE evidence SET deleted = true WHERE evidence_id = $1`
+	if errExec := database.Exec(ctx, query, evidence.EvidenceId); errExec != nil {
+		return Err(errExec)
+	}
+	log.WithFields(log.Fields{"evidence_id": evidence.EvidenceId, "soft": true}).Infof("Evidence deleted")
+	evidence.Deleted = true
+	return nil
+}
+
+func scanEvidence(row pgx.Row, evidence *model.Evidence) error {
+	return row.Scan(
+		&evidence.EvidenceId,
+		&evidence.ParentKind,
+		&evidence.ParentId,
+		&evidence.MessageId,
+		&evidence.AuthorId,
+		&evidence.Kind,
+		&evidence.MediaId,
+		&evidence.PersonMessageId,
+		&evidence.DemoId,
+		&evidence.TickStart,
+		&evidence.TickEnd,
+		&evidence.MatchId,
+		&evidence.Note,
+		&evidence.Deleted,
+		&evidence.CreatedOn,
+		&evidence.UpdatedOn,
+	)
+}
+
+func (database *pgStore) GetEvidence(ctx context.Context, evidenceId int64, evidence *model.Evidence) error {
+	query := `SELECT ` + evidenceColumns + ` FROM evidence WHERE deleted = false AND evidence_id = $1`
+	return Err(scanEvidence(database.QueryRow(ctx, query, evidenceId), evidence))
+}
+
+// GetEvidenceByParent returns all the evidence attached to a report or ban thread, oldest first
+func (database *pgStore) GetEvidenceByParent(ctx context.Context, parentKind model.EvidenceParent, parentId int64) ([]model.Evidence, error) {
+	query := `SELECT ` + evidenceColumns + ` FROM evidence
+		WHERE deleted = false AND parent_kind = $1 AND parent_id = $2
+		ORDER BY created_on`
+	rows, errQuery := database.Query(ctx, query, parentKind, parentId)
+	if errQuery != nil {
+		return nil, Err(errQuery)
+	}
+	defer rows.Close()
+	evidence := []model.Evidence{}
+	for rows.Next() {
+		var entry model.Evidence
+		if errScan := scanEvidence(rows, &entry); errScan != nil {
+			return nil, Err(errScan)
+		}
+		evidence = append(evidence, entry)
+	}
+	return evidence, nil
+}
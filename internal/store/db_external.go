@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"github.com/leighmacdonald/gbans/internal/model"
+)
+
+// GetExternalBanEntries returns all the currently synced entries of an external ban list
+func (database *pgStore) GetExternalBanEntries(ctx context.Context, source string) ([]model.ExternalBanEntry, error) {
+	const query = `
+		SELECT source, kind, entry, ban_id, created_on
+		FROM external_ban_entry
+		WHERE source = $1`
+	rows, errQuery := database.conn.Query(ctx, query, source)
+	if errQuery != nil {
+		return nil, Err(errQuery)
+	}
+	defer rows.Close()
+	entries := []model.ExternalBanEntry{}
+	for rows.Next() {
+		var entry model.ExternalBanEntry
+		if errScan := rows.Scan(&entry.Source, &entry.Kind, &entry.Entry, &entry.BanId, &entry.CreatedOn); errScan != nil {
+			return nil, Err(errScan)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func (database *pgStore) SaveExternalBanEntry(ctx context.Context, entry *model.ExternalBanEntry) error {
+	const query = `
+		INSERT INTO external_ban_entry (source, kind, entry, ban_id, created_on) 
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (source, kind, entry) DO UPDATE SET ban_id = $4`
+	return Err(database.Exec(ctx, query, entry.Source, entry.Kind, entry.Entry, entry.BanId, entry.CreatedOn))
+}
+
+func (database *pgStore) DropExternalBanEntry(ctx context.Context, entry *model.ExternalBanEntry) error {
+	const query = `DELETE FROM external_ban_entry WHERE source = $1 AND kind = $2 AND entry = $3`
+	return Err(database.Exec(ctx, query, entry.Source, entry.Kind, entry.Entry))
+}
+
+// GetExternalBanSources returns the number of synced entries of each external ban list
+func (database *pgStore) GetExternalBanSources(ctx context.Context) ([]model.ExternalBanSource, error) {
+	const query = `
+		SELECT source, count(*) FILTER (WHERE kind = $1), count(*) FILTER (WHERE kind = $2), max(created_on)
+		FROM external_ban_entry
+		GROUP BY source
+		ORDER BY source`
+	rows, errQuery := database.conn.Query(ctx, query, model.BanKindSteam, model.BanKindCIDR)
+	if errQuery != nil {
+		return nil, Err(errQuery)
+	}
+	defer rows.Close()
+	sources := []model.ExternalBanSource{}
+	for rows.Next() {
+		var source model.ExternalBanSource
+		if errScan := rows.Scan(&source.Source, &source.Steam, &source.CIDR, &source.UpdatedOn); errScan != nil {
+			return nil, Err(errScan)
+		}
+		sources = append(sources, source)
+	}
+	return sources, nil
+}
@@ -17,11 +17,13 @@ func (database *pgStore) SaveFilter(ctx context.Context, filter *model.Filter) e
 
 func (database *pgStore) insertFilter(ctx context.Context, filter *model.Filter) error {
 	const query = `
-		INSERT INTO filtered_word (word, filter_name, created_on, discord_created_on, discord_id) 
-		VALUES ($1, $2, $3, $4, $5) 
+		INSERT INTO filtered_word (word, filter_name, created_on, discord_created_on, discord_id, action, weight,
+		                           ban_reason, duration, ping_discord) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) 
 		RETURNING word_id`
 	if errQuery := database.QueryRow(ctx, query, filter.Patterns.String(), filter.FilterName,
-		filter.CreatedOn, filter.DiscordCreatedOn, filter.DiscordId).Scan(&filter.WordID); errQuery != nil {
+		filter.CreatedOn, filter.DiscordCreatedOn, filter.DiscordId, filter.Action, filter.Weight, filter.BanReason,
+		filter.Duration, filter.PingDiscord).Scan(&filter.WordID); errQuery != nil {
 		return Err(errQuery)
 	}
 	log.Debugf("Created filter: %d", filter.WordID)
@@ -30,10 +32,12 @@ func (database *pgStore) insertFilter(ctx context.Context, filter *model.Filter)
 
 func (database *pgStore) updateFilter(ctx context.Context, filter *model.Filter) error {
 	const query = `
-		UPDATE filtered_word SET word = $2, created_on = $3, discord_id = $4, discord_created_on = $5, filter_name = $6
+		UPDATE filtered_word SET word = $2, created_on = $3, discord_id = $4, discord_created_on = $5, filter_name = $6,
+			action = $7, weight = $8, ban_reason = $9, duration = $10, ping_discord = $11
     	WHERE word_id = $1`
 	if errQuery := database.Exec(ctx, query, filter.WordID, filter.Patterns.String(),
-		filter.CreatedOn, filter.DiscordId, filter.DiscordCreatedOn, filter.FilterName); errQuery != nil {
+		filter.CreatedOn, filter.DiscordId, filter.DiscordCreatedOn, filter.FilterName, filter.Action, filter.Weight,
+		filter.BanReason, filter.Duration, filter.PingDiscord); errQuery != nil {
 		return Err(errQuery)
 	}
 	log.Debugf("Created filter: %d", filter.WordID)
@@ -49,12 +53,14 @@ func (database *pgStore) DropFilter(ctx context.Context, filter *model.Filter) e
 }
 
 func (database *pgStore) GetFilterByID(ctx context.Context, wordId int64, f *model.Filter) error {
-	const query = `SELECT word_id, word, created_on,discord_id, discord_created_on, filter_name 
+	const query = `SELECT word_id, word, created_on, discord_id, discord_created_on, filter_name, action, weight,
+		       ban_reason, duration, ping_discord
 		FROM filtered_word 
 		WHERE word_id = $1`
 	var word string
 	if errQuery := database.QueryRow(ctx, query, wordId).Scan(&f.WordID, &word, &f.CreatedOn,
-		&f.DiscordId, &f.DiscordCreatedOn, &f.FilterName); errQuery != nil {
+		&f.DiscordId, &f.DiscordCreatedOn, &f.FilterName, &f.Action, &f.Weight, &f.BanReason, &f.Duration,
+		&f.PingDiscord); errQuery != nil {
 		return errors.Wrapf(errQuery, "Failed to load filter")
 	}
 	f.Patterns = model.WordFiltersFromString(word)
@@ -63,12 +69,14 @@ func (database *pgStore) GetFilterByID(ctx context.Context, wordId int64, f *mod
 
 func (database *pgStore) GetFilterByName(ctx context.Context, filterName string, f *model.Filter) error {
 	const query = `
-		SELECT word_id, word, created_on,discord_id, discord_created_on, filter_name 
+		SELECT word_id, word, created_on, discord_id, discord_created_on, filter_name, action, weight,
+		       ban_reason, duration, ping_discord
 		FROM filtered_word 
 		WHERE filter_name = $1`
 	var word string
 	if errQuery := database.QueryRow(ctx, query, filterName).Scan(&f.WordID, &word, &f.CreatedOn,
-		&f.DiscordId, &f.DiscordCreatedOn, &f.FilterName); errQuery != nil {
+		&f.DiscordId, &f.DiscordCreatedOn, &f.FilterName, &f.Action, &f.Weight, &f.BanReason, &f.Duration,
+		&f.PingDiscord); errQuery != nil {
 		return errors.Wrapf(errQuery, "Failed to load filter")
 	}
 	f.Patterns = model.WordFiltersFromString(word)
@@ -77,7 +85,8 @@ func (database *pgStore) GetFilterByName(ctx context.Context, filterName string,
 
 func (database *pgStore) GetFilters(ctx context.Context) ([]model.Filter, error) {
 	const query = `
-		SELECT word_id, word, created_on, discord_id, discord_created_on, filter_name
+		SELECT word_id, word, created_on, discord_id, discord_created_on, filter_name, action, weight,
+		       ban_reason, duration, ping_discord
 		FROM filtered_word`
 	rows, errQuery := database.Query(ctx, query)
 	if errQuery != nil {
@@ -89,7 +98,8 @@ func (database *pgStore) GetFilters(ctx context.Context) ([]model.Filter, error)
 		var filter model.Filter
 		var pattern string
 		if errQuery = rows.Scan(&filter.WordID, &pattern, &filter.CreatedOn, &filter.DiscordId,
-			&filter.DiscordCreatedOn, &filter.FilterName); errQuery != nil {
+			&filter.DiscordCreatedOn, &filter.FilterName, &filter.Action, &filter.Weight, &filter.BanReason,
+			&filter.Duration, &filter.PingDiscord); errQuery != nil {
 			return nil, errors.Wrapf(errQuery, "Failed to load filter")
 		}
 		filter.Patterns = model.WordFiltersFromString(pattern)
@@ -146,7 +146,7 @@ func (database *pgStore) GetExpiredNetBans(ctx context.Context) ([]model.BanCIDR
 		SELECT net_id, cidr, origin, created_on, updated_on, reason_text, valid_until, deleted, note, 
 		       unban_reason_text, is_enabled, target_id, source_id, reason, appeal_state
 		FROM ban_net
-		WHERE valid_until < $1`
+		WHERE valid_until < $1 AND deleted = false`
 	var bans []model.BanCIDR
 	rows, errQuery := database.Query(ctx, query, config.Now())
 	if errQuery != nil {
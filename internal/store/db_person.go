@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v4"
 	"github.com/leighmacdonald/gbans/internal/config"
 	"github.com/leighmacdonald/gbans/internal/consts"
 	"github.com/leighmacdonald/gbans/internal/model"
@@ -415,6 +418,236 @@ func (database *pgStore) QueryChatHistory(ctx context.Context, query ChatHistory
 	return messages, nil
 }
 
+// ChatSearchMode defines how the ChatSearchQueryFilter query is matched against messages
+type ChatSearchMode string
+
+const (
+	// ChatSearchContains matches messages containing the query, case-insensitively
+	ChatSearchContains ChatSearchMode = "contains"
+	// ChatSearchText is a full text search supporting the web search syntax: "quoted phrases", or, -excluded
+	ChatSearchText ChatSearchMode = "text"
+	// ChatSearchPhrase matches messages containing the words of the query in order
+	ChatSearchPhrase ChatSearchMode = "phrase"
+	// ChatSearchRegex matches messages against a case-insensitive POSIX regular expression
+	ChatSearchRegex ChatSearchMode = "regex"
+)
+
+// maxChatSearchContext is the maximum number of messages that can be requested before and after each hit
+const maxChatSearchContext = 25
+
+var (
+	ErrChatSearchMode  = errors.New("Invalid chat search mode")
+	ErrChatSearchRegex = errors.New("Invalid regular expression")
+)
+
+type ChatSearchQueryFilter struct {
+	QueryFilter
+	Mode       ChatSearchMode     `json:"mode"`
+	SteamIds   steamid.Collection `json:"-"`
+	ServerId   int                `json:"server_id,omitempty"`
+	TeamOnly   bool               `json:"team_only,omitempty"`
+	SentAfter  *time.Time         `json:"sent_after,omitempty"`
+	SentBefore *time.Time         `json:"sent_before,omitempty"`
+	// Context is the number of messages sent on the same server before and after each hit to include
+	Context int `json:"context,omitempty"`
+}
+
+const personMessageColumns = `m.person_message_id, m.steam_id, m.server_id, m.body, m.team, m.created_on,
+	m.persona_name, coalesce(s.short_name, '')`
+
+func scanPersonMessages(rows pgx.Rows) (model.PersonMessages, error) {
+	defer rows.Close()
+	messages := model.PersonMessages{}
+	for rows.Next() {
+		var message model.PersonMessage
+		if errScan := rows.Scan(
+			&message.PersonMessageId,
+			&message.SteamId,
+			&message.ServerId,
+			&message.Body,
+			&message.Team,
+			&message.CreatedOn,
+			&message.PersonaName,
+			&message.ServerName,
+		); errScan != nil {
+			return nil, Err(errScan)
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// SearchChat finds the messages matching the query, newest first, along with the requested number of
+// context messages surrounding each of them
+func (database *pgStore) SearchChat(ctx context.Context, query ChatSearchQueryFilter) ([]model.ChatSearchHit, error) {
+	qb := sb.Select(personMessageColumns).
+		From("person_messages m").
+		LeftJoin("server s on m.server_id = s.server_id").
+		OrderBy("m.created_on DESC", "m.person_message_id DESC")
+	if query.Query != "" {
+		switch query.Mode {
+		case ChatSearchContains, "":
+			qb = qb.Where(sq.ILike{"m.body": "%" + escapeLike(query.Query) + "%"})
+		case ChatSearchText:
+			qb = qb.Where("to_tsvector('simple', m.body) @@ websearch_to_tsquery('simple', ?)", query.Query)
+		case ChatSearchPhrase:
+			qb = qb.Where("to_tsvector('simple', m.body) @@ phraseto_tsquery('simple', ?)", query.Query)
+		case ChatSearchRegex:
+			qb = qb.Where("m.body ~* ?", query.Query)
+		default:
+			return nil, ErrChatSearchMode
+		}
+	}
+	if len(query.SteamIds) > 0 {
+		var steamIds []int64
+		for _, steamId := range query.SteamIds {
+			steamIds = append(steamIds, steamId.Int64())
+		}
+		qb = qb.Where(sq.Eq{"m.steam_id": steamIds})
+	}
+	if query.ServerId > 0 {
+		qb = qb.Where(sq.Eq{"m.server_id": query.ServerId})
+	}
+	if query.TeamOnly {
+		qb = qb.Where(sq.Eq{"m.team": true})
+	}
+	if query.SentAfter != nil {
+		qb = qb.Where(sq.Gt{"m.created_on": query.SentAfter})
+	}
+	if query.SentBefore != nil {
+		qb = qb.Where(sq.Lt{"m.created_on": query.SentBefore})
+	}
+	if query.Limit > 0 {
+		qb = qb.Limit(query.Limit)
+	}
+	if query.Offset > 0 {
+		qb = qb.Offset(query.Offset)
+	}
+	q, args, errQuery := qb.ToSql()
+	if errQuery != nil {
+		return nil, errors.Wrap(errQuery, "Failed to build query")
+	}
+	rows, errRows := database.Query(ctx, q, args...)
+	if errRows != nil {
+		return nil, chatSearchErr(errRows)
+	}
+	messages, errMessages := scanPersonMessages(rows)
+	if errMessages != nil {
+		return nil, chatSearchErr(errMessages)
+	}
+	// Postgres reports errors, such as an invalid pattern, when reading the first row
+	if errRead := rows.Err(); errRead != nil {
+		return nil, chatSearchErr(errRead)
+	}
+	hits := []model.ChatSearchHit{}
+	for _, message := range messages {
+		hits = append(hits, model.ChatSearchHit{Message: message, Before: model.PersonMessages{}, After: model.PersonMessages{}})
+	}
+	contextLines := query.Context
+	if contextLines > maxChatSearchContext {
+		contextLines = maxChatSearchContext
+	}
+	if contextLines > 0 && len(hits) > 0 {
+		if errContext := database.chatContext(ctx, hits, contextLines); errContext != nil {
+			return nil, errContext
+		}
+	}
+	return hits, nil
+}
+
+// chatSearchErr maps a rejected regex search pattern to ErrChatSearchRegex. Patterns are matched
+// by postgres using its own regular expression syntax, so they can only be validated by running the query.
+func chatSearchErr(rootError error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(rootError, &pgErr) && pgErr.Code == pgerrcode.InvalidRegularExpression {
+		return errors.Wrap(ErrChatSearchRegex, pgErr.Message)
+	}
+	return Err(rootError)
+}
+
+// chatContextQuery selects up to $4 messages sent on the same server directly before and after each hit.
+// The hits are passed in as parallel arrays of their id, server and creation time.
+const chatContextQuery = `
+	WITH hits AS (
+		SELECT * FROM unnest($1::bigint[], $2::int[], $3::timestamptz[]) AS h(hit_id, server_id, created_on)
+	)
+	SELECT h.hit_id, true, c.*
+	FROM hits h
+	CROSS JOIN LATERAL (
+		SELECT ` + personMessageColumns + `
+		FROM person_messages m
+		LEFT JOIN server s on m.server_id = s.server_id
+		WHERE m.server_id = h.server_id AND (m.created_on, m.person_message_id) < (h.created_on, h.hit_id)
+		ORDER BY m.created_on DESC, m.person_message_id DESC
+		LIMIT $4
+	) c
+	UNION ALL
+	SELECT h.hit_id, false, c.*
+	FROM hits h
+	CROSS JOIN LATERAL (
+		SELECT ` + personMessageColumns + `
+		FROM person_messages m
+		LEFT JOIN server s on m.server_id = s.server_id
+		WHERE m.server_id = h.server_id AND (m.created_on, m.person_message_id) > (h.created_on, h.hit_id)
+		ORDER BY m.created_on, m.person_message_id
+		LIMIT $4
+	) c
+	ORDER BY 1, 8, 3`
+
+// chatContext fills in the messages surrounding each hit, in chronological order, using a single query
+func (database *pgStore) chatContext(ctx context.Context, hits []model.ChatSearchHit, limit int) error {
+	var (
+		hitIds    = make([]int64, len(hits))
+		serverIds = make([]int, len(hits))
+		created   = make([]time.Time, len(hits))
+		hitIndex  = map[int64]int{}
+	)
+	for idx, hit := range hits {
+		hitIds[idx] = hit.Message.PersonMessageId
+		serverIds[idx] = hit.Message.ServerId
+		created[idx] = hit.Message.CreatedOn
+		hitIndex[hit.Message.PersonMessageId] = idx
+	}
+	rows, errRows := database.Query(ctx, chatContextQuery, hitIds, serverIds, created, limit)
+	if errRows != nil {
+		return Err(errRows)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			hitId   int64
+			before  bool
+			message model.PersonMessage
+		)
+		if errScan := rows.Scan(
+			&hitId,
+			&before,
+			&message.PersonMessageId,
+			&message.SteamId,
+			&message.ServerId,
+			&message.Body,
+			&message.Team,
+			&message.CreatedOn,
+			&message.PersonaName,
+			&message.ServerName,
+		); errScan != nil {
+			return Err(errScan)
+		}
+		hit := &hits[hitIndex[hitId]]
+		if before {
+			hit.Before = append(hit.Before, message)
+		} else {
+			hit.After = append(hit.After, message)
+		}
+	}
+	return Err(rows.Err())
+}
+
+// escapeLike escapes the LIKE pattern characters so the value is matched literally
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
 func (database *pgStore) GetPersonIPHistory(ctx context.Context, sid64 steamid.SID64, limit int) (model.PersonConnections, error) {
 	qb := sb.
 		Select(
@@ -467,6 +700,69 @@ func (database *pgStore) AddConnectionHistory(ctx context.Context, conn *model.P
 	return nil
 }
 
+// GetSteamIDsAtNetwork returns the distinct steam ids which have connected from within the network since the time given
+func (database *pgStore) GetSteamIDsAtNetwork(ctx context.Context, ipNet *net.IPNet, since time.Time) (steamid.Collection, error) {
+	const query = `
+		SELECT DISTINCT steam_id
+		FROM person_connections
+		WHERE ip_addr <<= $1::inet AND created_on >= $2`
+	if ipNet == nil {
+		return nil, errors.New("Invalid network")
+	}
+	rows, errQuery := database.conn.Query(ctx, query, ipNet.String(), since)
+	if errQuery != nil {
+		return nil, Err(errQuery)
+	}
+	defer rows.Close()
+	var ids steamid.Collection
+	for rows.Next() {
+		var sid64 int64
+		if errScan := rows.Scan(&sid64); errScan != nil {
+			return nil, Err(errScan)
+		}
+		ids = append(ids, steamid.SID64(sid64))
+	}
+	return ids, nil
+}
+
+// SavePersonLink inserts a new link between two accounts, or updates the last seen address of an existing link
+func (database *pgStore) SavePersonLink(ctx context.Context, link *model.PersonLink) error {
+	const query = `
+		INSERT INTO person_link (steam_id, linked_id, ip_addr, created_on, updated_on)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (steam_id, linked_id) DO UPDATE SET ip_addr = $3, updated_on = $5`
+	return Err(database.Exec(ctx, query, link.SteamId.Int64(), link.LinkedId.Int64(), link.IPAddr,
+		link.CreatedOn, link.UpdatedOn))
+}
+
+// GetPersonLinks returns all the links, in either direction, for the account
+func (database *pgStore) GetPersonLinks(ctx context.Context, sid64 steamid.SID64) ([]model.PersonLink, error) {
+	const query = `
+		SELECT steam_id, linked_id, ip_addr, created_on, updated_on
+		FROM person_link
+		WHERE steam_id = $1 OR linked_id = $1`
+	rows, errQuery := database.conn.Query(ctx, query, sid64.Int64())
+	if errQuery != nil {
+		return nil, Err(errQuery)
+	}
+	defer rows.Close()
+	var links []model.PersonLink
+	for rows.Next() {
+		var (
+			link     model.PersonLink
+			steamId  int64
+			linkedId int64
+		)
+		if errScan := rows.Scan(&steamId, &linkedId, &link.IPAddr, &link.CreatedOn, &link.UpdatedOn); errScan != nil {
+			return nil, Err(errScan)
+		}
+		link.SteamId = steamid.SID64(steamId)
+		link.LinkedId = steamid.SID64(linkedId)
+		links = append(links, link)
+	}
+	return links, nil
+}
+
 var personAuthColumns = []string{"person_auth_id", "steam_id", "ip_addr", "refresh_token", "created_on"}
 
 func (database *pgStore) GetPersonAuth(ctx context.Context, sid64 steamid.SID64, ipAddr net.IP, auth *model.PersonAuth) error {
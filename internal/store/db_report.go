@@ -7,13 +7,15 @@ import (
 	"github.com/leighmacdonald/gbans/internal/model"
 	"github.com/leighmacdonald/steamid/v2/steamid"
 	log "github.com/sirupsen/logrus"
+	"time"
 )
 
 func (database *pgStore) insertReport(ctx context.Context, report *model.Report) error {
 	const query = `INSERT INTO report (
-		    author_id, reported_id, report_status, description, deleted, created_on, updated_on, reason, reason_text
+		    author_id, reported_id, report_status, description, deleted, created_on, updated_on, reason, reason_text, demo_name,
+		    assignee_id, status_changed_on, escalated
 		)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 		RETURNING report_id`
 	if errQuery := database.conn.QueryRow(ctx, query,
 		report.AuthorId,
@@ -25,6 +27,10 @@ func (database *pgStore) insertReport(ctx context.Context, report *model.Report)
 		report.UpdatedOn,
 		report.Reason,
 		report.ReasonText,
+		report.DemoName,
+		report.AssigneeId,
+		report.StatusChangedOn,
+		report.Escalated,
 	).Scan(&report.ReportId); errQuery != nil {
 		return Err(errQuery)
 	}
@@ -40,10 +46,12 @@ func (database *pgStore) updateReport(ctx context.Context, report *model.Report)
 	const q = `
 		UPDATE report 
 		SET author_id = $1, reported_id = $2, report_status = $3, description = $4,
-            deleted = $5, updated_on = $6, reason = $7, reason_text = $8
-        WHERE report_id = $9`
+            deleted = $5, updated_on = $6, reason = $7, reason_text = $8, demo_name = $9,
+            assignee_id = $10, status_changed_on = $11, escalated = $12
+        WHERE report_id = $13`
 	return Err(database.Exec(ctx, q, report.AuthorId, report.ReportedId, report.ReportStatus, report.Description,
-		report.Deleted, report.UpdatedOn, report.Reason, report.ReasonText, report.ReportId))
+		report.Deleted, report.UpdatedOn, report.Reason, report.ReasonText, report.DemoName, report.AssigneeId, report.StatusChangedOn,
+		report.Escalated, report.ReportId))
 }
 
 func (database *pgStore) SaveReport(ctx context.Context, report *model.Report) error {
@@ -143,18 +151,40 @@ type AuthorQueryFilter struct {
 
 type ReportQueryFilter struct {
 	AuthorQueryFilter
+	// ReportStatus filters by status unless set to model.AnyStatus
 	ReportStatus model.ReportStatus `json:"report_status"`
+	AssigneeId   steamid.SID64      `json:"assignee_id,string"`
+	Unassigned   bool               `json:"unassigned"`
+	// StatusChangedBefore limits results to reports which have been in their current status since before the time
+	StatusChangedBefore *time.Time `json:"status_changed_before,omitempty"`
 }
 
-func (database *pgStore) GetReports(ctx context.Context, opts AuthorQueryFilter) ([]model.Report, error) {
+// NewReportQueryFilter returns a filter matching reports of any status
+func NewReportQueryFilter() ReportQueryFilter {
+	return ReportQueryFilter{ReportStatus: model.AnyStatus}
+}
+
+func (database *pgStore) GetReports(ctx context.Context, opts ReportQueryFilter) ([]model.Report, error) {
 	var conditions sq.And
 	conditions = append(conditions, sq.Eq{"deleted": opts.Deleted})
 	if opts.AuthorId > 0 {
 		conditions = append(conditions, sq.Eq{"author_id": opts.AuthorId})
 	}
+	if opts.ReportStatus != model.AnyStatus {
+		conditions = append(conditions, sq.Eq{"report_status": opts.ReportStatus})
+	}
+	if opts.Unassigned {
+		conditions = append(conditions, sq.Eq{"assignee_id": 0})
+	} else if opts.AssigneeId > 0 {
+		conditions = append(conditions, sq.Eq{"assignee_id": opts.AssigneeId})
+	}
+	if opts.StatusChangedBefore != nil {
+		conditions = append(conditions, sq.Lt{"status_changed_on": opts.StatusChangedBefore})
+	}
 	builder := sb.
 		Select("report_id", "author_id", "reported_id", "report_status",
-			"description", "deleted", "created_on", "updated_on", "reason", "reason_text").
+			"description", "deleted", "created_on", "updated_on", "reason", "reason_text", "demo_name",
+			"assignee_id", "status_changed_on", "escalated").
 		From("report").
 		Where(conditions)
 	if opts.Limit > 0 {
@@ -190,6 +220,10 @@ func (database *pgStore) GetReports(ctx context.Context, opts AuthorQueryFilter)
 			&report.UpdatedOn,
 			&report.Reason,
 			&report.ReasonText,
+			&report.DemoName,
+			&report.AssigneeId,
+			&report.StatusChangedOn,
+			&report.Escalated,
 		); errScan != nil {
 			return nil, Err(errScan)
 		}
@@ -203,7 +237,8 @@ func (database *pgStore) GetReportBySteamId(ctx context.Context, authorId steami
 	const query = `
 		SELECT 
 		   report_id, author_id, reported_id, report_status, description, 
-		   deleted, created_on, updated_on, reason, reason_text
+		   deleted, created_on, updated_on, reason, reason_text, demo_name,
+		   assignee_id, status_changed_on, escalated
 		FROM report
 		WHERE deleted = false AND reported_id = $1 AND report_status <= $2 AND author_id = $3`
 	if errQuery := database.conn.QueryRow(ctx, query, steamId, model.NeedMoreInfo, authorId).Scan(
@@ -217,6 +252,10 @@ func (database *pgStore) GetReportBySteamId(ctx context.Context, authorId steami
 		&report.UpdatedOn,
 		&report.Reason,
 		&report.ReasonText,
+		&report.DemoName,
+		&report.AssigneeId,
+		&report.StatusChangedOn,
+		&report.Escalated,
 	); errQuery != nil {
 		return Err(errQuery)
 	}
@@ -226,7 +265,8 @@ func (database *pgStore) GetReport(ctx context.Context, reportId int64, report *
 	const query = `
 		SELECT 
 		   report_id, author_id, reported_id, report_status, description, 
-		   deleted, created_on, updated_on, reason, reason_text
+		   deleted, created_on, updated_on, reason, reason_text, demo_name,
+		   assignee_id, status_changed_on, escalated
 		FROM report
 		WHERE deleted = false AND report_id = $1`
 	if errQuery := database.conn.QueryRow(ctx, query, reportId).Scan(
@@ -240,6 +280,10 @@ func (database *pgStore) GetReport(ctx context.Context, reportId int64, report *
 		&report.UpdatedOn,
 		&report.Reason,
 		&report.ReasonText,
+		&report.DemoName,
+		&report.AssigneeId,
+		&report.StatusChangedOn,
+		&report.Escalated,
 	); errQuery != nil {
 		return Err(errQuery)
 	}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/leighmacdonald/gbans/internal/model"
+	"time"
+)
+
+const scheduledTaskColumns = `
+	scheduled_task_id, name, schedule, kind, command, coalesce(server_id, 0), region, only_when_empty,
+	only_when_outdated, is_enabled, created_on, updated_on`
+
+// nullServerId stores tasks targeting a region with a null server
+func nullServerId(serverId int) *int {
+	if serverId <= 0 {
+		return nil
+	}
+	return &serverId
+}
+
+func (database *pgStore) GetScheduledTask(ctx context.Context, scheduledTaskId int, task *model.ScheduledTask) error {
+	const query = `SELECT ` + scheduledTaskColumns + ` FROM scheduled_task WHERE scheduled_task_id = $1`
+	return scanScheduledTask(database.QueryRow(ctx, query, scheduledTaskId), task)
+}
+
+func (database *pgStore) GetScheduledTasks(ctx context.Context, includeDisabled bool) ([]model.ScheduledTask, error) {
+	query := `SELECT ` + scheduledTaskColumns + ` FROM scheduled_task`
+	if !includeDisabled {
+		query += ` WHERE is_enabled = true`
+	}
+	rows, errQuery := database.Query(ctx, query+` ORDER BY name`)
+	if errQuery != nil {
+		return nil, Err(errQuery)
+	}
+	defer rows.Close()
+	tasks := []model.ScheduledTask{}
+	for rows.Next() {
+		var task model.ScheduledTask
+		if errScan := scanScheduledTask(rows, &task); errScan != nil {
+			return nil, errScan
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+// SaveScheduledTask updates or creates the scheduled task
+func (database *pgStore) SaveScheduledTask(ctx context.Context, task *model.ScheduledTask) error {
+	task.UpdatedOn = config.Now()
+	if task.ScheduledTaskId > 0 {
+		const query = `
+			UPDATE scheduled_task
+			SET name = $2, schedule = $3, kind = $4, command = $5, server_id = $6, region = $7,
+			    only_when_empty = $8, only_when_outdated = $9, is_enabled = $10, updated_on = $11
+			WHERE scheduled_task_id = $1`
+		return Err(database.Exec(ctx, query, task.ScheduledTaskId, task.Name, task.Schedule, task.Kind, task.Command,
+			nullServerId(task.ServerId), task.Region, task.OnlyWhenEmpty, task.OnlyWhenOutdated, task.IsEnabled,
+			task.UpdatedOn))
+	}
+	const query = `
+		INSERT INTO scheduled_task (name, schedule, kind, command, server_id, region, only_when_empty,
+		    only_when_outdated, is_enabled, created_on, updated_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING scheduled_task_id`
+	return Err(database.QueryRow(ctx, query, task.Name, task.Schedule, task.Kind, task.Command,
+		nullServerId(task.ServerId), task.Region, task.OnlyWhenEmpty, task.OnlyWhenOutdated, task.IsEnabled,
+		task.CreatedOn, task.UpdatedOn).Scan(&task.ScheduledTaskId))
+}
+
+// DropScheduledTask removes the task along with its recorded runs
+func (database *pgStore) DropScheduledTask(ctx context.Context, scheduledTaskId int) error {
+	const query = `DELETE FROM scheduled_task WHERE scheduled_task_id = $1`
+	return Err(database.Exec(ctx, query, scheduledTaskId))
+}
+
+func (database *pgStore) AddScheduledTaskRun(ctx context.Context, run *model.ScheduledTaskRun) error {
+	const query = `
+		INSERT INTO scheduled_task_run (scheduled_task_id, server_id, status, output, created_on)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING scheduled_task_run_id`
+	return Err(database.QueryRow(ctx, query, run.ScheduledTaskId, run.ServerId, run.Status, run.Output,
+		run.CreatedOn).Scan(&run.ScheduledTaskRunId))
+}
+
+// ScheduledTaskRunQueryFilter limits the runs returned. Zero values are not used as filters.
+type ScheduledTaskRunQueryFilter struct {
+	ScheduledTaskId int                          `json:"scheduled_task_id"`
+	Status          model.ScheduledTaskRunStatus `json:"status"`
+	Limit           uint64                       `json:"limit"`
+}
+
+// GetScheduledTaskRuns returns the matching runs, newest first
+func (database *pgStore) GetScheduledTaskRuns(ctx context.Context, filter ScheduledTaskRunQueryFilter) ([]model.ScheduledTaskRun, error) {
+	builder := sb.Select("scheduled_task_run_id", "scheduled_task_id", "server_id", "status", "output", "created_on").
+		From("scheduled_task_run").
+		OrderBy("created_on DESC", "scheduled_task_run_id DESC")
+	if filter.ScheduledTaskId > 0 {
+		builder = builder.Where(sq.Eq{"scheduled_task_id": filter.ScheduledTaskId})
+	}
+	if filter.Status != "" {
+		builder = builder.Where(sq.Eq{"status": filter.Status})
+	}
+	if filter.Limit > 0 {
+		builder = builder.Limit(filter.Limit)
+	}
+	query, args, errQuery := builder.ToSql()
+	if errQuery != nil {
+		return nil, Err(errQuery)
+	}
+	rows, errRows := database.Query(ctx, query, args...)
+	if errRows != nil {
+		return nil, Err(errRows)
+	}
+	defer rows.Close()
+	runs := []model.ScheduledTaskRun{}
+	for rows.Next() {
+		var run model.ScheduledTaskRun
+		if errScan := rows.Scan(&run.ScheduledTaskRunId, &run.ScheduledTaskId, &run.ServerId, &run.Status,
+			&run.Output, &run.CreatedOn); errScan != nil {
+			return nil, Err(errScan)
+		}
+		runs = append(runs, run)
+	}
+	return runs, nil
+}
+
+// PruneScheduledTaskRuns deletes the runs recorded before the given time, returning the number deleted
+func (database *pgStore) PruneScheduledTaskRuns(ctx context.Context, before time.Time) (int64, error) {
+	const query = `DELETE FROM scheduled_task_run WHERE created_on < $1`
+	tag, errExec := database.conn.Exec(ctx, query, before)
+	if errExec != nil {
+		return 0, Err(errExec)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func scanScheduledTask(row pgx.Row, task *model.ScheduledTask) error {
+	return Err(row.Scan(&task.ScheduledTaskId, &task.Name, &task.Schedule, &task.Kind, &task.Command, &task.ServerId,
+		&task.Region, &task.OnlyWhenEmpty, &task.OnlyWhenOutdated, &task.IsEnabled, &task.CreatedOn, &task.UpdatedOn))
+}
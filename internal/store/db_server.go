@@ -372,3 +372,60 @@ func (database *pgStore) BatchInsertServerLogs(ctx context.Context, serverEvents
 		Debug("Wrote event serverEvents successfully")
 	return nil
 }
+
+// AddServerUptime closes the currently open interval of the server's health check and opens the new one
+func (database *pgStore) AddServerUptime(ctx context.Context, uptime *model.ServerUptime) error {
+	tx, errBeginTx := database.conn.Begin(ctx)
+	if errBeginTx != nil {
+		return Err(errBeginTx)
+	}
+	if _, errClose := tx.Exec(ctx, `
+		UPDATE server_uptime SET ended_on = $3
+		WHERE server_id = $1 AND check_name = $2 AND ended_on IS NULL`,
+		uptime.ServerId, uptime.Check, uptime.StartedOn); errClose != nil {
+		_ = tx.Rollback(ctx)
+		return Err(errClose)
+	}
+	if errInsert := tx.QueryRow(ctx, `
+		INSERT INTO server_uptime (server_id, check_name, up, started_on)
+		VALUES ($1, $2, $3, $4)
+		RETURNING server_uptime_id`,
+		uptime.ServerId, uptime.Check, uptime.Up, uptime.StartedOn).Scan(&uptime.ServerUptimeId); errInsert != nil {
+		_ = tx.Rollback(ctx)
+		return Err(errInsert)
+	}
+	if errCommit := tx.Commit(ctx); errCommit != nil {
+		return Err(errCommit)
+	}
+	return nil
+}
+
+// GetServerUptime returns the health check intervals of the server which overlap the period after since,
+// oldest first
+func (database *pgStore) GetServerUptime(ctx context.Context, serverId int, since time.Time) ([]model.ServerUptime, error) {
+	rows, errQuery := database.Query(ctx, `
+		SELECT server_uptime_id, server_id, check_name, up, started_on, ended_on
+		FROM server_uptime
+		WHERE server_id = $1 AND (ended_on IS NULL OR ended_on > $2)
+		ORDER BY started_on`, serverId, since)
+	if errQuery != nil {
+		return nil, Err(errQuery)
+	}
+	defer rows.Close()
+	intervals := []model.ServerUptime{}
+	for rows.Next() {
+		var (
+			uptime  model.ServerUptime
+			endedOn *time.Time
+		)
+		if errScan := rows.Scan(&uptime.ServerUptimeId, &uptime.ServerId, &uptime.Check, &uptime.Up,
+			&uptime.StartedOn, &endedOn); errScan != nil {
+			return nil, Err(errScan)
+		}
+		if endedOn != nil {
+			uptime.EndedOn = *endedOn
+		}
+		intervals = append(intervals, uptime)
+	}
+	return intervals, nil
+}
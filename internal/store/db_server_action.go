@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const serverActionColumns = `
+	server_action_id, server_id, steam_id, kind, reason, status, message, created_on, updated_on`
+
+func (database *pgStore) SaveServerAction(ctx context.Context, action *model.ServerAction) error {
+	action.UpdatedOn = config.Now()
+	if action.ServerActionId > 0 {
+		const query = `UPDATE server_action SET status = $2, message = $3, updated_on = $4 WHERE server_action_id = $1`
+		if errExec := database.Exec(ctx, query, action.ServerActionId, action.Status, action.Message,
+			action.UpdatedOn); errExec != nil {
+			return Err(errExec)
+		}
+		return nil
+	}
+	const query = `
+		INSERT INTO server_action (server_id, steam_id, kind, reason, status, message, created_on, updated_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING server_action_id`
+	if errQuery := database.QueryRow(ctx, query, action.ServerId, action.SteamId.Int64(), action.Kind, action.Reason,
+		action.Status, action.Message, action.CreatedOn, action.UpdatedOn).Scan(&action.ServerActionId); errQuery != nil {
+		return Err(errQuery)
+	}
+	log.Debugf("Created server action: %d", action.ServerActionId)
+	return nil
+}
+
+func (database *pgStore) GetServerAction(ctx context.Context, serverActionId int64, action *model.ServerAction) error {
+	const query = `SELECT ` + serverActionColumns + ` FROM server_action WHERE server_action_id = $1`
+	return scanServerAction(database.QueryRow(ctx, query, serverActionId), action)
+}
+
+// GetPendingServerActions returns the unconfirmed actions for a server, oldest first
+func (database *pgStore) GetPendingServerActions(ctx context.Context, serverId int) ([]model.ServerAction, error) {
+	const query = `SELECT ` + serverActionColumns + `
+		FROM server_action
+		WHERE server_id = $1 AND status = $2
+		ORDER BY created_on`
+	rows, errQuery := database.Query(ctx, query, serverId, model.ServerActionPending)
+	if errQuery != nil {
+		return nil, Err(errQuery)
+	}
+	defer rows.Close()
+	var actions []model.ServerAction
+	for rows.Next() {
+		var action model.ServerAction
+		if errScan := scanServerAction(rows, &action); errScan != nil {
+			return nil, errScan
+		}
+		actions = append(actions, action)
+	}
+	return actions, nil
+}
+
+// ExpireServerActions marks the pending actions created before the given time as expired, returning
+// the number of actions updated
+func (database *pgStore) ExpireServerActions(ctx context.Context, createdBefore time.Time) (int64, error) {
+	const query = `UPDATE server_action SET status = $1, updated_on = $2 WHERE status = $3 AND created_on < $4`
+	tag, errExec := database.conn.Exec(ctx, query, model.ServerActionExpired, config.Now(),
+		model.ServerActionPending, createdBefore)
+	if errExec != nil {
+		return 0, Err(errExec)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func scanServerAction(row pgx.Row, action *model.ServerAction) error {
+	var steamId int64
+	if errScan := row.Scan(&action.ServerActionId, &action.ServerId, &steamId, &action.Kind, &action.Reason,
+		&action.Status, &action.Message, &action.CreatedOn, &action.UpdatedOn); errScan != nil {
+		return Err(errScan)
+	}
+	action.SteamId = steamid.SID64(steamId)
+	return nil
+}
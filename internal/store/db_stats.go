@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v4"
 	"github.com/leighmacdonald/gbans/internal/config"
 	"github.com/leighmacdonald/gbans/internal/model"
 	"github.com/leighmacdonald/gbans/pkg/fp"
@@ -77,6 +78,58 @@ func (database *pgStore) MatchSave(ctx context.Context, match *model.Match) erro
 	return nil
 }
 
+// SaveMatchStates persists the state of in-progress matches so that they can be resumed after a restart.
+// Only a single in-progress match is stored per server. The states are written together in a single batch.
+func (database *pgStore) SaveMatchStates(ctx context.Context, matches []*model.Match) error {
+	const q = `
+		INSERT INTO match_state (server_id, state, updated_on) VALUES ($1, $2, $3)
+		ON CONFLICT (server_id) DO UPDATE SET state = $2, updated_on = $3`
+	now := config.Now()
+	batch := pgx.Batch{}
+	for _, match := range matches {
+		state, errState := match.MarshalState()
+		if errState != nil {
+			return errState
+		}
+		batch.Queue(q, match.ServerId, state, now)
+	}
+	if batch.Len() == 0 {
+		return nil
+	}
+	return Err(database.conn.SendBatch(ctx, &batch).Close())
+}
+
+// GetMatchStates loads all persisted in-progress matches, keyed by their server id
+func (database *pgStore) GetMatchStates(ctx context.Context) (map[int]*model.Match, error) {
+	rows, errQuery := database.Query(ctx, `SELECT server_id, state FROM match_state`)
+	if errQuery != nil {
+		return nil, Err(errQuery)
+	}
+	defer rows.Close()
+	matches := map[int]*model.Match{}
+	for rows.Next() {
+		var (
+			serverId int
+			state    []byte
+		)
+		if errScan := rows.Scan(&serverId, &state); errScan != nil {
+			return nil, Err(errScan)
+		}
+		var match model.Match
+		if errState := model.UnmarshalMatchState(state, &match); errState != nil {
+			log.WithError(errState).WithField("server_id", serverId).Warnf("Discarding invalid match state")
+			continue
+		}
+		matches[serverId] = &match
+	}
+	return matches, nil
+}
+
+// DropMatchState removes any in-progress match state for the server
+func (database *pgStore) DropMatchState(ctx context.Context, serverId int) error {
+	return Err(database.Exec(ctx, `DELETE FROM match_state WHERE server_id = $1`, serverId))
+}
+
 type MatchesQueryOpts struct {
 	QueryFilter
 	SteamID   steamid.SID64 `json:"steam_id"`
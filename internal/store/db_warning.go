@@ -0,0 +1,103 @@
+package store
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/leighmacdonald/gbans/internal/model"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	log "github.com/sirupsen/logrus"
+)
+
+const warningColumns = `
+	w.warning_id, w.steam_id, w.source_id, coalesce(w.server_id, 0), coalesce(s.short_name, ''), w.reason,
+	w.message, coalesce(w.filter_id, 0), w.matched_word, w.created_on, w.valid_until, w.weight,
+	w.matched_step`
+
+// SaveWarning inserts a new warning. Warnings are never updated, only dropped
+func (database *pgStore) SaveWarning(ctx context.Context, warning *model.UserWarning) error {
+	const query = `
+		INSERT INTO warning (steam_id, source_id, server_id, reason, message, filter_id, matched_word, created_on,
+		                     valid_until, weight, matched_step)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING warning_id`
+	var serverId, filterId any
+	if warning.ServerId > 0 {
+		serverId = warning.ServerId
+	}
+	if warning.FilterId > 0 {
+		filterId = warning.FilterId
+	}
+	if errQuery := database.QueryRow(ctx, query, warning.SteamId.Int64(), warning.SourceId.Int64(), serverId,
+		warning.WarnReason, warning.Message, filterId, warning.MatchedWord, warning.CreatedOn,
+		warning.ValidUntil, warning.Weight, warning.MatchedStep).Scan(&warning.WarningId); errQuery != nil {
+		return Err(errQuery)
+	}
+	log.Debugf("Created warning: %d", warning.WarningId)
+	return nil
+}
+
+// GetWarnings returns the warnings issued to a player, newest first. When includeExpired is false,
+// only warnings that still count towards the warning limit are returned.
+func (database *pgStore) GetWarnings(ctx context.Context, steamId steamid.SID64, includeExpired bool) (model.UserWarnings, error) {
+	query := `SELECT ` + warningColumns + `
+		FROM warning w
+		LEFT JOIN server s ON s.server_id = w.server_id
+		WHERE w.steam_id = $1`
+	args := []any{steamId.Int64()}
+	if !includeExpired {
+		query += ` AND w.valid_until > $2`
+		args = append(args, config.Now())
+	}
+	query += ` ORDER BY w.created_on DESC`
+	rows, errQuery := database.Query(ctx, query, args...)
+	if errQuery != nil {
+		return nil, Err(errQuery)
+	}
+	return scanWarnings(rows)
+}
+
+// GetActiveWarnings returns all warnings, for all players, which have not yet expired
+func (database *pgStore) GetActiveWarnings(ctx context.Context) (model.UserWarnings, error) {
+	query := `SELECT ` + warningColumns + `
+		FROM warning w
+		LEFT JOIN server s ON s.server_id = w.server_id
+		WHERE w.valid_until > $1
+		ORDER BY w.created_on`
+	rows, errQuery := database.Query(ctx, query, config.Now())
+	if errQuery != nil {
+		return nil, Err(errQuery)
+	}
+	return scanWarnings(rows)
+}
+
+// DropWarnings removes all warnings for a player, returning the number removed
+func (database *pgStore) DropWarnings(ctx context.Context, steamId steamid.SID64) (int64, error) {
+	tag, errExec := database.conn.Exec(ctx, `DELETE FROM warning WHERE steam_id = $1`, steamId.Int64())
+	if errExec != nil {
+		return 0, Err(errExec)
+	}
+	log.Debugf("Deleted %d warnings: %d", tag.RowsAffected(), steamId.Int64())
+	return tag.RowsAffected(), nil
+}
+
+func scanWarnings(rows pgx.Rows) (model.UserWarnings, error) {
+	defer rows.Close()
+	warnings := model.UserWarnings{}
+	for rows.Next() {
+		var (
+			warning  model.UserWarning
+			steamId  int64
+			sourceId int64
+		)
+		if errScan := rows.Scan(&warning.WarningId, &steamId, &sourceId, &warning.ServerId, &warning.ServerName,
+			&warning.WarnReason, &warning.Message, &warning.FilterId, &warning.MatchedWord, &warning.CreatedOn,
+			&warning.ValidUntil, &warning.Weight, &warning.MatchedStep); errScan != nil {
+			return nil, Err(errScan)
+		}
+		warning.SteamId = steamid.SID64(steamId)
+		warning.SourceId = steamid.SID64(sourceId)
+		warnings = append(warnings, warning)
+	}
+	return warnings, nil
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"github.com/jackc/pgx/v4"
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/leighmacdonald/gbans/internal/model"
+)
+
+const watchedServerColumns = `
+	watched_server_id, name, address, port, region, cc, is_enabled, created_on, updated_on`
+
+func (database *pgStore) GetWatchedServer(ctx context.Context, watchedServerId int, server *model.WatchedServer) error {
+	const query = `SELECT ` + watchedServerColumns + ` FROM watched_server WHERE watched_server_id = $1`
+	return scanWatchedServer(database.QueryRow(ctx, query, watchedServerId), server)
+}
+
+func (database *pgStore) GetWatchedServers(ctx context.Context, includeDisabled bool) ([]model.WatchedServer, error) {
+	query := `SELECT ` + watchedServerColumns + ` FROM watched_server`
+	if !includeDisabled {
+		query += ` WHERE is_enabled = true`
+	}
+	rows, errQuery := database.Query(ctx, query+` ORDER BY name, address, port`)
+	if errQuery != nil {
+		return nil, Err(errQuery)
+	}
+	defer rows.Close()
+	servers := []model.WatchedServer{}
+	for rows.Next() {
+		var server model.WatchedServer
+		if errScan := scanWatchedServer(rows, &server); errScan != nil {
+			return nil, errScan
+		}
+		servers = append(servers, server)
+	}
+	return servers, nil
+}
+
+// SaveWatchedServer updates or creates the watched server
+func (database *pgStore) SaveWatchedServer(ctx context.Context, server *model.WatchedServer) error {
+	server.UpdatedOn = config.Now()
+	if server.WatchedServerId > 0 {
+		const query = `
+			UPDATE watched_server
+			SET name = $2, address = $3, port = $4, region = $5, cc = $6, is_enabled = $7, updated_on = $8
+			WHERE watched_server_id = $1`
+		return Err(database.Exec(ctx, query, server.WatchedServerId, server.Name, server.Address, server.Port,
+			server.Region, server.CC, server.IsEnabled, server.UpdatedOn))
+	}
+	const query = `
+		INSERT INTO watched_server (name, address, port, region, cc, is_enabled, created_on, updated_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING watched_server_id`
+	return Err(database.QueryRow(ctx, query, server.Name, server.Address, server.Port, server.Region, server.CC,
+		server.IsEnabled, server.CreatedOn, server.UpdatedOn).Scan(&server.WatchedServerId))
+}
+
+func (database *pgStore) DropWatchedServer(ctx context.Context, watchedServerId int) error {
+	const query = `DELETE FROM watched_server WHERE watched_server_id = $1`
+	return Err(database.Exec(ctx, query, watchedServerId))
+}
+
+func scanWatchedServer(row pgx.Row, server *model.WatchedServer) error {
+	return Err(row.Scan(&server.WatchedServerId, &server.Name, &server.Address, &server.Port, &server.Region,
+		&server.CC, &server.IsEnabled, &server.CreatedOn, &server.UpdatedOn))
+}
@@ -0,0 +1,15 @@
+begin;
+
+create table if not exists match_state
+(
+    server_id  integer     not null
+        constraint match_state_pk
+            primary key
+        constraint match_state_server_server_id_fk
+            references server
+            on update cascade on delete cascade,
+    state      bytea       not null,
+    updated_on timestamptz not null
+);
+
+commit;
@@ -0,0 +1,29 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS warning
+(
+    warning_id   bigserial primary key,
+    steam_id     bigint      not null
+        constraint warning_steam_id_fk
+            references person
+            on update cascade on delete cascade,
+    source_id    bigint      not null default 0,
+    server_id    int
+        constraint warning_server_id_fk
+            references server
+            on update cascade on delete set null,
+    reason       int         not null,
+    message      text        not null default '',
+    filter_id    bigint
+        constraint warning_filter_id_fk
+            references filtered_word
+            on update cascade on delete set null,
+    matched_word text        not null default '',
+    created_on   timestamptz not null,
+    valid_until  timestamptz not null
+);
+
+create index if not exists warning_steam_id_index
+    on warning (steam_id, valid_until);
+
+COMMIT;
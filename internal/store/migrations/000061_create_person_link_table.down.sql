@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP INDEX IF EXISTS person_connections_ip_addr_index;
+DROP TABLE IF EXISTS person_link;
+
+COMMIT;
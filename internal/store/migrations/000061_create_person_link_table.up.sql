@@ -0,0 +1,28 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS person_link
+(
+    steam_id   bigint      not null
+        constraint person_link_steam_id_fk
+            references person
+            on update cascade on delete cascade,
+    linked_id  bigint      not null
+        constraint person_link_linked_id_fk
+            references person
+            on update cascade on delete cascade,
+    ip_addr    inet        not null,
+    created_on timestamptz not null,
+    updated_on timestamptz not null,
+    constraint person_link_pk
+        primary key (steam_id, linked_id),
+    constraint person_link_order_check
+        check (steam_id < linked_id)
+);
+
+create index if not exists person_link_linked_id_index
+    on person_link (linked_id);
+
+create index if not exists person_connections_ip_addr_index
+    on person_connections using gist (ip_addr inet_ops);
+
+COMMIT;
@@ -0,0 +1,23 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS ban_join_attempt
+(
+    ban_join_attempt_id bigserial primary key,
+    ban_type            text        not null,
+    ban_id              bigint      not null,
+    steam_id            bigint      not null,
+    server_id           int
+        constraint ban_join_attempt_server_id_fk
+            references server
+            on update cascade on delete set null,
+    ip_addr             inet        not null,
+    created_on          timestamptz not null
+);
+
+create index if not exists ban_join_attempt_ban_index
+    on ban_join_attempt (ban_type, ban_id);
+
+create index if not exists ban_join_attempt_steam_id_index
+    on ban_join_attempt (steam_id);
+
+COMMIT;
@@ -0,0 +1,22 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS ban_appeal_state
+(
+    appeal_state_change_id bigserial primary key,
+    ban_id                 bigint      not null
+        constraint ban_appeal_state_ban_id_fk
+            references ban
+            on update cascade on delete cascade,
+    source_id              bigint      not null,
+    from_state             int         not null,
+    to_state               int         not null,
+    note                   text        not null default '',
+    valid_until_before     timestamptz not null,
+    valid_until_after      timestamptz not null,
+    created_on             timestamptz not null
+);
+
+create index if not exists ban_appeal_state_ban_id_index
+    on ban_appeal_state (ban_id, created_on);
+
+COMMIT;
@@ -0,0 +1,17 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS ban_audit
+(
+    ban_audit_id bigserial primary key,
+    kind         text        not null,
+    ban_id       bigint      not null,
+    target       text        not null default '',
+    action       text        not null,
+    note         text        not null default '',
+    created_on   timestamptz not null
+);
+
+create index if not exists ban_audit_kind_ban_id_index
+    on ban_audit (kind, ban_id, created_on);
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS external_ban_entry;
+
+COMMIT;
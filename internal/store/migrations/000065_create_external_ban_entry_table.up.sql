@@ -0,0 +1,13 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS external_ban_entry
+(
+    source     text        not null,
+    kind       text        not null,
+    entry      text        not null,
+    ban_id     bigint      not null default 0,
+    created_on timestamptz not null,
+    primary key (source, kind, entry)
+);
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE report DROP COLUMN IF EXISTS demo_name;
+
+COMMIT;
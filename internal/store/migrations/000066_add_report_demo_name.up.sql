@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE report ADD COLUMN IF NOT EXISTS demo_name text default '' not null;
+
+COMMIT;
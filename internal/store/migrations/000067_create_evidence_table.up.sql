@@ -0,0 +1,31 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS evidence
+(
+    evidence_id       bigserial primary key,
+    parent_kind       text        not null,
+    parent_id         bigint      not null,
+    message_id        bigint      not null default 0,
+    author_id         bigint      not null
+        constraint evidence_author_id_fk references person on update cascade on delete restrict,
+    kind              text        not null,
+    media_id          int
+        constraint evidence_media_id_fk references media on update cascade on delete cascade,
+    person_message_id bigint
+        constraint evidence_person_message_id_fk references person_messages on update cascade on delete cascade,
+    demo_id           int
+        constraint evidence_demo_id_fk references demo on update cascade on delete cascade,
+    tick_start        int         not null default 0,
+    tick_end          int         not null default 0,
+    match_id          int
+        constraint evidence_match_id_fk references match on update cascade on delete cascade,
+    note              text        not null default '',
+    deleted           boolean     not null default false,
+    created_on        timestamptz not null,
+    updated_on        timestamptz not null
+);
+
+create index if not exists evidence_parent_index
+    on evidence (parent_kind, parent_id);
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP INDEX IF EXISTS report_status_changed_on_index;
+ALTER TABLE report DROP COLUMN IF EXISTS assignee_id;
+ALTER TABLE report DROP COLUMN IF EXISTS status_changed_on;
+ALTER TABLE report DROP COLUMN IF EXISTS escalated;
+
+COMMIT;
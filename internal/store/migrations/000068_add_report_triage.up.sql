@@ -0,0 +1,16 @@
+BEGIN;
+
+ALTER TABLE report ADD COLUMN IF NOT EXISTS assignee_id bigint default 0 not null;
+ALTER TABLE report ADD COLUMN IF NOT EXISTS status_changed_on timestamptz;
+ALTER TABLE report ADD COLUMN IF NOT EXISTS escalated boolean default false not null;
+
+-- Existing reports start their sla and timeouts from the migration, otherwise every old report
+-- would be escalated or closed as soon as triage runs for the first time
+UPDATE report SET status_changed_on = now() WHERE status_changed_on IS NULL;
+
+ALTER TABLE report ALTER COLUMN status_changed_on SET NOT NULL;
+
+create index if not exists report_status_changed_on_index
+    on report (report_status, status_changed_on);
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+ALTER TABLE filtered_word DROP COLUMN IF EXISTS action;
+ALTER TABLE filtered_word DROP COLUMN IF EXISTS weight;
+ALTER TABLE filtered_word DROP COLUMN IF EXISTS ban_reason;
+ALTER TABLE filtered_word DROP COLUMN IF EXISTS duration;
+ALTER TABLE filtered_word DROP COLUMN IF EXISTS ping_discord;
+
+ALTER TABLE warning DROP COLUMN IF EXISTS weight;
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+ALTER TABLE filtered_word ADD COLUMN IF NOT EXISTS action text default 'warn' not null;
+ALTER TABLE filtered_word ADD COLUMN IF NOT EXISTS weight int default 1 not null;
+-- 9 = model.Language
+ALTER TABLE filtered_word ADD COLUMN IF NOT EXISTS ban_reason int default 9 not null;
+ALTER TABLE filtered_word ADD COLUMN IF NOT EXISTS duration text default '' not null;
+ALTER TABLE filtered_word ADD COLUMN IF NOT EXISTS ping_discord boolean default false not null;
+
+ALTER TABLE warning ADD COLUMN IF NOT EXISTS weight int default 1 not null;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE warning DROP COLUMN IF EXISTS matched_step;
+
+COMMIT;
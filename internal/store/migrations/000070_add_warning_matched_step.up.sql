@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE warning ADD COLUMN IF NOT EXISTS matched_step text default '' not null;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS person_messages_server_created_idx;
+DROP INDEX IF EXISTS person_messages_body_trgm_idx;
+DROP INDEX IF EXISTS person_messages_body_tsv_idx;
+
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN;
+
+-- Creating the extension requires a superuser, or a database owner on postgres 13+ as pg_trgm is a trusted
+-- extension. Otherwise, it must be created manually by a superuser before running this migration.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Full text search for the text and phrase search modes
+CREATE INDEX IF NOT EXISTS person_messages_body_tsv_idx ON person_messages USING gin (to_tsvector('simple', body));
+-- Trigram index used by the contains and regex search modes
+CREATE INDEX IF NOT EXISTS person_messages_body_trgm_idx ON person_messages USING gin (body gin_trgm_ops);
+-- Context lookups around each search hit
+CREATE INDEX IF NOT EXISTS person_messages_server_created_idx ON person_messages (server_id, created_on);
+
+COMMIT;
@@ -0,0 +1,22 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS server_action
+(
+    server_action_id bigserial primary key,
+    server_id        int         not null
+        constraint server_action_server_id_fk
+            references server
+            on update cascade on delete cascade,
+    steam_id         bigint      not null,
+    kind             text        not null,
+    reason           text        not null default '',
+    status           text        not null default 'pending',
+    message          text        not null default '',
+    created_on       timestamptz not null,
+    updated_on       timestamptz not null
+);
+
+create index if not exists server_action_pending_index
+    on server_action (server_id, created_on) where status = 'pending';
+
+COMMIT;
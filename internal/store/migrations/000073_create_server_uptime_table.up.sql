@@ -0,0 +1,19 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS server_uptime
+(
+    server_uptime_id bigserial primary key,
+    server_id        int         not null
+        constraint server_uptime_server_id_fk
+            references server
+            on update cascade on delete cascade,
+    check_name       text        not null,
+    up               boolean     not null,
+    started_on       timestamptz not null,
+    ended_on         timestamptz
+);
+
+create index if not exists server_uptime_server_id_index
+    on server_uptime (server_id, check_name, started_on);
+
+COMMIT;
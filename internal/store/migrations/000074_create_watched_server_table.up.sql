@@ -0,0 +1,19 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS watched_server
+(
+    watched_server_id serial primary key,
+    name              text        not null default '',
+    address           text        not null,
+    port              int         not null,
+    region            text        not null default '',
+    cc                text        not null default '',
+    is_enabled        boolean     not null default true,
+    created_on        timestamptz not null,
+    updated_on        timestamptz not null
+);
+
+create unique index if not exists watched_server_address_uindex
+    on watched_server (address, port);
+
+COMMIT;
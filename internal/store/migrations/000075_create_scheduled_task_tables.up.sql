@@ -0,0 +1,41 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS scheduled_task
+(
+    scheduled_task_id  serial primary key,
+    name               text        not null,
+    schedule           text        not null,
+    kind               text        not null,
+    command            text        not null,
+    server_id          int
+        constraint scheduled_task_server_id_fk
+            references server
+            on update cascade on delete cascade,
+    region             text        not null default '',
+    only_when_empty    boolean     not null default false,
+    only_when_outdated boolean     not null default false,
+    is_enabled         boolean     not null default true,
+    created_on         timestamptz not null,
+    updated_on         timestamptz not null
+);
+
+CREATE TABLE IF NOT EXISTS scheduled_task_run
+(
+    scheduled_task_run_id bigserial primary key,
+    scheduled_task_id     int         not null
+        constraint scheduled_task_run_scheduled_task_id_fk
+            references scheduled_task
+            on update cascade on delete cascade,
+    server_id             int         not null
+        constraint scheduled_task_run_server_id_fk
+            references server
+            on update cascade on delete cascade,
+    status                text        not null,
+    output                text        not null default '',
+    created_on            timestamptz not null
+);
+
+create index if not exists scheduled_task_run_scheduled_task_id_index
+    on scheduled_task_run (scheduled_task_id, created_on);
+
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS ban_group_member
+(
+    group_id   bigint      not null,
+    steam_id   bigint      not null,
+    updated_on timestamptz not null,
+    primary key (group_id, steam_id)
+);
+
+create index if not exists ban_group_member_steam_id_index
+    on ban_group_member (steam_id);
+
+COMMIT;
@@ -34,6 +34,32 @@ type ServerStore interface {
 	GetServerByName(ctx context.Context, serverName string, server *model.Server) error
 	SaveServer(ctx context.Context, server *model.Server) error
 	DropServer(ctx context.Context, serverID int) error
+	AddServerUptime(ctx context.Context, uptime *model.ServerUptime) error
+	GetServerUptime(ctx context.Context, serverId int, since time.Time) ([]model.ServerUptime, error)
+}
+
+type ServerActionStore interface {
+	SaveServerAction(ctx context.Context, action *model.ServerAction) error
+	GetServerAction(ctx context.Context, serverActionId int64, action *model.ServerAction) error
+	GetPendingServerActions(ctx context.Context, serverId int) ([]model.ServerAction, error)
+	ExpireServerActions(ctx context.Context, createdBefore time.Time) (int64, error)
+}
+
+type WatchedServerStore interface {
+	GetWatchedServer(ctx context.Context, watchedServerId int, server *model.WatchedServer) error
+	GetWatchedServers(ctx context.Context, includeDisabled bool) ([]model.WatchedServer, error)
+	SaveWatchedServer(ctx context.Context, server *model.WatchedServer) error
+	DropWatchedServer(ctx context.Context, watchedServerId int) error
+}
+
+type ScheduledTaskStore interface {
+	GetScheduledTask(ctx context.Context, scheduledTaskId int, task *model.ScheduledTask) error
+	GetScheduledTasks(ctx context.Context, includeDisabled bool) ([]model.ScheduledTask, error)
+	SaveScheduledTask(ctx context.Context, task *model.ScheduledTask) error
+	DropScheduledTask(ctx context.Context, scheduledTaskId int) error
+	AddScheduledTaskRun(ctx context.Context, run *model.ScheduledTaskRun) error
+	GetScheduledTaskRuns(ctx context.Context, filter ScheduledTaskRunQueryFilter) ([]model.ScheduledTaskRun, error)
+	PruneScheduledTaskRuns(ctx context.Context, before time.Time) (int64, error)
 }
 
 type DemoStore interface {
@@ -54,6 +80,7 @@ type NewsStore interface {
 type BanStore interface {
 	GetBanBySteamID(ctx context.Context, steamID steamid.SID64, bannedPerson *model.BannedPerson, deletedOk bool) error
 	GetBanByBanID(ctx context.Context, banID int64, bannedPerson *model.BannedPerson, deletedOk bool) error
+	GetBanCountsByReason(ctx context.Context, sid64 steamid.SID64, reason model.Reason) (map[model.BanKind]int, error)
 	SaveBan(ctx context.Context, ban *model.BanSteam) error
 	DropBan(ctx context.Context, ban *model.BanSteam, hardDelete bool) error
 	GetBansSteam(ctx context.Context, queryFilter BansQueryFilter) ([]model.BannedPerson, error)
@@ -75,15 +102,36 @@ type BanStore interface {
 	GetExpiredASNBans(ctx context.Context) ([]model.BanASN, error)
 
 	GetBanGroups(ctx context.Context) ([]model.BanGroup, error)
-	GetBanGroup(ctx context.Context, groupId steamid.GID, banGroup *model.BanGroup) error
+	GetBanGroup(ctx context.Context, targetId steamid.SID64, banGroup *model.BanGroup) error
+	GetBanGroupByGroupId(ctx context.Context, groupId steamid.GID, banGroup *model.BanGroup) error
 	GetBanGroupById(ctx context.Context, banGroupId int64, banGroup *model.BanGroup) error
 	SaveBanGroup(ctx context.Context, banGroup *model.BanGroup) error
 	DropBanGroup(ctx context.Context, banGroup *model.BanGroup) error
+	GetExpiredBanGroups(ctx context.Context) ([]model.BanGroup, error)
+	SaveBanGroupMembers(ctx context.Context, groupId steamid.GID, members steamid.Collection) error
+	GetBanGroupMemberships(ctx context.Context, steamIds steamid.Collection) ([]steamid.GID, error)
 
 	SaveBanMessage(ctx context.Context, message *model.UserMessage) error
 	DropBanMessage(ctx context.Context, message *model.UserMessage) error
 	GetBanMessages(ctx context.Context, banId int64) ([]model.UserMessage, error)
 	GetBanMessageById(ctx context.Context, banMessageId int, message *model.UserMessage) error
+
+	SaveBanJoinAttempt(ctx context.Context, attempt *model.BanJoinAttempt) error
+	GetBanJoinAttempts(ctx context.Context, filter BanJoinAttemptQueryFilter) ([]model.BanJoinAttempt, error)
+	GetBanJoinAttemptCount(ctx context.Context, attemptType model.BanKind, banId int64) (int, error)
+
+	SaveBanAppealState(ctx context.Context, ban *model.BanSteam, change *model.AppealStateChange) error
+	GetAppealStateChanges(ctx context.Context, banId int64) ([]model.AppealStateChange, error)
+
+	SaveBanAuditEntry(ctx context.Context, entry *model.BanAuditEntry) error
+	GetBanAuditEntries(ctx context.Context, kind model.BanKind, banId int64) ([]model.BanAuditEntry, error)
+
+	GetBansBySubject(ctx context.Context, filter BanSubjectFilter) ([]model.BanMatch, error)
+
+	GetExternalBanEntries(ctx context.Context, source string) ([]model.ExternalBanEntry, error)
+	SaveExternalBanEntry(ctx context.Context, entry *model.ExternalBanEntry) error
+	DropExternalBanEntry(ctx context.Context, entry *model.ExternalBanEntry) error
+	GetExternalBanSources(ctx context.Context) ([]model.ExternalBanSource, error)
 }
 
 type ReportStore interface {
@@ -93,7 +141,7 @@ type ReportStore interface {
 	DropReportMessage(ctx context.Context, message *model.UserMessage) error
 	GetReport(ctx context.Context, reportId int64, report *model.Report) error
 	GetReportBySteamId(ctx context.Context, authorId steamid.SID64, steamId steamid.SID64, report *model.Report) error
-	GetReports(ctx context.Context, opts AuthorQueryFilter) ([]model.Report, error)
+	GetReports(ctx context.Context, opts ReportQueryFilter) ([]model.Report, error)
 	GetReportMessages(ctx context.Context, reportId int64) ([]model.UserMessage, error)
 	GetReportMessageById(ctx context.Context, reportMessageId int64, message *model.UserMessage) error
 }
@@ -110,9 +158,13 @@ type PersonStore interface {
 	GetExpiredProfiles(ctx context.Context, limit int) ([]model.Person, error)
 	GetPersonIPHistory(ctx context.Context, sid steamid.SID64, limit int) (model.PersonConnections, error)
 	QueryChatHistory(ctx context.Context, query ChatHistoryQueryFilter) (model.PersonMessages, error)
+	SearchChat(ctx context.Context, query ChatSearchQueryFilter) ([]model.ChatSearchHit, error)
 	GetPersonMessageById(ctx context.Context, query int64, msg *model.PersonMessage) error
 	AddChatHistory(ctx context.Context, message *model.PersonMessage) error
 	AddConnectionHistory(ctx context.Context, conn *model.PersonConnection) error
+	GetSteamIDsAtNetwork(ctx context.Context, ipNet *net.IPNet, since time.Time) (steamid.Collection, error)
+	SavePersonLink(ctx context.Context, link *model.PersonLink) error
+	GetPersonLinks(ctx context.Context, sid64 steamid.SID64) ([]model.PersonLink, error)
 }
 
 type FilterStore interface {
@@ -123,6 +175,13 @@ type FilterStore interface {
 	GetFilters(ctx context.Context) ([]model.Filter, error)
 }
 
+type WarningStore interface {
+	SaveWarning(ctx context.Context, warning *model.UserWarning) error
+	GetWarnings(ctx context.Context, steamId steamid.SID64, includeExpired bool) (model.UserWarnings, error)
+	GetActiveWarnings(ctx context.Context) (model.UserWarnings, error)
+	DropWarnings(ctx context.Context, steamId steamid.SID64) (int64, error)
+}
+
 type MigrationStore interface {
 	Migrate(action MigrationAction) error
 }
@@ -133,6 +192,13 @@ type MediaStore interface {
 	GetMediaById(ctx context.Context, mediaId int, media *model.Media) error
 }
 
+type EvidenceStore interface {
+	SaveEvidence(ctx context.Context, evidence *model.Evidence) error
+	DropEvidence(ctx context.Context, evidence *model.Evidence) error
+	GetEvidence(ctx context.Context, evidenceId int64, evidence *model.Evidence) error
+	GetEvidenceByParent(ctx context.Context, parentKind model.EvidenceParent, parentId int64) ([]model.Evidence, error)
+}
+
 type WikiStore interface {
 	GetWikiPageBySlug(ctx context.Context, slug string, page *wiki.Page) error
 	DeleteWikiPageBySlug(ctx context.Context, slug string) error
@@ -142,6 +208,9 @@ type WikiStore interface {
 type StatStore interface {
 	GetStats(ctx context.Context, stats *model.Stats) error
 	MatchSave(ctx context.Context, match *model.Match) error
+	SaveMatchStates(ctx context.Context, matches []*model.Match) error
+	GetMatchStates(ctx context.Context) (map[int]*model.Match, error)
+	DropMatchState(ctx context.Context, serverId int) error
 	MatchGetById(ctx context.Context, matchId int) (*model.Match, error)
 	Matches(ctx context.Context, opts MatchesQueryOpts) (model.MatchSummaryCollection, error)
 	SaveLocalTF2Stats(ctx context.Context, duration StatDuration, stats model.LocalTF2StatsSnapshot) error
@@ -170,11 +239,16 @@ type Store interface {
 	NetworkStore
 	PersonStore
 	ServerStore
+	ServerActionStore
+	WatchedServerStore
+	ScheduledTaskStore
 	StatStore
 	ReportStore
 	NewsStore
 	WikiStore
 	MediaStore
+	EvidenceStore
 	AuthStore
+	WarningStore
 	io.Closer
 }
@@ -10,51 +10,36 @@ import (
 	log "github.com/sirupsen/logrus"
 	"io"
 	"net"
+	"net/http"
 	"os"
 	"path"
 	"strings"
 )
 
-var (
-	networks []*net.IPNet
-	steamids []steamid.SID64
-)
-
-func containsSID(sid steamid.SID64) bool {
-	for _, s := range steamids {
-		if s.Int64() == sid.Int64() {
-			return true
-		}
-	}
-	return false
+// BanListEntries holds the parsed contents of an external ban list
+type BanListEntries struct {
+	SteamIds steamid.Collection
+	Networks []*net.IPNet
 }
 
-func containsIP(ip net.IP) bool {
-	for _, b := range networks {
-		if b.Contains(ip) {
-			return true
-		}
-	}
-	return false
-}
-
-// Import is used to download and load block lists into memory
-func Import(list config.BanList) error {
+// FetchBanList downloads, or loads from the cache when it's younger than the configured max age, and
+// parses an external ban list
+func FetchBanList(list config.BanList) (BanListEntries, error) {
 	if !golib.Exists(config.Net.CachePath) {
 		if errMkDir := os.MkdirAll(config.Net.CachePath, 0755); errMkDir != nil {
-			log.Fatalf("Failed to create cache dir (%s): %v", config.Net.CachePath, errMkDir)
+			return BanListEntries{}, errors.Wrapf(errMkDir, "Failed to create cache dir (%s)", config.Net.CachePath)
 		}
 	}
 	filePath := path.Join(config.Net.CachePath, list.Name)
 	maxAge, errParseDuration := config.ParseDuration(config.Net.MaxAge)
 	if errParseDuration != nil {
-		return errors.Wrapf(errParseDuration, "Failed to parse cache max age")
+		return BanListEntries{}, errors.Wrapf(errParseDuration, "Failed to parse cache max age")
 	}
 	expired := false
 	if golib.Exists(filePath) {
 		fileInfo, errStat := os.Stat(filePath)
 		if errStat != nil {
-			return errors.Wrapf(errStat, "Failed to stat cached file")
+			return BanListEntries{}, errors.Wrapf(errStat, "Failed to stat cached file")
 		}
 		if config.Now().Sub(fileInfo.ModTime()) > maxAge {
 			expired = true
@@ -64,19 +49,20 @@ func Import(list config.BanList) error {
 	}
 	if expired {
 		if errDownload := download(list.URL, filePath); errDownload != nil {
-			return errors.Wrapf(errDownload, "Failed to download net ban list")
+			return BanListEntries{}, errors.Wrapf(errDownload, "Failed to download net ban list")
 		}
 	}
 	body, errReadFile := os.ReadFile(filePath)
 	if errReadFile != nil {
-		return errReadFile
+		return BanListEntries{}, errReadFile
 	}
-	count, errLoadBody := load(body, list.Type)
+	entries, errLoadBody := load(body, list.Type)
 	if errLoadBody != nil {
-		return errors.Wrapf(errLoadBody, "Failed to load list")
+		return BanListEntries{}, errors.Wrapf(errLoadBody, "Failed to load list")
 	}
-	log.WithFields(log.Fields{"count": count, "list": list.Name, "type": "steam"}).Debugf("Loaded blocklist")
-	return nil
+	log.WithFields(log.Fields{"steam": len(entries.SteamIds), "cidr": len(entries.Networks), "list": list.Name}).
+		Debugf("Loaded blocklist")
+	return entries, nil
 }
 
 func download(url string, savePath string) error {
@@ -84,81 +70,101 @@ func download(url string, savePath string) error {
 	if errQuery != nil {
 		return errQuery
 	}
+	defer func() {
+		if errClose := response.Body.Close(); errClose != nil {
+			log.Warnf("Failed to close block list response body: %v", errClose)
+		}
+	}()
+	if response.StatusCode != http.StatusOK {
+		return errors.Errorf("Invalid response code: %d", response.StatusCode)
+	}
 	outFile, errCreate := os.Create(savePath)
 	if errCreate != nil {
-		return errQuery
+		return errCreate
 	}
+	defer func() {
+		if errClose := outFile.Close(); errClose != nil {
+			log.Warnf("Failed to close block list file: %v", errClose)
+		}
+	}()
 	_, errCopy := io.Copy(outFile, response.Body)
 	if errCopy != nil {
 		return errCopy
 	}
-	defer func() {
-		if errClose := response.Body.Close(); errClose != nil {
-			log.Warnf("Failed to close block list response body: %v", errClose)
-		}
-	}()
 	return nil
 }
 
-func load(src []byte, listType config.BanListType) (count int, err error) {
+func load(src []byte, listType config.BanListType) (BanListEntries, error) {
+	var entries BanListEntries
 	switch listType {
 	case config.CIDR:
 		nets, errParseCIDR := parseCIDR(src)
 		if errParseCIDR != nil {
-			return 0, errParseCIDR
+			return entries, errParseCIDR
 		}
-		return addNets(nets), nil
+		entries.Networks = uniqueNets(nets)
 	case config.ValveNet:
 		nets, errParseValveNet := parseValveNet(src)
 		if errParseValveNet != nil {
-			return 0, errParseValveNet
+			return entries, errParseValveNet
 		}
-		return addNets(nets), nil
+		entries.Networks = uniqueNets(nets)
 	case config.ValveSID:
 		ids, errParseValveSID := parseValveSID(src)
 		if errParseValveSID != nil {
-			return 0, errParseValveSID
+			return entries, errParseValveSID
 		}
-		return addSIDs(ids), nil
+		entries.SteamIds = uniqueSIDs(ids)
 	case config.TF2BD:
 		ids, errParseBD := parseTF2BD(src)
 		if errParseBD != nil {
-			return 0, errParseBD
+			return entries, errParseBD
 		}
-		return addSIDs(ids), nil
+		entries.SteamIds = uniqueSIDs(ids)
 	default:
-		return 0, errors.Errorf("Unimplemented list type: %v", listType)
+		return entries, errors.Errorf("Unimplemented list type: %v", listType)
 	}
+	return entries, nil
 }
 
-func addNets(networks []*net.IPNet) int {
-	count := 0
+func uniqueNets(networks []*net.IPNet) []*net.IPNet {
+	var (
+		seen   = map[string]bool{}
+		unique []*net.IPNet
+	)
 	for _, network := range networks {
-		if !containsIP(network.IP) {
-			networks = append(networks, network)
-			count++
+		if !seen[network.String()] {
+			seen[network.String()] = true
+			unique = append(unique, network)
 		}
 	}
-	return count
+	return unique
 }
 
-func addSIDs(steamIds steamid.Collection) int {
-	count := 0
+func uniqueSIDs(steamIds steamid.Collection) steamid.Collection {
+	var (
+		seen   = map[steamid.SID64]bool{}
+		unique steamid.Collection
+	)
 	for _, sid64 := range steamIds {
-		if !containsSID(sid64) {
-			steamids = append(steamids, sid64)
-			count++
+		if !seen[sid64] {
+			seen[sid64] = true
+			unique = append(unique, sid64)
 		}
 	}
-	return count
+	return unique
 }
 
 func parseCIDR(src []byte) ([]*net.IPNet, error) {
 	var nets []*net.IPNet
 	for _, line := range strings.Split(string(src), "\n") {
+		line = strings.TrimSpace(line)
 		if line == "" {
 			continue
 		}
+		if !strings.Contains(line, "/") {
+			line += "/32"
+		}
 		_, ipNet, errParseCIDR := net.ParseCIDR(line)
 		if errParseCIDR != nil {
 			continue
@@ -0,0 +1,38 @@
+package thirdparty
+
+import (
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	entries, errLoad := load([]byte("1.2.3.0/24\n1.2.3.0/24\r\n5.6.7.8\nnot an ip\n"), config.CIDR)
+	require.NoError(t, errLoad)
+	require.Len(t, entries.Networks, 2)
+	require.Equal(t, "1.2.3.0/24", entries.Networks[0].String())
+	require.Equal(t, "5.6.7.8/32", entries.Networks[1].String())
+	require.Empty(t, entries.SteamIds)
+
+	valveEntries, errValve := load([]byte("banid 0 STEAM_0:1:16683555\r\nbanid 0 STEAM_0:1:16683555\r\n"), config.ValveSID)
+	require.NoError(t, errValve)
+	require.Len(t, valveEntries.SteamIds, 1)
+
+	_, errUnknown := load(nil, "unknown")
+	require.Error(t, errUnknown)
+}
+
+func TestParseWordList(t *testing.T) {
+	words, errText := parseWordList([]byte("# comment\nfoo\r\n\n bar \n"))
+	require.NoError(t, errText)
+	require.Equal(t, []string{"foo", "bar"}, words)
+
+	jsonWords, errJSON := parseWordList([]byte(`["foo", " ", "baz"]`))
+	require.NoError(t, errJSON)
+	require.Equal(t, []string{"foo", "baz"}, jsonWords)
+
+	_, errEmpty := parseWordList([]byte("\n# only a comment\n"))
+	require.Error(t, errEmpty)
+	_, errInvalid := parseWordList([]byte(`["foo", `))
+	require.Error(t, errInvalid)
+}
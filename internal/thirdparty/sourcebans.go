@@ -0,0 +1,268 @@
+package thirdparty
+
+import (
+	"fmt"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/pkg/errors"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SourceBansBan is a single row of the sb_bans, or sb_comms, table of a SourceBans++ install
+type SourceBansBan struct {
+	BanId int64
+	// Comm is set for rows of the sb_comms table, which are mutes, gags or silences
+	Comm bool
+	// Type is 0 for steam and 1 for ip bans. For comm blocks it is 1 for mutes, 2 for gags and 3 for silences
+	Type        int
+	SteamId     steamid.SID64
+	IP          net.IP
+	Name        string
+	Reason      string
+	Created     time.Time
+	Ends        time.Time
+	Length      time.Duration
+	Removed     bool
+	UnbanReason string
+}
+
+// Permanent checks if the ban never expires
+func (ban SourceBansBan) Permanent() bool {
+	return ban.Length == 0
+}
+
+var (
+	sourceBansInsertRx = regexp.MustCompile("(?i)INSERT\\s+INTO\\s+`?\\w*?(bans|comms)`?\\s*(\\(([^)]*)\\))?\\s*VALUES\\s*")
+	// Column orders used when a dump does not include the column names of the insert
+	sourceBansBanColumns = []string{"bid", "ip", "authid", "name", "created", "ends", "length", "reason", "aid",
+		"adminIp", "sid", "country", "RemovedBy", "RemoveType", "RemovedOn", "type", "ureason"}
+	sourceBansCommColumns = []string{"bid", "authid", "name", "created", "ends", "length", "reason", "aid",
+		"adminIp", "sid", "RemovedBy", "RemoveType", "RemovedOn", "type", "ureason"}
+)
+
+// ParseSourceBansSQL parses the sb_bans and sb_comms inserts of a SourceBans++ mysql dump. Inserts
+// for all other tables are ignored.
+func ParseSourceBansSQL(src []byte) ([]SourceBansBan, error) {
+	body := string(src)
+	var bans []SourceBansBan
+	for _, match := range sourceBansInsertRx.FindAllStringSubmatchIndex(body, -1) {
+		isComm := strings.EqualFold(body[match[2]:match[3]], "comms")
+		columns := sourceBansBanColumns
+		if isComm {
+			columns = sourceBansCommColumns
+		}
+		if match[6] >= 0 {
+			columns = nil
+			for _, column := range strings.Split(body[match[6]:match[7]], ",") {
+				columns = append(columns, strings.Trim(strings.TrimSpace(column), "`"))
+			}
+		}
+		rows, errRows := parseSQLTuples(body[match[1]:])
+		if errRows != nil {
+			return nil, errRows
+		}
+		for _, row := range rows {
+			if len(row) != len(columns) {
+				return nil, errors.Errorf("Column count mismatch, expected %d got %d", len(columns), len(row))
+			}
+			values := map[string]*string{}
+			for i, column := range columns {
+				values[strings.ToLower(column)] = row[i]
+			}
+			ban, errBan := newSourceBansBan(values, isComm)
+			if errBan != nil {
+				return nil, errBan
+			}
+			bans = append(bans, ban)
+		}
+	}
+	return bans, nil
+}
+
+func newSourceBansBan(values map[string]*string, isComm bool) (SourceBansBan, error) {
+	str := func(key string) string {
+		if value, found := values[key]; found && value != nil {
+			return *value
+		}
+		return ""
+	}
+	num := func(key string) int64 {
+		value, _ := strconv.ParseInt(str(key), 10, 64)
+		return value
+	}
+	ban := SourceBansBan{
+		BanId:       num("bid"),
+		Comm:        isComm,
+		Type:        int(num("type")),
+		Name:        str("name"),
+		Reason:      str("reason"),
+		Created:     time.Unix(num("created"), 0),
+		Ends:        time.Unix(num("ends"), 0),
+		Length:      time.Duration(num("length")) * time.Second,
+		Removed:     str("removetype") != "",
+		UnbanReason: str("ureason"),
+	}
+	if authId := str("authid"); authId != "" {
+		sid64, errSid := parseSourceBansSID(authId)
+		if errSid != nil {
+			return ban, errors.Wrapf(errSid, "Invalid authid for ban %d", ban.BanId)
+		}
+		ban.SteamId = sid64
+	}
+	if addr := str("ip"); addr != "" {
+		ban.IP = net.ParseIP(addr)
+	}
+	if !ban.SteamId.Valid() && ban.IP == nil {
+		return ban, errors.Errorf("Ban %d has no valid target", ban.BanId)
+	}
+	return ban, nil
+}
+
+// parseSourceBansSID parses the STEAM_X:Y:Z format used by SourceBans. Unlike steamid.SIDToSID64
+// this does not panic on malformed input.
+func parseSourceBansSID(authId string) (steamid.SID64, error) {
+	if strings.Count(authId, ":") != 2 {
+		return 0, errors.Errorf("Invalid steam id: %s", authId)
+	}
+	return steamid.StringToSID64(authId)
+}
+
+// parseSQLTuples parses the value tuples of an insert statement up to the terminating semicolon. NULL
+// values are returned as nil.
+func parseSQLTuples(body string) ([][]*string, error) {
+	var (
+		rows [][]*string
+		pos  = 0
+	)
+	skipSpace := func() {
+		for pos < len(body) && strings.ContainsRune(" \t\r\n", rune(body[pos])) {
+			pos++
+		}
+	}
+	for {
+		skipSpace()
+		if pos >= len(body) || body[pos] != '(' {
+			return nil, errors.New("Expected value tuple")
+		}
+		pos++
+		var row []*string
+		for {
+			skipSpace()
+			if pos >= len(body) {
+				return nil, errors.New("Unexpected end of values")
+			}
+			if body[pos] == '\'' {
+				var value strings.Builder
+				pos++
+				for ; pos < len(body); pos++ {
+					char := body[pos]
+					if char == '\\' && pos+1 < len(body) {
+						pos++
+						switch body[pos] {
+						case 'n':
+							value.WriteByte('\n')
+						case 'r':
+							value.WriteByte('\r')
+						case 't':
+							value.WriteByte('\t')
+						case '0':
+							value.WriteByte(0)
+						default:
+							value.WriteByte(body[pos])
+						}
+						continue
+					}
+					if char == '\'' {
+						if pos+1 < len(body) && body[pos+1] == '\'' {
+							value.WriteByte('\'')
+							pos++
+							continue
+						}
+						break
+					}
+					value.WriteByte(char)
+				}
+				if pos >= len(body) {
+					return nil, errors.New("Unterminated string value")
+				}
+				pos++
+				str := value.String()
+				row = append(row, &str)
+			} else {
+				start := pos
+				for pos < len(body) && body[pos] != ',' && body[pos] != ')' {
+					pos++
+				}
+				raw := strings.TrimSpace(body[start:pos])
+				if strings.EqualFold(raw, "NULL") {
+					row = append(row, nil)
+				} else {
+					row = append(row, &raw)
+				}
+			}
+			skipSpace()
+			if pos >= len(body) {
+				return nil, errors.New("Unexpected end of values")
+			}
+			if body[pos] == ',' {
+				pos++
+				continue
+			}
+			if body[pos] == ')' {
+				pos++
+				break
+			}
+			return nil, errors.Errorf("Unexpected character in values: %c", body[pos])
+		}
+		rows = append(rows, row)
+		skipSpace()
+		if pos < len(body) && body[pos] == ',' {
+			pos++
+			continue
+		}
+		return rows, nil
+	}
+}
+
+// WriteSourceBansSQL writes the bans as SourceBans++ compatible inserts into the sb_bans and sb_comms tables
+func WriteSourceBansSQL(writer io.Writer, bans []SourceBansBan) error {
+	quote := func(value string) string {
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\r", `\r`).Replace(value) + "'"
+	}
+	for _, ban := range bans {
+		authId := ""
+		if ban.SteamId.Valid() {
+			authId = string(steamid.SID64ToSID(ban.SteamId))
+		}
+		removeType := "NULL"
+		if ban.Removed {
+			removeType = "'U'"
+		}
+		created, ends := ban.Created.Unix(), ban.Ends.Unix()
+		length := int64(ban.Length / time.Second)
+		var line string
+		if ban.Comm {
+			line = fmt.Sprintf("INSERT INTO `sb_comms` (`authid`, `name`, `created`, `ends`, `length`, `reason`, "+
+				"`aid`, `adminIp`, `sid`, `RemoveType`, `type`, `ureason`) VALUES (%s, %s, %d, %d, %d, %s, 0, '', 0, %s, %d, %s);\n",
+				quote(authId), quote(ban.Name), created, ends, length, quote(ban.Reason), removeType, ban.Type,
+				quote(ban.UnbanReason))
+		} else {
+			addr := ""
+			if ban.IP != nil {
+				addr = ban.IP.String()
+			}
+			line = fmt.Sprintf("INSERT INTO `sb_bans` (`ip`, `authid`, `name`, `created`, `ends`, `length`, `reason`, "+
+				"`aid`, `adminIp`, `sid`, `country`, `RemoveType`, `type`, `ureason`) VALUES (%s, %s, %s, %d, %d, %d, %s, 0, '', 0, NULL, %s, %d, %s);\n",
+				quote(addr), quote(authId), quote(ban.Name), created, ends, length, quote(ban.Reason), removeType,
+				ban.Type, quote(ban.UnbanReason))
+		}
+		if _, errWrite := io.WriteString(writer, line); errWrite != nil {
+			return errors.Wrap(errWrite, "Failed to write ban")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package thirdparty
+
+import (
+	"bytes"
+	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParseSourceBansSQL(t *testing.T) {
+	const dump = "INSERT INTO `sb_admins` VALUES (1,'admin');\n" +
+		"INSERT INTO `sb_bans` VALUES (1,'','STEAM_0:1:16683555','Player ''one''',1600000000,1600003600,3600," +
+		"'aimbot, \\'obvious\\'',1,'1.2.3.4',1,NULL,NULL,NULL,NULL,0,NULL)," +
+		"(2,'89.229.79.121','','',1600000000,1600000000,0,'ip ban',1,'1.2.3.4',1,NULL,1,'U',1600001000,1,'appealed');\n" +
+		"INSERT INTO `sb_comms` (`bid`, `authid`, `name`, `created`, `ends`, `length`, `reason`, `type`) VALUES " +
+		"(7,'STEAM_0:0:1234','muted',1600000000,1600000000,0,'spam',1);"
+	bans, errParse := ParseSourceBansSQL([]byte(dump))
+	require.NoError(t, errParse)
+	require.Len(t, bans, 3)
+	require.Equal(t, steamid.SIDToSID64("STEAM_0:1:16683555"), bans[0].SteamId)
+	require.Equal(t, "Player 'one'", bans[0].Name)
+	require.Equal(t, "aimbot, 'obvious'", bans[0].Reason)
+	require.Equal(t, time.Hour, bans[0].Length)
+	require.False(t, bans[0].Removed)
+	require.Equal(t, "89.229.79.121", bans[1].IP.String())
+	require.True(t, bans[1].Permanent())
+	require.True(t, bans[1].Removed)
+	require.Equal(t, "appealed", bans[1].UnbanReason)
+	require.True(t, bans[2].Comm)
+	require.Equal(t, 1, bans[2].Type)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteSourceBansSQL(&buf, bans))
+	written, errWritten := ParseSourceBansSQL(buf.Bytes())
+	require.NoError(t, errWritten)
+	require.Len(t, written, 3)
+	for i := range bans {
+		require.Equal(t, bans[i].SteamId, written[i].SteamId)
+		require.Equal(t, bans[i].Reason, written[i].Reason)
+		require.Equal(t, bans[i].Length, written[i].Length)
+		require.Equal(t, bans[i].Removed, written[i].Removed)
+	}
+}
+
+func TestParseValveBans(t *testing.T) {
+	bans, errParse := ParseValveBans([]byte("banid 0 STEAM_0:1:16683555\r\naddip 0.0 89.229.79.121\nbanid 10 bad\n"))
+	require.NoError(t, errParse)
+	require.Len(t, bans, 2)
+	require.Equal(t, steamid.SIDToSID64("STEAM_0:1:16683555"), bans[0].SteamId)
+	require.Equal(t, "89.229.79.121", bans[1].IP.String())
+	var buf bytes.Buffer
+	require.NoError(t, WriteValveBans(&buf, bans))
+	require.Equal(t, "banid 0 STEAM_0:1:16683555\r\naddip 0 89.229.79.121\r\n", buf.String())
+}
@@ -1,41 +1,103 @@
 package thirdparty
 
 import (
+	"fmt"
 	"github.com/leighmacdonald/steamid/v2/steamid"
+	"github.com/pkg/errors"
+	"io"
 	"net"
+	"strconv"
 	"strings"
 )
 
-// parseValveSID parses the format: banid 0 STEAM_0:1:16683555
-func parseValveSID(src []byte) (steamid.Collection, error) {
-	var steamIds steamid.Collection
-	for _, line := range strings.Split(string(src), "\r\n") {
-		pieces := strings.SplitN(line, " ", 3)
+// ValveBan is a single entry of a srcds banned_user.cfg or banned_ip.cfg file. Only one of SteamId or IP
+// is set. A Minutes value of 0 denotes a permanent ban.
+type ValveBan struct {
+	SteamId steamid.SID64
+	IP      net.IP
+	Minutes int
+}
+
+// ParseValveBans parses both the banned_user.cfg format: banid 0 STEAM_0:1:16683555
+// and the banned_ip.cfg format: addip 0 89.229.79.121
+func ParseValveBans(src []byte) ([]ValveBan, error) {
+	var bans []ValveBan
+	for _, line := range strings.Split(string(src), "\n") {
+		pieces := strings.Fields(line)
 		if len(pieces) != 3 {
 			continue
 		}
-		sid64 := steamid.SIDToSID64(steamid.SID(pieces[2]))
-		if !sid64.Valid() {
+		minutes, errMinutes := strconv.ParseFloat(pieces[1], 64)
+		if errMinutes != nil {
+			continue
+		}
+		ban := ValveBan{Minutes: int(minutes)}
+		switch strings.ToLower(pieces[0]) {
+		case "banid":
+			sid64, errSid := parseSourceBansSID(pieces[2])
+			if errSid != nil || !sid64.Valid() {
+				continue
+			}
+			ban.SteamId = sid64
+		case "addip":
+			ban.IP = net.ParseIP(pieces[2])
+			if ban.IP == nil {
+				continue
+			}
+		default:
 			continue
 		}
-		steamIds = append(steamIds, sid64)
+		bans = append(bans, ban)
+	}
+	return bans, nil
+}
+
+// WriteValveBans writes the bans in the banned_user.cfg or banned_ip.cfg format, depending on the
+// type of each ban.
+func WriteValveBans(writer io.Writer, bans []ValveBan) error {
+	for _, ban := range bans {
+		var line string
+		if ban.SteamId.Valid() {
+			line = fmt.Sprintf("banid %d %s\r\n", ban.Minutes, steamid.SID64ToSID(ban.SteamId))
+		} else if ban.IP != nil {
+			line = fmt.Sprintf("addip %d %s\r\n", ban.Minutes, ban.IP.String())
+		} else {
+			continue
+		}
+		if _, errWrite := io.WriteString(writer, line); errWrite != nil {
+			return errors.Wrap(errWrite, "Failed to write ban")
+		}
+	}
+	return nil
+}
+
+// parseValveSID parses the format: banid 0 STEAM_0:1:16683555
+func parseValveSID(src []byte) (steamid.Collection, error) {
+	bans, errBans := ParseValveBans(src)
+	if errBans != nil {
+		return nil, errBans
+	}
+	var steamIds steamid.Collection
+	for _, ban := range bans {
+		if ban.SteamId.Valid() {
+			steamIds = append(steamIds, ban.SteamId)
+		}
 	}
 	return steamIds, nil
 }
 
 // parseValveNet parses the format: addip 0 89.229.79.121
 func parseValveNet(src []byte) ([]*net.IPNet, error) {
+	bans, errBans := ParseValveBans(src)
+	if errBans != nil {
+		return nil, errBans
+	}
 	var valveNetworks []*net.IPNet
-	for _, line := range strings.Split(string(src), "\r\n") {
-		pieces := strings.SplitN(line, " ", 3)
-		if len(pieces) != 3 {
-			continue
-		}
-		_, network, errParseCIDR := net.ParseCIDR(pieces[2] + `\32`)
-		if errParseCIDR != nil {
+	for _, ban := range bans {
+		if ban.IP == nil || ban.IP.To4() == nil {
 			continue
 		}
-		valveNetworks = append(valveNetworks, network)
+		valveNetworks = append(valveNetworks, &net.IPNet{IP: ban.IP.To4(), Mask: net.CIDRMask(32, 32)})
 	}
 	return valveNetworks, nil
 }
@@ -0,0 +1,83 @@
+package thirdparty
+
+import (
+	"encoding/json"
+	"github.com/leighmacdonald/gbans/internal/config"
+	"github.com/leighmacdonald/golib"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"path"
+	"strings"
+)
+
+// FetchWordList downloads and parses an external word list. The last successfully parsed copy is kept in the
+// cache path and is used instead when the list cannot be downloaded, or fails to parse.
+func FetchWordList(list config.WordList) ([]string, error) {
+	if !golib.Exists(config.Filter.CachePath) {
+		if errMkDir := os.MkdirAll(config.Filter.CachePath, 0755); errMkDir != nil {
+			return nil, errors.Wrapf(errMkDir, "Failed to create cache dir (%s)", config.Filter.CachePath)
+		}
+	}
+	filePath := path.Join(config.Filter.CachePath, list.Name)
+	tmpPath := filePath + ".tmp"
+	errFetch := download(list.URL, tmpPath)
+	if errFetch == nil {
+		body, errRead := os.ReadFile(tmpPath)
+		if errRead != nil {
+			return nil, errRead
+		}
+		words, errParse := parseWordList(body)
+		if errParse == nil {
+			if errRename := os.Rename(tmpPath, filePath); errRename != nil {
+				log.WithFields(log.Fields{"list": list.Name}).Warnf("Failed to update cached word list: %v", errRename)
+			}
+			return words, nil
+		}
+		errFetch = errParse
+	}
+	if !golib.Exists(filePath) {
+		return nil, errors.Wrapf(errFetch, "Failed to fetch word list")
+	}
+	log.WithFields(log.Fields{"list": list.Name}).Warnf("Using cached word list: %v", errFetch)
+	return LoadCachedWordList(list)
+}
+
+// LoadCachedWordList loads the last good copy of the word list from the cache path
+func LoadCachedWordList(list config.WordList) ([]string, error) {
+	body, errRead := os.ReadFile(path.Join(config.Filter.CachePath, list.Name))
+	if errRead != nil {
+		return nil, errRead
+	}
+	return parseWordList(body)
+}
+
+// parseWordList parses either a JSON array of strings, or plain text with a single word per line. Blank lines
+// and lines starting with # are ignored.
+func parseWordList(src []byte) ([]string, error) {
+	body := strings.TrimSpace(string(src))
+	var words []string
+	if strings.HasPrefix(body, "[") {
+		var values []string
+		if errUnmarshal := json.Unmarshal([]byte(body), &values); errUnmarshal != nil {
+			return nil, errors.Wrap(errUnmarshal, "Invalid json word list")
+		}
+		for _, value := range values {
+			if word := strings.TrimSpace(value); word != "" {
+				words = append(words, word)
+			}
+		}
+	} else {
+		for _, line := range strings.Split(body, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			words = append(words, line)
+		}
+	}
+	if len(words) == 0 {
+		return nil, errors.New("Empty word list")
+	}
+	return words, nil
+}